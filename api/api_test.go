@@ -118,3 +118,62 @@ func TestErrors(t *testing.T) {
 		t.Errorf("got %q, want %q", structure.Scenarios[0].Name, "Internal")
 	}
 }
+
+func TestMiddleware(t *testing.T) {
+	type Calculator struct {
+		api.Specification
+
+		Add func(ctx context.Context, a, b int) (int, error)
+		Div func(a, b int) (int, error)
+
+		Nested struct {
+			Neg func(int) int
+		}
+	}
+	var impl Calculator
+	impl.Add = func(ctx context.Context, a, b int) (int, error) { return a + b, nil }
+	impl.Div = func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	}
+	impl.Nested.Neg = func(a int) int { return -a }
+
+	var trace []string
+	tracer := func(name string) api.Middleware {
+		return func(ctx context.Context, fn api.Function, args []reflect.Value, next api.Next) ([]reflect.Value, error) {
+			trace = append(trace, name+">"+fn.Name)
+			results, err := next(ctx, args)
+			trace = append(trace, name+"<"+fn.Name)
+			return results, err
+		}
+	}
+	cache := func(ctx context.Context, fn api.Function, args []reflect.Value, next api.Next) ([]reflect.Value, error) {
+		if fn.Name == "Div" && args[1].Int() == 0 {
+			return []reflect.Value{reflect.ValueOf(0)}, errors.New("cached")
+		}
+		return next(ctx, args)
+	}
+	wrapped := api.Wrap(impl, tracer("a"), tracer("b"), cache)
+
+	if sum, err := wrapped.Add(context.Background(), 1, 2); sum != 3 || err != nil {
+		t.Fatalf("got %v, %v, want 3, nil", sum, err)
+	}
+	if fmt.Sprint(trace) != "[a>Add b>Add b<Add a<Add]" {
+		t.Fatalf("unexpected trace %v", trace)
+	}
+	if _, err := wrapped.Div(1, 0); err == nil || err.Error() != "cached" {
+		t.Fatalf("got %v, want cached", err)
+	}
+	if neg := wrapped.Nested.Neg(1); neg != -1 {
+		t.Fatalf("got %v, want -1", neg)
+	}
+	if len(trace) != 12 {
+		t.Fatalf("unexpected trace %v", trace)
+	}
+	trace = nil
+	if _, err := impl.Div(1, 0); err == nil || err.Error() != "division by zero" || trace != nil {
+		t.Fatal("original implementation should not be wrapped")
+	}
+}
@@ -11,7 +11,12 @@ import (
 	"runtime.link/api/xray"
 )
 
-type platform struct{}
+// platform calls C functions with dyncall, when variadic is set, the
+// arguments from index fixed onwards are passed as C variadic arguments.
+type platform struct {
+	variadic bool
+	fixed    int
+}
 
 // CallingConvention TODO
 func (platform) CallingConvention(reflect.Type) (args, rets []jit.Location, err error) {
@@ -19,55 +24,25 @@ func (platform) CallingConvention(reflect.Type) (args, rets []jit.Location, err
 }
 
 // Call with a CGO dyncall.
-func (platform) Call(ptr unsafe.Pointer, args []reflect.Value, rets ...reflect.Type) ([]reflect.Value, []func(), error) {
+func (p platform) Call(ptr unsafe.Pointer, args []reflect.Value, rets ...reflect.Type) ([]reflect.Value, []func(), error) {
 	var free = make([]func(), 0, len(rets))
 	var vm = dyncall.NewVM(4096)
 	defer vm.Free()
-	for _, arg := range args {
-		switch arg.Kind() {
-		case reflect.Int8:
-			vm.PushChar(int8(arg.Int()))
-		case reflect.Int16:
-			vm.PushShort(int16(arg.Int()))
-		case reflect.Int32:
-			vm.PushSignedInt(int32(arg.Int()))
-		case reflect.Int:
-			vm.PushSignedLong(int(arg.Int()))
-		case reflect.Int64:
-			vm.PushSignedLongLong(arg.Int())
-		case reflect.Uint8:
-			u8 := uint8(arg.Uint())
-			i8 := *(*int8)(unsafe.Pointer(&u8))
-			vm.PushChar(i8)
-		case reflect.Uint16:
-			u16 := uint16(arg.Uint())
-			i16 := *(*int16)(unsafe.Pointer(&u16))
-			vm.PushShort(i16)
-		case reflect.Uint32:
-			u32 := uint32(arg.Uint())
-			i32 := *(*int32)(unsafe.Pointer(&u32))
-			vm.PushSignedInt(i32)
-		case reflect.Uint:
-			u := uint(arg.Uint())
-			i := *(*int)(unsafe.Pointer(&u))
-			vm.PushSignedLong(i)
-		case reflect.Uint64:
-			u64 := arg.Uint()
-			i64 := *(*int64)(unsafe.Pointer(&u64))
-			vm.PushSignedLongLong(i64)
-		case reflect.Uintptr:
-			u := uintptr(arg.Uint())
-			i := *(*int64)(unsafe.Pointer(&u))
-			vm.PushSignedLongLong(i)
-		case reflect.Float32:
-			vm.PushFloat(float32(arg.Float()))
-		case reflect.Float64:
-			vm.PushDouble(arg.Float())
-		case reflect.Pointer, reflect.Func, reflect.Chan, reflect.Map, reflect.UnsafePointer:
-			vm.PushPointer(arg.UnsafePointer())
-		default:
-			return nil, free, fmt.Errorf("unsupported call argument type %s", arg.Type())
+	if p.variadic {
+		for i := p.fixed; i < len(args); i++ {
+			args[i] = promote(args[i])
+		}
+	}
+	for i := range args {
+		if p.variadic && i == p.fixed {
+			vm.Variadic()
 		}
+		if err := p.push(vm, args, i); err != nil {
+			return nil, free, err
+		}
+	}
+	if p.variadic && p.fixed == len(args) {
+		vm.Variadic()
 	}
 	if len(rets) == 0 || rets[0] == nil {
 		vm.Call(ptr)
@@ -120,8 +95,110 @@ func (platform) Call(ptr unsafe.Pointer, args []reflect.Value, rets ...reflect.T
 		value = reflect.NewAt(rets[0].Elem(), vm.CallPointer(ptr))
 	case reflect.Func:
 		value = reflect.ValueOf(unsafe.Pointer(vm.CallPointer(ptr)))
+	case reflect.Struct:
+		var err error
+		value, err = p.callStruct(vm, ptr, args, out)
+		if err != nil {
+			return nil, free, err
+		}
 	default:
 		return nil, free, fmt.Errorf("unsupported call result type %s", rets[0])
 	}
 	return []reflect.Value{value}, free, nil
 }
+
+// push the argument at index i onto the VM.
+func (p platform) push(vm *dyncall.VM, args []reflect.Value, i int) error {
+	switch arg := args[i]; arg.Kind() {
+	case reflect.Bool:
+		vm.PushBool(arg.Bool())
+	case reflect.Int8:
+		vm.PushChar(int8(arg.Int()))
+	case reflect.Int16:
+		vm.PushShort(int16(arg.Int()))
+	case reflect.Int32:
+		vm.PushSignedInt(int32(arg.Int()))
+	case reflect.Int:
+		vm.PushSignedLong(int(arg.Int()))
+	case reflect.Int64:
+		vm.PushSignedLongLong(arg.Int())
+	case reflect.Uint8:
+		u8 := uint8(arg.Uint())
+		i8 := *(*int8)(unsafe.Pointer(&u8))
+		vm.PushChar(i8)
+	case reflect.Uint16:
+		u16 := uint16(arg.Uint())
+		i16 := *(*int16)(unsafe.Pointer(&u16))
+		vm.PushShort(i16)
+	case reflect.Uint32:
+		u32 := uint32(arg.Uint())
+		i32 := *(*int32)(unsafe.Pointer(&u32))
+		vm.PushSignedInt(i32)
+	case reflect.Uint:
+		u := uint(arg.Uint())
+		i := *(*int)(unsafe.Pointer(&u))
+		vm.PushSignedLong(i)
+	case reflect.Uint64:
+		u64 := arg.Uint()
+		i64 := *(*int64)(unsafe.Pointer(&u64))
+		vm.PushSignedLongLong(i64)
+	case reflect.Uintptr:
+		u := uintptr(arg.Uint())
+		i := *(*int64)(unsafe.Pointer(&u))
+		vm.PushSignedLongLong(i)
+	case reflect.Float32:
+		vm.PushFloat(float32(arg.Float()))
+	case reflect.Float64:
+		vm.PushDouble(arg.Float())
+	case reflect.Pointer, reflect.Func, reflect.Chan, reflect.Map, reflect.UnsafePointer, reflect.Slice:
+		vm.PushPointer(arg.UnsafePointer())
+	case reflect.String:
+		vm.PushString(arg.String())
+	case reflect.Array:
+		// C arrays decay into a pointer to their first element.
+		array := reflect.New(arg.Type())
+		array.Elem().Set(arg)
+		vm.PushPointer(array.UnsafePointer())
+	case reflect.Struct:
+		return p.pushStruct(vm, args, i)
+	default:
+		return fmt.Errorf("unsupported call argument type %s", arg.Type())
+	}
+	return nil
+}
+
+// promote applies the C default argument promotions to a variadic argument.
+func promote(arg reflect.Value) reflect.Value {
+	switch arg.Kind() {
+	case reflect.Bool:
+		if arg.Bool() {
+			return reflect.ValueOf(int32(1))
+		}
+		return reflect.ValueOf(int32(0))
+	case reflect.Int8, reflect.Int16:
+		return reflect.ValueOf(int32(arg.Int()))
+	case reflect.Uint8, reflect.Uint16:
+		return reflect.ValueOf(uint32(arg.Uint()))
+	case reflect.Float32:
+		return reflect.ValueOf(arg.Float())
+	default:
+		return arg
+	}
+}
+
+// padded returns a copy of the given struct value, padded to a multiple
+// of 8 bytes, so that it can be read eightbyte by eightbyte.
+func padded(value reflect.Value) unsafe.Pointer {
+	size := value.Type().Size()
+	if pad := (size+7)&^7 - size; pad > 0 {
+		copied := reflect.New(reflect.StructOf([]reflect.StructField{
+			{Name: "Value", Type: value.Type()},
+			{Name: "Padding", Type: reflect.ArrayOf(int(pad), reflect.TypeOf(byte(0)))},
+		}))
+		copied.Elem().Field(0).Set(value)
+		return copied.UnsafePointer()
+	}
+	copied := reflect.New(value.Type())
+	copied.Elem().Set(value)
+	return copied.UnsafePointer()
+}
@@ -0,0 +1,101 @@
+package call
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"runtime.link/api/call/internal/cgo/dyncall"
+)
+
+// layouts caches the dyncall layout of each struct type passed by value.
+var layouts sync.Map // map[reflect.Type]*dyncall.Struct
+
+// pushStruct pushes a struct argument by value, dyncall classifies it as per the
+// System V (or Windows x64) calling convention.
+func (p platform) pushStruct(vm *dyncall.VM, args []reflect.Value, i int) error {
+	layout, err := layoutOf(args[i].Type())
+	if err != nil {
+		return err
+	}
+	vm.PushStruct(layout, padded(args[i]))
+	return nil
+}
+
+// callStruct calls a function that returns a struct by value.
+func (p platform) callStruct(vm *dyncall.VM, ptr unsafe.Pointer, args []reflect.Value, out reflect.Type) (reflect.Value, error) {
+	layout, err := layoutOf(out)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	ret := reflect.New(out)
+	vm.CallStruct(ptr, layout, ret.UnsafePointer())
+	return ret.Elem(), nil
+}
+
+// layoutOf returns the dyncall layout of the given struct type.
+func layoutOf(rtype reflect.Type) (*dyncall.Struct, error) {
+	if layout, ok := layouts.Load(rtype); ok {
+		return layout.(*dyncall.Struct), nil
+	}
+	layout := dyncall.NewStruct(rtype.NumField(), rtype.Size())
+	for i := 0; i < rtype.NumField(); i++ {
+		field := rtype.Field(i)
+		kind, count, nested, err := fieldOf(field.Type)
+		if err != nil {
+			layout.Free()
+			return nil, fmt.Errorf("unsupported struct field %s.%s: %w", rtype, field.Name, err)
+		}
+		layout.Field(kind, field.Offset, count, nested)
+	}
+	layout.Close()
+	if existing, loaded := layouts.LoadOrStore(rtype, layout); loaded {
+		layout.Free()
+		return existing.(*dyncall.Struct), nil
+	}
+	return layout, nil
+}
+
+// fieldOf returns the dyncall kind of a struct field, along with its length
+// and, for nested structs, the layout of the struct.
+func fieldOf(rtype reflect.Type) (rune, int, *dyncall.Struct, error) {
+	switch rtype.Kind() {
+	case reflect.Bool:
+		return dyncall.Bool, 1, nil, nil
+	case reflect.Int8:
+		return dyncall.Char, 1, nil, nil
+	case reflect.Uint8:
+		return dyncall.UnsignedChar, 1, nil, nil
+	case reflect.Int16:
+		return dyncall.Short, 1, nil, nil
+	case reflect.Uint16:
+		return dyncall.UnsignedShort, 1, nil, nil
+	case reflect.Int32:
+		return dyncall.Int, 1, nil, nil
+	case reflect.Uint32:
+		return dyncall.Uint, 1, nil, nil
+	case reflect.Int, reflect.Int64:
+		return dyncall.LongLong, 1, nil, nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return dyncall.UnsignedLongLong, 1, nil, nil
+	case reflect.Float32:
+		return dyncall.Float, 1, nil, nil
+	case reflect.Float64:
+		return dyncall.Double, 1, nil, nil
+	case reflect.Complex64:
+		return dyncall.Float, 2, nil, nil
+	case reflect.Complex128:
+		return dyncall.Double, 2, nil, nil
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Func:
+		return dyncall.Pointer, 1, nil, nil
+	case reflect.Array:
+		kind, count, nested, err := fieldOf(rtype.Elem())
+		return kind, count * rtype.Len(), nested, err
+	case reflect.Struct:
+		nested, err := layoutOf(rtype)
+		return dyncall.Aggregate, 1, nested, err
+	default:
+		return 0, 0, nil, fmt.Errorf("%s cannot be passed to C by value", rtype)
+	}
+}
@@ -0,0 +1,119 @@
+package call
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"runtime.link/api/call/internal/abi"
+	"runtime.link/api/call/internal/cgo/dyncall"
+	"runtime.link/xyz"
+)
+
+// pushStruct pushes a struct argument by value. dyncall does not support structs
+// on arm64, so they are classified as per AAPCS64 and then pushed as the
+// individual registers that they would be passed in, larger structs are copied
+// and passed by reference.
+func (p platform) pushStruct(vm *dyncall.VM, args []reflect.Value, i int) error {
+	value := args[i]
+	if p.variadic && i >= p.fixed {
+		return fmt.Errorf("unsupported variadic struct argument %s", value.Type())
+	}
+	cc, err := abi.AAPCS64(functionOf(args, nil))
+	if err != nil {
+		return err
+	}
+	ptr := padded(value)
+	if xyz.ValueOf(cc.Args[i]) == abi.Locations.Indirect {
+		vm.PushPointer(ptr)
+		return nil
+	}
+	floating, n, ok := placement(cc.Args[i])
+	if !ok {
+		return fmt.Errorf("unsupported struct argument %s, as it would be passed on the stack", value.Type())
+	}
+	if floating {
+		size := value.Type().Size() / uintptr(n)
+		for j := 0; j < n; j++ {
+			member := unsafe.Add(ptr, uintptr(j)*size)
+			if size == 4 {
+				vm.PushFloat(*(*float32)(member))
+			} else {
+				vm.PushDouble(*(*float64)(member))
+			}
+		}
+		return nil
+	}
+	for j := 0; j < n; j++ {
+		vm.PushSignedLongLong(*(*int64)(unsafe.Add(ptr, j*8)))
+	}
+	return nil
+}
+
+// callStruct calls a function that returns a struct by value, only structs that
+// are returned in a single register are supported.
+func (p platform) callStruct(vm *dyncall.VM, ptr unsafe.Pointer, args []reflect.Value, out reflect.Type) (reflect.Value, error) {
+	cc, err := abi.AAPCS64(functionOf(args, out))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	ret := reflect.New(out)
+	floating, n, ok := placement(cc.Rets[0])
+	switch {
+	case ok && n == 1 && floating && out.Size() == 4:
+		*(*float32)(ret.UnsafePointer()) = vm.CallFloat(ptr)
+	case ok && n == 1 && floating:
+		*(*float64)(ret.UnsafePointer()) = vm.CallDouble(ptr)
+	case ok && n == 1:
+		word := vm.CallLongLong(ptr)
+		copy(unsafe.Slice((*byte)(ret.UnsafePointer()), out.Size()), unsafe.Slice((*byte)(unsafe.Pointer(&word)), 8))
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported struct result %s", out)
+	}
+	return ret.Elem(), nil
+}
+
+// functionOf returns the [abi.Function] for a call with the given arguments.
+func functionOf(args []reflect.Value, out reflect.Type) abi.Function {
+	var fn abi.Function
+	for _, arg := range args {
+		switch arg.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array:
+			fn.Args = append(fn.Args, abi.Values.Memory)
+		default:
+			fn.Args = append(fn.Args, abi.ValueOf(arg.Type()))
+		}
+	}
+	if out != nil {
+		fn.Rets = []abi.Value{abi.ValueOf(out)}
+	}
+	return fn
+}
+
+// placement reports whether the location is made up of registers, whether
+// they are floating-point registers and how many of them there are.
+func placement(loc abi.Location) (floating bool, n int, ok bool) {
+	var locations = []abi.Location{loc}
+	if xyz.ValueOf(loc) == abi.Locations.Multiple {
+		locations = abi.Locations.Multiple.Get(loc)
+	}
+	for i, part := range locations {
+		if xyz.ValueOf(part) != abi.Locations.Hardware {
+			return false, 0, false
+		}
+		switch xyz.ValueOf(abi.Locations.Hardware.Get(part)) {
+		case abi.HardwareLocations.Floating:
+			if i > 0 && !floating {
+				return false, 0, false
+			}
+			floating = true
+		case abi.HardwareLocations.Register:
+			if floating {
+				return false, 0, false
+			}
+		default:
+			return false, 0, false
+		}
+	}
+	return floating, len(locations), true
+}
@@ -0,0 +1,123 @@
+package call_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"runtime.link/api"
+	"runtime.link/api/call"
+)
+
+type Quotient struct{ Quot, Rem int32 }
+
+type LongQuotient struct{ Quot, Rem int64 }
+
+type Libc struct {
+	api.Specification `call:"libc.so.6"`
+
+	Div      func(num, den int32) Quotient                                    `call:"div func(int,int)div_t"`
+	Ldiv     func(num, den int64) LongQuotient                                `call:"ldiv func(long,long)ldiv_t"`
+	Snprintf func(buf []byte, size uintptr, format string, args ...any) int32 `call:"snprintf func(&char,size_t,&char,#char...?@3)int"`
+}
+
+func TestCall(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test requires glibc")
+	}
+	libc := api.Import[Libc](call.API, "", call.Options{})
+	if q := libc.Div(7, 2); q != (Quotient{3, 1}) {
+		t.Fatal("unexpected div result", q)
+	}
+	if q := libc.Ldiv(-1<<40, 3); q != (LongQuotient{-366503875925, -1}) {
+		t.Fatal("unexpected ldiv result", q)
+	}
+	buf := make([]byte, 64)
+	n := libc.Snprintf(buf, uintptr(len(buf)), "%d %s %.2f %g %c %d", 42, "go", 1.5, float32(2.5), 'x', int8(-1))
+	if got := string(buf[:n]); got != "42 go 1.50 2.5 x -1" {
+		t.Fatalf("unexpected snprintf result %q", got)
+	}
+	if n := libc.Snprintf(buf, uintptr(len(buf)), "no arguments"); string(buf[:n]) != "no arguments" {
+		t.Fatalf("unexpected snprintf result %q", buf[:n])
+	}
+}
+
+type Vec2 struct{ X, Y float32 }
+
+type Vec3 struct{ X, Y, Z float64 }
+
+type Mixed struct {
+	A float64
+	B int32
+}
+
+type Vectors struct {
+	Add   func(a, b Vec2) Vec2               `call:"vec2_add func(vec2,vec2)vec2"`
+	Scale func(v Vec3, s float64) Vec3       `call:"vec3_scale func(vec3,double)vec3"`
+	Sum   func(m Mixed) float64              `call:"mixed_sum func(mixed)double"`
+	Make  func(a float64, b int32) Mixed     `call:"mixed_make func(double,int)mixed"`
+	Total func(n int32, args ...any) float64 `call:"vec2_total func(int,#vec2...=@1)double"`
+}
+
+const vectors = `#include <stdarg.h>
+
+typedef struct { float x, y; } vec2;
+typedef struct { double x, y, z; } vec3;
+typedef struct { double a; int b; } mixed;
+
+vec2 vec2_add(vec2 a, vec2 b) { return (vec2){a.x + b.x, a.y + b.y}; }
+vec3 vec3_scale(vec3 v, double s) { return (vec3){v.x * s, v.y * s, v.z * s}; }
+double mixed_sum(mixed m) { return m.a + m.b; }
+mixed mixed_make(double a, int b) { return (mixed){a, b}; }
+
+double vec2_total(int n, ...) {
+	va_list args;
+	va_start(args, n);
+	double total = 0;
+	for (int i = 0; i < n; i++) {
+		vec2 v = va_arg(args, vec2);
+		total += v.x + v.y;
+	}
+	va_end(args);
+	return total;
+}
+`
+
+func TestStructs(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("struct results that span multiple registers are only supported on amd64")
+	}
+	if testing.Short() {
+		t.Skip("skipping shared library build in short mode")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler available")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "vectors.c"), []byte(vectors), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lib := filepath.Join(dir, "libvectors.so")
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-o", lib, filepath.Join(dir, "vectors.c")).CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	vec := api.Import[Vectors](call.API, lib, call.Options{})
+	if v := vec.Add(Vec2{1, 2}, Vec2{3, 4}); v != (Vec2{4, 6}) {
+		t.Fatal("unexpected vec2_add result", v)
+	}
+	if v := vec.Scale(Vec3{1, 2, 3}, 2); v != (Vec3{2, 4, 6}) {
+		t.Fatal("unexpected vec3_scale result", v)
+	}
+	if sum := vec.Sum(Mixed{1.5, 2}); sum != 3.5 {
+		t.Fatal("unexpected mixed_sum result", sum)
+	}
+	if m := vec.Make(1.5, 2); m != (Mixed{1.5, 2}) {
+		t.Fatal("unexpected mixed_make result", m)
+	}
+	if total := vec.Total(2, Vec2{1, 2}, Vec2{3, 4}); total != 10 {
+		t.Fatal("unexpected vec2_total result", total)
+	}
+}
@@ -0,0 +1,473 @@
+package call
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"runtime.link/api/call/internal/cgo"
+	"runtime.link/api/call/internal/ffi"
+)
+
+// SafetyError is returned in checked mode, when an argument does not meet
+// a safety assertion of the call tag (functions without an error result
+// panic with it instead) or when the result meets the error condition of
+// the call tag.
+type SafetyError struct {
+	Symbol   string // C symbol being called.
+	Argument int    // index of the C argument (starting from 1), or 0 for the result.
+	Check    string // "capacity", "size", "range", "overlap", "type", "format", "immutable" or "error"
+	Reason   string
+	Failure  string // symbol to refer to, for information about why the result failed (if any).
+}
+
+func (e *SafetyError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: ", e.Symbol)
+	switch {
+	case e.Check == "error":
+		fmt.Fprintf(&b, "result met the error condition: %s", e.Reason)
+		if e.Failure != "" {
+			fmt.Fprintf(&b, " (see %s)", e.Failure)
+		}
+		return b.String()
+	case e.Argument > 0:
+		fmt.Fprintf(&b, "argument %d ", e.Argument)
+	default:
+		fmt.Fprintf(&b, "result ")
+	}
+	fmt.Fprintf(&b, "failed %s assertion: %s", e.Check, e.Reason)
+	if e.Failure != "" {
+		fmt.Fprintf(&b, " (see %s)", e.Failure)
+	}
+	return b.String()
+}
+
+// checked wraps the implementation of a linked function, so that the
+// safety assertions of its call tag are validated before and after each
+// call.
+func checked(symbol string, ctype ffi.Type, goType reflect.Type, impl reflect.Value) reflect.Value {
+	return reflect.MakeFunc(goType, func(args []reflect.Value) []reflect.Value {
+		check := checker{symbol: symbol, ctype: ctype, args: args}
+		if err := check.before(); err != nil {
+			return failed(goType, err)
+		}
+		immutable := check.snapshot()
+		var results []reflect.Value
+		if goType.IsVariadic() {
+			results = impl.CallSlice(args)
+		} else {
+			results = impl.Call(args)
+		}
+		if err := check.after(results, immutable); err != nil {
+			return reported(goType, results, err)
+		}
+		return results
+	})
+}
+
+// failed returns the zero results of the function along with the error,
+// or else panics with it.
+func failed(goType reflect.Type, err error) []reflect.Value {
+	out := goType.NumOut()
+	if out == 0 || goType.Out(out-1) != reflect.TypeFor[error]() {
+		panic(err)
+	}
+	results := make([]reflect.Value, out)
+	for i := range results {
+		results[i] = reflect.Zero(goType.Out(i))
+	}
+	results[out-1] = reflect.ValueOf(&err).Elem()
+	return results
+}
+
+// reported returns the results of a call that has already been made, with
+// the error as its error result. The call cannot be undone, so functions
+// without an error result return their results as they are.
+func reported(goType reflect.Type, results []reflect.Value, err error) []reflect.Value {
+	out := goType.NumOut()
+	if out == 0 || goType.Out(out-1) != reflect.TypeFor[error]() {
+		return results
+	}
+	results[out-1] = reflect.ValueOf(&err).Elem()
+	return results
+}
+
+type checker struct {
+	symbol string
+	ctype  ffi.Type
+	args   []reflect.Value
+}
+
+func (c *checker) fail(arg int, check, reason string, args ...any) error {
+	return &SafetyError{
+		Symbol:   c.symbol,
+		Argument: arg,
+		Check:    check,
+		Reason:   fmt.Sprintf(reason, args...),
+	}
+}
+
+// value returns the Go value for the C argument (starting from 1), '-'
+// arguments are inferred from the length of the argument they refer to.
+func (c *checker) value(index int) (reflect.Value, bool) {
+	if index < 1 || index > len(c.ctype.Args) {
+		return reflect.Value{}, false
+	}
+	arg := c.ctype.Args[index-1]
+	if arg.Free == '-' {
+		if ref := arg.Test.Equality; ref.Check && ref.Index > 0 && int(ref.Index) != index {
+			if value, ok := c.value(int(ref.Index)); ok {
+				return reflect.ValueOf(length(value)), true
+			}
+		}
+		return reflect.Value{}, false
+	}
+	if arg.Maps < 1 || arg.Maps > len(c.args) {
+		return reflect.Value{}, false
+	}
+	return c.args[arg.Maps-1], true
+}
+
+// number returns the integer that the assertion argument refers to.
+func (c *checker) number(arg ffi.Argument) (int64, bool) {
+	switch {
+	case arg.Index > 0:
+		value, ok := c.value(int(arg.Index))
+		if !ok {
+			return 0, false
+		}
+		return integer(value)
+	case arg.Const != "":
+		field := reflect.ValueOf(cgo.Constants).FieldByName(arg.Const)
+		if !field.IsValid() {
+			return 0, false
+		}
+		return integer(field)
+	default:
+		return arg.Value, true
+	}
+}
+
+// relation returns the argument that the value is compared to.
+func relation(test ffi.Assertions) (ffi.Argument, bool) {
+	for _, arg := range []ffi.Argument{test.Equality, test.MoreThan, test.LessThan} {
+		if arg.Check {
+			return arg, true
+		}
+	}
+	return ffi.Argument{}, false
+}
+
+// compare reports whether x meets the comparison of the assertions with
+// n, an equality assertion on a capacity is met by any capacity that is
+// large enough.
+func compare(x, n int64, test ffi.Assertions) bool {
+	var ok bool
+	switch {
+	case test.MoreThan.Check && test.Equality.Check:
+		ok = x >= n
+	case test.LessThan.Check && test.Equality.Check:
+		ok = x <= n
+	case test.MoreThan.Check:
+		ok = x > n
+	case test.LessThan.Check:
+		ok = x < n
+	case test.Equality.Check && test.Capacity:
+		ok = x >= n
+	default:
+		ok = x == n
+	}
+	return ok != test.Inverted
+}
+
+func operator(test ffi.Assertions) string {
+	var op string
+	switch {
+	case test.MoreThan.Check && test.Equality.Check:
+		op = ">="
+	case test.LessThan.Check && test.Equality.Check:
+		op = "<="
+	case test.MoreThan.Check:
+		op = ">"
+	case test.LessThan.Check:
+		op = "<"
+	case test.Capacity:
+		op = ">="
+	default:
+		op = "=="
+	}
+	if test.Inverted {
+		return "!" + op
+	}
+	return op
+}
+
+func (c *checker) before() error {
+	for i, arg := range c.ctype.Args {
+		if arg.Free == '-' {
+			continue
+		}
+		value, ok := c.value(i + 1)
+		if !ok {
+			continue
+		}
+		if err := c.check(i+1, arg, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *checker) check(index int, arg ffi.Type, value reflect.Value) error {
+	test := arg.Test
+	if ref, ok := relation(test); ok {
+		n, ok := c.number(ref)
+		switch {
+		case !ok:
+		case test.Capacity:
+			if capacity := int64(capacityOf(value)); !compare(capacity, n, test) {
+				return c.fail(index, "capacity", "capacity %d is not %s %d", capacity, operator(test), n)
+			}
+		case test.Indirect > 0 && ref.Index > 0:
+			other, _ := c.value(int(ref.Index))
+			size := int64(sizeOf(other))
+			if x, ok := integer(value); ok && !compare(x, size, test) {
+				return c.fail(index, "size", "%d is not %s the size %d of argument %d", x, operator(test), size, ref.Index)
+			}
+		default:
+			if x, ok := integer(value); ok && !compare(x, n, test) {
+				return c.fail(index, "range", "%d is not %s %d", x, operator(test), n)
+			}
+		}
+	}
+	if ref := test.Overlaps; ref.Check && ref.Index > 0 {
+		if other, ok := c.value(int(ref.Index)); ok && overlaps(value, other) == test.Inverted {
+			if test.Inverted {
+				return c.fail(index, "overlap", "memory overlaps with argument %d", ref.Index)
+			}
+			return c.fail(index, "overlap", "memory does not overlap with argument %d", ref.Index)
+		}
+	}
+	if ref := test.SameType; ref.Check && ref.Index > 0 {
+		if other, ok := c.value(int(ref.Index)); ok && elemOf(value.Type()) != elemOf(other.Type()) {
+			return c.fail(index, "type", "%s does not match %s of argument %d", elemOf(value.Type()), elemOf(other.Type()), ref.Index)
+		}
+	}
+	if ref := test.OfFormat; ref.Check && ref.Index > 0 {
+		if format, ok := c.value(int(ref.Index)); ok && format.Kind() == reflect.String {
+			if err := checkFormat(format.String(), value); err != nil {
+				return c.fail(index, "format", "%v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// snapshot copies the memory of each immutable ('#') slice argument.
+func (c *checker) snapshot() map[int][]byte {
+	var copies map[int][]byte
+	for i, arg := range c.ctype.Args {
+		if !arg.Hash {
+			continue
+		}
+		if value, ok := c.value(i + 1); ok && value.Kind() == reflect.Slice {
+			if copies == nil {
+				copies = make(map[int][]byte)
+			}
+			copies[i+1] = bytes.Clone(memoryOf(value))
+		}
+	}
+	return copies
+}
+
+func (c *checker) after(results []reflect.Value, immutable map[int][]byte) error {
+	for index, before := range immutable {
+		value, _ := c.value(index)
+		if !bytes.Equal(before, memoryOf(value)) {
+			return c.fail(index, "immutable", "memory was modified by the call")
+		}
+	}
+	ret := c.ctype.Func
+	if ret == nil || len(results) == 0 {
+		return nil
+	}
+	// result assertions are the error condition of the function.
+	if ref, ok := relation(ret.Test); ok {
+		n, ok := c.number(ref)
+		if x, isInt := integer(results[0]); ok && isInt && compare(x, n, ret.Test) {
+			err := c.fail(0, "error", "%d is %s %d", x, operator(ret.Test), n)
+			err.(*SafetyError).Failure = c.ctype.Call.Name
+			return err
+		}
+	}
+	return nil
+}
+
+func integer(value reflect.Value) (int64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return int64(value.Float()), true
+	case reflect.String, reflect.Slice, reflect.Array:
+		return int64(value.Len()), true
+	}
+	return 0, false
+}
+
+func length(value reflect.Value) uint64 {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array:
+		return uint64(value.Len())
+	}
+	return 0
+}
+
+// capacityOf returns the number of elements that the C function can
+// access through the value, strings include their NUL terminator.
+func capacityOf(value reflect.Value) int {
+	switch value.Kind() {
+	case reflect.Slice:
+		return value.Cap()
+	case reflect.String:
+		return value.Len() + 1
+	case reflect.Array:
+		return value.Len()
+	case reflect.Pointer:
+		if value.IsNil() {
+			return 0
+		}
+		return 1
+	}
+	return 0
+}
+
+// sizeOf returns the size of the elements of the value.
+func sizeOf(value reflect.Value) uintptr {
+	if !value.IsValid() {
+		return 0
+	}
+	return elemOf(value.Type()).Size()
+}
+
+func elemOf(rtype reflect.Type) reflect.Type {
+	switch rtype.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Pointer:
+		return rtype.Elem()
+	case reflect.String:
+		return reflect.TypeFor[byte]()
+	}
+	return rtype
+}
+
+// memoryOf returns the memory of a slice.
+func memoryOf(value reflect.Value) []byte {
+	if value.Kind() != reflect.Slice || value.Len() == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(value.UnsafePointer()), uintptr(value.Len())*value.Type().Elem().Size())
+}
+
+// overlaps reports whether the memory of the two values overlap.
+func overlaps(a, b reflect.Value) bool {
+	span := func(value reflect.Value) (uintptr, uintptr) {
+		switch value.Kind() {
+		case reflect.Slice:
+			start := uintptr(value.UnsafePointer())
+			return start, start + uintptr(value.Cap())*value.Type().Elem().Size()
+		case reflect.Pointer, reflect.UnsafePointer:
+			start := uintptr(value.UnsafePointer())
+			return start, start + elemOf(value.Type()).Size()
+		}
+		return 0, 0
+	}
+	aStart, aEnd := span(a)
+	bStart, bEnd := span(b)
+	return aStart < aEnd && bStart < bEnd && aStart < bEnd && bStart < aEnd
+}
+
+// checkFormat validates the variadic arguments against the C printf
+// format.
+func checkFormat(format string, varargs reflect.Value) error {
+	var args []reflect.Value
+	switch varargs.Kind() {
+	case reflect.Slice:
+		for i := range varargs.Len() {
+			arg := varargs.Index(i)
+			for arg.Kind() == reflect.Interface && !arg.IsNil() {
+				arg = arg.Elem()
+			}
+			args = append(args, arg)
+		}
+	default:
+		args = append(args, varargs)
+	}
+	next := func(verb byte) (reflect.Value, error) {
+		if len(args) == 0 {
+			return reflect.Value{}, fmt.Errorf("missing argument for %%%c", verb)
+		}
+		arg := args[0]
+		args = args[1:]
+		return arg, nil
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		// flags, width, precision and length modifiers.
+		for ; i < len(format) && strings.IndexByte("-+ #0123456789.*hljztL'", format[i]) >= 0; i++ {
+			if format[i] == '*' {
+				arg, err := next('*')
+				if err != nil {
+					return err
+				}
+				if _, ok := integer(arg); !ok || arg.Kind() == reflect.String || arg.Kind() == reflect.Slice {
+					return fmt.Errorf("%%* requires an integer, not %s", arg.Type())
+				}
+			}
+		}
+		if i >= len(format) {
+			return fmt.Errorf("incomplete format verb at the end of %q", format)
+		}
+		verb := format[i]
+		if verb == '%' {
+			continue
+		}
+		if verb == 'n' {
+			return fmt.Errorf("%%n is not permitted")
+		}
+		arg, err := next(verb)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		switch kind := arg.Kind(); verb {
+		case 'd', 'i', 'c', 'u', 'o', 'x', 'X':
+			ok = kind >= reflect.Int && kind <= reflect.Uintptr
+		case 'f', 'F', 'e', 'E', 'g', 'G', 'a', 'A':
+			ok = kind == reflect.Float32 || kind == reflect.Float64
+		case 's':
+			ok = kind == reflect.String || (kind == reflect.Slice && arg.Type().Elem().Kind() == reflect.Uint8)
+		case 'p':
+			ok = kind == reflect.Pointer || kind == reflect.UnsafePointer || kind == reflect.Uintptr
+		default:
+			return fmt.Errorf("unsupported verb %%%c", verb)
+		}
+		if !ok {
+			if !arg.IsValid() {
+				return fmt.Errorf("%%%c does not accept nil", verb)
+			}
+			return fmt.Errorf("%%%c does not accept %s", verb, arg.Type())
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%d extra arguments for %q", len(args), format)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package call_test
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"runtime.link/api"
+	"runtime.link/api/call"
+)
+
+type CheckedLibc struct {
+	api.Specification `call:"libc.so.6"`
+
+	Memset func(buf []byte, c int32, n uintptr) unsafe.Pointer `call:"memset func(&void[>=@3],int,size_t)&void"`
+	Memcpy func(dst, src []byte, n uintptr) unsafe.Pointer     `call:"memcpy func(&void[=@3],&#void~@1,size_t)&void"`
+	Abs    func(n int32) (int32, error)                        `call:"abs func(int)int<0"`
+	Printf func(format string, args ...any) (int32, error)     `call:"printf func(&char,#char...?@1)int"`
+
+	Fmemopen func(buf []byte, size uintptr, mode string) unsafe.Pointer              `call:"fmemopen func(&void,size_t,&char)$void"`
+	Fread    func(buf []byte, size, n uintptr, file unsafe.Pointer) (uintptr, error) `call:"fread func(&void[=@3],size_t*=@1,size_t,&void)size_t<@3; ferror(@4)"`
+	Fgetc    func(file unsafe.Pointer) int32                                         `call:"fgetc func(&void)int<0; ferror(@1)"`
+	Fclose   func(file unsafe.Pointer) int32                                         `call:"fclose func(&void)int"`
+}
+
+func TestChecked(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test requires glibc")
+	}
+	libc := api.Import[CheckedLibc](call.API, "", call.Options{Checked: true})
+
+	safety := func(err any, check string) {
+		t.Helper()
+		failure, ok := err.(error)
+		var safe *call.SafetyError
+		if !ok || !errors.As(failure, &safe) || safe.Check != check {
+			t.Fatalf("expected %s safety error, got %v", check, err)
+		}
+	}
+	panics := func(fn func()) (err any) {
+		defer func() { err = recover() }()
+		fn()
+		return nil
+	}
+
+	buf := make([]byte, 4)
+	libc.Memset(buf, 'x', 4)
+	if string(buf) != "xxxx" {
+		t.Fatal("unexpected memset result", string(buf))
+	}
+	safety(panics(func() { libc.Memset(buf, 'y', 8) }), "capacity")
+	if string(buf) != "xxxx" {
+		t.Fatal("memset was called", string(buf))
+	}
+	safety(panics(func() { libc.Memcpy(buf, buf[1:], 2) }), "overlap")
+	src := []byte("abcd")
+	libc.Memcpy(buf, src, 4)
+	if string(buf) != "abcd" {
+		t.Fatal("unexpected memcpy result", string(buf))
+	}
+	if n, err := libc.Abs(-3); n != 3 || err != nil {
+		t.Fatal("unexpected abs result", n, err)
+	}
+	_, err := libc.Abs(math.MinInt32)
+	safety(err, "error")
+
+	data := []byte("abcd")
+	file := libc.Fmemopen(data, uintptr(len(data)), "r")
+	if file == nil {
+		t.Fatal("fmemopen failed")
+	}
+	defer libc.Fclose(file)
+	into := make([]byte, 2)
+	if n, err := libc.Fread(into, 1, 2, file); n != 2 || err != nil || string(into) != "ab" {
+		t.Fatal("unexpected fread result", n, err, string(into))
+	}
+	into = make([]byte, 4)
+	n, err := libc.Fread(into, 1, 4, file)
+	var short *call.SafetyError
+	if n != 2 || string(into[:n]) != "cd" || !errors.As(err, &short) || short.Check != "error" || short.Failure != "ferror" {
+		t.Fatal("expected a short read to be reported", n, err)
+	}
+	if c := libc.Fgetc(file); c != -1 { // EOF, without a panic.
+		t.Fatal("unexpected fgetc result", c)
+	}
+	runtime.KeepAlive(data)
+
+	for _, args := range [][]any{
+		{"%d %s\n", 1},
+		{"%d\n", "one"},
+		{"%s\n", "one", 2},
+		{"%n\n", new(int32)},
+		{"%*d\n", "width", 1},
+	} {
+		_, err = libc.Printf(args[0].(string), args[1:]...)
+		safety(err, "format")
+	}
+}
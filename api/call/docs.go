@@ -115,6 +115,82 @@ tagged.
 		Name string `ffi:"name &char"`
 	}
 
+Structs made up of numbers, pointers, arrays and other such structs can
+be passed to (and returned from) C functions by value, in which case
+the tag refers to the C name of the struct.
+
+	div func(int,int)div_t
+
+On arm64, struct results must fit within a single register.
+
+# Variadic Functions
+
+C variadic functions are linked to Go variadic functions, with '...'
+following the type of the variadic arguments in the tag. The C default
+argument promotions apply to each variadic argument, Go strings are
+passed as null-terminated char pointers.
+
+	Snprintf func([]byte, uintptr, string, ...any) int32 `call:"snprintf func(&char,size_t,&char,#char...?@3)int"`
+
+# Exports
+
+The [Exporter] generates cgo '//export' wrappers for each call tagged
+function of a structure, along with a matching C header, so that a Go
+implementation can be built into a c-shared library with the same tags
+used to link against it.
+
+	api.Export(call.Exporter, example.API{}, call.Exports{
+		Dir:    "cmd/libexample",
+		Import: "example.com/example",
+		Value:  "example.New()",
+	})
+
+'$' arguments are copied and then freed, '&' arguments are borrowed for
+the duration of the call and '+' arguments receive results. Strings
+returned with '$' must be freed by the caller, whereas '&' and '~' strings
+are never freed. A function that returns an error, reports it with its C
+result (-1 for signed integers, 1 for unsigned integers and false for
+bool), in which case, any Go results are written to '+' arguments. Slice
+arguments require a '-' argument that holds their length.
+
+# Headers
+
+Rather than writing tags by hand, [Generate] can import a C header and
+write a Go package with a library structure, along with any structs,
+enums, typedefs and #define constants that it declares.
+
+	call.Generate(w, "zlib", header, map[string]string{
+		"linux":  "libz.so.1",
+		"darwin": "libz.dylib",
+	})
+
+Preprocessor conditionals and includes are not evaluated, so headers that
+declare their functions through macros should be preprocessed first (ie.
+with 'cc -E -P'). Declarations that cannot be represented are listed in a
+comment at the end of the structure.
+
+C headers do not document the ownership of pointers, so pointers are
+assumed to be borrowed ('&'). The generated functions that have pointer
+arguments (or results) are marked with a TODO comment, as a reminder to
+review their ownership and to add any safety assertions.
+
+# Checked Mode
+
+Safety assertions are not enforced by default. When [Options.Checked] is
+set, each call validates capacities, size relations, ranges, overlaps and
+printf formats before the call is made and any result assertions after it
+returns, '#' slices are also compared against a copy, to make sure they
+were not modified. A violation is reported as a [SafetyError], either as
+the error result of the function or else as a panic, instead of passing
+unsafe values to C. Once the call has been made, a result that meets the
+error condition of the tag (or a modified '#' slice) is only reported
+through the error result, as the call cannot be undone.
+
+	libc := api.Import[Libc](call.API, "", call.Options{Checked: true})
+
+Inside a capacity assertion, '=@n' means that the capacity must hold at
+least '@n' elements. Lifetime ('^') assertions are not checked.
+
 # Deep Copies
 
 By default, values are deep-copied between languages. In order to
@@ -0,0 +1,535 @@
+package call
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+
+	"runtime.link/api"
+	"runtime.link/api/call/internal/cgo"
+	"runtime.link/api/call/internal/ffi"
+	"runtime.link/api/xray"
+)
+
+// Exports configures the c-shared library generated by the [Exporter].
+type Exports struct {
+	Dir    string // directory to write the Go source and the C header into.
+	Header string // name of the C header (without .h), defaults to the lower case name of the structure.
+	Import string // import path of the Go package that implements the API.
+	Value  string // Go expression for the implementation, ie. "example.New()"
+}
+
+// Exporter implements [api.Exporter] by generating a Go source file
+// (exports.go) of cgo '//export' wrappers for each call tagged function
+// of the structure, along with a matching C header. The directory is a
+// main package that can be built with 'go build -buildmode=c-shared'. The
+// paths of the generated files are returned.
+var Exporter api.Exporter[[]string, Exports] = exporter{}
+
+type exporter struct{}
+
+// Export implements [api.Exporter].
+func (exporter) Export(spec api.Structure, opts Exports) ([]string, error) {
+	if opts.Value == "" {
+		return nil, xray.New(errors.New("call.Exports requires a Value for the implementation"))
+	}
+	name := opts.Header
+	if name == "" {
+		name = strings.ToLower(spec.Name)
+	}
+	gen := generator{
+		imports: make(map[string]bool),
+	}
+	if opts.Import != "" {
+		gen.imports[opts.Import] = true
+	}
+	for fn := range spec.Iter() {
+		if fn.Tags.Get("call") == "" {
+			continue
+		}
+		if err := gen.function(fn); err != nil {
+			return nil, xray.New(fmt.Errorf("%s: %w", strings.Join(append(slices.Clone(fn.Path), fn.Name), "."), err))
+		}
+	}
+	var (
+		source = gen.source(name, opts)
+		header = gen.header(name, spec.Docs)
+	)
+	formatted, err := format.Source(source)
+	if err != nil {
+		return nil, xray.New(err)
+	}
+	var (
+		sourcePath = filepath.Join(opts.Dir, "exports.go")
+		headerPath = filepath.Join(opts.Dir, name+".h")
+	)
+	if err := os.WriteFile(sourcePath, formatted, 0644); err != nil {
+		return nil, xray.New(err)
+	}
+	if err := os.WriteFile(headerPath, header, 0644); err != nil {
+		return nil, xray.New(err)
+	}
+	return []string{sourcePath, headerPath}, nil
+}
+
+// generator of the Go wrappers and C declarations for each function.
+type generator struct {
+	imports map[string]bool
+	intern  bool // whether the intern helper is needed.
+
+	wrappers     bytes.Buffer
+	declarations bytes.Buffer
+}
+
+func (gen *generator) source(header string, opts Exports) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by runtime.link/api/call. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package main\n\n")
+	fmt.Fprintf(&b, "/*\n#include <stdbool.h>\n#include <stddef.h>\n#include <stdint.h>\n#include <stdlib.h>\n*/\nimport \"C\"\n\n")
+	if gen.intern {
+		gen.imports["sync"] = true
+	}
+	if len(gen.imports) > 0 {
+		fmt.Fprintf(&b, "import (\n")
+		for _, path := range slices.Sorted(func(yield func(string) bool) {
+			for path := range gen.imports {
+				if !yield(path) {
+					return
+				}
+			}
+		}) {
+			fmt.Fprintf(&b, "\t%q\n", path)
+		}
+		fmt.Fprintf(&b, ")\n\n")
+	}
+	fmt.Fprintf(&b, "// Library is the implementation exported by the shared library, see %s.h\n", header)
+	fmt.Fprintf(&b, "var Library = %s\n\n", opts.Value)
+	fmt.Fprintf(&b, "func main() {}\n\n")
+	b.Write(gen.wrappers.Bytes())
+	if gen.intern {
+		fmt.Fprintf(&b, "var interned struct {\n\tsync.Mutex\n\tstrings map[string]*C.char\n}\n\n")
+		fmt.Fprintf(&b, "// intern returns a C copy of the string, that is never freed.\n")
+		fmt.Fprintf(&b, "func intern(s string) *C.char {\n")
+		fmt.Fprintf(&b, "\tinterned.Lock()\n\tdefer interned.Unlock()\n")
+		fmt.Fprintf(&b, "\tif ptr, ok := interned.strings[s]; ok {\n\t\treturn ptr\n\t}\n")
+		fmt.Fprintf(&b, "\tif interned.strings == nil {\n\t\tinterned.strings = make(map[string]*C.char)\n\t}\n")
+		fmt.Fprintf(&b, "\tptr := C.CString(s)\n\tinterned.strings[s] = ptr\n\treturn ptr\n}\n")
+	}
+	return b.Bytes()
+}
+
+func (gen *generator) header(name, docs string) []byte {
+	guard := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name) + "_H"
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "/* Code generated by runtime.link/api/call. DO NOT EDIT. */\n\n")
+	if docs != "" {
+		writeComment(&b, docs)
+		fmt.Fprintf(&b, "\n")
+	}
+	fmt.Fprintf(&b, "#ifndef %s\n#define %s\n\n", guard, guard)
+	fmt.Fprintf(&b, "#include <stdbool.h>\n#include <stddef.h>\n#include <stdint.h>\n\n")
+	fmt.Fprintf(&b, "#ifdef __cplusplus\nextern \"C\" {\n#endif\n")
+	b.Write(gen.declarations.Bytes())
+	fmt.Fprintf(&b, "\n#ifdef __cplusplus\n}\n#endif\n\n#endif /* %s */\n", guard)
+	return b.Bytes()
+}
+
+// function generates the wrapper and declaration for the function, the
+// C arguments are mapped to the Go arguments by position (except for '-'
+// arguments, that are inferred from the argument they refer to). Go
+// results are written to trailing '+' arguments, after the C result
+// (unless it reports the error).
+func (gen *generator) function(fn api.Function) error {
+	names, ctype, err := ffi.ParseTag(fn.Tags.Get("call"))
+	if err != nil {
+		return err
+	}
+	if ctype.Name != "func" {
+		return fmt.Errorf("call tag must have a func type")
+	}
+	var (
+		symbol  = names[0]
+		params  []string // C parameters.
+		inputs  []string // Go wrapper parameters.
+		prelude []string // Go statements to run before the call.
+		args    = make([]string, fn.NumIn())
+		outputs []int // indices of '+' arguments that receive results.
+		notes   []string
+	)
+	for i, arg := range ctype.Args {
+		name := fmt.Sprintf("arg%d", i+1)
+		cdecl, gotype, err := gen.types(arg)
+		if err != nil {
+			return err
+		}
+		params = append(params, cdecl+" "+name)
+		inputs = append(inputs, name+" "+gotype)
+		if arg.Free == '-' {
+			continue
+		}
+		if arg.Maps-1 >= fn.NumIn() {
+			if arg.Free != '+' || !isPointer(arg) {
+				return fmt.Errorf("too many arguments in call tag (%s)", arg.Name)
+			}
+			outputs = append(outputs, i)
+			continue
+		}
+		expr, err := gen.argument(fn.In(arg.Maps-1), arg, i, ctype.Args, &prelude)
+		if err != nil {
+			return err
+		}
+		if arg.Free == '$' {
+			notes = append(notes, fmt.Sprintf("takes ownership of %s.", name))
+		}
+		args[arg.Maps-1] = expr
+	}
+	for i, arg := range args {
+		if arg == "" {
+			return fmt.Errorf("argument %d (%s) is not mapped by the call tag", i+1, fn.In(i))
+		}
+	}
+	var (
+		creturn  = "void"
+		goreturn string
+		ret      = ctype.Func
+		errored  = fn.Type.NumOut() != fn.NumOut()
+		results  = make([]string, fn.Type.NumOut())
+	)
+	if ret != nil && ret.Name == "void" && !isPointer(*ret) {
+		ret = nil
+	}
+	for i := range results {
+		results[i] = fmt.Sprintf("r%d", i)
+	}
+	if errored {
+		results[len(results)-1] = "err"
+	}
+	var (
+		body   bytes.Buffer
+		values = results[:fn.NumOut()]
+	)
+	for _, line := range prelude {
+		fmt.Fprintf(&body, "\t%s\n", line)
+	}
+	call := "Library." + strings.Join(append(slices.Clone(fn.Path), fn.Name), ".")
+	if fn.Type.NumIn() != fn.NumIn() {
+		gen.imports["context"] = true
+		args = append([]string{"context.Background()"}, args...)
+	}
+	if len(results) > 0 {
+		fmt.Fprintf(&body, "\t%s := %s(%s)\n", strings.Join(results, ", "), call, strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(&body, "\t%s(%s)\n", call, strings.Join(args, ", "))
+	}
+	var epilogue string
+	if ret != nil {
+		cdecl, gotype, err := gen.types(*ret)
+		if err != nil {
+			return err
+		}
+		creturn, goreturn = cdecl, gotype
+		switch {
+		case len(values) > len(outputs):
+			if errored {
+				fmt.Fprintf(&body, "\tif err != nil {\n\t\treturn %s\n\t}\n", zero(*ret))
+			}
+			expr, err := gen.result(fn.Type.Out(0), *ret, values[0])
+			if err != nil {
+				return err
+			}
+			values = values[1:]
+			epilogue = "return " + expr
+			switch ret.Free {
+			case '$':
+				notes = append(notes, "the caller must free the result.")
+			case '&':
+				notes = append(notes, "the caller must copy the result.")
+			}
+		case errored:
+			failure, success, err := status(*ret)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&body, "\tif err != nil {\n\t\treturn %s\n\t}\n", failure)
+			epilogue = "return " + success
+			notes = append(notes, fmt.Sprintf("returns %s on failure.", strings.TrimPrefix(failure, "C.")))
+		default:
+			return fmt.Errorf("call tag has a result, but the function does not")
+		}
+	} else if errored {
+		return fmt.Errorf("call tag requires a result, to report the error")
+	}
+	if len(values) != len(outputs) {
+		return fmt.Errorf("call tag has %d '+' arguments for %d results", len(outputs), len(values))
+	}
+	for i, out := range outputs {
+		expr, err := gen.result(fn.Type.Out(fn.NumOut()-len(values)+i), ctype.Args[out], values[i])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&body, "\tif arg%d != nil {\n\t\t*arg%d = %s\n\t}\n", out+1, out+1, expr)
+	}
+	if epilogue != "" {
+		fmt.Fprintf(&body, "\t%s\n", epilogue)
+	}
+	fmt.Fprintf(&gen.wrappers, "//export %s\n", symbol)
+	fmt.Fprintf(&gen.wrappers, "func %s(%s) %s {\n%s}\n\n", symbol, strings.Join(inputs, ", "), goreturn, body.Bytes())
+
+	fmt.Fprintf(&gen.declarations, "\n")
+	writeComment(&gen.declarations, strings.TrimSpace(fn.Docs+"\n\n"+strings.Join(notes, "\n")))
+	if len(params) == 0 {
+		params = []string{"void"}
+	}
+	fmt.Fprintf(&gen.declarations, "%s %s(%s);\n", creturn, symbol, strings.Join(params, ", "))
+	return nil
+}
+
+// cgoNames of the multi-word C types, as they are referred to by cgo.
+var cgoNames = map[string]string{
+	"signed_char":        "schar",
+	"unsigned_char":      "uchar",
+	"unsigned_short":     "ushort",
+	"unsigned_int":       "uint",
+	"unsigned_long":      "ulong",
+	"long_long":          "longlong",
+	"unsigned_long_long": "ulonglong",
+}
+
+// isPointer reports whether the type has an ownership assertion, which
+// signals that the value is a pointer.
+func isPointer(ctype ffi.Type) bool {
+	return ctype.Hash || strings.ContainsRune("$&*~+", ctype.Free)
+}
+
+// types returns the C declaration and the cgo type for the given type.
+func (gen *generator) types(ctype ffi.Type) (string, string, error) {
+	if ctype.More {
+		return "", "", fmt.Errorf("variadic %s arguments cannot be exported", ctype.Name)
+	}
+	name := ctype.Name
+	switch {
+	case name == "func":
+		return "", "", fmt.Errorf("function arguments cannot be exported")
+	case name == "long_double" || name == "max_align_t":
+		return "", "", fmt.Errorf("%s cannot be exported", name)
+	case name != "void" && cgo.Types.LookupKind(name) == reflect.Invalid:
+		return "", "", fmt.Errorf("unsupported C type %s", name)
+	}
+	var (
+		cdecl  = name
+		gotype = "C." + name
+	)
+	if alias, ok := cgoNames[name]; ok {
+		cdecl = strings.ReplaceAll(name, "_", " ")
+		gotype = "C." + alias
+	}
+	if !isPointer(ctype) {
+		return cdecl, gotype, nil
+	}
+	if ctype.Hash {
+		cdecl = "const " + cdecl
+	}
+	if name == "void" {
+		gen.imports["unsafe"] = true
+		return cdecl + "*", "unsafe.Pointer", nil
+	}
+	return cdecl + "*", "*" + gotype, nil
+}
+
+// argument returns the Go expression that converts the C argument (at the
+// given index) into the Go type, any statements required to do so are
+// added to the prelude.
+func (gen *generator) argument(rtype reflect.Type, arg ffi.Type, index int, args []ffi.Type, prelude *[]string) (string, error) {
+	name := fmt.Sprintf("arg%d", index+1)
+	length := ""
+	for i, other := range args {
+		if other.Free == '-' && other.Test.Equality.Check && int(other.Test.Equality.Index) == index+1 {
+			length = fmt.Sprintf("arg%d", i+1)
+		}
+	}
+	pointer := isPointer(arg)
+	switch rtype.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if pointer {
+			break
+		}
+		return fmt.Sprintf("%s(%s)", gen.typeName(rtype), name), nil
+	case reflect.Uintptr:
+		if pointer {
+			gen.imports["unsafe"] = true
+			return fmt.Sprintf("%s(unsafe.Pointer(%s))", gen.typeName(rtype), name), nil
+		}
+		return fmt.Sprintf("%s(%s)", gen.typeName(rtype), name), nil
+	case reflect.UnsafePointer:
+		if !pointer {
+			break
+		}
+		gen.imports["unsafe"] = true
+		return fmt.Sprintf("unsafe.Pointer(%s)", name), nil
+	case reflect.Pointer:
+		if !pointer {
+			break
+		}
+		gen.imports["unsafe"] = true
+		return fmt.Sprintf("(%s)(unsafe.Pointer(%s))", gen.typeName(rtype), name), nil
+	case reflect.String:
+		if !pointer || arg.Name != "char" {
+			break
+		}
+		expr := fmt.Sprintf("C.GoString(%s)", name)
+		if length != "" {
+			expr = fmt.Sprintf("C.GoStringN(%s, C.int(%s))", name, length)
+		}
+		if arg.Free == '$' {
+			gen.imports["unsafe"] = true
+			*prelude = append(*prelude, fmt.Sprintf("defer C.free(unsafe.Pointer(%s))", name))
+		}
+		if rtype.Name() != "string" {
+			expr = fmt.Sprintf("%s(%s)", gen.typeName(rtype), expr)
+		}
+		return expr, nil
+	case reflect.Slice:
+		if !pointer {
+			break
+		}
+		if length == "" {
+			return "", fmt.Errorf("%s argument requires a '-' length argument, ie. -size_t=@%d", rtype, index+1)
+		}
+		gen.imports["unsafe"] = true
+		expr := fmt.Sprintf("unsafe.Slice((*%s)(unsafe.Pointer(%s)), %s)", gen.typeName(rtype.Elem()), name, length)
+		if arg.Free == '$' {
+			gen.imports["slices"] = true
+			expr = fmt.Sprintf("slices.Clone(%s)", expr)
+			*prelude = append(*prelude, fmt.Sprintf("defer C.free(unsafe.Pointer(%s))", name))
+		}
+		if rtype.Name() != "" {
+			expr = fmt.Sprintf("%s(%s)", gen.typeName(rtype), expr)
+		}
+		return expr, nil
+	}
+	return "", fmt.Errorf("%s arguments cannot be exported as %s", rtype, arg.Name)
+}
+
+// result returns the C expression that converts the Go value into the C
+// type. Strings are copied into C memory, '$' strings are for the caller
+// to free, whereas '&' and '~' strings are interned, so that they remain
+// valid.
+func (gen *generator) result(rtype reflect.Type, ctype ffi.Type, value string) (string, error) {
+	_, gotype, err := gen.types(ctype)
+	if err != nil {
+		return "", err
+	}
+	if ctype.Free == '+' {
+		ctype.Free = 0 // the value is written through the pointer.
+		_, gotype, err = gen.types(ctype)
+		if err != nil {
+			return "", err
+		}
+	}
+	pointer := isPointer(ctype)
+	switch rtype.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if pointer {
+			break
+		}
+		return fmt.Sprintf("%s(%s)", gotype, value), nil
+	case reflect.UnsafePointer:
+		if !pointer {
+			break
+		}
+		if gotype == "unsafe.Pointer" {
+			return value, nil
+		}
+		return fmt.Sprintf("(%s)(%s)", gotype, value), nil
+	case reflect.String:
+		if !pointer || ctype.Name != "char" {
+			break
+		}
+		switch ctype.Free {
+		case '$':
+			return fmt.Sprintf("C.CString(string(%s))", value), nil
+		case '&', '~':
+			gen.intern = true
+			return fmt.Sprintf("intern(string(%s))", value), nil
+		}
+	}
+	return "", fmt.Errorf("%s results cannot be exported as %s", rtype, ctype.Name)
+}
+
+// zero returns the zero value of the C type.
+func zero(ctype ffi.Type) string {
+	if isPointer(ctype) {
+		return "nil"
+	}
+	if ctype.Name == "bool" {
+		return "false"
+	}
+	return "0"
+}
+
+// status returns the C values to return on failure and success, for a
+// function that only returns an error.
+func status(ctype ffi.Type) (failure, success string, err error) {
+	if isPointer(ctype) {
+		return "", "", fmt.Errorf("%s cannot report an error", ctype.Name)
+	}
+	switch kind := cgo.Types.LookupKind(ctype.Name); kind {
+	case reflect.Bool:
+		return "false", "true", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "-1", "0", nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "1", "0", nil
+	default:
+		return "", "", fmt.Errorf("%s cannot report an error", ctype.Name)
+	}
+}
+
+// typeName returns the Go source for the type, importing its package.
+func (gen *generator) typeName(rtype reflect.Type) string {
+	if rtype.Name() != "" {
+		if rtype.PkgPath() != "" {
+			gen.imports[rtype.PkgPath()] = true
+		}
+		return rtype.String()
+	}
+	switch rtype.Kind() {
+	case reflect.Pointer:
+		return "*" + gen.typeName(rtype.Elem())
+	case reflect.Slice:
+		return "[]" + gen.typeName(rtype.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", rtype.Len(), gen.typeName(rtype.Elem()))
+	}
+	return rtype.String()
+}
+
+// writeComment writes the documentation as a C comment.
+func writeComment(b *bytes.Buffer, docs string) {
+	if docs = strings.TrimSpace(docs); docs == "" {
+		return
+	}
+	for _, line := range strings.Split(docs, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			fmt.Fprintf(b, "//\n")
+			continue
+		}
+		fmt.Fprintf(b, "// %s\n", line)
+	}
+}
@@ -0,0 +1,145 @@
+package call_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"runtime.link/api"
+	"runtime.link/api/call"
+)
+
+// Library mirrors the library package written by TestExport.
+type Library struct {
+	api.Specification `api:"Example"
+		is an example c-shared library.`
+
+	Add func(a, b int32) int32 `call:"example_add func(int32_t,int32_t)int32_t"
+		returns the sum of a and b.`
+	Greet   func(name string) string        `call:"example_greet func(&char)$char"`
+	Version func() string                   `call:"example_version func()~char"`
+	Sum     func(values []float64) float64  `call:"example_sum func(&double,-size_t=@1)double"`
+	Div     func(a, b int32) (int32, error) `call:"example_div func(int32_t,int32_t,+int32_t)int"`
+}
+
+const library = `package lib
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Library struct {
+	Add     func(a, b int32) int32
+	Greet   func(name string) string
+	Version func() string
+	Sum     func(values []float64) float64
+	Div     func(a, b int32) (int32, error)
+}
+
+func New() Library {
+	return Library{
+		Add:     func(a, b int32) int32 { return a + b },
+		Greet:   func(name string) string { return fmt.Sprintf("Hello %s", name) },
+		Version: func() string { return "v1.0.0" },
+		Sum: func(values []float64) float64 {
+			var sum float64
+			for _, value := range values {
+				sum += value
+			}
+			return sum
+		},
+		Div: func(a, b int32) (int32, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		},
+	}
+}
+`
+
+const program = `#include <stdio.h>
+#include <stdlib.h>
+#include "example.h"
+
+int main(void) {
+	char *greeting = example_greet("World");
+	double values[] = {1.5, 2.5, 3};
+	int32_t quotient = 0;
+	printf("%d %s %s %g\n", example_add(2, 3), greeting, example_version(), example_sum(values, 3));
+	free(greeting);
+	int status = example_div(7, 2, &quotient);
+	printf("%d %d\n", status, quotient);
+	printf("%d\n", example_div(1, 0, &quotient));
+	return 0;
+}
+`
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := api.Export(call.Exporter, Library{}, call.Exports{
+		Dir:    dir,
+		Import: "example/lib",
+		Value:  "lib.New()",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := os.ReadFile(files[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, decl := range []string{
+		"int32_t example_add(int32_t arg1, int32_t arg2);",
+		"char* example_greet(char* arg1);",
+		"char* example_version(void);",
+		"double example_sum(double* arg1, size_t arg2);",
+		"int example_div(int32_t arg1, int32_t arg2, int32_t* arg3);",
+		"// the caller must free the result.",
+		"// returns -1 on failure.",
+	} {
+		if !strings.Contains(string(header), decl) {
+			t.Fatalf("missing %q in header:\n%s", decl, header)
+		}
+	}
+	if testing.Short() {
+		t.Skip("skipping c-shared build in short mode")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler available")
+	}
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example\n\ngo 1.24\n\nrequire runtime.link v0.0.0\n\nreplace runtime.link => "+root+"\n")
+	write("lib/lib.go", library)
+	write("main.c", program)
+	run := func(name string, args ...string) string {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "CGO_ENABLED=1", "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s: %v\n%s", name, strings.Join(args, " "), err, out)
+		}
+		return string(out)
+	}
+	run("go", "build", "-buildmode=c-shared", "-o", "libexample.so", ".")
+	run(cc, "-o", "example", "main.c", "-L.", "-lexample", "-Wl,-rpath,"+dir)
+	if out := run("./example"); out != "5 Hello World v1.0.0 7\n0 3\n-1\n" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
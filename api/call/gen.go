@@ -0,0 +1,1638 @@
+package call
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"runtime.link/api/call/internal/cgo"
+	"runtime.link/api/call/internal/ffi"
+	"runtime.link/api/xray"
+)
+
+// Generate writes the Go source for a package named pkg to w, containing a
+// library specification structure (named API) with call tags for each
+// function prototype in the given C header. libs maps each GOOS to the
+// names of the library on that platform, which are written as [To] fields.
+// Structs, enums and typedefs become named Go types and #define constants
+// become Go constants. Pointers are borrowed ('&') unless they are function
+// results, as the ownership cannot be known from the header alone, each
+// function with pointers is flagged with a comment for manual review.
+// Preprocessor conditionals are not evaluated, so the header should not
+// depend on them for any declarations.
+func Generate(w io.Writer, pkg string, header []byte, libs map[string]string) error {
+	var parser = cparser{
+		tokens:   lexC(string(header)),
+		ignore:   make(map[string]bool),
+		unwrap:   make(map[string]bool),
+		typedefs: make(map[string]*ctypedef),
+		structs:  make(map[string]*cstruct),
+		enums:    make(map[string]*cenum),
+		values:   make(map[string]int64),
+	}
+	if err := parser.parse(); err != nil {
+		return xray.New(err)
+	}
+	var gen = cgenerator{
+		cparser: &parser,
+		taken:   map[string]bool{"API": true},
+		names:   make(map[any]string),
+		imports: make(map[string]bool),
+	}
+	gen.imports["runtime.link/api"] = true
+	gen.name()
+	var functions, skipped = []string{}, parser.skipped
+	for _, fn := range parser.functions {
+		field, err := gen.function(fn)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", fn.name, err))
+			continue
+		}
+		functions = append(functions, field)
+	}
+	var decls = gen.declarations()
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by runtime.link/api/call from a C header. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	if len(libs) > 0 {
+		gen.imports["runtime.link/api/call"] = true
+	}
+	src.WriteString("import (\n")
+	var paths = slices.Sorted(func(yield func(string) bool) {
+		for path := range gen.imports {
+			if !yield(path) {
+				return
+			}
+		}
+	})
+	for _, std := range []bool{true, false} {
+		for _, path := range paths {
+			if std == !strings.Contains(path, ".") {
+				fmt.Fprintf(&src, "\t%q\n", path)
+			}
+		}
+		if std {
+			src.WriteString("\n")
+		}
+	}
+	src.WriteString(")\n\n")
+	src.WriteString("// API specification for the library.\ntype API struct {\n\tapi.Specification\n")
+	if len(libs) > 0 {
+		src.WriteString("\n")
+		for _, goos := range sortedKeys(libs) {
+			fmt.Fprintf(&src, "\t%s call.To `lib:%q`\n", goos, libs[goos])
+		}
+	}
+	if len(functions) > 0 {
+		src.WriteString("\n")
+	}
+	for _, fn := range functions {
+		src.WriteString(fn)
+	}
+	if len(skipped) > 0 {
+		src.WriteString("\n\t// The following declarations could not be represented:\n")
+		for _, reason := range skipped {
+			fmt.Fprintf(&src, "\t//  - %s\n", reason)
+		}
+	}
+	src.WriteString("}\n")
+	for _, decl := range decls {
+		src.WriteString("\n")
+		src.WriteString(decl)
+	}
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return xray.New(fmt.Errorf("call: generated invalid Go source: %w", err))
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// ctoken is a lexical token of a C header.
+type ctoken struct {
+	kind  byte // 'i' identifier, 'n' number, 's' string, 'c' character, '#' directive or else punctuation.
+	text  string
+	line  int
+	docs  string // comment directly above the token.
+	trail string // comment after the token, on the same line.
+}
+
+// lexC splits C source into tokens, comments are attached to the tokens
+// they document.
+func lexC(src string) []ctoken {
+	var (
+		tokens  []ctoken
+		pending []string
+		lastDoc int // line that the last pending comment ends on.
+		line    = 1
+		start   = true // at the start of a line.
+	)
+	emit := func(tok ctoken) {
+		if len(pending) > 0 && lastDoc >= tok.line-1 {
+			tok.docs = strings.Join(pending, "\n")
+		}
+		pending = nil
+		tokens = append(tokens, tok)
+	}
+	comment := func(text string, at int) {
+		text = cleanComment(text)
+		if len(tokens) > 0 && tokens[len(tokens)-1].line == at && len(pending) == 0 {
+			prev := &tokens[len(tokens)-1]
+			prev.trail = strings.TrimSpace(prev.trail + "\n" + text)
+			return
+		}
+		if text != "" {
+			pending = append(pending, text)
+		}
+		lastDoc = line
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			start = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+			continue
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			i += 2
+			line++
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			comment(src[i:i+end], line)
+			i += end
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			text := src[i : i+2+end]
+			at := line
+			line += strings.Count(text, "\n")
+			comment(text, at)
+			i += end + 4
+			continue
+		case c == '#' && start:
+			var (
+				b     strings.Builder
+				trail []string
+			)
+			j := i + 1
+			for j < len(src) && src[j] != '\n' {
+				switch {
+				case src[j] == '\\' && j+1 < len(src) && src[j+1] == '\n':
+					b.WriteByte(' ')
+					line++
+					j += 2
+				case strings.HasPrefix(src[j:], "/*"):
+					end := strings.Index(src[j+2:], "*/")
+					if end < 0 {
+						end = len(src) - j - 2
+					}
+					trail = append(trail, cleanComment(src[j:j+2+end]))
+					line += strings.Count(src[j:j+2+end], "\n")
+					b.WriteByte(' ')
+					j += end + 4
+				case strings.HasPrefix(src[j:], "//"):
+					end := strings.IndexByte(src[j:], '\n')
+					if end < 0 {
+						end = len(src) - j
+					}
+					trail = append(trail, cleanComment(src[j:j+end]))
+					j += end
+				default:
+					b.WriteByte(src[j])
+					j++
+				}
+			}
+			emit(ctoken{kind: '#', text: strings.TrimSpace(b.String()), line: line, trail: strings.TrimSpace(strings.Join(trail, "\n"))})
+			i = min(j, len(src))
+			continue
+		}
+		start = false
+		tok := ctoken{line: line}
+		j := i + 1
+		switch {
+		case c == '_' || unicode.IsLetter(rune(c)):
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tok.kind = 'i'
+		case unicode.IsDigit(rune(c)) || (c == '.' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			for j < len(src) && (src[j] == '.' || src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) ||
+				((src[j] == '+' || src[j] == '-') && strings.ContainsRune("eEpP", rune(src[j-1])) && !strings.HasPrefix(strings.ToLower(src[i:j]), "0x"))) {
+				j++
+			}
+			tok.kind = 'n'
+		case c == '"' || c == '\'':
+			for j < len(src) && src[j] != c && src[j] != '\n' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(src))
+			tok.kind = 's'
+			if c == '\'' {
+				tok.kind = 'c'
+			}
+		default:
+			tok.kind = c
+			for _, punct := range []string{"...", "<<", ">>", "->", "&&", "||", "==", "!=", "<=", ">="} {
+				if strings.HasPrefix(src[i:], punct) {
+					j = i + len(punct)
+					break
+				}
+			}
+		}
+		tok.text = src[i:j]
+		emit(tok)
+		i = j
+	}
+	return tokens
+}
+
+// cleanComment removes the comment markers and any decorations from a
+// C comment.
+func cleanComment(text string) string {
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "//"), "/*")
+	text = strings.TrimSuffix(text, "*/")
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "*/!<")
+		line = strings.TrimSpace(line)
+		if strings.Trim(line, "*-=_#") == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cdecl is a C type, as referred to by a declaration.
+type cdecl struct {
+	base     string // standard type name (ie. unsigned_int) or else the name of the struct, enum or typedef.
+	kind     byte   // 's' struct, 'u' union, 'e' enum or else 0.
+	konst    bool   // the base type is const.
+	pointers int
+	array    string // length of the array (if any).
+	fn       *cfunc // function pointer.
+}
+
+type cfunc struct {
+	name     string
+	docs     string
+	result   cdecl
+	params   []cparam
+	variadic bool
+}
+
+type cparam struct {
+	name string
+	cdecl
+}
+
+type cfield struct {
+	name string
+	docs string
+	cdecl
+}
+
+type cstruct struct {
+	name   string
+	docs   string
+	fields []cfield
+	union  bool
+	body   bool // the struct is not opaque.
+	bits   bool // the struct has bit fields.
+}
+
+type cenum struct {
+	name   string
+	docs   string
+	values []cconst
+}
+
+type cconst struct {
+	name  string
+	docs  string
+	value string // Go literal.
+}
+
+type ctypedef struct {
+	name string
+	docs string
+	cdecl
+}
+
+// cparser parses the declarations of a C header.
+type cparser struct {
+	tokens  []ctoken
+	pos     int
+	handled int      // directives before this position have been handled.
+	skipped []string // declarations that could not be parsed.
+
+	ignore    map[string]bool // macros that expand to nothing (or to storage classes).
+	unwrap    map[string]bool // function-like macros that expand to their argument.
+	typedefs  map[string]*ctypedef
+	structs   map[string]*cstruct
+	enums     map[string]*cenum
+	values    map[string]int64 // integer constants.
+	order     []any            // declarations, in order.
+	constants []cconst
+	functions []cfunc
+}
+
+// peek returns the next token, handling any directives before it.
+func (p *cparser) peek() ctoken {
+	for p.pos < len(p.tokens) && p.tokens[p.pos].kind == '#' {
+		if p.pos >= p.handled {
+			p.directive(p.tokens[p.pos])
+			p.handled = p.pos + 1
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.tokens) {
+		return ctoken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *cparser) next() ctoken {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *cparser) expect(text string) error {
+	if tok := p.next(); tok.text != text {
+		return fmt.Errorf("line %d: expected '%s' but found '%s'", tok.line, text, tok.text)
+	}
+	return nil
+}
+
+// skip consumes tokens until the closing bracket of the one just consumed.
+func (p *cparser) skip(open, close string) {
+	for depth := 1; depth > 0 && p.pos < len(p.tokens); {
+		switch p.next().text {
+		case open:
+			depth++
+		case close:
+			depth--
+		}
+	}
+}
+
+// skipDecl consumes tokens until the end of the current declaration.
+func (p *cparser) skipDecl() {
+	for p.pos < len(p.tokens) {
+		switch p.next().text {
+		case ";":
+			return
+		case "{":
+			p.skip("{", "}")
+			if p.peek().text == ";" {
+				p.next()
+			}
+			return
+		case "(":
+			p.skip("(", ")")
+		}
+	}
+}
+
+func (p *cparser) parse() error {
+	for p.pos < len(p.tokens) {
+		tok := p.peek()
+		switch {
+		case p.pos >= len(p.tokens):
+		case tok.text == ";" || tok.text == "}":
+			p.next()
+		case tok.text == "extern" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == 's':
+			p.pos += 2
+			if p.peek().text == "{" {
+				p.next()
+			}
+		default:
+			start := p.pos
+			if err := p.declaration(); err != nil {
+				// headers often have alternative declarations inside of
+				// preprocessor conditionals, so skip what can't be parsed.
+				p.skipped = append(p.skipped, err.Error())
+				p.pos = start
+				p.skipDecl()
+			}
+			if p.pos == start {
+				p.next()
+			}
+		}
+	}
+	return nil
+}
+
+// directive handles #define constants, all other directives are ignored.
+func (p *cparser) directive(tok ctoken) {
+	text, ok := strings.CutPrefix(tok.text, "define")
+	if !ok || text == "" || (text[0] != ' ' && text[0] != '\t') {
+		return
+	}
+	tokens := lexC(strings.TrimSpace(text))
+	if len(tokens) == 0 || tokens[0].kind != 'i' {
+		return
+	}
+	name := tokens[0].text
+	body := tokens[1:]
+	if len(body) > 0 && body[0].text == "(" && !strings.HasPrefix(strings.TrimSpace(text)[len(name):], " ") {
+		// function-like macros are only supported when they expand to
+		// their argument, ie. #define OF(args) args
+		if len(body) == 4 && body[1].kind == 'i' && body[2].text == ")" && body[3].text == body[1].text {
+			p.unwrap[name] = true
+		}
+		return
+	}
+	if len(body) == 0 || (len(body) == 1 && body[0].kind == 'i' && ignorable[body[0].text]) {
+		p.ignore[name] = true
+		return
+	}
+	if value, ok := p.evaluate(body); ok {
+		p.values[name] = value
+	}
+	if value, ok := p.literal(body); ok {
+		p.constants = append(p.constants, cconst{name: name, docs: firstNonEmpty(tok.docs, tok.trail), value: value})
+	}
+}
+
+// ignorable keywords, that are not relevant to the Go representation.
+var ignorable = map[string]bool{
+	"extern": true, "static": true, "inline": true, "__inline": true, "__inline__": true,
+	"register": true, "volatile": true, "restrict": true, "__restrict": true, "__restrict__": true,
+	"_Noreturn": true, "__extension__": true, "__cdecl": true, "__stdcall": true,
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// literal returns the Go literal for a constant expression (if it can be
+// represented).
+func (p *cparser) literal(tokens []ctoken) (string, bool) {
+	for len(tokens) > 2 && tokens[0].text == "(" && tokens[len(tokens)-1].text == ")" {
+		tokens = tokens[1 : len(tokens)-1]
+	}
+	if len(tokens) == 1 {
+		switch tok := tokens[0]; tok.kind {
+		case 's':
+			if _, err := strconv.Unquote(tok.text); err == nil {
+				return tok.text, true
+			}
+			return "", false
+		case 'n':
+			if value, ok := number(tok.text); ok {
+				return value, true
+			}
+		}
+	}
+	if len(tokens) == 2 && tokens[0].text == "-" && tokens[1].kind == 'n' {
+		if value, ok := number(tokens[1].text); ok && strings.ContainsAny(value, ".eE") && !strings.HasPrefix(value, "0x") {
+			return "-" + value, true
+		}
+	}
+	value, ok := p.evaluate(tokens)
+	if !ok {
+		return "", false
+	}
+	return strconv.FormatInt(value, 10), true
+}
+
+// number converts a C numeric literal into a Go one.
+func number(text string) (string, bool) {
+	lower := strings.ToLower(text)
+	hex := strings.HasPrefix(lower, "0x")
+	lower = strings.TrimRight(lower, "ul")
+	if !hex || !strings.Contains(lower, "p") {
+		if strings.ContainsAny(lower, ".e") && !hex {
+			lower = strings.TrimSuffix(lower, "f")
+		}
+	}
+	if len(lower) > 1 && lower[0] == '0' && !hex && !strings.ContainsAny(lower, ".e") {
+		lower = "0o" + lower[1:]
+	}
+	if _, err := strconv.ParseFloat(lower, 64); err == nil && strings.ContainsAny(lower, ".ep") && !(hex && !strings.Contains(lower, "p")) {
+		return lower, true
+	}
+	if _, err := strconv.ParseUint(lower, 0, 64); err == nil {
+		return lower, true
+	}
+	return "", false
+}
+
+// evaluate an integer constant expression.
+func (p *cparser) evaluate(tokens []ctoken) (int64, bool) {
+	tokens = slices.DeleteFunc(slices.Clone(tokens), func(tok ctoken) bool { return tok.kind == '#' })
+	eval := exprEvaluator{tokens: tokens, values: p.values}
+	value := eval.binary(0)
+	return value, eval.ok && eval.pos == len(tokens)
+}
+
+type exprEvaluator struct {
+	tokens []ctoken
+	pos    int
+	values map[string]int64
+	failed bool
+	ok     bool
+}
+
+var precedence = map[string]int{
+	"|": 1, "^": 2, "&": 3, "<<": 4, ">>": 4, "+": 5, "-": 5, "*": 6, "/": 6, "%": 6,
+}
+
+func (e *exprEvaluator) binary(min int) int64 {
+	x := e.unary()
+	for e.pos < len(e.tokens) {
+		op := e.tokens[e.pos].text
+		prec, ok := precedence[op]
+		if !ok || prec <= min {
+			break
+		}
+		e.pos++
+		y := e.binary(prec)
+		switch op {
+		case "|":
+			x |= y
+		case "^":
+			x ^= y
+		case "&":
+			x &= y
+		case "<<":
+			x <<= y
+		case ">>":
+			x >>= y
+		case "+":
+			x += y
+		case "-":
+			x -= y
+		case "*":
+			x *= y
+		case "/", "%":
+			if y == 0 {
+				e.failed = true
+				break
+			}
+			if op == "/" {
+				x /= y
+			} else {
+				x %= y
+			}
+		}
+	}
+	e.ok = !e.failed
+	return x
+}
+
+func (e *exprEvaluator) unary() int64 {
+	if e.pos >= len(e.tokens) {
+		e.failed = true
+		return 0
+	}
+	tok := e.tokens[e.pos]
+	e.pos++
+	switch {
+	case tok.text == "-":
+		return -e.unary()
+	case tok.text == "+":
+		return e.unary()
+	case tok.text == "~":
+		return ^e.unary()
+	case tok.text == "!":
+		if e.unary() == 0 {
+			return 1
+		}
+		return 0
+	case tok.text == "(":
+		x := e.binary(0)
+		if e.pos >= len(e.tokens) || e.tokens[e.pos].text != ")" {
+			e.failed = true
+			return 0
+		}
+		e.pos++
+		return x
+	case tok.kind == 'n':
+		text, ok := number(tok.text)
+		if !ok {
+			e.failed = true
+			return 0
+		}
+		value, err := strconv.ParseUint(text, 0, 64)
+		if err != nil {
+			e.failed = true
+		}
+		return int64(value)
+	case tok.kind == 'c':
+		value, _, _, err := strconv.UnquoteChar(strings.Trim(tok.text, "'"), '\'')
+		if err != nil {
+			e.failed = true
+		}
+		return int64(value)
+	case tok.kind == 'i':
+		value, ok := e.values[tok.text]
+		if !ok {
+			e.failed = true
+		}
+		return value
+	}
+	e.failed = true
+	return 0
+}
+
+// declaration parses a top-level declaration.
+func (p *cparser) declaration() error {
+	first := p.peek()
+	docs := firstNonEmpty(first.docs)
+	typedef := false
+	if first.text == "typedef" {
+		typedef = true
+		p.next()
+	}
+	base, ok, err := p.specifiers()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		p.skipDecl()
+		return nil
+	}
+	for p.peek().text != ";" {
+		name, cdecl, fn, err := p.declarator(base)
+		if err != nil {
+			return err
+		}
+		switch {
+		case typedef:
+			if name != "" {
+				def := &ctypedef{name: name, docs: docs, cdecl: cdecl}
+				p.typedefs[name] = def
+				p.order = append(p.order, def)
+				switch cdecl.kind {
+				case 's', 'u':
+					if s := p.structs[cdecl.base]; s != nil && s.docs == "" {
+						s.docs = docs
+					}
+				case 'e':
+					if e := p.enums[cdecl.base]; e != nil && e.docs == "" {
+						e.docs = docs
+					}
+				}
+			}
+		case fn != nil && name != "":
+			if p.peek().text == "{" {
+				p.next()
+				p.skip("{", "}")
+				return nil // inline function.
+			}
+			fn.name = name
+			fn.docs = firstNonEmpty(docs, p.peek().trail)
+			p.functions = append(p.functions, *fn)
+		}
+		if p.peek().text == "," {
+			p.next()
+			continue
+		}
+		if p.peek().text != ";" {
+			p.skipDecl()
+			return nil
+		}
+	}
+	p.next()
+	return nil
+}
+
+// specifiers parses the declaration specifiers of a type.
+func (p *cparser) specifiers() (cdecl, bool, error) {
+	var (
+		t                cdecl
+		signed, unsigned bool
+		short, long      int
+		char, numeric    bool
+		named, guessed   bool
+	)
+specifiers:
+	for {
+		tok := p.peek()
+		if tok.kind != 'i' {
+			break
+		}
+		if guessed && keywords[tok.text] {
+			// the unknown identifier was a macro, ie. ZEXTERN int
+			t.base, named, guessed = "", false, false
+		}
+		switch word := tok.text; {
+		case word == "const":
+			t.konst = true
+		case ignorable[word] || p.ignore[word]:
+		case word == "__attribute__" || word == "__declspec" || word == "__asm__" || word == "asm":
+			p.next()
+			if p.peek().text == "(" {
+				p.next()
+				p.skip("(", ")")
+			}
+			continue
+		case word == "signed":
+			signed, numeric = true, true
+		case word == "unsigned":
+			unsigned, numeric = true, true
+		case word == "short":
+			short++
+			numeric = true
+		case word == "long":
+			long++
+			numeric = true
+		case word == "int":
+			numeric = true
+		case word == "char":
+			char, numeric = true, true
+		case word == "void" || word == "float" || word == "double" || word == "_Bool" || word == "bool":
+			if named {
+				return t, true, nil
+			}
+			t.base, named = word, true
+			if word == "_Bool" {
+				t.base = "bool"
+			}
+		case word == "struct" || word == "union" || word == "enum":
+			if named || numeric {
+				return t, true, nil
+			}
+			p.next()
+			if err := p.tagged(&t, word); err != nil {
+				return t, false, err
+			}
+			named = true
+			continue
+		default:
+			if named || numeric {
+				break specifiers
+			}
+			_, known := p.typedefs[word]
+			t.base, named, guessed = word, true, !known && !standard(word)
+		}
+		p.next()
+	}
+	if numeric {
+		switch {
+		case t.base == "double" && long > 0:
+			t.base = "long_double"
+		case t.base != "":
+		case char:
+			t.base = "char"
+			if signed {
+				t.base = "signed_char"
+			}
+		case short > 0:
+			t.base = "short"
+		case long == 1:
+			t.base = "long"
+		case long > 1:
+			t.base = "long_long"
+		default:
+			t.base = "int"
+		}
+		if unsigned {
+			t.base = "unsigned_" + t.base
+		}
+		named = true
+	}
+	return t, named, nil
+}
+
+// keywords that name a C type.
+var keywords = map[string]bool{
+	"signed": true, "unsigned": true, "short": true, "long": true, "int": true, "char": true,
+	"void": true, "float": true, "double": true, "_Bool": true, "bool": true,
+	"struct": true, "union": true, "enum": true,
+}
+
+// tagged parses a struct, union or enum specifier.
+func (p *cparser) tagged(t *cdecl, keyword string) error {
+	var name string
+	if tok := p.peek(); tok.kind == 'i' {
+		name = tok.text
+		p.next()
+	}
+	docs := p.tokens[p.pos-1].docs
+	if p.pos >= 2 {
+		docs = firstNonEmpty(p.tokens[p.pos-2].docs, docs)
+	}
+	if name == "" {
+		name = fmt.Sprintf("anonymous%d", len(p.structs)+len(p.enums)+1)
+	}
+	t.base = name
+	switch keyword {
+	case "enum":
+		t.kind = 'e'
+		enum := p.enums[name]
+		if enum == nil {
+			enum = &cenum{name: name, docs: docs}
+			p.enums[name] = enum
+			p.order = append(p.order, enum)
+		}
+		if p.peek().text == "{" {
+			p.next()
+			return p.enumerators(enum)
+		}
+	default:
+		t.kind = keyword[0]
+		s := p.structs[name]
+		if s == nil {
+			s = &cstruct{name: name, docs: docs, union: keyword == "union"}
+			p.structs[name] = s
+			p.order = append(p.order, s)
+		}
+		if p.peek().text == "{" {
+			p.next()
+			s.body = true
+			return p.fields(s)
+		}
+	}
+	return nil
+}
+
+func (p *cparser) enumerators(enum *cenum) error {
+	var next int64
+	for p.peek().text != "}" {
+		tok := p.next()
+		if tok.kind != 'i' {
+			return fmt.Errorf("line %d: expected enumerator name but found '%s'", tok.line, tok.text)
+		}
+		value := next
+		if p.peek().text == "=" {
+			p.next()
+			start := p.pos
+			for p.pos < len(p.tokens) && p.peek().text != "," && p.peek().text != "}" {
+				p.next()
+			}
+			var ok bool
+			if value, ok = p.evaluate(p.tokens[start:p.pos]); !ok {
+				return fmt.Errorf("line %d: unsupported value for enumerator %s", tok.line, tok.text)
+			}
+		}
+		docs := firstNonEmpty(tok.docs, p.tokens[p.pos-1].trail)
+		if p.peek().text == "," {
+			docs = firstNonEmpty(docs, p.next().trail)
+		}
+		p.values[tok.text] = value
+		enum.values = append(enum.values, cconst{name: tok.text, docs: docs, value: strconv.FormatInt(value, 10)})
+		next = value + 1
+	}
+	p.next()
+	return nil
+}
+
+func (p *cparser) fields(s *cstruct) error {
+	for p.peek().text != "}" {
+		if p.pos >= len(p.tokens) {
+			return fmt.Errorf("unterminated struct %s", s.name)
+		}
+		docs := p.peek().docs
+		base, ok, err := p.specifiers()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			p.skipDecl()
+			continue
+		}
+		for p.peek().text != ";" {
+			name, cdecl, _, err := p.declarator(base)
+			if err != nil {
+				return err
+			}
+			if p.peek().text == ":" {
+				s.bits = true
+				for p.peek().text != "," && p.peek().text != ";" {
+					p.next()
+				}
+			}
+			field := cfield{name: name, docs: docs, cdecl: cdecl}
+			if p.peek().text == "," {
+				p.next()
+			}
+			s.fields = append(s.fields, field)
+		}
+		end := p.next()
+		if len(s.fields) > 0 {
+			last := &s.fields[len(s.fields)-1]
+			last.docs = firstNonEmpty(last.docs, end.trail)
+		}
+	}
+	p.next()
+	return nil
+}
+
+// declarator parses the declarator of a type, returning the function
+// type for function declarators.
+func (p *cparser) declarator(base cdecl) (string, cdecl, *cfunc, error) {
+	var t = base
+	for p.peek().text == "*" || (p.peek().kind == 'i' && (p.peek().text == "const" || ignorable[p.peek().text] || p.ignore[p.peek().text] || p.pointer())) {
+		if p.next().text == "*" {
+			t.pointers++
+		}
+	}
+	var name string
+	if p.peek().text == "(" && p.pos+1 < len(p.tokens) && (p.tokens[p.pos+1].text == "*" || p.tokens[p.pos+1].text == "^") {
+		p.next()
+		var pointers int
+		for p.peek().text == "*" || p.peek().text == "^" || p.peek().text == "const" {
+			if p.next().text != "const" {
+				pointers++
+			}
+		}
+		if p.peek().kind == 'i' {
+			name = p.next().text
+		}
+		if err := p.expect(")"); err != nil {
+			return "", t, nil, err
+		}
+		fn, err := p.signature()
+		if err != nil {
+			return "", t, nil, err
+		}
+		fn.result = t
+		return name, cdecl{fn: fn, pointers: pointers - 1}, nil, nil
+	}
+	// identifiers before the name are assumed to be macros, ie. ZEXPORT
+	for p.peek().kind == 'i' {
+		name = p.next().text
+		if p.peek().kind != 'i' || p.wrapper() {
+			break
+		}
+	}
+	for p.peek().text == "__attribute__" || p.peek().text == "__asm__" || p.peek().text == "asm" {
+		p.next()
+		if p.peek().text == "(" {
+			p.next()
+			p.skip("(", ")")
+		}
+	}
+	switch tok := p.peek(); {
+	case tok.text == "(" || p.wrapper():
+		fn, err := p.signature()
+		if err != nil {
+			return "", t, nil, err
+		}
+		fn.result = t
+		// skip any attribute macros, ie. __THROW __nonnull ((1))
+		for p.peek().kind == 'i' {
+			p.next()
+			if p.peek().text == "(" {
+				p.next()
+				p.skip("(", ")")
+			}
+		}
+		return name, t, fn, nil
+	case tok.text == "[":
+		for p.peek().text == "[" {
+			p.next()
+			start := p.pos
+			p.skip("[", "]")
+			size := p.tokens[start : p.pos-1]
+			if t.array != "" || len(size) == 0 {
+				t.pointers++
+				continue
+			}
+			value, ok := p.evaluate(size)
+			if !ok {
+				return "", t, nil, fmt.Errorf("unsupported array length for %s", name)
+			}
+			t.array = strconv.FormatInt(value, 10)
+		}
+	}
+	return name, t, nil, nil
+}
+
+// pointer reports whether the next token is an identifier followed by a
+// pointer, so that it must be a macro, ie. void FAR *
+func (p *cparser) pointer() bool {
+	return p.peek().kind == 'i' && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "*"
+}
+
+// wrapper reports whether the next token is a macro that wraps a parameter
+// list, either because it was defined as one, or because it is followed by
+// two opening parentheses, ie. OF((int a, int b)).
+func (p *cparser) wrapper() bool {
+	tok := p.peek()
+	if tok.kind != 'i' || tok.text == "__attribute__" || tok.text == "__declspec" {
+		return false
+	}
+	return p.unwrap[tok.text] || (p.pos+2 < len(p.tokens) && p.tokens[p.pos+1].text == "(" && p.tokens[p.pos+2].text == "(")
+}
+
+// signature parses the parenthesized parameter list of a function, which
+// may be wrapped by a macro, ie. OF((int a, int b)).
+func (p *cparser) signature() (*cfunc, error) {
+	wrapped := p.wrapper()
+	if wrapped {
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	fn, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+	if wrapped {
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	return fn, nil
+}
+
+// parameters parses the parameter list of a function, after the opening
+// parenthesis.
+func (p *cparser) parameters() (*cfunc, error) {
+	var fn cfunc
+	if p.peek().text == "void" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == ")" {
+		p.pos += 2
+		return &fn, nil
+	}
+	for p.peek().text != ")" {
+		if p.pos >= len(p.tokens) {
+			return nil, fmt.Errorf("unterminated parameter list")
+		}
+		if p.peek().text == "..." {
+			p.next()
+			fn.variadic = true
+			continue
+		}
+		base, ok, err := p.specifiers()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: unexpected '%s' in parameter list", p.peek().line, p.peek().text)
+		}
+		name, t, inner, err := p.declarator(base)
+		if err != nil {
+			return nil, err
+		}
+		if inner != nil {
+			t = cdecl{fn: inner}
+		}
+		if t.array != "" {
+			t.array = ""
+			t.pointers++
+		}
+		fn.params = append(fn.params, cparam{name: name, cdecl: t})
+		if p.peek().text == "," {
+			p.next()
+		}
+	}
+	p.next()
+	return &fn, nil
+}
+
+// cgenerator writes Go source for the declarations of a C header.
+type cgenerator struct {
+	*cparser
+	taken   map[string]bool // package-level identifiers.
+	names   map[any]string  // declarations to Go names.
+	imports map[string]bool // import paths.
+}
+
+func (gen *cgenerator) unique(name string) string {
+	for gen.taken[name] {
+		name += "_"
+	}
+	gen.taken[name] = true
+	return name
+}
+
+// name assigns Go names to each type and constant, typedefs of structs and
+// enums name the underlying type.
+func (gen *cgenerator) name() {
+	for _, decl := range gen.order {
+		def, ok := decl.(*ctypedef)
+		if !ok || def.pointers > 0 || def.array != "" || def.fn != nil {
+			continue
+		}
+		var target any
+		switch def.kind {
+		case 's', 'u':
+			target = gen.structs[def.base]
+		case 'e':
+			target = gen.enums[def.base]
+		}
+		if target != nil {
+			if _, named := gen.names[target]; !named {
+				gen.names[target] = gen.unique(exportedC(gen.trimPrefix(def.name)))
+				gen.names[def] = gen.names[target]
+			}
+		}
+	}
+	for _, decl := range gen.order {
+		if _, named := gen.names[decl]; named {
+			continue
+		}
+		switch decl := decl.(type) {
+		case *ctypedef:
+			if decl.kind != 0 && decl.pointers == 0 && decl.array == "" && decl.fn == nil {
+				continue // alias of a struct or enum.
+			}
+			gen.names[decl] = gen.unique(exportedC(gen.trimPrefix(decl.name)))
+		case *cstruct:
+			gen.names[decl] = gen.unique(exportedC(gen.trimPrefix(decl.name)))
+		case *cenum:
+			if !strings.HasPrefix(decl.name, "anonymous") {
+				gen.names[decl] = gen.unique(exportedC(gen.trimPrefix(decl.name)))
+			}
+		}
+	}
+	for _, enum := range gen.enums {
+		for i := range enum.values {
+			enum.values[i].name = gen.unique(exportedConst(enum.values[i].name))
+		}
+	}
+	for i := range gen.constants {
+		gen.constants[i].name = gen.unique(exportedConst(gen.constants[i].name))
+	}
+}
+
+// resolve returns the C type after following any typedefs.
+func (gen *cgenerator) resolve(t cdecl) cdecl {
+	for i := 0; i < 32 && t.kind == 0 && t.fn == nil; i++ {
+		def, ok := gen.typedefs[t.base]
+		if !ok {
+			break
+		}
+		inner := def.cdecl
+		inner.pointers += t.pointers
+		inner.konst = inner.konst || t.konst
+		if t.array != "" {
+			inner.array = t.array
+		}
+		t = inner
+	}
+	return t
+}
+
+// standard reports whether the type name is a standard C type.
+func standard(name string) bool {
+	return name == "void" || (name != "func" && cgo.Types.LookupKind(name) != 0)
+}
+
+// posix types, that are not C standard types.
+var posix = map[string]string{
+	"ssize_t": "ptrdiff_t", "off_t": "long", "pid_t": "int", "uid_t": "unsigned_int",
+	"gid_t": "unsigned_int", "mode_t": "unsigned_int", "_Bool": "bool",
+}
+
+// base returns the Go type and tag name for a C type, without pointers.
+func (gen *cgenerator) base(t cdecl) (string, string, error) {
+	if t.fn != nil {
+		return gen.funcType(t.fn)
+	}
+	if name, ok := posix[t.base]; ok && t.kind == 0 {
+		t.base = name
+	}
+	switch t.kind {
+	case 's', 'u':
+		s := gen.structs[t.base]
+		return gen.names[s], t.base, nil
+	case 'e':
+		if name, ok := gen.names[gen.enums[t.base]]; ok {
+			return name, "int", nil
+		}
+		return "int32", "int", nil
+	}
+	if def, ok := gen.typedefs[t.base]; ok {
+		resolved := gen.resolve(cdecl{base: t.base})
+		_, tag, err := gen.base(resolved)
+		if err != nil {
+			return "", "", err
+		}
+		if resolved.pointers > 0 || resolved.array != "" {
+			goType, _, err := gen.goType(resolved, false)
+			return goType, tag, err
+		}
+		if name, ok := gen.names[def]; ok {
+			return name, tag, nil
+		}
+		goType, _, err := gen.base(resolved)
+		return goType, tag, err
+	}
+	if !standard(t.base) {
+		return "", "", fmt.Errorf("unknown type '%s'", t.base)
+	}
+	switch t.base {
+	case "void":
+		return "", "void", nil
+	case "long_double", "max_align_t":
+		return "", "", fmt.Errorf("%s cannot be represented in Go", t.base)
+	}
+	kind := cgo.Types.LookupKind(t.base)
+	return kind.String(), t.base, nil
+}
+
+// funcType returns the Go and tag representation of a function pointer.
+func (gen *cgenerator) funcType(fn *cfunc) (string, string, error) {
+	var goArgs, tagArgs []string
+	for _, param := range fn.params {
+		goType, tag, err := gen.goType(param.cdecl, false)
+		if err != nil {
+			return "", "", err
+		}
+		goArgs = append(goArgs, goType)
+		tagArgs = append(tagArgs, tag)
+	}
+	goType := "func(" + strings.Join(goArgs, ", ") + ")"
+	tag := "func(" + strings.Join(tagArgs, ",") + ")"
+	if fn.result.base != "void" || fn.result.pointers > 0 {
+		result, rtag, err := gen.goType(fn.result, true)
+		if err != nil {
+			return "", "", err
+		}
+		goType += " " + result
+		tag += rtag
+	}
+	return goType, tag, nil
+}
+
+// goType returns the Go type and tag for a parameter, result or field.
+func (gen *cgenerator) goType(t cdecl, result bool) (string, string, error) {
+	if t.fn != nil && t.pointers == 0 {
+		goType, tag, err := gen.funcType(t.fn)
+		if err != nil {
+			return "", "", err
+		}
+		if def, ok := gen.typedefOf(t.fn); ok {
+			goType = def
+		}
+		if !result {
+			tag = "&" + tag
+		}
+		return goType, tag, nil
+	}
+	resolved := gen.resolve(t)
+	if resolved.fn != nil && resolved.pointers == 0 && t.pointers == 0 {
+		goType, tag, err := gen.goType(resolved, result)
+		if err != nil {
+			return "", "", err
+		}
+		if name, ok := gen.names[gen.typedefs[t.base]]; ok {
+			goType = name
+		}
+		return goType, tag, nil
+	}
+	if t.pointers == 0 && resolved.pointers > 0 {
+		t = resolved
+	}
+	goType, tag, err := gen.base(cdecl{base: t.base, kind: t.kind})
+	if err != nil {
+		if t.pointers == 0 || t.kind != 0 {
+			return "", "", err
+		}
+		goType, tag = "", t.base // unknown types (ie. FILE) can still be pointed to.
+	}
+	if t.array != "" && t.pointers == 0 {
+		return "[" + t.array + "]" + goType, tag + "[=" + t.array + "]", nil
+	}
+	if t.pointers == 0 {
+		if tag == "void" {
+			return "", "void", nil
+		}
+		return goType, tag, nil
+	}
+	owner := "&"
+	if t.konst {
+		owner = "&#"
+	}
+	if t.pointers > 1 {
+		gen.imports["unsafe"] = true
+		return "unsafe.Pointer", owner + "void", nil
+	}
+	switch {
+	case tag == "char" && (t.konst || result):
+		return "string", owner + tag, nil
+	case (tag == "char" || tag == "unsigned_char" || tag == "uint8_t" || tag == "signed_char") && !result:
+		return "[]byte", owner + tag, nil
+	case tag == "void" || goType == "":
+		gen.imports["unsafe"] = true
+		return "unsafe.Pointer", owner + tag, nil
+	}
+	return "*" + goType, owner + tag, nil
+}
+
+// typedefOf returns the Go name of the typedef for a function pointer.
+func (gen *cgenerator) typedefOf(fn *cfunc) (string, bool) {
+	for _, decl := range gen.order {
+		if def, ok := decl.(*ctypedef); ok && def.fn == fn {
+			name, ok := gen.names[def]
+			return name, ok
+		}
+	}
+	return "", false
+}
+
+// function returns the API field for a function prototype.
+func (gen *cgenerator) function(fn cfunc) (string, error) {
+	var (
+		names    []string
+		goArgs   []string
+		tagArgs  []string
+		pointers []string
+		unnamed  bool
+	)
+	for i, param := range fn.params {
+		goType, tag, err := gen.goType(param.cdecl, false)
+		if err != nil {
+			return "", err
+		}
+		if goType == "" {
+			return "", fmt.Errorf("parameter %d is void", i+1)
+		}
+		if strings.HasPrefix(tag, "&") {
+			pointers = append(pointers, "argument "+strconv.Itoa(i+1))
+		}
+		name := param.name
+		if name == "" {
+			unnamed = true
+		}
+		if token.IsKeyword(name) {
+			name += "_"
+		}
+		names = append(names, name)
+		goArgs = append(goArgs, goType)
+		tagArgs = append(tagArgs, tag)
+	}
+	if fn.variadic {
+		format := slices.IndexFunc(tagArgs, func(tag string) bool { return tag == "&#char" })
+		if format < 0 {
+			return "", fmt.Errorf("variadic arguments without a format string")
+		}
+		names = append(names, "args")
+		goArgs = append(goArgs, "...any")
+		tagArgs = append(tagArgs, "#char...?@"+strconv.Itoa(format+1))
+	}
+	var params []string
+	for i, goType := range goArgs {
+		switch {
+		case unnamed:
+			params = append(params, goType)
+		case i+1 < len(goArgs) && goArgs[i+1] == goType && !strings.HasPrefix(goType, "..."):
+			params = append(params, names[i]) // a, b int32
+		default:
+			params = append(params, names[i]+" "+goType)
+		}
+	}
+	result, rtag, err := gen.goType(fn.result, true)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(rtag, "&") {
+		pointers = append(pointers, "result")
+	}
+	tag := fn.name + " func(" + strings.Join(tagArgs, ",") + ")"
+	if rtag != "void" {
+		tag += rtag
+	}
+	if _, _, err := ffi.ParseTag(tag); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if len(pointers) > 0 {
+		fmt.Fprintf(&b, "\t// TODO: review the ownership of %s.\n", list(pointers))
+	}
+	fmt.Fprintf(&b, "\t%s func(%s)", gen.unique(exportedC(gen.trimPrefix(fn.name))), strings.Join(params, ", "))
+	if result != "" {
+		fmt.Fprintf(&b, " %s", result)
+	}
+	fmt.Fprintf(&b, " `call:%q%s`\n", tag, documentation(fn.docs))
+	return b.String(), nil
+}
+
+// list joins the items into an English list, ie. "argument 1, argument 2
+// and result" becomes "arguments 1, 2 and the result".
+func list(items []string) string {
+	var args []string
+	for _, item := range items {
+		if n, ok := strings.CutPrefix(item, "argument "); ok {
+			args = append(args, n)
+		}
+	}
+	var words []string
+	switch len(args) {
+	case 0:
+	case 1:
+		words = append(words, "argument "+args[0])
+	default:
+		words = append(words, "arguments "+strings.Join(args[:len(args)-1], ", ")+" and "+args[len(args)-1])
+	}
+	if len(args) < len(items) {
+		words = append(words, "the result")
+	}
+	return strings.Join(words, " and ")
+}
+
+// trimPrefix removes the namespace prefix shared by all of the functions
+// (ie. 'example_') from the name.
+func (gen *cgenerator) trimPrefix(name string) string {
+	if len(gen.functions) < 2 {
+		return name
+	}
+	prefix := gen.functions[0].name
+	for _, fn := range gen.functions[1:] {
+		for !strings.HasPrefix(fn.name, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	prefix = prefix[:strings.LastIndex(prefix, "_")+1]
+	if trimmed := strings.TrimPrefix(name, prefix); trimmed != "" && !unicode.IsDigit(rune(trimmed[0])) {
+		return trimmed
+	}
+	return name
+}
+
+// declarations returns the Go declarations for each type and constant.
+func (gen *cgenerator) declarations() []string {
+	var decls []string
+	for _, decl := range gen.order {
+		var b strings.Builder
+		switch decl := decl.(type) {
+		case *ctypedef:
+			name, ok := gen.names[decl]
+			if !ok {
+				continue
+			}
+			if gen.names[gen.structs[decl.base]] == name || gen.names[gen.enums[decl.base]] == name && decl.kind != 0 {
+				continue
+			}
+			goType, _, err := gen.goType(decl.cdecl, false)
+			if decl.fn != nil {
+				goType, _, err = gen.funcType(decl.fn)
+			} else if decl.pointers == 0 && decl.array == "" {
+				goType, _, err = gen.base(decl.cdecl)
+			}
+			if err != nil || goType == "" || goType == name {
+				continue
+			}
+			writeGoComment(&b, name, decl.docs)
+			fmt.Fprintf(&b, "type %s %s\n", name, goType)
+		case *cstruct:
+			name, ok := gen.names[decl]
+			if !ok {
+				continue
+			}
+			var docs, note = decl.docs, ""
+			switch {
+			case decl.union:
+				note = "a C union"
+			case !decl.body:
+				note = "an opaque C struct"
+			case decl.bits:
+				note = "a C struct with bit fields"
+			}
+			if note != "" && docs == "" {
+				docs = "is " + note + ", so it can only be referred to by pointer."
+			} else if note != "" {
+				docs += "\n\n" + strings.ToUpper(note[:1]) + note[1:] + ", so it can only be referred to by pointer."
+			}
+			writeGoComment(&b, name, docs)
+			if decl.union || !decl.body || decl.bits {
+				fmt.Fprintf(&b, "type %s struct{ _ [0]byte }\n", name)
+				break
+			}
+			fmt.Fprintf(&b, "type %s struct {\n", name)
+			for _, field := range decl.fields {
+				goType, tag, err := gen.goType(field.cdecl, false)
+				if strings.HasPrefix(goType, "[]") || goType == "string" {
+					goType = "unsafe.Pointer"
+					gen.imports["unsafe"] = true
+				}
+				if err != nil || goType == "" {
+					goType = "unsafe.Pointer"
+					tag = "&void"
+					gen.imports["unsafe"] = true
+				}
+				fmt.Fprintf(&b, "\t%s %s `ffi:\"%s %s\"%s`\n", exportedC(field.name), goType, field.name, tag, documentation(field.docs))
+			}
+			b.WriteString("}\n")
+		case *cenum:
+			name, named := gen.names[decl]
+			writeGoComment(&b, name, decl.docs)
+			if named {
+				fmt.Fprintf(&b, "type %s int32\n\n", name)
+			}
+			if len(decl.values) == 0 {
+				break
+			}
+			b.WriteString("const (\n")
+			for _, value := range decl.values {
+				writeGoComment(&b, "\t"+value.name, value.docs)
+				if named {
+					fmt.Fprintf(&b, "\t%s %s = %s\n", value.name, name, value.value)
+				} else {
+					fmt.Fprintf(&b, "\t%s = %s\n", value.name, value.value)
+				}
+			}
+			b.WriteString(")\n")
+		}
+		if b.Len() > 0 {
+			decls = append(decls, b.String())
+		}
+	}
+	if len(gen.constants) > 0 {
+		var b strings.Builder
+		b.WriteString("const (\n")
+		for _, c := range gen.constants {
+			writeGoComment(&b, "\t"+c.name, c.docs)
+			fmt.Fprintf(&b, "\t%s = %s\n", c.name, c.value)
+		}
+		b.WriteString(")\n")
+		decls = append([]string{b.String()}, decls...)
+	}
+	return decls
+}
+
+// exportedC converts a C identifier into an exported Go identifier, ie.
+// example_point_t becomes ExamplePoint.
+func exportedC(name string) string {
+	name = strings.TrimSuffix(name, "_t")
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' }) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter(rune(s[0])) {
+		s = "X" + s
+	}
+	return s
+}
+
+// exportedConst keeps the C name of a constant, so that it remains
+// recognisable, unless it is unexported.
+func exportedConst(name string) string {
+	if unicode.IsUpper(rune(name[0])) {
+		return name
+	}
+	return exportedC(name)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// documentation returns the docs in the format of the trailing lines of
+// a runtime.link struct tag.
+func documentation(docs string) string {
+	docs = strings.TrimSpace(strings.ReplaceAll(docs, "`", "'"))
+	if docs == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(docs, "\n") {
+		b.WriteString("\n\t\t" + strings.TrimRightFunc(line, unicode.IsSpace))
+	}
+	return b.String()
+}
+
+// writeGoComment writes the description as a Go comment for the named
+// declaration.
+func writeGoComment(b *strings.Builder, name, description string) {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return
+	}
+	indent := name[:len(name)-len(strings.TrimLeft(name, "\t"))]
+	for _, line := range strings.Split(strings.TrimLeft(name, "\t")+" "+description, "\n") {
+		b.WriteString(indent + strings.TrimRightFunc("// "+line, unicode.IsSpace) + "\n")
+	}
+}
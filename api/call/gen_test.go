@@ -0,0 +1,191 @@
+package call_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"runtime.link/api/call"
+)
+
+func TestGenerate(t *testing.T) {
+	const header = `#ifndef EXAMPLE_H
+#define EXAMPLE_H
+
+#include <stddef.h>
+#include <stdint.h>
+#include <stdio.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+#define EXAMPLE_API
+#define EXAMPLE_VERSION "1.0.0"
+#define EXAMPLE_MAX_NAME 32 /* longest name */
+#define EXAMPLE_FLAGS (1u << 4 | 0x2)
+#define EXAMPLE_SQUARE(x) ((x)*(x))
+
+/* color of a point. */
+typedef enum example_color {
+	EXAMPLE_RED,    /* red */
+	EXAMPLE_GREEN = 4,
+	EXAMPLE_BLUE,
+} example_color_t;
+
+/**
+ * point on a plane.
+ */
+typedef struct example_point {
+	int32_t x, y;
+	char name[EXAMPLE_MAX_NAME]; // name of the point.
+	example_color_t color;
+} example_point_t;
+
+typedef struct example_file example_file_t;
+
+typedef int (*example_compare_fn)(const void *a, const void *b);
+
+typedef unsigned long example_size;
+
+/* returns the sum of a and b. */
+EXAMPLE_API int example_add(int a, int b);
+EXAMPLE_API const char *example_version(void);
+EXAMPLE_API size_t example_read(example_file_t *file, char *buf, size_t len);
+EXAMPLE_API void example_move(example_point_t *p, int dx, int dy);
+EXAMPLE_API example_size example_length(const example_point_t *p);
+EXAMPLE_API void example_sort(void *base, size_t n, size_t size, example_compare_fn cmp);
+EXAMPLE_API int example_printf(const char *format, ...);
+EXAMPLE_API double example_scale(double x, unsigned int factor);
+EXAMPLE_API off64_t example_seek(example_file_t *file, off64_t offset);
+EXAMPLE_API FILE *example_open(const char *path);
+
+static inline int example_twice(int x) { return x * 2; }
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
+`
+	const expected = "// Code generated by runtime.link/api/call from a C header. DO NOT EDIT.\n" + `
+package example
+
+import (
+	"unsafe"
+
+	"runtime.link/api"
+	"runtime.link/api/call"
+)
+
+// API specification for the library.
+type API struct {
+	api.Specification
+
+	darwin call.To ` + "`" + `lib:"libexample.dylib"` + "`" + `
+	linux  call.To ` + "`" + `lib:"libexample.so"` + "`" + `
+
+	Add func(a, b int32) int32 ` + "`" + `call:"example_add func(int,int)int"
+		returns the sum of a and b.` + "`" + `
+	// TODO: review the ownership of the result.
+	Version func() string ` + "`" + `call:"example_version func()&#char"` + "`" + `
+	// TODO: review the ownership of arguments 1 and 2.
+	Read func(file *File, buf []byte, len uint64) uint64 ` + "`" + `call:"example_read func(&example_file,&char,size_t)size_t"` + "`" + `
+	// TODO: review the ownership of argument 1.
+	Move func(p *Point, dx, dy int32) ` + "`" + `call:"example_move func(&example_point,int,int)"` + "`" + `
+	// TODO: review the ownership of argument 1.
+	Length func(p *Point) Size ` + "`" + `call:"example_length func(&#example_point)unsigned_long"` + "`" + `
+	// TODO: review the ownership of arguments 1 and 4.
+	Sort func(base unsafe.Pointer, n, size uint64, cmp CompareFn) ` + "`" + `call:"example_sort func(&void,size_t,size_t,&func(&#void,&#void)int)"` + "`" + `
+	// TODO: review the ownership of argument 1.
+	Printf func(format string, args ...any) int32 ` + "`" + `call:"example_printf func(&#char,#char...?@1)int"` + "`" + `
+	Scale  func(x float64, factor uint32) float64 ` + "`" + `call:"example_scale func(double,unsigned_int)double"` + "`" + `
+	// TODO: review the ownership of argument 1 and the result.
+	Open func(path string) unsafe.Pointer ` + "`" + `call:"example_open func(&#char)&FILE"` + "`" + `
+
+	// The following declarations could not be represented:
+	//  - example_seek: unknown type 'off64_t'
+}
+
+const (
+	EXAMPLE_VERSION = "1.0.0"
+	// EXAMPLE_MAX_NAME longest name
+	EXAMPLE_MAX_NAME = 32
+	EXAMPLE_FLAGS    = 18
+)
+
+// Color color of a point.
+type Color int32
+
+const (
+	// EXAMPLE_RED red
+	EXAMPLE_RED   Color = 0
+	EXAMPLE_GREEN Color = 4
+	EXAMPLE_BLUE  Color = 5
+)
+
+// Point point on a plane.
+type Point struct {
+	X    int32    ` + "`" + `ffi:"x int32_t"` + "`" + `
+	Y    int32    ` + "`" + `ffi:"y int32_t"` + "`" + `
+	Name [32]int8 ` + "`" + `ffi:"name char[=32]"
+		name of the point.` + "`" + `
+	Color Color ` + "`" + `ffi:"color int"` + "`" + `
+}
+
+// File is an opaque C struct, so it can only be referred to by pointer.
+type File struct{ _ [0]byte }
+
+type CompareFn func(unsafe.Pointer, unsafe.Pointer) int32
+
+type Size uint64
+`
+	var buf bytes.Buffer
+	if err := call.Generate(&buf, "example", []byte(header), map[string]string{
+		"linux":  "libexample.so",
+		"darwin": "libexample.dylib",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("unexpected source:\n%s", buf.String())
+	}
+}
+
+func TestGenerateCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("test builds the generated package")
+	}
+	const header = `
+extern double nextafter(double __x, double __y);
+extern double nexttoward(double __x, long double __y);
+extern long double sqrtl(long double __x);
+extern double frexp(double __x, int *__exponent);
+`
+	var buf bytes.Buffer
+	if err := call.Generate(&buf, "math", []byte(header), map[string]string{
+		"linux": "libm.so.6",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, skipped := range []string{"nexttoward: long_double", "sqrtl: long_double"} {
+		if !strings.Contains(buf.String(), skipped) {
+			t.Fatalf("expected %s to be listed as not represented:\n%s", skipped, buf.String())
+		}
+	}
+	// the package must be inside of the module, so that it can import runtime.link.
+	dir, err := os.MkdirTemp(".", "generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "math.go"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("go", "build", "./"+dir).CombinedOutput(); err != nil {
+		t.Fatalf("generated source does not compile: %v\n%s\n%s", err, out, buf.String())
+	}
+}
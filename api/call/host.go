@@ -1,11 +0,0 @@
-package call
-
-import (
-	"errors"
-
-	"runtime.link/api"
-)
-
-func host(structure api.Structure, dir string) error {
-	return errors.New("not implemented")
-}
@@ -133,6 +133,7 @@ func ValueOf(rtype reflect.Type) Value {
 		for i := 0; i < rtype.Len(); i++ {
 			values = append(values, ValueOf(rtype.Elem()))
 		}
+		return Values.Struct.As(values)
 	case reflect.Struct:
 		for i := 0; i < rtype.NumField(); i++ {
 			values = append(values, ValueOf(rtype.Field(i).Type))
@@ -198,9 +199,9 @@ func (val Value) Size() uintptr {
 		structure := Values.Struct.Get(val)
 		var size uintptr
 		for _, field := range structure {
-			size += field.Size()
+			size = alignUp(size, field.Align()) + field.Size()
 		}
-		return size
+		return alignUp(size, val.Align())
 	}
 }
 
@@ -224,7 +225,7 @@ func (val Value) Align() uintptr {
 		return unsafe.Alignof(uintptr(0))
 	default:
 		structure := Values.Struct.Get(val)
-		var align uintptr
+		var align uintptr = 1
 		for _, field := range structure {
 			align = max(align, field.Align())
 		}
@@ -232,6 +233,11 @@ func (val Value) Align() uintptr {
 	}
 }
 
+// alignUp rounds n up to the next multiple of align.
+func alignUp(n, align uintptr) uintptr {
+	return (n + align - 1) &^ (align - 1)
+}
+
 func (val Value) Pin(location Location) []cpu.Location {
 	switch val {
 	case Values.Memory:
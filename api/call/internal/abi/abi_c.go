@@ -0,0 +1,285 @@
+package abi
+
+import (
+	"errors"
+
+	"runtime.link/api/call/internal/cpu"
+	"runtime.link/xyz"
+)
+
+// class of an eightbyte, as classified by the System V ABI.
+type class uint8
+
+const (
+	classNone class = iota
+	classInteger
+	classSSE
+	classMemory
+)
+
+// SystemV is the System V AMD64 C calling convention, as used on Linux, macOS
+// and the BSDs. Integer arguments are passed in the first six integer registers
+// (RDI, RSI, RDX, RCX, R8 and R9) and floating-point arguments in the first eight
+// SSE registers, the rest are passed on the stack in left to right order. Structs
+// of up to 16 bytes are split into eightbytes, each eightbyte is passed in the
+// next register of its class, unless there aren't enough registers left for the
+// entire struct, in which case it is passed on the stack. Larger structs are passed
+// on the stack and returned through a hidden pointer, passed as the first argument.
+//
+// Results are returned in RAX and RDX or XMM0 and XMM1, the register numbers of
+// the results refer to these registers, rather than to the argument registers.
+func SystemV(fn Function) (cc CallingConvention, err error) {
+	const (
+		gprs = 6
+		fprs = 8
+	)
+	var (
+		r0, x0 cpu.Location
+		sp     uintptr
+	)
+	cc.Args = make([]Location, len(fn.Args))
+	cc.Rets = make([]Location, len(fn.Rets))
+	if len(fn.Rets) > 1 {
+		return cc, errors.New("abi: C functions cannot return multiple values")
+	}
+	if len(fn.Rets) == 1 && fn.Rets[0].Size() > 0 {
+		classes := classifySystemV(fn.Rets[0])
+		if classes[0] == classMemory {
+			cc.Rets[0] = Locations.Indirect.As(IndirectLocation{Location: register(r0)})
+			r0++
+		} else {
+			var rax, xmm0 cpu.Location
+			locations := make([]Location, len(classes))
+			for i, class := range classes {
+				if class == classSSE {
+					locations[i] = floating(xmm0)
+					xmm0++
+				} else {
+					locations[i] = register(rax)
+					rax++
+				}
+			}
+			cc.Rets[0] = multiple(locations)
+		}
+	}
+	for i, arg := range fn.Args {
+		if arg.Size() == 0 {
+			continue
+		}
+		classes := classifySystemV(arg)
+		var ints, sses cpu.Location
+		for _, class := range classes {
+			if class == classSSE {
+				sses++
+			} else {
+				ints++
+			}
+		}
+		if classes[0] == classMemory || r0+ints > gprs || x0+sses > fprs {
+			sp = alignUp(sp, max(8, arg.Align()))
+			cc.Args[i] = stack(sp)
+			sp += alignUp(arg.Size(), 8)
+			continue
+		}
+		locations := make([]Location, len(classes))
+		for j, class := range classes {
+			if class == classSSE {
+				locations[j] = floating(x0)
+				x0++
+			} else {
+				locations[j] = register(r0)
+				r0++
+			}
+		}
+		cc.Args[i] = multiple(locations)
+	}
+	return cc, nil
+}
+
+// classifySystemV returns the class of each eightbyte of the given value,
+// if the value must be passed in memory, a single [classMemory] is returned.
+func classifySystemV(val Value) []class {
+	size := val.Size()
+	if size > 16 {
+		return []class{classMemory}
+	}
+	var (
+		classes   = make([]class, (size+7)/8)
+		unaligned bool
+	)
+	val.scalars(0, func(offset uintptr, scalar Value) {
+		if scalar.Size() == 0 {
+			return
+		}
+		if offset%scalar.Align() != 0 {
+			unaligned = true
+			return
+		}
+		var eightbyte = &classes[offset/8]
+		switch {
+		case scalar == Values.Float4 || scalar == Values.Float8:
+			if *eightbyte == classNone {
+				*eightbyte = classSSE
+			}
+		default:
+			*eightbyte = classInteger
+		}
+	})
+	if unaligned {
+		return []class{classMemory}
+	}
+	return classes
+}
+
+// AAPCS64 is the Procedure Call Standard for the Arm 64-bit Architecture. Integer
+// arguments are passed in X0-X7 and floating-point arguments in V0-V7, the rest
+// are passed on the stack in left to right order. Homogeneous floating-point
+// aggregates (structs made up of one to four floats, or doubles) are passed in
+// consecutive V registers and other structs of up to 16 bytes are passed in one
+// or two X registers, unless there aren't enough registers left, in which case
+// they are passed on the stack. Larger structs are copied to memory by the caller
+// and passed by reference. Larger results are written to the memory pointed to by
+// X8 (register 8).
+//
+// Variadic arguments are classified the same way as any other argument, Apple
+// platforms deviate from this and always pass variadic arguments on the stack.
+func AAPCS64(fn Function) (cc CallingConvention, err error) {
+	const (
+		gprs = 8
+		fprs = 8
+	)
+	var (
+		ngrn, nsrn cpu.Location
+		nsaa       uintptr
+	)
+	cc.Args = make([]Location, len(fn.Args))
+	cc.Rets = make([]Location, len(fn.Rets))
+	if len(fn.Rets) > 1 {
+		return cc, errors.New("abi: C functions cannot return multiple values")
+	}
+	stacked := func(val Value) Location {
+		nsaa = alignUp(nsaa, max(8, val.Align()))
+		loc := stack(nsaa)
+		nsaa += alignUp(val.Size(), 8)
+		return loc
+	}
+	if len(fn.Rets) == 1 && fn.Rets[0].Size() > 0 {
+		ret := fn.Rets[0]
+		if members, ok := homogeneous(ret); ok {
+			locations := make([]Location, members)
+			for i := range locations {
+				locations[i] = floating(cpu.Location(i))
+			}
+			cc.Rets[0] = multiple(locations)
+		} else if ret.Size() > 16 {
+			cc.Rets[0] = Locations.Indirect.As(IndirectLocation{Location: register(8)})
+		} else {
+			locations := make([]Location, (ret.Size()+7)/8)
+			for i := range locations {
+				locations[i] = register(cpu.Location(i))
+			}
+			cc.Rets[0] = multiple(locations)
+		}
+	}
+	for i, arg := range fn.Args {
+		if arg.Size() == 0 {
+			continue
+		}
+		if members, ok := homogeneous(arg); ok {
+			if nsrn+cpu.Location(members) > fprs {
+				nsrn = fprs
+				cc.Args[i] = stacked(arg)
+				continue
+			}
+			locations := make([]Location, members)
+			for j := range locations {
+				locations[j] = floating(nsrn)
+				nsrn++
+			}
+			cc.Args[i] = multiple(locations)
+			continue
+		}
+		if arg.Size() > 16 {
+			var pointer Location
+			if ngrn < gprs {
+				pointer = register(ngrn)
+				ngrn++
+			} else {
+				pointer = stacked(Values.Memory)
+			}
+			cc.Args[i] = Locations.Indirect.As(IndirectLocation{Location: pointer})
+			continue
+		}
+		words := cpu.Location((arg.Size() + 7) / 8)
+		if ngrn+words > gprs {
+			ngrn = gprs
+			cc.Args[i] = stacked(arg)
+			continue
+		}
+		locations := make([]Location, words)
+		for j := range locations {
+			locations[j] = register(ngrn)
+			ngrn++
+		}
+		cc.Args[i] = multiple(locations)
+	}
+	return cc, nil
+}
+
+// homogeneous reports whether the value is a floating-point value or a
+// homogeneous floating-point aggregate, along with the number of members.
+func homogeneous(val Value) (int, bool) {
+	var (
+		members int
+		element Value
+		mixed   bool
+	)
+	val.scalars(0, func(offset uintptr, scalar Value) {
+		if scalar.Size() == 0 {
+			return
+		}
+		if members == 0 {
+			element = scalar
+		}
+		if scalar != element || (scalar != Values.Float4 && scalar != Values.Float8) {
+			mixed = true
+		}
+		members++
+	})
+	return members, !mixed && members > 0 && members <= 4
+}
+
+// scalars calls fn with each non-struct value inside of val, along with
+// its offset.
+func (val Value) scalars(offset uintptr, fn func(offset uintptr, scalar Value)) {
+	if xyz.ValueOf(val) != Values.Struct {
+		fn(offset, val)
+		return
+	}
+	for _, field := range Values.Struct.Get(val) {
+		offset = alignUp(offset, field.Align())
+		field.scalars(offset, fn)
+		offset += field.Size()
+	}
+}
+
+func register(n cpu.Location) Location {
+	return Locations.Hardware.As(HardwareLocations.Register.As(n))
+}
+
+func floating(n cpu.Location) Location {
+	return Locations.Hardware.As(HardwareLocations.Floating.As(n))
+}
+
+func stack(offset uintptr) Location {
+	return Locations.Hardware.As(HardwareLocations.StackLtr.As(offset))
+}
+
+// multiple returns a single location as is, otherwise the locations are
+// wrapped in a [Locations.Multiple].
+func multiple(locations []Location) Location {
+	if len(locations) == 1 {
+		return locations[0]
+	}
+	return Locations.Multiple.As(locations)
+}
@@ -1,9 +1,13 @@
 package abi_test
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"runtime.link/api/call/internal/abi"
+	"runtime.link/xyz"
 )
 
 func TestVariant(t *testing.T) {
@@ -22,3 +26,78 @@ func TestOperationToString(t *testing.T) {
 		}
 	}
 }
+
+// describe returns a readable representation of a C calling convention location.
+func describe(loc abi.Location) string {
+	switch xyz.ValueOf(loc) {
+	case abi.Locations.Hardware:
+		hw := abi.Locations.Hardware.Get(loc)
+		switch xyz.ValueOf(hw) {
+		case abi.HardwareLocations.Register:
+			return fmt.Sprintf("R%d", abi.HardwareLocations.Register.Get(hw))
+		case abi.HardwareLocations.Floating:
+			return fmt.Sprintf("X%d", abi.HardwareLocations.Floating.Get(hw))
+		case abi.HardwareLocations.StackLtr:
+			return fmt.Sprintf("SP+%d", abi.HardwareLocations.StackLtr.Get(hw))
+		}
+	case abi.Locations.Indirect:
+		return "&" + describe(abi.Locations.Indirect.Get(loc).Location)
+	case abi.Locations.Multiple:
+		var parts []string
+		for _, part := range abi.Locations.Multiple.Get(loc) {
+			parts = append(parts, describe(part))
+		}
+		return "{" + strings.Join(parts, " ") + "}"
+	}
+	return "?"
+}
+
+type (
+	point struct{ X, Y float32 }
+	vec4  struct{ X, Y, Z, W float64 }
+	mixed struct {
+		A float64
+		B int32
+	}
+	div    struct{ Quot, Rem int32 }
+	triple struct{ A, B, C int64 }
+	padded struct {
+		A int8
+		B int64
+	}
+)
+
+func TestCallingConventions(t *testing.T) {
+	var tests = []struct {
+		name string
+		conv abi.Type
+		fn   any
+		args []string
+		rets []string
+	}{
+		{"SystemV", abi.SystemV, func(point, mixed, float32) div { return div{} }, []string{"X0", "{X1 R0}", "X2"}, []string{"R0"}},
+		{"SystemV", abi.SystemV, func(int64, triple) triple { return triple{} }, []string{"R1", "SP+0"}, []string{"&R0"}},
+		{"SystemV", abi.SystemV, func(vec4, padded, mixed) point { return point{} }, []string{"SP+0", "{R0 R1}", "{X0 R2}"}, []string{"X0"}},
+		{"SystemV", abi.SystemV, func(int64, int64, int64, int64, int64, padded, int64) mixed { return mixed{} }, []string{"R0", "R1", "R2", "R3", "R4", "SP+0", "R5"}, []string{"{X0 R0}"}},
+		{"AAPCS64", abi.AAPCS64, func(point, mixed, float32) div { return div{} }, []string{"{X0 X1}", "{R0 R1}", "X2"}, []string{"R0"}},
+		{"AAPCS64", abi.AAPCS64, func(int64, triple) triple { return triple{} }, []string{"R0", "&R1"}, []string{"&R8"}},
+		{"AAPCS64", abi.AAPCS64, func(vec4, vec4, point, float64) vec4 { return vec4{} }, []string{"{X0 X1 X2 X3}", "{X4 X5 X6 X7}", "SP+0", "SP+8"}, []string{"{X0 X1 X2 X3}"}},
+		{"AAPCS64", abi.AAPCS64, func(int64, int64, int64, int64, int64, int64, int64, padded, int64) {}, []string{"R0", "R1", "R2", "R3", "R4", "R5", "R6", "SP+0", "SP+16"}, nil},
+	}
+	for _, test := range tests {
+		cc, err := test.conv(abi.FunctionOf(reflect.TypeOf(test.fn)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range test.args {
+			if got := describe(cc.Args[i]); got != want {
+				t.Errorf("%s %T argument %d: expected %s, got %s", test.name, test.fn, i, want, got)
+			}
+		}
+		for i, want := range test.rets {
+			if got := describe(cc.Rets[i]); got != want {
+				t.Errorf("%s %T result %d: expected %s, got %s", test.name, test.fn, i, want, got)
+			}
+		}
+	}
+}
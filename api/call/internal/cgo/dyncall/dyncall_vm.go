@@ -18,12 +18,31 @@ DCCallback *goNewCallback(const DCsigchar * signature, uintptr_t userdata) {
 typedef struct {
 	DCsigchar vtype;
 	DCValue value;
+	DCaggr *aggr;
 } GoArg;
 
-void goArgs(DCCallVM *vm, GoArg *arg, int argc) {
+void goAggrField(DCaggr *ag, DCsigchar type, DCint offset, DCsize array_len, DCaggr *sub) {
+	if (type == DC_SIGCHAR_AGGREGATE) {
+		dcAggrField(ag, type, offset, array_len, sub);
+	} else {
+		dcAggrField(ag, type, offset, array_len);
+	}
+}
+
+// goArgs pushes the arguments onto the VM, if fixed is not negative, the
+// function is variadic and the arguments from index fixed onwards are the
+// variadic ones. If ret is not NULL, the function returns an aggregate.
+void goArgs(DCCallVM *vm, GoArg *arg, int argc, int fixed, DCaggr *ret) {
 	dcReset(vm);
+	dcMode(vm, fixed < 0 ? DC_CALL_C_DEFAULT : DC_CALL_C_ELLIPSIS);
+	if (ret != NULL) {
+		dcBeginCallAggr(vm, ret);
+	}
 	DCValue value;
 	for (int i = 0; i < argc; i++) {
+		if (i == fixed) {
+			dcMode(vm, DC_CALL_C_ELLIPSIS_VARARGS);
+		}
 		value = arg[i].value;
 		switch (arg[i].vtype) {
 		case DC_SIGCHAR_BOOL:
@@ -72,17 +91,22 @@ void goArgs(DCCallVM *vm, GoArg *arg, int argc) {
 			dcArgPointer(vm, value.p);
 			break;
 		case DC_SIGCHAR_AGGREGATE:
-			assert(0); // FIXME
+			dcArgAggr(vm, arg[i].aggr, value.p);
 			break;
 		}
 	}
 }
 
-double goCallDouble(DCCallVM *vm, DCpointer funcptr, GoArg *arg, int argc) {
-	goArgs(vm, arg, argc);
+double goCallDouble(DCCallVM *vm, DCpointer funcptr, GoArg *arg, int argc, int fixed) {
+	goArgs(vm, arg, argc, fixed, NULL);
 	return dcCallDouble(vm, funcptr);
 }
 
+void goCallAggr(DCCallVM *vm, DCpointer funcptr, GoArg *arg, int argc, int fixed, DCaggr *ag, DCpointer ret) {
+	goArgs(vm, arg, argc, fixed, ag);
+	dcCallAggr(vm, funcptr, ag, ret);
+}
+
 */
 import "C"
 import (
@@ -105,32 +129,78 @@ func (callback *Callback) Free() {
 	C.dcbFreeCallback((*C.DCCallback)(callback))
 }
 
+// Struct describes the memory layout of a C struct, so that it can be passed
+// or returned by value.
+type Struct C.DCaggr
+
+// NewStruct returns a new [Struct] of the given size, with room for the given
+// number of fields. [Struct.Close] must be called after adding the fields.
+func NewStruct(fields int, size uintptr) *Struct {
+	return (*Struct)(C.dcNewAggr(C.DCsize(fields), C.DCsize(size)))
+}
+
+// Field adds a field of the given signature kind at the given offset, count
+// is the length of the field when it is an array (otherwise 1). The nested
+// [Struct] is required for [Aggregate] fields.
+func (s *Struct) Field(kind rune, offset uintptr, count int, nested *Struct) {
+	C.goAggrField((*C.DCaggr)(s), C.DCsigchar(kind), C.DCint(offset), C.DCsize(count), (*C.DCaggr)(nested))
+}
+
+// Close completes the [Struct], after all fields have been added.
+func (s *Struct) Close() {
+	C.dcCloseAggr((*C.DCaggr)(s))
+}
+
+func (s *Struct) Free() {
+	C.dcFreeAggr((*C.DCaggr)(s))
+}
+
 type VM struct {
 	ptr *C.DCCallVM
 	buf []C.GoArg
+
+	fixed int              // number of fixed arguments of a variadic call, or -1.
+	keep  []unsafe.Pointer // keep struct values alive for the call.
 }
 
 func NewVM(size int) *VM {
 	return &VM{
-		ptr: C.dcNewCallVM(C.size_t(size)),
-		buf: make([]C.GoArg, 0),
+		ptr:   C.dcNewCallVM(C.size_t(size)),
+		buf:   make([]C.GoArg, 0),
+		fixed: -1,
 	}
 }
 
 func (vm *VM) Reset() {
 	vm.buf = vm.buf[:0]
+	vm.keep = vm.keep[:0]
+	vm.fixed = -1
+}
+
+// Variadic marks the call as a C variadic call, any arguments pushed after
+// this are passed as variadic arguments.
+func (vm *VM) Variadic() {
+	vm.fixed = len(vm.buf)
 }
 
 func (vm *VM) Free() {
 	C.dcFree((*C.DCCallVM)(vm.ptr))
 }
 
+// valueOf returns a DCValue holding the given value. cgo represents the
+// union as a byte array, so the value is copied into it, rather than
+// written through a (misaligned) pointer conversion.
+func valueOf[T any](value T) (val C.DCValue) {
+	copy(val[:], unsafe.Slice((*byte)(unsafe.Pointer(&value)), unsafe.Sizeof(value)))
+	return val
+}
+
 func (vm *VM) PushBool(value bool) {
 	var val C.DCValue
 	if value {
-		*(*C.DCbool)(unsafe.Pointer(&val)) = C.DCbool(1)
+		val = valueOf(C.DCbool(1))
 	} else {
-		*(*C.DCbool)(unsafe.Pointer(&val)) = C.DCbool(0)
+		val = valueOf(C.DCbool(0))
 	}
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_BOOL,
@@ -139,8 +209,7 @@ func (vm *VM) PushBool(value bool) {
 }
 
 func (vm *VM) PushChar(value int8) {
-	var val C.DCValue
-	*(*C.DCchar)(unsafe.Pointer(&val)) = C.DCchar(value)
+	val := valueOf(C.DCchar(value))
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_CHAR,
 		value: val,
@@ -148,8 +217,7 @@ func (vm *VM) PushChar(value int8) {
 }
 
 func (vm *VM) PushShort(value int16) {
-	var val C.DCValue
-	*(*C.DCshort)(unsafe.Pointer(&val)) = C.DCshort(value)
+	val := valueOf(C.DCshort(value))
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_SHORT,
 		value: val,
@@ -157,8 +225,7 @@ func (vm *VM) PushShort(value int16) {
 }
 
 func (vm *VM) PushSignedInt(value int32) {
-	var val C.DCValue
-	*(*C.DCint)(unsafe.Pointer(&val)) = C.DCint(value)
+	val := valueOf(C.DCint(value))
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_INT,
 		value: val,
@@ -166,8 +233,7 @@ func (vm *VM) PushSignedInt(value int32) {
 }
 
 func (vm *VM) PushSignedLong(value int) {
-	var val C.DCValue
-	*(*C.DClong)(unsafe.Pointer(&val)) = C.DClong(value)
+	val := valueOf(C.DClong(value))
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_LONG,
 		value: val,
@@ -175,8 +241,7 @@ func (vm *VM) PushSignedLong(value int) {
 }
 
 func (vm *VM) PushSignedLongLong(value int64) {
-	var val C.DCValue
-	*(*C.DClonglong)(unsafe.Pointer(&val)) = C.DClonglong(value)
+	val := valueOf(C.DClonglong(value))
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_LONGLONG,
 		value: val,
@@ -184,8 +249,7 @@ func (vm *VM) PushSignedLongLong(value int64) {
 }
 
 func (vm *VM) PushFloat(value float32) {
-	var val C.DCValue
-	*(*C.DCfloat)(unsafe.Pointer(&val)) = C.DCfloat(value)
+	val := valueOf(C.DCfloat(value))
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_FLOAT,
 		value: val,
@@ -193,8 +257,7 @@ func (vm *VM) PushFloat(value float32) {
 }
 
 func (vm *VM) PushDouble(value float64) {
-	var val C.DCValue
-	*(*C.DCdouble)(unsafe.Pointer(&val)) = C.DCdouble(value)
+	val := valueOf(C.DCdouble(value))
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_DOUBLE,
 		value: val,
@@ -202,59 +265,88 @@ func (vm *VM) PushDouble(value float64) {
 }
 
 func (vm *VM) PushPointer(value unsafe.Pointer) {
-	var val C.DCValue
-	*(*C.DCpointer)(unsafe.Pointer(&val)) = C.DCpointer(value)
+	val := valueOf(C.DCpointer(value))
+	vm.keep = append(vm.keep, value)
 	vm.buf = append(vm.buf, C.GoArg{
 		vtype: C.DC_SIGCHAR_POINTER,
 		value: val,
 	})
 }
 
+// PushString pushes a null-terminated copy of the given string.
+func (vm *VM) PushString(value string) {
+	var str = unsafe.Pointer(unsafe.StringData(value + "\x00"))
+	val := valueOf(C.DCpointer(str))
+	vm.keep = append(vm.keep, str)
+	vm.buf = append(vm.buf, C.GoArg{
+		vtype: C.DC_SIGCHAR_STRING,
+		value: val,
+	})
+}
+
+// PushStruct pushes the struct value pointed to by value, which must be laid
+// out as described by layout and padded to a multiple of 8 bytes.
+func (vm *VM) PushStruct(layout *Struct, value unsafe.Pointer) {
+	val := valueOf(C.DCpointer(value))
+	vm.keep = append(vm.keep, value)
+	vm.buf = append(vm.buf, C.GoArg{
+		vtype: C.DC_SIGCHAR_AGGREGATE,
+		value: val,
+		aggr:  (*C.DCaggr)(layout),
+	})
+}
+
+// CallStruct calls the function at the given address, which returns a struct
+// laid out as described by layout, the result is written to ret.
+func (vm *VM) CallStruct(address unsafe.Pointer, layout *Struct, ret unsafe.Pointer) {
+	C.goCallAggr((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address)), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), (*C.DCaggr)(layout), C.DCpointer(ret))
+}
+
 func (vm *VM) Call(address unsafe.Pointer) {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	C.dcCallVoid((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address)))
 }
 
 func (vm *VM) CallBool(address unsafe.Pointer) bool {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	return C.dcCallBool((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address))) != 0
 }
 
 func (vm *VM) CallChar(address unsafe.Pointer) int8 {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	return int8(C.dcCallChar((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address))))
 }
 
 func (vm *VM) CallShort(address unsafe.Pointer) int16 {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	return int16(C.dcCallShort((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address))))
 }
 
 func (vm *VM) CallInt(address unsafe.Pointer) int32 {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	return int32(C.dcCallInt((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address))))
 }
 
 func (vm *VM) CallLong(address unsafe.Pointer) int {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	return int(C.dcCallLong((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address))))
 }
 
 func (vm *VM) CallLongLong(address unsafe.Pointer) int64 {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	return int64(C.dcCallLongLong((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address))))
 }
 
 func (vm *VM) CallFloat(address unsafe.Pointer) float32 {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	return float32(C.dcCallFloat((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address))))
 }
 
 func (vm *VM) CallDouble(address unsafe.Pointer) float64 {
-	return float64(C.goCallDouble((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address)), unsafe.SliceData(vm.buf), C.int(len(vm.buf))))
+	return float64(C.goCallDouble((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address)), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed)))
 }
 
 func (vm *VM) CallPointer(address unsafe.Pointer) unsafe.Pointer {
-	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)))
+	C.goArgs((*C.DCCallVM)(vm.ptr), unsafe.SliceData(vm.buf), C.int(len(vm.buf)), C.int(vm.fixed), nil)
 	return unsafe.Pointer(C.dcCallPointer((*C.DCCallVM)(vm.ptr), (C.DCpointer)(unsafe.Pointer(address))))
 }
@@ -322,15 +322,19 @@ func parseType(tag string, scan *scanner.Scanner, pos int) (Type, error) {
 	}
 	tok = scan.Scan()
 	//tokPos := pos + scan.Pos().Column
+	var orEqual bool
+	if (tok == '>' || tok == '<') && scan.Peek() == '=' {
+		orEqual = true
+		scan.Scan()
+	}
 	arg, err := argument(tag, scan, pos)
 	if err != nil {
 		return stype, xray.New(err)
 	}
 	switch tok {
 	case '>', '<':
-		if scan.Peek() == '=' {
+		if orEqual {
 			stype.Test.Equality = arg
-			scan.Scan()
 		}
 		if tok == '>' {
 			stype.Test.MoreThan = arg
@@ -68,3 +68,21 @@ func TestTagParsing(t *testing.T) {
 		t.Fatal("expected function to call 'ferror' with argument 4")
 	}
 }
+
+func TestComparisonTag(t *testing.T) {
+	const tag = `memset func(&void[>=@3],int<=255,size_t)&void>0`
+
+	_, ctype, err := ffi.ParseTag(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test := ctype.Args[0].Test; !test.Capacity || !test.MoreThan.Check || !test.Equality.Check || test.MoreThan.Index != 3 {
+		t.Fatal("expected 1st argument to have a capacity of at least the 3rd argument")
+	}
+	if test := ctype.Args[1].Test; !test.LessThan.Check || !test.Equality.Check || test.LessThan.Value != 255 {
+		t.Fatal("expected 2nd argument to be at most 255")
+	}
+	if test := ctype.Func.Test; !test.MoreThan.Check || test.Equality.Check || test.MoreThan.Value != 0 {
+		t.Fatal("expected return value to be more than 0")
+	}
+}
@@ -207,6 +207,30 @@ func (asm Assembly) StringLen(s Value) Value {
 	return Value{}
 }
 
+// Unpack returns each element of the given slice, interface elements are
+// replaced with their underlying value (nil interfaces with a nil pointer).
+func (asm Assembly) Unpack(s Value) []Value {
+	if asm.direct {
+		if s.direct.Kind() != reflect.Slice {
+			panic("expected slice, got" + s.direct.Kind().String())
+		}
+		values := make([]Value, s.direct.Len())
+		for i := range values {
+			elem := s.direct.Index(i)
+			if elem.Kind() == reflect.Interface {
+				if elem.IsNil() {
+					elem = reflect.ValueOf(unsafe.Pointer(nil))
+				} else {
+					elem = elem.Elem()
+				}
+			}
+			values[i] = Value{direct: elem}
+		}
+		return values
+	}
+	return nil
+}
+
 func (asm Assembly) Go(val Value, fn func(pointer unsafe.Pointer) reflect.Value) Value {
 	if asm.direct {
 		return Value{direct: fn(val.direct.UnsafePointer())}
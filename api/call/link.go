@@ -18,6 +18,11 @@ import (
 // Options
 type Options struct {
 	LookupSymbol func(string) (unsafe.Pointer, error)
+
+	// Checked validates the safety assertions of each call tag, before
+	// and after each call, violations (and results that meet the error
+	// condition) are reported as a [SafetyError].
+	Checked bool
 }
 
 func Make[T any](jump unsafe.Pointer, tag string) (T, error) {
@@ -104,6 +109,9 @@ func link(opts Options, structure api.Structure, tables []dll.SymbolTable) {
 			fn.MakeError(err)
 			continue
 		}
+		if opts.Checked {
+			compiled = checked(finalName, stype, fn.Type, compiled)
+		}
 		fn.Make(compiled)
 	}
 	for _, structure := range structure.Namespace {
@@ -158,15 +166,18 @@ func compile(name string, symbol unsafe.Pointer, abi jit.ABI, goType reflect.Typ
 	return jit.MakeFunc(goType, func(asm jit.Assembly, args []jit.Value) ([]jit.Value, error) {
 		//var pinner = asm.Pinner()
 		//defer pinner.Unpin()
-		var send = make([]jit.Value, len(ldType.Args))
+		var (
+			send   = make([]jit.Value, 0, len(ldType.Args))
+			caller = abi
+		)
 		for i, arg := range ldType.Args {
 			into := cgo.Types.LookupKind(arg.Name)
 			if arg.Free == '-' {
-				var err error
-				send[i], err = inferValue(asm, args, arg, into, goType)
+				value, err := inferValue(asm, args, arg, into, goType)
 				if err != nil {
 					return nil, fmt.Errorf("runtime.link/api/call unable to infer argument %d (%s): %w", i, arg.Name, err)
 				}
+				send = append(send, value)
 				continue
 			}
 			if arg.Maps-1 >= goType.NumIn() {
@@ -176,51 +187,63 @@ func compile(name string, symbol unsafe.Pointer, abi jit.ABI, goType reflect.Typ
 				from  = goType.In(arg.Maps - 1)
 				value = args[arg.Maps-1]
 			)
-			if from.Kind() == into {
-				send[i] = value
-			} else {
-				if from.ConvertibleTo(normal(into)) {
-					send[i] = asm.Convert(value, normal(into))
-					continue
-				}
-				if from.Kind() == reflect.String && arg.Name == "char" && arg.Free == '&' {
-					s := asm.NullTerminated(value)
-					//pinner.Pin(s)
-					send[i] = s.UnsafePointer()
-					continue
-				}
-				if from.Kind() == reflect.Slice && (arg.Name == "void" || arg.Name == "char") && arg.Free == '&' {
-					//pinner.Pin(value.UnsafePointer())
-					send[i] = value.UnsafePointer()
-					continue
-				}
-				if from.Kind() == reflect.UnsafePointer || from.Kind() == reflect.Ptr {
-					//pinner.Pin(value.UnsafePointer())
-					send[i] = value.UnsafePointer()
-					continue
-				}
-				if from.Kind() == reflect.Uintptr {
-					send[i] = value
-					continue
+			if arg.More {
+				p, ok := abi.(platform)
+				if !ok || from.Kind() != reflect.Slice {
+					return nil, fmt.Errorf("runtime.link/api/call does not support variadic '%s' arguments", from)
 				}
+				p.variadic, p.fixed = true, len(send)
+				caller = p
+				send = append(send, asm.Unpack(value)...)
+				continue
+			}
+			if from.Kind() == into {
+				send = append(send, value)
+				continue
+			}
+			switch {
+			case from.Kind() == reflect.Struct || from.Kind() == reflect.Array:
+				send = append(send, value) // passed by value.
+			case normal(into) != nil && from.ConvertibleTo(normal(into)):
+				send = append(send, asm.Convert(value, normal(into)))
+			case from.Kind() == reflect.String && arg.Name == "char" && arg.Free == '&':
+				s := asm.NullTerminated(value)
+				//pinner.Pin(s)
+				send = append(send, s.UnsafePointer())
+			case from.Kind() == reflect.Slice && (arg.Name == "void" || arg.Name == "char") && arg.Free == '&':
+				//pinner.Pin(value.UnsafePointer())
+				send = append(send, value.UnsafePointer())
+			case from.Kind() == reflect.UnsafePointer || from.Kind() == reflect.Ptr:
+				//pinner.Pin(value.UnsafePointer())
+				send = append(send, value.UnsafePointer())
+			case from.Kind() == reflect.Uintptr:
+				send = append(send, value)
+			default:
 				return nil, fmt.Errorf("runtime.link/api/call does not support '%s' arguments", from.Kind())
 			}
 		}
 		var kind reflect.Type
 		if ldType.Func != nil {
-			if ldType.Func.Name == "func" || ldType.Func.Name == "void" {
+			if ldType.Func.Name == "func" || ldType.Func.Name == "void" || goType.Out(0).Kind() == reflect.Struct {
 				kind = goType.Out(0)
 			} else {
 				kind = normal(cgo.Types.LookupKind(ldType.Func.Name))
 			}
 		}
-		call, _, err := asm.UnsafeCall(abi, symbol, send, kind)
+		call, _, err := asm.UnsafeCall(caller, symbol, send, kind)
 		if err != nil {
 			return nil, err
 		}
 		rets := make([]jit.Value, goType.NumOut())
+		if n := goType.NumOut(); n > 1 && goType.Out(n-1) == reflect.TypeOf((*error)(nil)).Elem() {
+			rets[n-1] = asm.NewError()
+		}
 		if ldType.Func != nil {
 			into := goType.Out(0)
+			if into.Kind() == reflect.Struct {
+				rets[0] = call[0]
+				return rets, nil
+			}
 			if into.Kind() == reflect.Func {
 				rets[0] = asm.Go(call[0].UnsafePointer(), func(value unsafe.Pointer) reflect.Value {
 					fn, err := compile(name, value, abi, into, *ldType.Func)
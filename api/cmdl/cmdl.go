@@ -25,9 +25,76 @@ additionally specify one of the subsequent flags:
   - 'invert'
     bool (and the behaviour of omitempty).
 
+  - 'env=NAME'
+    environment variable to read, when the flag is missing.
+
+  - 'default=value'
+    value to use, when the flag is missing.
+
+  - 'required'
+    the flag must be provided.
+
 The documentation of a field tag will be used for the help text. If a
 field is a [io.Reader] it will be passed to stdin, [io.Writer] will be
 passed to stdout by default unless the field is tagged with `cmdl:",stderr"`.
+
+# Linking
+
+When a program is linked with [API], the [*os/exec.Cmd] passed to
+[api.Import] (if any) is used as a template for each command, such that
+its Path is used when no program is given, its Args (after the first) are
+placed before the arguments of each function and its Env, Dir, SysProcAttr,
+ExtraFiles, WaitDelay and standard streams are applied to every command.
+
+[io.Reader] arguments are passed to stdin. Functions that return an
+[io.Reader] receive stdout once the command has finished. Functions that
+return an [io.ReadCloser] stream stdout whilst the command is running,
+after the last read, any failure of the command is returned and Close
+stops the command. An [iter.Seq2] of strings and errors yields each line
+of stdout and then any failure, the command is only started when iterated
+and is stopped when the loop breaks. An [iter.Seq] of strings behaves the
+same way, except that failures are lost, so use an [iter.Seq2] when they
+matter.
+
+Failures are mapped to registered [api.Error] scenarios with a cmdl tag,
+either 'exit=N' to match the exit code and/or 'stderr=regexp' to match
+stderr, such that
+
+	type Error api.Error[struct {
+		NotFound xyz.Case[Error, error] `cmdl:"exit=128,stderr=not a git repository"`
+	}]
+
+otherwise the error is the text written to stderr.
+
+# Flags
+
+When a program is run with [Main], the flags of each struct argument may
+appear anywhere after the command (and before a '--' argument), a field tagged `cmdl:"--name=%v"`
+or `cmdl:"--name %v"` accepts both '--name=value' and '--name value' and a
+single letter flag like `cmdl:"-n %v"` also accepts '-nvalue'. Bool flags
+do not need a value and can be cleared with '--no-name'. Repeated flags are
+appended to slices, map flags accept 'key=value' and [time.Duration] flags
+accept values like '1m30s'. Fields tagged with 'env' are read from the
+environment.
+
+# Help
+
+[Main] writes help text when it is run without any arguments, with 'help'
+or when the last argument is '--help' (or '-h'). The help text lists each
+command along with the first line of its documentation and 'help <command>'
+describes the options of a command, using the documentation of each field.
+Nested structures are reached through their cmdl tag (or else their lower
+case name), such that `cmdl:"remote"` on a nested structure with a function
+tagged `cmdl:"add %v"` is run with 'remote add origin'. Unknown commands
+result in an error that suggests the most similar command.
+
+# Completion
+
+[Completion] generates bash, zsh and fish completion scripts for a program
+and [Manual] generates a man page for it. Literal words in the cmdl tags
+of each function are completed as commands, fields of struct arguments as
+options ('dir' fields complete directories and 'env' fields are documented
+as environment variables) and [io/fs.File] arguments complete file paths.
 */
 package cmdl
 
@@ -44,6 +111,6 @@ type linker struct{}
 
 // Link implements the [api.Linker] interface.
 func (linker) Link(structure api.Structure, cmd string, client *exec.Cmd) error {
-	linkStructure(structure, cmd, "")
+	linkStructure(structure, structure, cmd, "", client)
 	return nil
 }
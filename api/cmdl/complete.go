@@ -0,0 +1,345 @@
+package cmdl
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"slices"
+	"strings"
+
+	"runtime.link/api"
+)
+
+// Shell that a completion script can be generated for.
+type Shell string
+
+// Shells supported by [Completion].
+const (
+	Bash Shell = "bash"
+	Zsh  Shell = "zsh"
+	Fish Shell = "fish"
+)
+
+// Completion writes a completion script for the given shell, such that the
+// commands, options and file arguments of the program (as documented by its
+// cmdl tags) can be completed when typing the named command.
+//
+//	cmdl.Completion(os.Stdout, cmdl.Bash, "example", &program)
+func Completion(w io.Writer, shell Shell, name string, program any) error {
+	completions := completionsOf(commandsOf(api.StructureOf(program), ""))
+	switch shell {
+	case Bash:
+		return bashCompletion(w, name, completions)
+	case Zsh:
+		return zshCompletion(w, name, completions)
+	case Fish:
+		return fishCompletion(w, name, completions)
+	default:
+		return fmt.Errorf("cmdl: unsupported shell %q", shell)
+	}
+}
+
+// completion of the arguments that follow a sequence of literal words.
+type completion struct {
+	Words []string     // literal words leading up to the completion.
+	Next  []completion // next words (with docs) for a group of commands.
+	Docs  string       // first line of documentation.
+
+	Options []option
+	Files   bool // complete file paths for arguments.
+	Command bool // words complete a command, rather than a group.
+}
+
+// completionsOf returns the completions for each group of commands along
+// with each command, longest first.
+func completionsOf(commands []command) []completion {
+	var (
+		groups      = make(map[string]*completion)
+		completions []completion
+	)
+	group := func(words []string) *completion {
+		key := strings.Join(words, " ")
+		if existing, ok := groups[key]; ok {
+			return existing
+		}
+		groups[key] = &completion{Words: words}
+		return groups[key]
+	}
+	group(nil)
+	for _, cmd := range commands {
+		words := cmd.words()
+		for i := range words {
+			parent := group(words[:i])
+			if !slices.ContainsFunc(parent.Next, func(c completion) bool { return c.Words[len(c.Words)-1] == words[i] }) {
+				next := completion{Words: words[:i+1]}
+				if i == len(words)-1 {
+					next.Docs, _, _ = strings.Cut(cmd.Docs, "\n")
+				}
+				parent.Next = append(parent.Next, next)
+			}
+		}
+		completion := completion{
+			Words:   words,
+			Docs:    cmd.Docs,
+			Command: true,
+		}
+		for _, opt := range optionsOf(cmd.Function) {
+			if !opt.Env {
+				completion.Options = append(completion.Options, opt)
+			}
+		}
+		for i := range cmd.NumIn() {
+			if cmd.In(i).Kind() == reflect.Interface && reflect.TypeFor[fs.File]().Implements(cmd.In(i)) {
+				completion.Files = true
+			}
+		}
+		if len(words) == 0 { // also offered alongside the first words of other commands.
+			group(nil).Options = append(group(nil).Options, completion.Options...)
+		}
+		completions = append(completions, completion)
+	}
+	for _, group := range groups {
+		if len(group.Next) > 0 {
+			completions = append(completions, *group)
+		}
+	}
+	slices.SortStableFunc(completions, func(a, b completion) int {
+		if len(a.Words) != len(b.Words) {
+			return len(b.Words) - len(a.Words)
+		}
+		if a.Command != b.Command { // groups before commands.
+			if a.Command {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(strings.Join(a.Words, " "), strings.Join(b.Words, " "))
+	})
+	return completions
+}
+
+// flags returns the words that complete the options.
+func (c completion) flags() []string {
+	var flags []string
+	for _, opt := range c.Options {
+		if opt.Name == "" {
+			continue
+		}
+		flags = append(flags, opt.Name)
+	}
+	return flags
+}
+
+func (c completion) next() []string {
+	var words []string
+	for _, next := range c.Next {
+		words = append(words, next.Words[len(next.Words)-1])
+	}
+	return words
+}
+
+// identifier returns a shell function name for the command name.
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// quote a string for use within a shell script.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func bashCompletion(w io.Writer, name string, completions []completion) error {
+	fn := "_" + identifier(name) + "_completion"
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintf(&b, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(&b, "\tlocal line=\"${COMP_WORDS[*]:1:COMP_CWORD-1}\"\n")
+	fmt.Fprintf(&b, "\tif [[ $cur == = ]]; then\n")
+	fmt.Fprintf(&b, "\t\tprev=\"$prev=\" cur=\"\"\n")
+	fmt.Fprintf(&b, "\telif [[ $prev == = ]]; then\n")
+	fmt.Fprintf(&b, "\t\tprev=\"${COMP_WORDS[COMP_CWORD-2]}=\"\n")
+	fmt.Fprintf(&b, "\tfi\n")
+	fmt.Fprintf(&b, "\tCOMPREPLY=()\n")
+	fmt.Fprintf(&b, "\tcase \"$line\" in\n")
+	for _, c := range completions {
+		words := strings.Join(c.Words, " ")
+		if !c.Command {
+			fmt.Fprintf(&b, "\t%s)\n", quote(words))
+			fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", quote(strings.Join(append(c.next(), c.flags()...), " ")))
+			fmt.Fprintf(&b, "\t\t;;\n")
+			continue
+		}
+		if words == "" {
+			fmt.Fprintf(&b, "\t*)\n")
+		} else {
+			fmt.Fprintf(&b, "\t%s|%s*)\n", quote(words), quote(words+" "))
+		}
+		var values []option
+		for _, opt := range c.Options {
+			if opt.Value && opt.Name != "" {
+				values = append(values, opt)
+			}
+		}
+		if len(values) > 0 {
+			fmt.Fprintf(&b, "\t\tcase \"$prev\" in\n")
+			for _, opt := range values {
+				fmt.Fprintf(&b, "\t\t%s)\n", quote(opt.Name))
+				if opt.Dir {
+					fmt.Fprintf(&b, "\t\t\tCOMPREPLY=($(compgen -d -- \"$cur\"))\n")
+				}
+				fmt.Fprintf(&b, "\t\t\treturn\n")
+				fmt.Fprintf(&b, "\t\t\t;;\n")
+			}
+			fmt.Fprintf(&b, "\t\tesac\n")
+		}
+		fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", quote(strings.Join(c.flags(), " ")))
+		if c.Files {
+			fmt.Fprintf(&b, "\t\tCOMPREPLY+=($(compgen -f -- \"$cur\"))\n")
+		}
+		fmt.Fprintf(&b, "\t\t;;\n")
+	}
+	fmt.Fprintf(&b, "\tesac\n")
+	fmt.Fprintf(&b, "\t[[ ${#COMPREPLY[@]} == 1 && ${COMPREPLY[0]} == *= ]] && compopt -o nospace\n")
+	fmt.Fprintf(&b, "}\n")
+	fmt.Fprintf(&b, "complete -o default -F %s %s\n", fn, quote(name))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func zshCompletion(w io.Writer, name string, completions []completion) error {
+	fn := "_" + identifier(name)
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n", name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	fmt.Fprintf(&b, "\tlocal line=\"${words[2,CURRENT-1]}\"\n")
+	fmt.Fprintf(&b, "\tlocal -a candidates\n")
+	fmt.Fprintf(&b, "\tcase \"$line\" in\n")
+	for _, c := range completions {
+		words := strings.Join(c.Words, " ")
+		switch {
+		case !c.Command:
+			fmt.Fprintf(&b, "\t%s)\n", quote(words))
+		case words == "":
+			fmt.Fprintf(&b, "\t*)\n")
+		default:
+			fmt.Fprintf(&b, "\t%s|%s*)\n", quote(words), quote(words+" "))
+		}
+		for _, opt := range c.Options {
+			if opt.Value && opt.Name != "" {
+				completer := "_files"
+				if opt.Dir {
+					completer = "_directories"
+				}
+				if strings.HasSuffix(opt.Name, "=") {
+					fmt.Fprintf(&b, "\t\tcompset -P %s && { %s; return }\n", quote(opt.Name), completer)
+				} else {
+					fmt.Fprintf(&b, "\t\t[[ ${words[CURRENT-1]} == %s ]] && { %s; return }\n", quote(opt.Name), completer)
+				}
+			}
+		}
+		fmt.Fprintf(&b, "\t\tcandidates=(")
+		var candidates []string
+		for _, next := range c.Next {
+			candidates = append(candidates, zshCandidate(next.Words[len(next.Words)-1], next.Docs))
+		}
+		for _, opt := range c.Options {
+			if opt.Name != "" {
+				docs, _, _ := strings.Cut(opt.Docs, "\n")
+				candidates = append(candidates, zshCandidate(opt.Name, docs))
+			}
+		}
+		fmt.Fprintf(&b, "%s)\n", strings.Join(candidates, " "))
+		tag := "command"
+		if c.Command {
+			tag = "option"
+		}
+		fmt.Fprintf(&b, "\t\t_describe %s candidates\n", tag)
+		if c.Files {
+			fmt.Fprintf(&b, "\t\t_files\n")
+		}
+		fmt.Fprintf(&b, "\t\t;;\n")
+	}
+	fmt.Fprintf(&b, "\tesac\n")
+	fmt.Fprintf(&b, "}\n\n")
+	fmt.Fprintf(&b, "compdef %s %s\n", fn, quote(name))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func zshCandidate(word, docs string) string {
+	word = strings.ReplaceAll(word, ":", `\:`)
+	if docs == "" {
+		return quote(word)
+	}
+	return quote(word + ":" + docs)
+}
+
+func fishCompletion(w io.Writer, name string, completions []completion) error {
+	fn := "__" + identifier(name) + "_cmdl"
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", name)
+	fmt.Fprintf(&b, "function %s\n", fn)
+	fmt.Fprintf(&b, "\tset -l tokens (commandline -opc)\n")
+	fmt.Fprintf(&b, "\tset -e tokens[1]\n")
+	fmt.Fprintf(&b, "\tset -l line (string join ' ' -- $tokens)\n")
+	fmt.Fprintf(&b, "\tswitch $argv[1]\n")
+	fmt.Fprintf(&b, "\tcase is\n")
+	fmt.Fprintf(&b, "\t\ttest \"$line\" = \"$argv[2]\"\n")
+	fmt.Fprintf(&b, "\tcase in\n")
+	fmt.Fprintf(&b, "\t\ttest \"$line\" = \"$argv[2]\"; or test -z \"$argv[2]\"; or string match -q -- \"$argv[2] *\" \"$line\"\n")
+	fmt.Fprintf(&b, "\tend\n")
+	fmt.Fprintf(&b, "end\n")
+	for _, c := range completions {
+		words := strings.Join(c.Words, " ")
+		if !c.Command {
+			for _, next := range c.Next {
+				fmt.Fprintf(&b, "complete -c %s -f -n %s -a %s", quote(name), quote(fn+" is "+quote(words)), quote(next.Words[len(next.Words)-1]))
+				if next.Docs != "" {
+					fmt.Fprintf(&b, " -d %s", quote(next.Docs))
+				}
+				fmt.Fprintf(&b, "\n")
+			}
+			continue
+		}
+		condition := quote(fn + " in " + quote(words))
+		for _, opt := range c.Options {
+			if opt.Name == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "complete -c %s -n %s", quote(name), condition)
+			flag := strings.TrimSuffix(opt.Name, "=")
+			switch {
+			case strings.HasPrefix(flag, "--"):
+				fmt.Fprintf(&b, " -l %s", quote(strings.TrimPrefix(flag, "--")))
+			case strings.HasPrefix(flag, "-") && len(flag) == 2:
+				fmt.Fprintf(&b, " -s %s", quote(strings.TrimPrefix(flag, "-")))
+			case strings.HasPrefix(flag, "-"):
+				fmt.Fprintf(&b, " -o %s", quote(strings.TrimPrefix(flag, "-")))
+			default:
+				fmt.Fprintf(&b, " -f -a %s", quote(flag))
+			}
+			switch {
+			case opt.Dir:
+				fmt.Fprintf(&b, " -r -a '(__fish_complete_directories)'")
+			case opt.Value:
+				fmt.Fprintf(&b, " -r -F")
+			}
+			if docs, _, _ := strings.Cut(opt.Docs, "\n"); docs != "" {
+				fmt.Fprintf(&b, " -d %s", quote(docs))
+			}
+			fmt.Fprintf(&b, "\n")
+		}
+		if c.Files {
+			fmt.Fprintf(&b, "complete -c %s -n %s -F\n", quote(name), condition)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,76 @@
+package cmdl_test
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"runtime.link/api"
+	"runtime.link/api/cmdl"
+)
+
+type completionOptions struct {
+	Verbose bool `cmdl:"--verbose"
+		print more output`
+	Dir  string `cmdl:"-C %v,dir"`
+	Home string `cmdl:"HOME,env"
+		home directory`
+}
+
+type completionAPI struct {
+	api.Specification `
+		greets people.`
+
+	Hello func(context.Context, string, completionOptions) (string, error) `cmdl:"hello %v %v"
+		says hello to someone.`
+	Cat func(context.Context, fs.File) error `cmdl:"cat %v"`
+
+	Remote struct {
+		Add func(context.Context, string) (string, error) `cmdl:"add %v"
+			adds a remote.`
+	} `cmdl:"remote"`
+}
+
+func TestCompletion(t *testing.T) {
+	var program completionAPI
+	for shell, expect := range map[cmdl.Shell][]string{
+		cmdl.Bash: {"complete -o default -F _greet_completion 'greet'", "'remote')", "compgen -W 'add'", "compgen -W 'hello cat remote'", "compgen -d", "'--verbose -C'"},
+		cmdl.Zsh:  {"#compdef greet", "'hello:says hello to someone.'", "_directories", "'--verbose:print more output'"},
+		cmdl.Fish: {"-a 'add' -d 'adds a remote.'", "-l 'verbose' -d 'print more output'", "-s 'C' -r -a '(__fish_complete_directories)'", "__greet_cmdl in '\\''cat'\\''' -F"},
+	} {
+		var buf bytes.Buffer
+		if err := cmdl.Completion(&buf, shell, "greet", &program); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range expect {
+			if !strings.Contains(buf.String(), s) {
+				t.Fatalf("expected %q in %s completion:\n%s", s, shell, buf.String())
+			}
+		}
+		if strings.Contains(buf.String(), "HOME") {
+			t.Fatalf("environment variables should not be completed:\n%s", buf.String())
+		}
+	}
+}
+
+func TestManual(t *testing.T) {
+	var program completionAPI
+	var buf bytes.Buffer
+	if err := cmdl.Manual(&buf, "greet", &program); err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		".TH GREET 1",
+		"greet \\- greets people.",
+		".B hello <string> [options]",
+		".B remote add <string>",
+		".B \\-\\-verbose",
+		".SH ENVIRONMENT\n.TP\n.B HOME\nhome directory",
+	} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Fatalf("expected %q in manual:\n%s", expect, buf.String())
+		}
+	}
+}
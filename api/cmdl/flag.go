@@ -0,0 +1,326 @@
+package cmdl
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagOptions are the comma separated options that follow the name of a
+// cmdl field tag.
+type tagOptions struct {
+	Env       bool   // the field is an environment variable.
+	Dir       bool   // the field is the working directory.
+	Invert    bool   // the field is a bool flag, that clears the field.
+	OmitEmpty bool   // the field is omitted when it is empty.
+	Stderr    bool   // the field is written to stderr.
+	Required  bool   // the flag must be provided.
+	Fallback  string // environment variable to use, when the flag is missing.
+	Default   string // value to use, when the flag is missing.
+
+	HasDefault bool
+}
+
+// parseTag splits a cmdl field tag into its form (name, along with any
+// placeholder) and its options.
+func parseTag(tag string) (string, tagOptions) {
+	form, rest, _ := strings.Cut(tag, ",")
+	var opts tagOptions
+	for _, opt := range strings.Split(rest, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "env":
+			if value != "" {
+				opts.Fallback = value
+			} else {
+				opts.Env = true
+			}
+		case "dir":
+			opts.Dir = true
+		case "invert":
+			opts.Invert = true
+		case "omitempty", "omitzero":
+			opts.OmitEmpty = true
+		case "stderr":
+			opts.Stderr = true
+		case "required":
+			opts.Required = true
+		case "default":
+			opts.Default, opts.HasDefault = value, true
+		}
+	}
+	return strings.TrimSpace(form), opts
+}
+
+// flag that can be set from the command-line.
+type flag struct {
+	option
+
+	value reflect.Value
+	seen  bool
+}
+
+// flagsOf returns the flags for each field of the options.
+func flagsOf(options reflect.Value) []*flag {
+	var flags []*flag
+	rtype := options.Type()
+	for i := range rtype.NumField() {
+		field := rtype.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("cmdl")
+		if tag == "" && (field.Anonymous || isOptions(field.Type)) && field.Type.Kind() == reflect.Struct {
+			flags = append(flags, flagsOf(options.Field(i))...)
+			continue
+		}
+		if tag == "" || tag == "-" {
+			continue
+		}
+		flags = append(flags, &flag{option: optionOf(field), value: options.Field(i)})
+	}
+	return flags
+}
+
+// accepts reports whether the flag accepts the given argument, returning
+// the value for it, if one is included in the argument.
+func (f *flag) accepts(arg string) (value string, included, ok bool) {
+	name := strings.TrimSuffix(f.Name, "=")
+	if name == "" || f.Env {
+		return "", false, false
+	}
+	switch {
+	case arg == name:
+		return "", false, true
+	case strings.HasPrefix(arg, name+"="):
+		return arg[len(name)+1:], true, true
+	case len(name) == 2 && name[0] == '-' && name[1] != '-' && strings.HasPrefix(arg, name) && f.value.Kind() != reflect.Bool:
+		return arg[2:], true, true // -nvalue
+	case f.value.Kind() == reflect.Bool && !f.Invert && strings.HasPrefix(name, "--") && arg == "--no-"+name[2:]:
+		return "false", true, true
+	}
+	return "", false, false
+}
+
+// set the flag to the given text.
+func (f *flag) set(text string) error {
+	if f.value.Kind() == reflect.Bool && f.Invert {
+		val, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", text, f.Name, err)
+		}
+		f.value.SetBool(!val)
+		return nil
+	}
+	if err := parseValue(f.value, text); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", text, strings.TrimSuffix(f.Name, "="), err)
+	}
+	return nil
+}
+
+// parseFlags sets the flags of each options argument from the command-line
+// arguments (which follow the program name) and returns the remaining
+// positional arguments. Flags may appear anywhere after the words of the
+// command, up until a '--' argument.
+func (os System) parseFlags(fn command, args []reflect.Value, argv []string) ([]string, error) {
+	var flags []*flag
+	for _, arg := range args {
+		if isOptions(arg.Type()) {
+			flags = append(flags, flagsOf(arg)...)
+		}
+	}
+	if len(flags) == 0 {
+		return argv, nil
+	}
+	for _, f := range flags {
+		if f.value.Kind() == reflect.Bool && f.Invert {
+			f.value.SetBool(true)
+		}
+	}
+	var positional []string
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		if arg == "--" {
+			positional = append(positional, argv[i+1:]...)
+			break
+		}
+		var (
+			matched  *flag
+			value    string
+			included bool
+		)
+		for _, f := range flags {
+			if v, inc, ok := f.accepts(arg); ok {
+				matched, value, included = f, v, inc
+				break
+			}
+		}
+		if matched == nil {
+			if len(arg) > 1 && arg[0] == '-' {
+				if _, err := strconv.ParseFloat(arg, 64); err != nil {
+					return nil, unknownFlag(flags, arg)
+				}
+			}
+			positional = append(positional, arg)
+			continue
+		}
+		switch {
+		case included:
+		case matched.value.Kind() == reflect.Bool:
+			value = "true"
+		case i+1 < len(argv):
+			i++
+			value = argv[i]
+		default:
+			return nil, fmt.Errorf("missing value for %s", arg)
+		}
+		if err := matched.set(value); err != nil {
+			return nil, err
+		}
+		matched.seen = true
+	}
+	for _, f := range flags {
+		if f.seen {
+			continue
+		}
+		switch {
+		case f.Env:
+			if value, ok := os.lookupEnv(f.Name); ok {
+				if err := f.set(value); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		case f.Fallback != "":
+			if value, ok := os.lookupEnv(f.Fallback); ok {
+				if err := f.set(value); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		switch {
+		case f.HasDefault:
+			if err := f.set(f.Default); err != nil {
+				return nil, err
+			}
+		case f.Required:
+			return nil, fmt.Errorf("missing required flag %s, usage: %s", strings.TrimSuffix(f.Name, "="), usageOf(fn))
+		}
+	}
+	return positional, nil
+}
+
+func (os System) lookupEnv(name string) (string, bool) {
+	for _, env := range os.Environ {
+		if key, value, ok := strings.Cut(env, "="); ok && key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// unknownFlag returns a usage error for the argument, suggesting the most
+// similar flag.
+func unknownFlag(flags []*flag, arg string) error {
+	name, _, _ := strings.Cut(arg, "=")
+	var (
+		closest  string
+		distance = len(name)/2 + 1
+	)
+	for _, f := range flags {
+		candidate := strings.TrimSuffix(f.Name, "=")
+		if candidate == "" || f.Env {
+			continue
+		}
+		if d := levenshtein(candidate, name); d < distance {
+			closest, distance = candidate, d
+		}
+	}
+	if closest == "" {
+		return fmt.Errorf("unknown flag: %s", name)
+	}
+	return fmt.Errorf("unknown flag: %s (did you mean '%s'?)", name, closest)
+}
+
+// parseValue parses the text into the value, slices are appended to and
+// maps are set from 'key=value' text.
+func parseValue(value reflect.Value, text string) error {
+	rtype := value.Type()
+	if reflect.PointerTo(rtype).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+	if rtype == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+	switch rtype.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(rtype.Elem())
+		if err := parseValue(elem.Elem(), text); err != nil {
+			return err
+		}
+		value.Set(elem)
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 0, rtype.Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(text, 0, rtype.Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, rtype.Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		elem := reflect.New(rtype.Elem()).Elem()
+		if err := parseValue(elem, text); err != nil {
+			return err
+		}
+		value.Set(reflect.Append(value, elem))
+	case reflect.Map:
+		k, v, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value")
+		}
+		key := reflect.New(rtype.Key()).Elem()
+		if err := parseValue(key, k); err != nil {
+			return err
+		}
+		val := reflect.New(rtype.Elem()).Elem()
+		if err := parseValue(val, v); err != nil {
+			return err
+		}
+		if value.IsNil() {
+			value.Set(reflect.MakeMap(rtype))
+		}
+		value.SetMapIndex(key, val)
+	default:
+		if _, err := fmt.Sscan(text, value.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package cmdl
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"runtime.link/api"
+)
+
+// command reachable from the command-line.
+type command struct {
+	api.Function
+
+	Usage string // cmdl tag, including any namespace prefix, without any options.
+}
+
+// words returns the leading literal words of the command.
+func (cmd command) words() []string {
+	var words []string
+	for _, word := range strings.Fields(cmd.Usage) {
+		if strings.Contains(word, "%") {
+			break
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// commandsOf returns every function in the structure (including any nested
+// namespaces) that can be called from the command-line, namespaces are
+// reached with their cmdl tag (or else their lowercase name).
+func commandsOf(spec api.Structure, prefix string) []command {
+	var commands []command
+	for _, fn := range spec.Functions {
+		tag, _, _ := strings.Cut(fn.Tags.Get("cmdl"), ",")
+		if tag == "" {
+			continue
+		}
+		commands = append(commands, command{
+			Function: fn,
+			Usage:    strings.TrimSpace(prefix + " " + tag),
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(spec.Namespace)) {
+		section := spec.Namespace[name]
+		tag, _, _ := strings.Cut(section.Tags.Get("cmdl"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = strings.ToLower(name)
+		}
+		commands = append(commands, commandsOf(section, strings.TrimSpace(prefix+" "+tag))...)
+	}
+	return commands
+}
+
+// isHelp reports whether the arguments are asking for help, returning the
+// words of the command to help with.
+func isHelp(commands []command, args []string) ([]string, bool) {
+	switch {
+	case len(args) == 0:
+		return nil, true
+	case args[0] == "help" && !slices.ContainsFunc(commands, func(cmd command) bool {
+		return slices.Equal(cmd.words()[:min(1, len(cmd.words()))], []string{"help"})
+	}):
+		return args[1:], true
+	case args[len(args)-1] == "--help" || args[len(args)-1] == "-h":
+		return args[:len(args)-1], true
+	}
+	return nil, false
+}
+
+// help writes the help text for the given words to Stdout, these may refer
+// to a command, or to a group of commands.
+func (os System) help(spec api.Structure, commands []command, words []string) error {
+	program := "command"
+	if len(os.Args) > 0 {
+		program = filepath.Base(os.Args[0])
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	for _, cmd := range commands {
+		if slices.Equal(cmd.words(), words) {
+			fmt.Fprintf(w, "Usage: %s\n", strings.TrimSpace(program+" "+usageOf(cmd)))
+			if cmd.Docs != "" {
+				fmt.Fprintf(w, "\n%s\n", cmd.Docs)
+			}
+			writeOptions(w, cmd.Function)
+			return nil
+		}
+	}
+	var group []command
+	for _, cmd := range commands {
+		if len(cmd.words()) > len(words) && slices.Equal(cmd.words()[:len(words)], words) {
+			group = append(group, cmd)
+		}
+	}
+	if len(group) == 0 && len(words) > 0 {
+		return unknown(commands, words)
+	}
+	if len(words) == 0 && spec.Docs != "" {
+		fmt.Fprintf(w, "%s\n\n", spec.Docs)
+	}
+	fmt.Fprintf(w, "Usage: %s <command> [arguments]\n\nCommands:\n", strings.TrimSpace(program+" "+strings.Join(words, " ")))
+	for _, cmd := range group {
+		docs, _, _ := strings.Cut(cmd.Docs, "\n")
+		fmt.Fprintf(w, "  %s\t%s\n", usageOf(cmd), docs)
+	}
+	fmt.Fprintf(w, "\nRun '%s help <command>' for more information about a command.\n", program)
+	return nil
+}
+
+// unknown returns a usage error for the given words, suggesting the most
+// similar command.
+func unknown(commands []command, words []string) error {
+	// find the longest run of words that lead to a command.
+	var depth int
+	for _, cmd := range commands {
+		prefix := cmd.words()
+		n := 0
+		for n < len(prefix) && n < len(words) && prefix[n] == words[n] {
+			n++
+		}
+		depth = max(depth, n)
+	}
+	if depth >= len(words) {
+		return fmt.Errorf("unknown command: %s", strings.Join(words, " "))
+	}
+	var (
+		closest  string
+		distance = len(words[depth])/2 + 1
+	)
+	for _, cmd := range commands {
+		prefix := cmd.words()
+		if len(prefix) <= depth || !slices.Equal(prefix[:depth], words[:depth]) {
+			continue
+		}
+		if d := levenshtein(prefix[depth], words[depth]); d < distance {
+			closest, distance = strings.Join(prefix[:depth+1], " "), d
+		}
+	}
+	if closest == "" {
+		return fmt.Errorf("unknown command: %s", strings.Join(words[:depth+1], " "))
+	}
+	return fmt.Errorf("unknown command: %s (did you mean '%s'?)", strings.Join(words[:depth+1], " "), closest)
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	next := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		next[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			next[j] = min(prev[j]+1, next[j-1]+1, prev[j-1]+cost)
+		}
+		prev, next = next, prev
+	}
+	return prev[len(b)]
+}
+
+// argumentOf returns the index of the argument referred to by the
+// placeholder, given the index of the previous argument.
+func argumentOf(placeholder string, previous int) int {
+	if _, explicit, ok := strings.Cut(placeholder, "%["); ok {
+		if n, _, ok := strings.Cut(explicit, "]"); ok {
+			if i, err := strconv.Atoi(n); err == nil {
+				return i - 1
+			}
+		}
+	}
+	return previous + 1
+}
+
+// usageOf returns the usage text for the command, where each placeholder
+// is replaced with a description of the argument.
+func usageOf(cmd command) string {
+	var (
+		words    = strings.Fields(cmd.Usage)
+		previous = -1
+	)
+	for i, word := range words {
+		if !strings.Contains(word, "%") {
+			continue
+		}
+		previous = argumentOf(word, previous)
+		if previous < 0 || previous >= cmd.NumIn() {
+			continue
+		}
+		rtype := cmd.In(previous)
+		switch {
+		case isOptions(rtype):
+			words[i] = "[options]"
+		case cmd.Type.IsVariadic() && previous == cmd.NumIn()-1:
+			words[i] = "[" + placeholderFor(rtype.Elem()) + "...]"
+		case rtype.Kind() == reflect.Pointer:
+			words[i] = "[" + placeholderFor(rtype.Elem()) + "]"
+		default:
+			words[i] = placeholderFor(rtype)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func placeholderFor(rtype reflect.Type) string {
+	for rtype.Kind() == reflect.Pointer {
+		rtype = rtype.Elem()
+	}
+	name := rtype.Name()
+	if name == "" {
+		name = rtype.Kind().String()
+	}
+	return "<" + strings.ToLower(name) + ">"
+}
+
+// isOptions reports whether the type is a struct of cmdl tagged fields.
+func isOptions(rtype reflect.Type) bool {
+	if rtype.Kind() != reflect.Struct || reflect.PointerTo(rtype).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return false
+	}
+	for i := range rtype.NumField() {
+		if field := rtype.Field(i); field.Tag.Get("cmdl") != "" || (field.Anonymous && isOptions(field.Type)) {
+			return true
+		}
+	}
+	return false
+}
+
+// option is a cmdl tagged field of a struct argument.
+type option struct {
+	tagOptions
+
+	Name string // name of the flag (or environment variable), without any placeholder.
+	Form string // cmdl tag, without any options.
+	Docs string
+
+	Field reflect.StructField
+
+	Value bool // flag that is followed by a value.
+}
+
+func optionOf(field reflect.StructField) option {
+	form, opts := parseTag(field.Tag.Get("cmdl"))
+	name, _, value := strings.Cut(form, "%")
+	return option{
+		tagOptions: opts,
+		Name:       strings.TrimSpace(name),
+		Form:       form,
+		Docs:       api.DocumentationOf(field),
+		Field:      field,
+		Value:      value,
+	}
+}
+
+// optionsOf returns the options of each struct argument of the function.
+func optionsOf(fn api.Function) []option {
+	var options []option
+	for i := range fn.NumIn() {
+		if isOptions(fn.In(i)) {
+			for _, flag := range flagsOf(reflect.New(fn.In(i)).Elem()) {
+				options = append(options, flag.option)
+			}
+		}
+	}
+	return options
+}
+
+// writeOptions writes the documentation for each cmdl tagged field of
+// any options passed to the function.
+func writeOptions(w io.Writer, fn api.Function) {
+	var written bool
+	for _, opt := range optionsOf(fn) {
+		name := strings.ReplaceAll(opt.Form, "%v", placeholderFor(opt.Field.Type))
+		if opt.Env {
+			name = "$" + opt.Name
+		}
+		if !written {
+			fmt.Fprintf(w, "\nOptions:\n")
+			written = true
+		}
+		docs, _, _ := strings.Cut(opt.Docs, "\n")
+		switch {
+		case opt.Required:
+			docs = strings.TrimSpace(docs + " (required)")
+		case opt.HasDefault:
+			docs = strings.TrimSpace(fmt.Sprintf("%s (default %s)", docs, opt.Default))
+		}
+		if opt.Fallback != "" {
+			docs = strings.TrimSpace(fmt.Sprintf("%s [$%s]", docs, opt.Fallback))
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", name, docs)
+	}
+}
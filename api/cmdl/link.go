@@ -9,9 +9,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"os"
 	"os/exec"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -23,9 +25,30 @@ type cmdInput struct {
 	args []string
 	env  []string
 	wd   string
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
 }
 
+var (
+	readerType = reflect.TypeFor[io.Reader]()
+	writerType = reflect.TypeFor[io.Writer]()
+)
+
 func (input *cmdInput) add(val reflect.Value) error {
+	switch {
+	case val.Type().Implements(readerType):
+		if !val.IsZero() {
+			input.stdin = val.Interface().(io.Reader)
+		}
+		return nil
+	case val.Type().Implements(writerType):
+		if !val.IsZero() {
+			input.stdout = val.Interface().(io.Writer)
+		}
+		return nil
+	}
 	switch val.Kind() {
 	case reflect.Struct:
 		rtype := val.Type()
@@ -48,28 +71,37 @@ func (input *cmdInput) add(val reflect.Value) error {
 				}
 				continue
 			}
-			exec := field.Tag.Get("cmdl")
+			exec, opts := parseTag(field.Tag.Get("cmdl"))
 			if exec == "-" {
 				continue
 			}
+			if opts.Stderr && field.Type.Implements(writerType) {
+				if !val.Field(i).IsZero() {
+					input.stderr = val.Field(i).Interface().(io.Writer)
+				}
+				continue
+			}
+			if field.Type.Implements(readerType) || field.Type.Implements(writerType) {
+				if err := input.add(val.Field(i)); err != nil {
+					return xray.New(err)
+				}
+				continue
+			}
 
 			omitBooleanFlag := (!strings.Contains(exec, "%v") && field.Type.Kind() == reflect.Bool)
 
-			if (strings.Contains(exec, ",omitempty") || strings.Contains(exec, ",omitzero") || omitBooleanFlag) && val.Field(i).IsZero() {
+			if (opts.OmitEmpty || omitBooleanFlag) && val.Field(i).IsZero() {
 				continue
 			}
-			if strings.Contains(exec, ",env") {
-				name, _, _ := strings.Cut(exec, ",")
-				input.env = append(input.env, fmt.Sprintf("%s=%v", name, val.Field(i).Interface()))
+			if opts.Env {
+				input.env = append(input.env, fmt.Sprintf("%s=%v", exec, val.Field(i).Interface()))
 				continue
 			}
-			if strings.Contains(exec, ",dir") {
+			if opts.Dir {
 				input.wd = fmt.Sprint(val.Field(i).Interface())
 				continue
 			}
 
-			exec, _, _ = strings.Cut(exec, ",")
-
 			parts := strings.Split(exec, " ")
 			for _, part := range parts {
 				if strings.HasPrefix(part, "%") || strings.Contains(part, "%") {
@@ -97,21 +129,59 @@ func (input *cmdInput) add(val reflect.Value) error {
 	return nil
 }
 
-func linkStructure(spec api.Structure, cmd string, prefix string) {
+// configure the command with the input, using the client as a template
+// for everything that the input does not set.
+func (input *cmdInput) configure(cmd *exec.Cmd, client *exec.Cmd) {
+	env := os.Environ()
+	if client != nil && client.Env != nil {
+		env = slices.Clone(client.Env)
+	}
+	cmd.Env = append(env, input.env...)
+	cmd.Dir = input.wd
+	cmd.Stdin = input.stdin
+	cmd.Stdout = input.stdout
+	cmd.Stderr = input.stderr
+	if client == nil {
+		return
+	}
+	if cmd.Dir == "" {
+		cmd.Dir = client.Dir
+	}
+	if cmd.Stdin == nil {
+		cmd.Stdin = client.Stdin
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = client.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = client.Stderr
+	}
+	if client.SysProcAttr != nil {
+		attr := *client.SysProcAttr
+		cmd.SysProcAttr = &attr
+	}
+	cmd.ExtraFiles = client.ExtraFiles
+	cmd.WaitDelay = client.WaitDelay
+}
+
+func linkStructure(root, spec api.Structure, cmd string, prefix string, client *exec.Cmd) {
+	if cmd == "" && client != nil {
+		cmd = client.Path
+	}
 	if _, err := exec.LookPath(cmd); err != nil {
 		spec.MakeError(fmt.Errorf("cannot find program '%s': %w", cmd, err))
 		return
 	}
 	for _, fn := range spec.Functions {
-		link(cmd, fn, prefix)
+		link(root, cmd, fn, prefix, client)
 	}
 	for _, section := range spec.Namespace {
 		section.Host = spec.Host
-		linkStructure(section, cmd, section.Tags.Get("cmdl"))
+		linkStructure(root, section, cmd, section.Tags.Get("cmdl"), client)
 	}
 }
 
-func link(cmd string, fn api.Function, prefix string) {
+func link(root api.Structure, cmd string, fn api.Function, prefix string, client *exec.Cmd) {
 	if prefix != "" {
 		prefix += " "
 	}
@@ -128,6 +198,9 @@ func link(cmd string, fn api.Function, prefix string) {
 		scanner := api.NewArgumentScanner(args)
 
 		var execArgs cmdInput
+		if client != nil && len(client.Args) > 1 {
+			execArgs.args = append(execArgs.args, client.Args[1:]...)
+		}
 		if tag != "" {
 			for _, component := range strings.Split(string(tag), " ") {
 				if strings.HasPrefix(component, "%") || strings.HasPrefix(component, "{") {
@@ -144,6 +217,53 @@ func link(cmd string, fn api.Function, prefix string) {
 				}
 			}
 		}
+		for _, arg := range args {
+			if arg.Type().Implements(readerType) || arg.Type().Implements(writerType) {
+				if err := execArgs.add(arg); err != nil {
+					return nil, xray.New(err)
+				}
+			}
+		}
+
+		if os.Getenv("DEBUG_CMD") != "" {
+			fmt.Println(cmd, execArgs)
+		}
+
+		results = make([]reflect.Value, fn.NumOut())
+		for i := range results {
+			results[i] = reflect.Zero(fn.Type.Out(i))
+		}
+
+		if fn.NumOut() > 0 && isStream(fn.Type.Out(0)) {
+			output := &process{
+				ctx: ctx,
+				command: func(running context.Context) *exec.Cmd {
+					cmd := exec.CommandContext(running, cmd, execArgs.args...)
+					execArgs.configure(cmd, client)
+					return cmd
+				},
+				tee:     execArgs.stderr,
+				errorOf: func(err error, stderr string) error { return errorOf(root, err, stderr) },
+			}
+			switch fn.Type.Out(0) {
+			case readCloserType:
+				if err := output.start(); err != nil {
+					return nil, err
+				}
+				results[0] = reflect.ValueOf(output)
+			case seqType:
+				results[0] = reflect.ValueOf(iter.Seq[string](func(yield func(string) bool) {
+					for line, err := range output.lines {
+						if err != nil || !yield(line) {
+							return // the failure is lost, see iter.Seq2.
+						}
+					}
+				}))
+			case seq2Type:
+				results[0] = reflect.ValueOf(iter.Seq2[string, error](output.lines))
+			}
+			return results, nil
+		}
 
 		var stdout bytes.Buffer
 		var stderr bytes.Buffer
@@ -154,21 +274,14 @@ func link(cmd string, fn api.Function, prefix string) {
 		}
 		stderrRead, stderrWrite, err := os.Pipe()
 		if err != nil {
+			closeAll(stdoutRead, stdoutWrite)
 			return nil, xray.New(err)
 		}
-		if os.Getenv("DEBUG_CMD") != "" {
-			fmt.Println(cmd, execArgs)
-		}
-		cmd := exec.CommandContext(ctx, cmd, execArgs.args...)
-		cmd.Env = append(os.Environ(), execArgs.env...)
-		cmd.Dir = execArgs.wd
+		running, cancel := context.WithCancel(ctx)
+		cmd := exec.CommandContext(running, cmd, execArgs.args...)
+		execArgs.configure(cmd, client)
 		setupOperatingSystemSpecificsFor(cmd, stdoutWrite, stderrWrite)
 
-		results = make([]reflect.Value, fn.NumOut())
-		for i := range results {
-			results[i] = reflect.Zero(fn.Type.Out(i))
-		}
-
 		var async bool
 		var chout chan []byte
 		var cherr chan error
@@ -197,9 +310,8 @@ func link(cmd string, fn api.Function, prefix string) {
 				}
 			}()
 		} else if fn.NumOut() > 0 {
-			cmd.Stdout = stdoutWrite
-			go io.Copy(&stdout, stdoutRead)
-		} else {
+			cmd.Stdout = &stdout
+		} else if cmd.Stdout == nil {
 			cmd.Stdout = os.Stdout
 		}
 
@@ -227,16 +339,20 @@ func link(cmd string, fn api.Function, prefix string) {
 				}
 			}()
 		} else if fn.NumOut() != fn.Type.NumOut() {
-			cmd.Stderr = stderrWrite
-			go io.Copy(&stderr, stderrRead)
-		} else {
+			cmd.Stderr = &stderr
+			if execArgs.stderr != nil {
+				cmd.Stderr = io.MultiWriter(&stderr, execArgs.stderr)
+			}
+		} else if cmd.Stderr == nil {
 			cmd.Stderr = os.Stderr
 		}
 		if async {
 			if err := cmd.Start(); err != nil {
+				cancel()
 				return results, xray.New(err)
 			}
 			go func() {
+				defer cancel()
 				if err := cmd.Wait(); err != nil {
 					select {
 					case cherr <- err:
@@ -248,11 +364,10 @@ func link(cmd string, fn api.Function, prefix string) {
 			}()
 			return
 		}
+		defer cancel()
+		defer closeAll(stdoutRead, stdoutWrite, stderrRead, stderrWrite)
 		if err := cmd.Run(); err != nil {
-			if text := stderr.String(); strings.TrimSpace(text) != "" {
-				return nil, errors.New(text)
-			}
-			return nil, xray.New(err)
+			return nil, errorOf(root, err, stderr.String())
 		}
 		if fn.NumOut() > 0 {
 			if isJSON {
@@ -264,6 +379,11 @@ func link(cmd string, fn api.Function, prefix string) {
 			} else {
 				if fn.NumOut() == 1 {
 					var value = reflect.New(fn.Type.Out(0)).Elem()
+					if fn.Type.Out(0) == readerType { // buffered, as the caller cannot stop the command.
+						value.Set(reflect.ValueOf(&stdout))
+						results[0] = value
+						return results, nil
+					}
 					switch fn.Type.Out(0).Kind() {
 					case reflect.String:
 						result := stdout.String()
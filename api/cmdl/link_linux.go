@@ -10,14 +10,16 @@ import (
 	"runtime.link/api/xray"
 )
 
-func setupOperatingSystemSpecificsFor(cmd *exec.Cmd, stdoutWrite, stderrWrite *os.File) {
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+func setupOperatingSystemSpecificsFor(cmd *exec.Cmd, pipes ...*os.File) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Setpgid = true
 	cmd.Cancel = func() error {
-		if err := stdoutWrite.Close(); err != nil {
-			return xray.New(err)
-		}
-		if err := stderrWrite.Close(); err != nil {
-			return xray.New(err)
+		for _, pipe := range pipes {
+			if err := pipe.Close(); err != nil {
+				return xray.New(err)
+			}
 		}
 		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
 			return cmd.Process.Kill()
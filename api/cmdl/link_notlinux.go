@@ -9,14 +9,13 @@ import (
 	"runtime.link/api/xray"
 )
 
-func setupOperatingSystemSpecificsFor(cmd *exec.Cmd, stdoutWrite, stderrWrite *os.File) {
+func setupOperatingSystemSpecificsFor(cmd *exec.Cmd, pipes ...*os.File) {
 	cancel := cmd.Cancel
 	cmd.Cancel = func() error {
-		if err := stdoutWrite.Close(); err != nil {
-			return xray.New(err)
-		}
-		if err := stderrWrite.Close(); err != nil {
-			return xray.New(err)
+		for _, pipe := range pipes {
+			if err := pipe.Close(); err != nil {
+				return xray.New(err)
+			}
 		}
 		if cancel != nil {
 			cancel()
@@ -0,0 +1,155 @@
+package cmdl_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"runtime.link/api"
+	"runtime.link/api/cmdl"
+	"runtime.link/xyz"
+)
+
+type ShellError api.Error[struct {
+	NotFound xyz.Case[ShellError, error] `cmdl:"exit=3"
+		file not found`
+	Denied ShellError `cmdl:"stderr=^denied"
+		access denied`
+}]
+
+type Shell struct {
+	api.Specification
+
+	Errors api.Register[error, ShellError]
+
+	Echo  func(ctx context.Context, script string) (string, error)           `cmdl:"%v"`
+	Cat   func(ctx context.Context, stdin io.Reader) (string, error)         `cmdl:"cat"`
+	Tee   func(ctx context.Context, opts TeeOptions) error                   `cmdl:"%v"`
+	Read  func(ctx context.Context, script string) (io.ReadCloser, error)    `cmdl:"%v"`
+	Lines func(ctx context.Context, script string) iter.Seq2[string, error]  `cmdl:"%v"`
+	Count func(ctx context.Context, script string) (iter.Seq[string], error) `cmdl:"%v"`
+	Fail  func(ctx context.Context, script string) error                     `cmdl:"%v"`
+
+	Output func(ctx context.Context, script string) (io.Reader, error) `cmdl:"%v"`
+}
+
+type TeeOptions struct {
+	Script string    `cmdl:"%v"`
+	Stdout io.Writer `cmdl:""`
+	Stderr io.Writer `cmdl:",stderr"`
+}
+
+func TestLink(t *testing.T) {
+	ctx := context.Background()
+
+	template := exec.Command("sh", "-c")
+	template.Env = []string{"GREETING=hello"}
+	template.Dir = t.TempDir()
+	sh := api.Import[Shell](cmdl.API, "", template)
+
+	if out, err := sh.Echo(ctx, `echo "$GREETING from $(basename "$PWD")"`); err != nil || out != "hello from "+last(template.Dir) {
+		t.Fatal("unexpected template output", out, err)
+	}
+	if out, err := sh.Cat(ctx, strings.NewReader("piped")); err != nil || out != "piped" {
+		t.Fatal("unexpected stdin output", out, err)
+	}
+	var stdout, stderr strings.Builder
+	if err := sh.Tee(ctx, TeeOptions{Script: "echo out; echo err >&2", Stdout: &stdout, Stderr: &stderr}); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Fatal("unexpected writers", stdout.String(), stderr.String())
+	}
+
+	r, err := sh.Read(ctx, "echo a; echo b; exit 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if string(data) != "a\nb\n" || caseOf(err) != "exit=3" {
+		t.Fatal("unexpected reader result", string(data), err)
+	}
+	r.Close()
+
+	var (
+		lines  []string
+		denied bool
+	)
+	for line, err := range sh.Lines(ctx, "echo one; echo two; echo denied >&2; exit 1") {
+		if err != nil {
+			if caseOf(err) != "stderr=^denied" {
+				t.Fatal("unexpected error", err)
+			}
+			denied = true
+			break
+		}
+		lines = append(lines, line)
+	}
+	if !denied || !slices.Equal(lines, []string{"one", "two"}) {
+		t.Fatal("unexpected lines", lines)
+	}
+	seq, err := sh.Count(ctx, "while true; do echo y; done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for range seq {
+		if n++; n == 100 {
+			break
+		}
+	}
+	if r, err := sh.Output(ctx, "echo a; echo b"); err != nil {
+		t.Fatal(err)
+	} else if data, _ := io.ReadAll(r); string(data) != "a\nb\n" {
+		t.Fatal("unexpected output", string(data))
+	}
+	if err := sh.Fail(ctx, "echo something else >&2; exit 3"); caseOf(err) != "exit=3" {
+		t.Fatal("expected scenario", err)
+	} else if strings.TrimSpace(err.Error()) != "something else" {
+		t.Fatal("unexpected scenario value", err)
+	}
+	if err := sh.Fail(ctx, "echo other >&2; exit 1"); err == nil || errors.As(err, new(ShellError)) || strings.TrimSpace(err.Error()) != "other" {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+func TestLazyStreams(t *testing.T) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("cannot count open files")
+	}
+	ctx := context.Background()
+	sh := api.Import[Shell](cmdl.API, "", exec.Command("sh", "-c"))
+	for range 100 {
+		_ = sh.Lines(ctx, "echo never")
+		if _, err := sh.Count(ctx, "echo never"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) > len(fds) {
+		t.Fatal("iterators that are never ranged leaked", len(after)-len(fds), "files")
+	}
+}
+
+// caseOf returns the cmdl tag of the ShellError case within err.
+func caseOf(err error) string {
+	var shell ShellError
+	if !errors.As(err, &shell) {
+		return ""
+	}
+	return shell.Tag().Get("cmdl")
+}
+
+func last(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -73,143 +73,103 @@ type nativeFS struct{}
 
 func (nativeFS) Open(name string) (fs.File, error) { return os.Open(name) }
 
-func (os System) consume(value reflect.Value, tracker int) (int, bool, error) {
-	var (
-		rtype   = value.Type()
-		extra   = 0
-		hasCMDL = false
-	)
-	for i := 0; i < value.NumField(); i++ {
-		field := rtype.Field(i)
-		tag := field.Tag.Get("cmdl")
-		if tag != "" {
-			hasCMDL = true
-		}
-		if field.Type.Kind() == reflect.Bool && strings.Contains(tag, ",invert") {
-			value.Field(i).SetBool(true)
-		}
+// scanFormat returns the [api.ArgumentScanner] format of a positional
+// component, such that components with any verb refer to their argument
+// in the same way as %v.
+func scanFormat(component string) string {
+	if len(component) > 1 && component[0] == '%' {
+		return component[:len(component)-1] + "v"
 	}
-	for consuming := true; consuming; {
-		arg := os.Args[tracker]
-		consuming = false
-		for i := 0; i < value.NumField(); i++ {
-			field := rtype.Field(i)
-			tag := field.Tag.Get("cmdl")
-			name, opts, _ := strings.Cut(tag, ",")
-			matches := arg == name
-			var (
-				prefix, format string
-			)
-			if strings.Contains(name, "%") {
-				prefix, format, _ = strings.Cut(name, "%")
-				format = "%" + format
-				if strings.HasPrefix(arg, prefix) {
-					matches = true
-				}
-				if strings.HasSuffix(prefix, "=") {
-					arg = strings.TrimPrefix(arg, prefix)
-				} else if strings.HasSuffix(prefix, " ") {
-					tracker++
-					extra++
-					if tracker >= len(os.Args) {
-						return extra, hasCMDL, fmt.Errorf("missing value for %s", arg)
-					}
-					arg = os.Args[tracker]
-					consuming = true
-				}
-			}
-			if !matches {
-				continue
-			}
-			switch field.Type.Kind() {
-			case reflect.Bool:
-				val := matches
-				if strings.Contains(name, "%") {
-					_, err := fmt.Sscanf(arg, format, &val)
-					if err != nil {
-						return extra, hasCMDL, err
-					}
-				}
-				if val {
-					tracker++
-					extra++
-					consuming = true
-				}
-				if strings.Contains(opts, "invert") {
-					val = !val
-				}
-				value.Field(i).SetBool(val)
-			case reflect.String:
-				value.Field(i).SetString(arg)
-			case reflect.Struct:
-				var err error
-				tracker, hasCMDL, err = os.consume(value.Field(i), tracker)
-				if err != nil {
-					return extra, hasCMDL, err
-				}
-				if hasCMDL {
-					if tracker >= len(os.Args) {
-						break
-					}
-					continue
-				}
-				fallthrough
-			default:
-				switch field.Type.Kind() {
-				case reflect.Pointer:
-					value.Field(i).Set(reflect.New(field.Type.Elem()))
-				}
-				if reflect.PointerTo(field.Type).Implements(reflect.TypeOf([0]encoding.TextUnmarshaler{}).Elem()) {
-					if err := value.Field(i).Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(arg)); err != nil {
-						return extra, hasCMDL, xray.New(err)
-					}
-				} else {
-					var ptr = value.Field(i).Addr().Interface()
-					if field.Type.Kind() == reflect.Ptr {
-						ptr = value.Field(i).Interface()
-					}
-					if _, err := fmt.Sscanf(arg, format, ptr); err != nil {
-						return extra, hasCMDL, xray.New(err)
-					}
-				}
-			}
-		}
-		if tracker >= len(os.Args) {
-			break
+	return component
+}
+
+// scanArgument sets the value (or appends to the variadic slice) from a
+// positional argument. Components with a %v or %s verb are parsed like flag
+// values, any other verb (such as %x or %q) is scanned with [fmt.Sscanf].
+func scanArgument(value reflect.Value, component, arg string) error {
+	verb := component[len(component)-1]
+	if verb == 'v' || verb == 's' {
+		return parseValue(value, arg)
+	}
+	if value.Kind() == reflect.Slice {
+		elem := reflect.New(value.Type().Elem()).Elem()
+		if err := scanArgument(elem, component, arg); err != nil {
+			return err
 		}
+		value.Set(reflect.Append(value, elem))
+		return nil
+	}
+	format := component
+	if strings.HasPrefix(format, "%[") { // the argument index is for the scanner.
+		_, verbs, _ := strings.Cut(format, "]")
+		format = "%" + verbs
 	}
-	return extra, hasCMDL, nil
+	if value.Kind() == reflect.Pointer {
+		value.Set(reflect.New(value.Type().Elem()))
+	}
+	if reflect.PointerTo(value.Type()).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return xray.New(value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(arg)))
+	}
+	var ptr = value.Addr().Interface()
+	if value.Kind() == reflect.Pointer {
+		ptr = value.Interface()
+	}
+	if _, err := fmt.Sscanf(arg, format, ptr); err != nil {
+		return xray.New(err)
+	}
+	return nil
 }
 
+// Run the program with the given system, if no command matches the arguments
+// (or help is requested with 'help', '--help' or '-h') then help text is
+// written to Stdout, or else a usage error is returned.
 func (os System) Run(program any) error {
 	spec := api.StructureOf(program)
+	commands := commandsOf(spec, "")
 
-	fn, ok, err := os.match(spec)
+	if words, ok := isHelp(commands, os.Args[min(1, len(os.Args)):]); ok {
+		return os.help(spec, commands, words)
+	}
+	cmd, ok, err := os.match(commands)
 	if err != nil {
 		return err
 	}
-
-	if !ok && len(os.Args) == 1 {
-		fmt.Fprintln(os.Stdout, spec.Docs)
-		return nil
-	} else if !ok {
-		return fmt.Errorf("unknown command: %s", os.Args[1])
+	if !ok {
+		return os.help(spec, commands, os.Args[1:])
 	}
+	fn := cmd.Function
 	var args = make([]reflect.Value, 0, fn.NumIn())
 	for i := 0; i < fn.NumIn(); i++ {
 		args = append(args, reflect.New(fn.In(i)).Elem())
 	}
+	argv, err := os.parseFlags(cmd, args, os.Args[1:])
+	if err != nil {
+		return err
+	}
+	argv = append([]string{os.Args[0]}, argv...)
 	var (
 		scanner     = api.NewArgumentScanner(args)
 		tracker int = 1
 	)
-	for _, component := range strings.Split(strings.Split(string(fn.Tags.Get("cmdl")), ",")[0], " ") {
+	for _, component := range strings.Split(cmd.Usage, " ") {
+		if tracker >= len(argv) {
+			if len(component) > 0 && component[0] == '%' {
+				value, err := scanner.Scan(scanFormat(component))
+				if err != nil {
+					return err
+				}
+				if value.Kind() != reflect.Struct && value.Kind() != reflect.Pointer && !(fn.Type.IsVariadic() && value.Kind() == reflect.Slice) {
+					return fmt.Errorf("missing %s, usage: %s", placeholderFor(value.Type()), usageOf(cmd))
+				}
+			}
+			continue
+		}
 		if len(component) > 0 && component[0] == '%' {
-			value, err := scanner.Scan(component)
+			value, err := scanner.Scan(scanFormat(component))
 			if err != nil {
 				return err
 			}
-			var arg = os.Args[tracker]
+			var arg = argv[tracker]
 			switch value.Kind() {
 			case reflect.Interface:
 				if reflect.TypeFor[fs.File]().Implements(value.Type()) {
@@ -225,53 +185,30 @@ func (os System) Run(program any) error {
 			case reflect.String:
 				value.SetString(arg)
 			case reflect.Slice:
-				switch value.Type().Elem() {
-				case reflect.TypeOf(""):
-					if fn.Type.IsVariadic() && value.Addr().Interface() == args[len(args)-1].Addr().Interface() {
-						value.Set(reflect.ValueOf(os.Args[tracker:]))
-					} else {
-						return fmt.Errorf("cannot set %s to %s", value.Type(), arg)
-					}
-				default:
+				if !fn.Type.IsVariadic() || value.Addr().Interface() != args[len(args)-1].Addr().Interface() {
 					return fmt.Errorf("cannot set %s to %s", value.Type(), arg)
 				}
-			case reflect.Struct:
-				count, hasCMDL, err := os.consume(value, tracker)
-				if err != nil {
-					return err
-				}
-				tracker += count
-				if hasCMDL {
-					if len(os.Args) <= tracker {
-						break
+				for _, arg := range argv[tracker:] {
+					if err := scanArgument(value, component, arg); err != nil {
+						return fmt.Errorf("invalid argument %q: %w", arg, err)
 					}
-					continue
+				}
+				tracker = len(argv)
+			case reflect.Struct:
+				if isOptions(value.Type()) {
+					continue // already set by parseFlags.
 				}
 				fallthrough
 			default:
-				switch value.Type().Kind() {
-				case reflect.Pointer:
-					value.Set(reflect.New(value.Type().Elem()))
-				}
-				if reflect.PointerTo(value.Type()).Implements(reflect.TypeOf([0]encoding.TextUnmarshaler{}).Elem()) {
-					if err := value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(arg)); err != nil {
-						return xray.New(err)
-					}
-				} else {
-					var ptr = value.Addr().Interface()
-					if value.Type().Kind() == reflect.Ptr {
-						ptr = value.Interface()
-					}
-					if _, err := fmt.Sscanf(arg, component, ptr); err != nil {
-						return xray.New(err)
-					}
+				if err := scanArgument(value, component, arg); err != nil {
+					return fmt.Errorf("invalid argument %q: %w", arg, err)
 				}
 			}
 		}
 		tracker++
-		if len(os.Args) <= tracker {
-			break
-		}
+	}
+	if tracker < len(argv) {
+		return fmt.Errorf("unexpected argument %q, usage: %s", argv[tracker], usageOf(cmd))
 	}
 	if fn.Type.IsVariadic() {
 		slice := args[len(args)-1]
@@ -311,44 +248,38 @@ func (os System) Run(program any) error {
 	return nil
 }
 
-func (os System) match(spec api.Structure) (api.Function, bool, error) {
+func (os System) match(commands []command) (command, bool, error) {
 	var match struct {
-		api.Function
+		command
 
 		Score int
 	}
 	if len(os.Args) == 1 {
-		return match.Function, false, nil
+		return match.command, false, nil
 	}
-	for _, fn := range spec.Functions {
-		tag := fn.Tags.Get("cmdl")
-
+	for _, cmd := range commands {
 		var matching bool = true
-		args := strings.Split(string(tag), " ")
+		args := strings.Split(cmd.Usage, " ")
 		var score = 0
 		for i, arg := range args {
 			if len(arg) > 0 && arg[0] == '%' {
 				score++
 				continue
 			}
-			if len(os.Args) > i+1 {
-				if arg == os.Args[i+1] {
-					score += 2
-					continue
-				} else {
-					break
-				}
-			} else {
-				break
+			if len(os.Args) > i+1 && arg == os.Args[i+1] {
+				score += 2
+				continue
 			}
+			matching = false
+			break
 		}
 		if matching && score > match.Score {
-			match.Function = fn
+			match.command = cmd
 			match.Score = score
 		}
 	}
 	if match.Score == 0 {
-		return match.Function, false, nil
+		return match.command, false, nil
 	}
-	return match.Function, true, nil
+	return match.command, true, nil
 }
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"runtime.link/api"
 	"runtime.link/api/cmdl"
@@ -28,6 +29,8 @@ func TestCommandLine(T *testing.T) {
 		WithPositional func(context.Context, string, Options) (string, error) `cmdl:"pos %[2]v %[1]v"`
 
 		DoSomething func(context.Context) (string, error) `cmdl:"something"`
+
+		Hex func(context.Context, int) (string, error) `cmdl:"hex %x"`
 	}
 	program := API{
 		Main: func(ctx context.Context, opts Options) (string, error) {
@@ -57,6 +60,9 @@ func TestCommandLine(T *testing.T) {
 		DoSomething: func(context.Context) (string, error) {
 			return "DoSomething", nil
 		},
+		Hex: func(_ context.Context, n int) (string, error) {
+			return strconv.Itoa(n), nil
+		},
 	}
 	exec := func(args string) cmdl.System {
 		return cmdl.System{
@@ -83,4 +89,135 @@ func TestCommandLine(T *testing.T) {
 	expect(exec("test --flag-int=42").Output(program))("42")
 	expect(exec("test --flag-pointer=0").Output(program))("0")
 	expect(exec("test pos --flag hello").Output(program))("hello")
+	expect(exec("test hex ff").Output(program))("255")
+}
+
+func TestHelp(T *testing.T) {
+	type Options struct {
+		Verbose bool `cmdl:"--verbose"
+			print more output`
+		Name string `cmdl:"--name=%v"
+			name to greet`
+	}
+	type API struct {
+		api.Specification `
+			greets people.`
+
+		Hello func(context.Context, string, Options) (string, error) `cmdl:"hello %v %v"
+			says hello to someone.`
+
+		Remote struct {
+			Add func(context.Context, string) (string, error) `cmdl:"add %v"
+				adds a remote.`
+		} `cmdl:"remote"`
+	}
+	var program API
+	program.Hello = func(_ context.Context, who string, opts Options) (string, error) {
+		return "hello " + who, nil
+	}
+	program.Remote.Add = func(_ context.Context, name string) (string, error) {
+		return "added " + name, nil
+	}
+	run := func(args ...string) (string, error) {
+		out, err := cmdl.System{Args: append([]string{"test"}, args...)}.Output(&program)
+		return string(out), err
+	}
+	out, err := run()
+	if err != nil {
+		T.Fatal(err)
+	}
+	for _, expect := range []string{"greets people.", "hello <string> [options]", "says hello to someone.", "remote add <string>", "adds a remote."} {
+		if !strings.Contains(out, expect) {
+			T.Fatalf("expected %q in help:\n%s", expect, out)
+		}
+	}
+	out, err = run("help", "hello")
+	if err != nil {
+		T.Fatal(err)
+	}
+	for _, expect := range []string{"Usage: test hello <string> [options]", "--verbose", "print more output", "--name=<string>", "name to greet"} {
+		if !strings.Contains(out, expect) {
+			T.Fatalf("expected %q in help:\n%s", expect, out)
+		}
+	}
+	if out, err = run("remote", "--help"); err != nil || !strings.Contains(out, "remote add <string>") {
+		T.Fatalf("unexpected namespace help %q: %v", out, err)
+	}
+	if out, err = run("remote", "add", "origin"); err != nil || out != "added origin\n" {
+		T.Fatalf("unexpected nested command output %q: %v", out, err)
+	}
+	if _, err = run("helo", "bob"); err == nil || !strings.Contains(err.Error(), "did you mean 'hello'?") {
+		T.Fatalf("expected a suggestion, got %v", err)
+	}
+	if _, err = run("remote", "ad", "origin"); err == nil || !strings.Contains(err.Error(), "did you mean 'remote add'?") {
+		T.Fatalf("expected a nested suggestion, got %v", err)
+	}
+	if _, err = run("remote", "add"); err == nil || !strings.Contains(err.Error(), "missing <string>") {
+		T.Fatalf("expected a usage error, got %v", err)
+	}
+	if out, err = run("hello", "--verbose", "bob"); err != nil || out != "hello bob\n" {
+		T.Fatalf("unexpected output with a flag after the command %q: %v", out, err)
+	}
+	for _, args := range [][]string{{"hello", "bob", "extra"}, {"remote", "add", "origin", "extra"}} {
+		if _, err = run(args...); err == nil || !strings.Contains(err.Error(), `unexpected argument "extra", usage:`) {
+			T.Fatalf("expected a usage error for %v, got %v", args, err)
+		}
+	}
+}
+
+func TestFlags(T *testing.T) {
+	type Options struct {
+		Name    string            `cmdl:"--name=%v,required"`
+		Count   int               `cmdl:"-n %v,default=3"`
+		Tags    []string          `cmdl:"--tag=%v"`
+		Labels  map[string]int    `cmdl:"--label %v"`
+		Wait    time.Duration     `cmdl:"--wait=%v,default=1s"`
+		Force   bool              `cmdl:"--force=%v"`
+		Color   bool              `cmdl:"--color,invert"`
+		Token   string            `cmdl:"--token=%v,env=TOKEN"`
+		Home    string            `cmdl:"HOME,env"`
+		Nothing map[string]string `cmdl:"-"`
+	}
+	var got Options
+	var files []string
+	var program struct {
+		Run func(context.Context, Options, ...string) error `cmdl:"run %v %v"`
+	}
+	program.Run = func(_ context.Context, opts Options, args ...string) error {
+		got, files = opts, args
+		return nil
+	}
+	run := func(args ...string) error {
+		got, files = Options{}, nil
+		_, err := cmdl.System{
+			Args:    append([]string{"test", "run"}, args...),
+			Environ: []string{"TOKEN=secret", "HOME=/home/test"},
+		}.Output(&program)
+		return err
+	}
+	if err := run("--name", "bob", "a.txt", "-n7", "--tag=x", "--tag", "y", "--label", "a=1", "--wait=2m", "--force", "--color", "--", "--name"); err != nil {
+		T.Fatal(err)
+	}
+	if got.Name != "bob" || got.Count != 7 || strings.Join(got.Tags, ",") != "x,y" || got.Labels["a"] != 1 ||
+		got.Wait != 2*time.Minute || !got.Force || got.Color || got.Token != "secret" || got.Home != "/home/test" {
+		T.Fatalf("unexpected options %+v", got)
+	}
+	if strings.Join(files, ",") != "a.txt,--name" {
+		T.Fatalf("unexpected positional arguments %q", files)
+	}
+	if err := run("--name=alice", "--token", "other", "--no-force"); err != nil {
+		T.Fatal(err)
+	}
+	if got.Count != 3 || got.Wait != time.Second || !got.Color || got.Force || got.Token != "other" {
+		T.Fatalf("unexpected defaults %+v", got)
+	}
+	if err := run("-n", "1"); err == nil || !strings.Contains(err.Error(), "missing required flag --name") {
+		T.Fatalf("expected a required flag error, got %v", err)
+	}
+	if err := run("--name=bob", "-n", "x"); err == nil || !strings.Contains(err.Error(), "invalid value") {
+		T.Fatalf("expected an invalid value error, got %v", err)
+	}
+	if err := run("--name=bob", "--forse"); err == nil || !strings.Contains(err.Error(), "did you mean '--force'?") {
+		T.Fatalf("expected an unknown flag error, got %v", err)
+	}
 }
@@ -0,0 +1,112 @@
+package cmdl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"runtime.link/api"
+)
+
+// Manual writes a roff man page (section 1) for the program, that documents
+// each command along with its options and environment variables.
+//
+//	cmdl.Manual(os.Stdout, "example", &program)
+func Manual(w io.Writer, name string, program any) error {
+	var (
+		spec     = api.StructureOf(program)
+		commands = commandsOf(spec, "")
+		b        strings.Builder
+	)
+	summary, _, _ := strings.Cut(spec.Docs, "\n")
+	fmt.Fprintf(&b, ".TH %s 1\n", roff(strings.ToUpper(name)))
+	fmt.Fprintf(&b, ".SH NAME\n")
+	if summary != "" {
+		fmt.Fprintf(&b, "%s \\- %s\n", roff(name), roff(summary))
+	} else {
+		fmt.Fprintf(&b, "%s\n", roff(name))
+	}
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n")
+	for i, cmd := range commands {
+		if i > 0 {
+			fmt.Fprintf(&b, ".br\n")
+		}
+		fmt.Fprintf(&b, ".B %s\n%s\n", roff(name), roff(usageOf(cmd)))
+	}
+	if spec.Docs != "" {
+		fmt.Fprintf(&b, ".SH DESCRIPTION\n")
+		writeParagraphs(&b, spec.Docs)
+	}
+	var env []option
+	if len(commands) > 0 {
+		fmt.Fprintf(&b, ".SH COMMANDS\n")
+	}
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, ".TP\n.B %s\n", roff(usageOf(cmd)))
+		writeParagraphs(&b, cmd.Docs)
+		var flags []option
+		for _, opt := range optionsOf(cmd.Function) {
+			if opt.Env {
+				env = append(env, opt)
+				continue
+			}
+			flags = append(flags, opt)
+		}
+		if len(flags) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, ".RS\n")
+		for _, opt := range flags {
+			form := strings.ReplaceAll(opt.Form, "%v", placeholderFor(opt.Field.Type))
+			fmt.Fprintf(&b, ".TP\n.B %s\n", roff(form))
+			writeParagraphs(&b, opt.Docs)
+			if opt.Dir {
+				fmt.Fprintf(&b, "Sets the working directory.\n")
+			}
+			if opt.Invert {
+				fmt.Fprintf(&b, "Enabled by default, this flag disables it.\n")
+			}
+		}
+		fmt.Fprintf(&b, ".RE\n")
+	}
+	if len(env) > 0 {
+		fmt.Fprintf(&b, ".SH ENVIRONMENT\n")
+		seen := make(map[string]bool)
+		for _, opt := range env {
+			if seen[opt.Name] {
+				continue
+			}
+			seen[opt.Name] = true
+			fmt.Fprintf(&b, ".TP\n.B %s\n", roff(opt.Name))
+			writeParagraphs(&b, opt.Docs)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeParagraphs writes the documentation, where blank lines separate
+// paragraphs.
+func writeParagraphs(b *strings.Builder, docs string) {
+	for i, paragraph := range strings.Split(strings.TrimSpace(docs), "\n\n") {
+		if paragraph == "" {
+			continue
+		}
+		if i > 0 {
+			fmt.Fprintf(b, ".PP\n")
+		}
+		for _, line := range strings.Split(paragraph, "\n") {
+			fmt.Fprintf(b, "%s\n", roff(strings.TrimSpace(line)))
+		}
+	}
+}
+
+// roff escapes text for use within a roff document.
+func roff(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\e`)
+	text = strings.ReplaceAll(text, "-", `\-`)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
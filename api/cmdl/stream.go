@@ -0,0 +1,259 @@
+package cmdl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"runtime.link/api"
+	"runtime.link/api/xray"
+	"runtime.link/xyz"
+)
+
+var (
+	readCloserType = reflect.TypeFor[io.ReadCloser]()
+	seqType        = reflect.TypeFor[iter.Seq[string]]()
+	seq2Type       = reflect.TypeFor[iter.Seq2[string, error]]()
+	errType        = reflect.TypeFor[error]()
+)
+
+// isStream reports whether the result type streams stdout back to the
+// caller, whilst the command is still running.
+func isStream(rtype reflect.Type) bool {
+	switch rtype {
+	case readCloserType, seqType, seq2Type:
+		return true
+	}
+	return false
+}
+
+// process is a command, whose stdout is read by the caller. Nothing is
+// allocated for the command until it is started. Once stdout has been read,
+// Read returns any error the command failed with. Close stops the command,
+// if it is still running.
+type process struct {
+	ctx     context.Context
+	command func(context.Context) *exec.Cmd
+	tee     io.Writer // also receives stderr, if non-nil.
+	errorOf func(err error, stderr string) error
+
+	cancel context.CancelFunc
+	stdout *os.File
+	stderr tail
+
+	done chan struct{}
+	err  error
+}
+
+func (p *process) start() error {
+	stdoutRead, stdoutWrite, err := os.Pipe()
+	if err != nil {
+		return xray.New(err)
+	}
+	running, cancel := context.WithCancel(p.ctx)
+	cmd := p.command(running)
+	setupOperatingSystemSpecificsFor(cmd, stdoutWrite)
+	cmd.Stdout = stdoutWrite
+	cmd.Stderr = &p.stderr
+	if p.tee != nil {
+		cmd.Stderr = io.MultiWriter(&p.stderr, p.tee)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		closeAll(stdoutRead, stdoutWrite)
+		return xray.New(err)
+	}
+	p.cancel, p.stdout = cancel, stdoutRead
+	p.done = make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		stdoutWrite.Close()
+		if err != nil {
+			p.err = p.errorOf(err, p.stderr.String())
+		}
+		close(p.done)
+	}()
+	return nil
+}
+
+func (p *process) Read(b []byte) (int, error) {
+	n, err := p.stdout.Read(b)
+	if err == io.EOF && p.done != nil {
+		<-p.done
+		if p.err != nil {
+			return n, p.err
+		}
+	}
+	return n, err
+}
+
+func (p *process) Close() error {
+	if p.done == nil {
+		return nil
+	}
+	p.cancel()
+	closeAll(p.stdout)
+	<-p.done
+	return nil
+}
+
+// lines starts the command and yields each line it writes to stdout,
+// the command is stopped if the caller stops early.
+func (p *process) lines(yield func(string, error) bool) {
+	if p.done != nil {
+		yield("", errors.New("cmdl: command output has already been read"))
+		return
+	}
+	if err := p.start(); err != nil {
+		yield("", err)
+		return
+	}
+	defer p.Close()
+	reader := bufio.NewReader(p)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if !yield(strings.TrimSuffix(line, "\n"), nil) {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				yield("", err)
+			}
+			return
+		}
+	}
+}
+
+// tail keeps the last 64KB written to it, so that long running commands
+// can write to stderr without bounds.
+type tail struct {
+	mutex sync.Mutex
+	data  []byte
+}
+
+const tailSize = 64 << 10
+
+func (t *tail) Write(b []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.data = append(t.data, b...)
+	if len(t.data) > 2*tailSize {
+		t.data = slices.Clone(t.data[len(t.data)-tailSize:])
+	}
+	return len(b), nil
+}
+
+func (t *tail) String() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return string(t.data[max(0, len(t.data)-tailSize):])
+}
+
+func closeAll[T io.Closer](closers ...T) {
+	for _, closer := range closers {
+		closer.Close()
+	}
+}
+
+// errorOf returns the error for a command that failed with the given
+// stderr. The first scenario with a cmdl tag that matches the exit code
+// ('exit=N') and/or the stderr ('stderr=regexp') is returned, with the
+// stderr as its error value.
+func errorOf(spec api.Structure, err error, stderr string) error {
+	cause := err
+	if strings.TrimSpace(stderr) != "" {
+		cause = errors.New(stderr)
+	}
+	code := -1
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		code = exit.ExitCode()
+	}
+	for _, scenario := range spec.Scenarios {
+		tag, ok := scenario.Tags.Lookup("cmdl")
+		if !ok || !matchScenario(tag, code, stderr) {
+			continue
+		}
+		if value, ok := scenarioError(spec, scenario, cause); ok {
+			return value
+		}
+	}
+	if cause != err {
+		return cause
+	}
+	return xray.New(err)
+}
+
+// matchScenario reports whether the cmdl tag of a scenario matches the
+// exit code and stderr of a command.
+func matchScenario(tag string, code int, stderr string) bool {
+	exit, pattern, hasPattern := strings.Cut(tag, "stderr=")
+	exit = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(exit), ","))
+	if exit == "" && !hasPattern {
+		return false
+	}
+	if exit != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(exit, "exit="))
+		if err != nil || n != code {
+			return false
+		}
+	}
+	if hasPattern {
+		re, err := regexp.Compile(pattern)
+		if err != nil || !re.MatchString(stderr) {
+			return false
+		}
+	}
+	return true
+}
+
+// scenarioError returns the registered error value for the scenario,
+// holding the given cause.
+func scenarioError(spec api.Structure, scenario api.Scenario, cause error) (error, bool) {
+	for _, rtype := range spec.Instances[errType] {
+		variant, ok := reflect.Zero(rtype).Interface().(interface{ Reflection() []xyz.CaseReflection })
+		if !ok || !slices.ContainsFunc(variant.Reflection(), func(c xyz.CaseReflection) bool {
+			return c.Name == scenario.Name && c.Tags == scenario.Tags
+		}) {
+			continue
+		}
+		values := reflect.Zero(rtype).MethodByName("Values")
+		if !values.IsValid() || values.Type().NumIn() != 1 || values.Type().NumOut() != 1 {
+			continue
+		}
+		value := values.Call([]reflect.Value{reflect.Zero(values.Type().In(0))})[0].FieldByName(scenario.Name)
+		if !value.IsValid() {
+			continue
+		}
+		if value.Type() == rtype {
+			return value.Interface().(error), true
+		}
+		with := value.MethodByName("New")
+		if !with.IsValid() || with.Type().NumIn() != 1 || with.Type().Out(0) != rtype {
+			continue
+		}
+		var arg reflect.Value
+		switch in := with.Type().In(0); {
+		case in == errType:
+			arg = reflect.ValueOf(&cause).Elem()
+		case in.Kind() == reflect.String:
+			arg = reflect.ValueOf(strings.TrimSpace(cause.Error())).Convert(in)
+		default:
+			arg = reflect.Zero(in)
+		}
+		return with.Call([]reflect.Value{arg})[0].Interface().(error), true
+	}
+	return nil, false
+}
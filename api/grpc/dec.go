@@ -0,0 +1,309 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// Decoder for the GRPC Protocol Buffers format, reads values
+// encoded by an [Encoder].
+type Decoder struct {
+	r io.Reader
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads all remaining protocol buffer data from the underlying
+// reader and decodes field 1 into the value pointed to by v.
+func (d *Decoder) Decode(v any) error {
+	rvalue := reflect.ValueOf(v)
+	if rvalue.Kind() != reflect.Ptr || rvalue.IsNil() {
+		return errors.New("grpc: Decode requires a non-nil pointer")
+	}
+	buf, err := io.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	return decodeFields(buf, func(num wireNumber) (reflect.Value, bool) {
+		return rvalue.Elem(), num == 1
+	})
+}
+
+// decodeFields decodes each field in the given message with the value
+// returned by lookup, unknown fields are skipped.
+func decodeFields(b []byte, lookup func(wireNumber) (reflect.Value, bool)) error {
+	for len(b) > 0 {
+		num, typ, n := consumeTag(b)
+		if n < 0 {
+			return parseError(n)
+		}
+		b = b[n:]
+		rvalue, ok := lookup(num)
+		if !ok {
+			n = consumeFieldValue(num, typ, b)
+			if n < 0 {
+				return parseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		n, err := decodeValue(num, typ, b, rvalue)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// decodeMessage decodes the fields of message b into the given struct value.
+func decodeMessage(b []byte, rvalue reflect.Value) error {
+	rtype := rvalue.Type()
+	if rtype.Implements(taggedType) {
+		return decodeVariant(b, rvalue)
+	}
+	var fields = make(map[wireNumber]int, rtype.NumField())
+	for i := range rtype.NumField() {
+		if num, ok := fieldNumber(rtype.Field(i)); ok {
+			fields[num] = i
+		}
+	}
+	return decodeFields(b, func(num wireNumber) (reflect.Value, bool) {
+		i, ok := fields[num]
+		if !ok {
+			return reflect.Value{}, false
+		}
+		return rvalue.Field(i), true
+	})
+}
+
+// decodeVariant decodes a oneof (as encoded by [variantOf]) into the given
+// tagged union, the field number selects the case and any unknown cases
+// are skipped.
+func decodeVariant(b []byte, rvalue reflect.Value) error {
+	rtype := rvalue.Type()
+	values := reflect.Zero(rtype).MethodByName("Values")
+	if !values.IsValid() || values.Type().NumIn() != 1 || values.Type().NumOut() != 1 {
+		return fmt.Errorf("grpc: cannot decode a oneof into %v", rtype)
+	}
+	var (
+		cases   = reflect.Zero(rtype).Interface().(taggedValue).Reflection()
+		index   = -1
+		payload reflect.Value
+	)
+	if err := decodeFields(b, func(num wireNumber) (reflect.Value, bool) {
+		if num < 1 || int(num) > len(cases) {
+			return reflect.Value{}, false
+		}
+		index = int(num) - 1
+		switch vary := cases[index].Vary; vary {
+		case nil:
+			payload = reflect.New(reflect.TypeFor[bool]()).Elem()
+		case errType:
+			payload = reflect.New(reflect.TypeFor[string]()).Elem()
+		default:
+			payload = reflect.New(vary).Elem()
+		}
+		return payload, true
+	}); err != nil {
+		return err
+	}
+	if index < 0 {
+		rvalue.SetZero()
+		return nil
+	}
+	field := values.Call([]reflect.Value{reflect.Zero(values.Type().In(0))})[0].Field(index)
+	if field.Type() == rtype {
+		rvalue.Set(field)
+		return nil
+	}
+	as := field.MethodByName("As")
+	if !as.IsValid() || as.Type().NumIn() != 1 || as.Type().NumOut() != 1 {
+		return fmt.Errorf("grpc: cannot decode case %d of %v", index+1, rtype)
+	}
+	if cases[index].Vary == errType {
+		payload = reflect.ValueOf(errors.New(payload.String()))
+	}
+	rvalue.Set(as.Call([]reflect.Value{payload.Convert(as.Type().In(0))})[0])
+	return nil
+}
+
+// decodeValue decodes a single field value of the given wire type into
+// rvalue, returning the number of bytes consumed from b.
+func decodeValue(num wireNumber, typ wireType, b []byte, rvalue reflect.Value) (int, error) {
+	rtype := rvalue.Type()
+	mismatch := func() (int, error) {
+		return 0, fmt.Errorf("grpc: cannot decode wire type %d into %v (field %d)", typ, rtype, num)
+	}
+	switch rtype.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		if typ != typeVarint {
+			return mismatch()
+		}
+		v, n := consumeVarint(b)
+		if n < 0 {
+			return 0, parseError(n)
+		}
+		switch rtype.Kind() {
+		case reflect.Bool:
+			rvalue.SetBool(decodeBool(v))
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+			rvalue.SetUint(v)
+		default:
+			rvalue.SetInt(int64(v))
+		}
+		return n, nil
+	case reflect.Float32:
+		if typ != typeFixed32 {
+			return mismatch()
+		}
+		v, n := consumeFixed32(b)
+		if n < 0 {
+			return 0, parseError(n)
+		}
+		rvalue.SetFloat(float64(math.Float32frombits(v)))
+		return n, nil
+	case reflect.Float64:
+		if typ != typeFixed64 {
+			return mismatch()
+		}
+		v, n := consumeFixed64(b)
+		if n < 0 {
+			return 0, parseError(n)
+		}
+		rvalue.SetFloat(math.Float64frombits(v))
+		return n, nil
+	case reflect.String:
+		if typ != typeBytes {
+			return mismatch()
+		}
+		v, n := consumeString(b)
+		if n < 0 {
+			return 0, parseError(n)
+		}
+		rvalue.SetString(v)
+		return n, nil
+	case reflect.Slice:
+		if rtype.Elem().Kind() == reflect.Uint8 {
+			if typ != typeBytes {
+				return mismatch()
+			}
+			v, n := consumeBytes(b)
+			if n < 0 {
+				return 0, parseError(n)
+			}
+			rvalue.SetBytes(append([]byte(nil), v...))
+			return n, nil
+		}
+		elem := rtype.Elem()
+		if typ == typeBytes && isScalar(elem) {
+			// packed repeated scalars.
+			v, n := consumeBytes(b)
+			if n < 0 {
+				return 0, parseError(n)
+			}
+			var etyp = typeVarint
+			switch elem.Kind() {
+			case reflect.Float32:
+				etyp = typeFixed32
+			case reflect.Float64:
+				etyp = typeFixed64
+			}
+			for len(v) > 0 {
+				item := reflect.New(elem).Elem()
+				m, err := decodeValue(num, etyp, v, item)
+				if err != nil {
+					return 0, err
+				}
+				rvalue.Set(reflect.Append(rvalue, item))
+				v = v[m:]
+			}
+			return n, nil
+		}
+		item := reflect.New(elem).Elem()
+		n, err := decodeValue(num, typ, b, item)
+		if err != nil {
+			return 0, err
+		}
+		rvalue.Set(reflect.Append(rvalue, item))
+		return n, nil
+	case reflect.Map:
+		if typ != typeBytes {
+			return mismatch()
+		}
+		v, n := consumeBytes(b)
+		if n < 0 {
+			return 0, parseError(n)
+		}
+		if rvalue.IsNil() {
+			rvalue.Set(reflect.MakeMap(rtype))
+		}
+		key := reflect.New(rtype.Key()).Elem()
+		val := reflect.New(rtype.Elem()).Elem()
+		if err := decodeFields(v, func(num wireNumber) (reflect.Value, bool) {
+			switch num {
+			case 1:
+				return key, true
+			case 2:
+				return val, true
+			}
+			return reflect.Value{}, false
+		}); err != nil {
+			return 0, err
+		}
+		rvalue.SetMapIndex(key, val)
+		return n, nil
+	case reflect.Array:
+		if typ != typeBytes {
+			return mismatch()
+		}
+		v, n := consumeBytes(b)
+		if n < 0 {
+			return 0, parseError(n)
+		}
+		if err := decodeFields(v, func(num wireNumber) (reflect.Value, bool) {
+			if int(num) > rvalue.Len() {
+				return reflect.Value{}, false
+			}
+			return rvalue.Index(int(num) - 1), true
+		}); err != nil {
+			return 0, err
+		}
+		return n, nil
+	case reflect.Pointer:
+		if rvalue.IsNil() {
+			rvalue.Set(reflect.New(rtype.Elem()))
+		}
+		return decodeValue(num, typ, b, rvalue.Elem())
+	case reflect.Struct:
+		if typ != typeBytes {
+			return mismatch()
+		}
+		v, n := consumeBytes(b)
+		if n < 0 {
+			return 0, parseError(n)
+		}
+		if err := decodeMessage(v, rvalue); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("grpc: unsupported type %v", rtype)
+}
+
+// isScalar reports whether values of the given type can be packed.
+func isScalar(rtype reflect.Type) bool {
+	switch rtype.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -5,9 +5,13 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"unsafe"
 
 	_ "unsafe"
+
+	"runtime.link/xyz"
 )
 
 // Encoder for the GRPC Protocol Buffers format
@@ -61,6 +65,9 @@ func sizeof(n wireNumber, rvalue reflect.Value) (int, int) {
 		}
 		return sizeTag(n) + sizeVarint(uint64(size)), size
 	case reflect.Slice:
+		if rtype.Elem().Kind() == reflect.Uint8 {
+			return sizeTag(n) + sizeVarint(uint64(rvalue.Len())), rvalue.Len()
+		}
 		var size int
 		for i := 0; i < rvalue.Len(); i++ {
 			head, body := sizeof(n, rvalue.Index(i))
@@ -75,9 +82,21 @@ func sizeof(n wireNumber, rvalue reflect.Value) (int, int) {
 		}
 		return sizeof(n, rvalue.Elem())
 	case reflect.Struct:
+		if rtype.Implements(taggedType) {
+			num, value, ok := variantOf(rvalue)
+			if !ok {
+				return sizeTag(n) + sizeVarint(0), 0
+			}
+			head, body := sizeof(num, value)
+			return sizeTag(n) + sizeVarint(uint64(head+body)), head + body
+		}
 		var size int
 		for i := range rvalue.NumField() {
-			head, body := sizeof(wireNumber(i)+1, rvalue.Field(i))
+			num, ok := fieldNumber(rtype.Field(i))
+			if !ok {
+				continue
+			}
+			head, body := sizeof(num, rvalue.Field(i))
 			size += head + body
 		}
 		return sizeTag(n) + sizeVarint(uint64(size)), size
@@ -137,6 +156,15 @@ func (e *Encoder) encode(n wireNumber, rvalue reflect.Value) error {
 		}
 		return nil
 	case reflect.Slice:
+		if rtype.Elem().Kind() == reflect.Uint8 {
+			slice = appendTag(slice[:], n, typeBytes)
+			slice = appendVarint(slice[:], uint64(rvalue.Len()))
+			if _, err := write(e.w, slice); err != nil {
+				return err
+			}
+			_, err := write(e.w, rvalue.Bytes())
+			return err
+		}
 		for i := 0; i < rvalue.Len(); i++ {
 			if err := e.encode(n, rvalue.Index(i)); err != nil {
 				return err
@@ -157,14 +185,88 @@ func (e *Encoder) encode(n wireNumber, rvalue reflect.Value) error {
 		if _, err := write(e.w, slice); err != nil {
 			return err
 		}
-		for i := 0; i < rvalue.NumField(); i++ {
-			if err := e.encode(wireNumber(i)+1, rvalue.Field(i)); err != nil {
-				return err
+		return e.fields(rvalue)
+	}
+	return errors.New("unsupported type")
+}
+
+// fields encodes the fields of the given struct value, without any
+// enclosing tag or length, such that the struct is encoded as a
+// top-level message.
+func (e *Encoder) fields(rvalue reflect.Value) error {
+	rtype := rvalue.Type()
+	if rtype.Implements(taggedType) {
+		num, value, ok := variantOf(rvalue)
+		if !ok {
+			return nil
+		}
+		return e.encode(num, value)
+	}
+	for i := 0; i < rvalue.NumField(); i++ {
+		num, ok := fieldNumber(rtype.Field(i))
+		if !ok {
+			continue
+		}
+		if err := e.encode(num, rvalue.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldNumber returns the protobuf field number for the given struct
+// field, either from the leading number of its grpc tag, or else from
+// its position within the struct. Unexported fields and fields tagged
+// with `grpc:"-"` are not encoded.
+func fieldNumber(field reflect.StructField) (wireNumber, bool) {
+	if !field.IsExported() {
+		return 0, false
+	}
+	tag, _, _ := strings.Cut(field.Tag.Get("grpc"), ",")
+	if tag == "-" {
+		return 0, false
+	}
+	if tag != "" {
+		if num, err := strconv.Atoi(tag); err == nil && wireNumber(num).IsValid() {
+			return wireNumber(num), true
+		}
+	}
+	return wireNumber(field.Index[len(field.Index)-1]) + 1, true
+}
+
+// taggedValue is implemented by [xyz.Tagged] unions (and [api.Error]s).
+type taggedValue interface {
+	Reflection() []xyz.CaseReflection
+	Interface() any
+}
+
+var taggedType = reflect.TypeFor[taggedValue]()
+
+// variantOf returns the field number (the position of the case, starting
+// from 1) and value of the active case of the given tagged union, so that
+// it can be encoded as a oneof. Cases without a value are encoded as true
+// and errors are encoded as their message.
+func variantOf(rvalue reflect.Value) (wireNumber, reflect.Value, bool) {
+	union := rvalue.Interface().(taggedValue)
+	for i, c := range union.Reflection() {
+		if !c.Test(union) {
+			continue
+		}
+		num := wireNumber(i + 1)
+		if c.Vary == nil {
+			return num, reflect.ValueOf(true), true
+		}
+		value := union.Interface()
+		if c.Vary == errType {
+			var message string
+			if err, ok := value.(error); ok && err != nil {
+				message = err.Error()
 			}
+			return num, reflect.ValueOf(message), true
 		}
-		return nil
+		return num, reflect.ValueOf(&value).Elem(), true
 	}
-	return errors.New("unsupported type")
+	return 0, reflect.Value{}, false
 }
 
 //go:linkname sizeof_map_noescape runtime.link/api/grpc.sizeof_map
@@ -203,16 +305,15 @@ func sizeof_map(n wireNumber, rvalue reflect.Value) (int, int) {
 		val.SetIterValue(iter)
 		keyHead, keyBody := sizeof(1, key)
 		valHead, valBody := sizeof(2, val)
-		size += keyHead + keyBody + valHead + valBody
+		entry := keyHead + keyBody + valHead + valBody
+		size += sizeTag(n) + sizeVarint(uint64(entry)) + entry
 	}
-	return sizeTag(n) + sizeVarint(uint64(size)), size
+	return 0, size
 }
 
 func encode_map(e *Encoder, n wireNumber, rvalue reflect.Value) error {
 	var stack [16]byte
 	var slice = stack[0:0:cap(stack)]
-	_, size := sizeof_map(n, rvalue)
-	size /= rvalue.Len()
 	iter := rvalue.MapRange()
 	var keyBuf, valBuf [3]uintptr
 	var key, val reflect.Value
@@ -229,9 +330,11 @@ func encode_map(e *Encoder, n wireNumber, rvalue reflect.Value) error {
 	for iter.Next() {
 		key.SetIterKey(iter)
 		val.SetIterValue(iter)
+		keyHead, keyBody := sizeof(1, key)
+		valHead, valBody := sizeof(2, val)
 		slice = stack[0:0:cap(stack)]
 		slice = appendTag(slice[:], n, typeBytes)
-		slice = appendVarint(slice[:], uint64(size))
+		slice = appendVarint(slice[:], uint64(keyHead+keyBody+valHead+valBody))
 		if _, err := write(e.w, slice); err != nil {
 			return err
 		}
@@ -0,0 +1,504 @@
+/*
+Package grpc provides a gRPC transport, over HTTP/2 (including h2c) using only the standard library.
+
+	var API struct {
+		api.Specification `www:"http://localhost:50051" grpc:"helloworld.Greeter"`
+
+		SayHello func(context.Context, HelloRequest) (HelloReply, error)
+	}
+
+When SayHello is called, it calls the '/helloworld.Greeter/SayHello'
+method on the host and returns the result. The same structure can be
+served with [Handler] or [ListenAndServe].
+
+# Tags
+
+The grpc tag on the [api.Specification] names the service (defaults to
+the name of the structure), nested namespaces are named with the tag
+on their field, or else by the name of the field after the parent
+service name, ie. 'helloworld.Greeter.Admin'. Each function can have a
+grpc tag that names the method (defaults to the name of the function),
+or the full path of the method, if it begins with a slash.
+
+	SayHello func(HelloRequest) HelloReply `grpc:"SayHello"`
+	SayHello func(HelloRequest) HelloReply `grpc:"/helloworld.Greeter/SayHello"`
+
+Functions tagged with `grpc:"-"` are not linked or served.
+
+# Messages
+
+Struct fields are numbered by the leading number in their grpc tag,
+or else by their position within the struct (starting from 1).
+
+	type HelloRequest struct {
+		Name string `grpc:"1"`
+	}
+
+When a function has a single struct argument (or result), it is used
+as the message, otherwise each argument (or result) is a field of the
+message numbered by its position.
+
+# Streaming
+
+Functions that return a receive-only channel, an [iter.Seq] or an
+[iter.Seq2] are served as server-streaming methods, each value is sent
+as a separate message. An [iter.Seq2] whose second value is an [error]
+ends the stream with that error, otherwise the pair is sent as fields
+1 and 2 of each message.
+
+Linked clients keep the stream open until it has been received to the end
+or until the context of the call is done, so cancel the context of any
+stream that may not be consumed in full. Only an [iter.Seq2] whose second
+value is an [error] reports a transport error or a non-OK status at the
+end of the stream, channels and [iter.Seq] results simply end early.
+
+# Errors
+
+Errors are returned to callers as [Error] statuses. Any [api.Scenario]
+that matches the error can specify the status code to use with a grpc
+tag, ie. `grpc:"5"`, otherwise the code is derived from any HTTP
+status of the error. When the error is a registered [api.Error], it is
+also sent as a detail of the status (in the Grpc-Status-Details-Bin
+trailer).
+
+# Protocol Buffers
+
+[WriteProto] writes a .proto definition of the services, so that they
+can be called from other languages. [xyz.Tagged] unions are written
+(and encoded) as a oneof, numbered by the position of each case.
+*/
+package grpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"runtime.link/api"
+	http_api "runtime.link/api/internal/http"
+)
+
+// Code is a gRPC status code.
+type Code int
+
+// gRPC status codes.
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+var codeNames = [...]string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND",
+	"ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION",
+	"ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// String returns the canonical name of the status code, ie. NOT_FOUND.
+func (c Code) String() string {
+	if c >= 0 && int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "CODE(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Error is a non-OK gRPC status.
+type Error struct {
+	Code    Code
+	Message string
+
+	details []byte // encoded google.rpc.Status, if any.
+}
+
+// Error implements the [error] interface.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return "grpc: status " + strconv.Itoa(int(e.Code))
+	}
+	return e.Message
+}
+
+// Is reports whether the status represents the target error,
+// ie. [api.ErrNotImplemented] for [Unimplemented].
+func (e *Error) Is(target error) bool {
+	return e.Code == Unimplemented && target == api.ErrNotImplemented
+}
+
+// StatusHTTP returns the HTTP status code that corresponds
+// to the gRPC status code.
+func (e *Error) StatusHTTP() int {
+	for status, code := range codesHTTP {
+		if code == e.Code {
+			return status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+var codesHTTP = map[int]Code{
+	http.StatusBadRequest:          InvalidArgument,
+	http.StatusUnauthorized:        Unauthenticated,
+	http.StatusForbidden:           PermissionDenied,
+	http.StatusNotFound:            NotFound,
+	http.StatusConflict:            AlreadyExists,
+	http.StatusPreconditionFailed:  FailedPrecondition,
+	http.StatusTooManyRequests:     ResourceExhausted,
+	499:                            Canceled,
+	http.StatusInternalServerError: Internal,
+	http.StatusNotImplemented:      Unimplemented,
+	http.StatusServiceUnavailable:  Unavailable,
+	http.StatusGatewayTimeout:      DeadlineExceeded,
+}
+
+// maxMessageSize is the largest message that will be read.
+const maxMessageSize = 4 << 20
+
+// method describes a gRPC method.
+type method struct {
+	api.Function
+
+	Route string
+
+	streaming bool
+}
+
+// specification describes a gRPC API specification.
+type specification struct {
+	api.Structure
+
+	Methods map[string]method
+	Routes  []string // routes in declaration order.
+}
+
+func specificationOf(structure api.Structure) (specification, error) {
+	var spec = specification{
+		Structure: structure,
+		Methods:   make(map[string]method),
+	}
+	service := structure.Tags.Get("grpc")
+	if service == "" {
+		service = structure.Name
+	}
+	if err := spec.load(structure, service); err != nil {
+		return specification{}, err
+	}
+	return spec, nil
+}
+
+func (spec *specification) load(structure api.Structure, service string) error {
+	for _, fn := range structure.Functions {
+		tag := fn.Tags.Get("grpc")
+		if tag == "-" {
+			continue
+		}
+		var m = method{Function: fn}
+		switch {
+		case strings.HasPrefix(tag, "/"):
+			m.Route = tag
+		case tag != "":
+			m.Route = "/" + service + "/" + tag
+		default:
+			m.Route = "/" + service + "/" + fn.Name
+		}
+		if fn.NumOut() == 1 {
+			m.streaming = isStream(fn.Type.Out(0))
+		}
+		if existing, ok := spec.Methods[m.Route]; ok {
+			return fmt.Errorf("grpc: duplicate method '%s' (%s and %s)", m.Route,
+				strings.Join(append(slices.Clone(existing.Path), existing.Name), "."), strings.Join(append(slices.Clone(fn.Path), fn.Name), "."))
+		}
+		spec.Methods[m.Route] = m
+		spec.Routes = append(spec.Routes, m.Route)
+	}
+	for _, name := range slices.Sorted(maps.Keys(structure.Namespace)) {
+		child := structure.Namespace[name]
+		nested := child.Tags.Get("grpc")
+		if nested == "" {
+			nested = service + "." + name
+		}
+		if err := spec.load(child, nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStream reports whether values of the given type are
+// streamed as a sequence of messages.
+func isStream(rtype reflect.Type) bool {
+	if rtype.Kind() == reflect.Chan {
+		return rtype.ChanDir() == reflect.RecvDir
+	}
+	if rtype.Kind() != reflect.Func || rtype.NumIn() != 1 || rtype.NumOut() != 0 {
+		return false
+	}
+	yield := rtype.In(0)
+	return yield.Kind() == reflect.Func && (yield.NumIn() == 1 || yield.NumIn() == 2) &&
+		yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+}
+
+// isSeq2Error reports whether the given stream type is an
+// [iter.Seq2] whose second value is an [error].
+func isSeq2Error(rtype reflect.Type) bool {
+	return rtype.Kind() == reflect.Func && rtype.In(0).NumIn() == 2 && rtype.In(0).In(1) == errType
+}
+
+var errType = reflect.TypeOf([0]error{}).Elem()
+
+// elementsOf returns the types of the values that make up each
+// message of the given stream type.
+func elementsOf(rtype reflect.Type) []reflect.Type {
+	if rtype.Kind() == reflect.Chan {
+		return []reflect.Type{rtype.Elem()}
+	}
+	if isSeq2Error(rtype) {
+		return []reflect.Type{rtype.In(0).In(0)}
+	}
+	yield := rtype.In(0)
+	var types = make([]reflect.Type, yield.NumIn())
+	for i := range types {
+		types[i] = yield.In(i)
+	}
+	return types
+}
+
+// marshal encodes the given values as a message, a single struct value
+// is encoded as the message itself, otherwise each value is a field.
+func marshal(values []reflect.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if len(values) == 1 && isMessage(values[0].Type()) {
+		value := values[0]
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return nil, nil
+			}
+			value = value.Elem()
+		}
+		if err := enc.fields(value); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	for i, value := range values {
+		if err := enc.encode(wireNumber(i+1), value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshal decodes the message b into values of the given types, the
+// inverse of [marshal].
+func unmarshal(b []byte, types []reflect.Type) ([]reflect.Value, error) {
+	var values = make([]reflect.Value, len(types))
+	for i := range values {
+		values[i] = reflect.New(types[i]).Elem()
+	}
+	if len(types) == 1 && isMessage(types[0]) {
+		value := values[0]
+		for value.Kind() == reflect.Pointer {
+			value.Set(reflect.New(value.Type().Elem()))
+			value = value.Elem()
+		}
+		return values, decodeMessage(b, value)
+	}
+	return values, decodeFields(b, func(num wireNumber) (reflect.Value, bool) {
+		if int(num) > len(values) {
+			return reflect.Value{}, false
+		}
+		return values[num-1], true
+	})
+}
+
+func isMessage(rtype reflect.Type) bool {
+	for rtype.Kind() == reflect.Pointer {
+		rtype = rtype.Elem()
+	}
+	return rtype.Kind() == reflect.Struct
+}
+
+// writeFrame writes a length-prefixed (uncompressed) gRPC message.
+func writeFrame(w io.Writer, message []byte) error {
+	var header [5]byte
+	binary.BigEndian.PutUint32(header[1:], uint32(len(message)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(message)
+	return err
+}
+
+// readFrame reads a length-prefixed gRPC message.
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, &Error{Code: Unimplemented, Message: "grpc: compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxMessageSize {
+		return nil, &Error{Code: ResourceExhausted, Message: "grpc: message too large"}
+	}
+	message := make([]byte, size)
+	if _, err := io.ReadFull(r, message); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+// statusOf converts an error into a gRPC status, using the
+// scenarios of the function to determine the code.
+func statusOf(fn api.Function, err error) *Error {
+	var already *Error
+	if errors.As(err, &already) {
+		return already
+	}
+	var status = &Error{Code: Unknown, Message: err.Error()}
+	if errors.Is(err, api.ErrNotImplemented) {
+		status.Code = Unimplemented
+	}
+	var withStatus http_api.WithStatus
+	if errors.As(err, &withStatus) {
+		if code, ok := codesHTTP[withStatus.StatusHTTP()]; ok {
+			status.Code = code
+		}
+	}
+	for _, scenario := range fn.Root.Scenarios {
+		if scenario.Test(err) {
+			if code, ok := codeOf(scenario); ok {
+				status.Code = code
+			}
+			if scenario.Text != "" {
+				status.Message = scenario.Text
+			}
+			break
+		}
+	}
+	status.details = detailsOf(fn, status, err)
+	return status
+}
+
+// statusDetails is the google.rpc.Status message.
+type statusDetails struct {
+	Code    int32
+	Message string
+	Details []anyDetail
+}
+
+// anyDetail is the google.protobuf.Any message.
+type anyDetail struct {
+	TypeURL string
+	Value   []byte
+}
+
+// detailsOf returns the encoded google.rpc.Status for the given status,
+// with the registered error value (if any) within err as its detail.
+func detailsOf(fn api.Function, status *Error, err error) []byte {
+	for ; err != nil; err = errors.Unwrap(err) {
+		rtype := reflect.TypeOf(err)
+		if !rtype.Implements(taggedType) || !slices.Contains(fn.Root.Instances[errType], rtype) {
+			continue
+		}
+		value, err := marshal([]reflect.Value{reflect.ValueOf(err)})
+		if err != nil {
+			return nil
+		}
+		service := fn.Root.Tags.Get("grpc")
+		if service == "" {
+			service = fn.Root.Name
+		}
+		details, err := marshal([]reflect.Value{reflect.ValueOf(statusDetails{
+			Code:    int32(status.Code),
+			Message: status.Message,
+			Details: []anyDetail{{
+				TypeURL: "type.googleapis.com/" + qualify(packageOf(service), messageName(rtype)),
+				Value:   value,
+			}},
+		})})
+		if err != nil {
+			return nil
+		}
+		return details
+	}
+	return nil
+}
+
+// codeOf returns the status code for the given scenario, from its
+// grpc tag, or else from its http tag.
+func codeOf(scenario api.Scenario) (Code, bool) {
+	if code, err := strconv.Atoi(scenario.Tags.Get("grpc")); err == nil {
+		return Code(code), true
+	}
+	if code, err := strconv.Atoi(scenario.Tags.Get("http")); err == nil {
+		mapped, ok := codesHTTP[code]
+		return mapped, ok
+	}
+	return 0, false
+}
+
+// statusFrom reads the gRPC status from the given header (or trailer).
+func statusFrom(header http.Header) (*Error, bool) {
+	value := header.Get("Grpc-Status")
+	if value == "" {
+		return nil, false
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return &Error{Code: Unknown, Message: "grpc: invalid status " + value}, true
+	}
+	if code == int(OK) {
+		return nil, true
+	}
+	message, err := url.PathUnescape(header.Get("Grpc-Message"))
+	if err != nil {
+		message = header.Get("Grpc-Message")
+	}
+	return &Error{Code: Code(code), Message: message}, true
+}
+
+// encodeMessage percent-encodes a status message for the Grpc-Message header.
+func encodeMessage(message string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < 0x20 || c > 0x7E || c == '%' {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xF])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
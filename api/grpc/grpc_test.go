@@ -0,0 +1,379 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"runtime.link/api"
+	"runtime.link/api/grpc"
+	"runtime.link/xyz"
+)
+
+type HelloRequest struct {
+	Name string `grpc:"1"`
+}
+
+type HelloReply struct {
+	Message string `grpc:"1"`
+	Count   int32  `grpc:"3"`
+}
+
+type Greeter struct {
+	api.Specification `grpc:"helloworld.Greeter"`
+
+	SayHello func(context.Context, HelloRequest) (HelloReply, error)
+	Add      func(a, b int) int                       `grpc:"Sum"`
+	Count    func(n int) <-chan HelloReply            `grpc:"CountTo"`
+	Failures func(n int) iter.Seq2[HelloReply, error] `grpc:"FailAfter"`
+	Missing  func() error
+}
+
+var Shapes = xyz.AccessorFor(Shape.Values)
+
+type Canvas struct {
+	api.Specification `grpc:"canvas.Canvas"`
+
+	Grow func(Shape) Shape
+	Copy func(Drawing) Drawing
+}
+
+// caller is an [api.Auth] that lets anyone call any function.
+type caller struct{}
+
+func (caller) Authenticate(r *http.Request, fn api.Function) (context.Context, error) {
+	return context.WithValue(r.Context(), caller{}, "anyone"), nil
+}
+func (caller) Authorize(context.Context, *http.Request, api.Function, []reflect.Value) error {
+	return nil
+}
+func (caller) Redact(ctx context.Context, err error) error { return err }
+
+func TestAuthenticatedDeadline(t *testing.T) {
+	handler, err := grpc.Handler(caller{}, Greeter{
+		SayHello: func(ctx context.Context, req HelloRequest) (HelloReply, error) {
+			if _, ok := ctx.Deadline(); !ok || ctx.Value(caller{}) != "anyone" {
+				return HelloReply{}, errors.New("missing deadline")
+			}
+			return HelloReply{Message: "Hello " + req.Name}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	client := api.Import[Greeter](grpc.API, server.URL, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := client.SayHello(ctx, HelloRequest{Name: "World"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTagged(t *testing.T) {
+	handler, err := grpc.Handler(nil, Canvas{
+		Grow: func(shape Shape) Shape {
+			switch xyz.ValueOf(shape) {
+			case Shapes.Circle:
+				return Shapes.Circle.As(Shapes.Circle.Get(shape) * 2)
+			default:
+				side := Shapes.Square.Get(shape)
+				return Shapes.Square.As([2]int32{side[0] * 2, side[1] * 2})
+			}
+		},
+		Copy: func(drawing Drawing) Drawing { return drawing },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	client := api.Import[Canvas](grpc.API, server.URL, nil)
+	if shape := client.Grow(Shapes.Circle.As(2.5)); xyz.ValueOf(shape) != Shapes.Circle || Shapes.Circle.Get(shape) != 5 {
+		t.Fatalf("unexpected shape %v", shape)
+	}
+	if shape := client.Grow(Shapes.Square.As([2]int32{1, 2})); xyz.ValueOf(shape) != Shapes.Square || Shapes.Square.Get(shape) != [2]int32{2, 4} {
+		t.Fatalf("unexpected shape %v", shape)
+	}
+	drawing := client.Copy(Drawing{Title: "box", Shapes: []Shape{Shapes.Square.As([2]int32{3, 3}), Shapes.Circle.As(1)}})
+	if drawing.Title != "box" || len(drawing.Shapes) != 2 || xyz.ValueOf(drawing.Shapes[0]) != Shapes.Square || Shapes.Circle.Get(drawing.Shapes[1]) != 1 {
+		t.Fatalf("unexpected drawing %+v", drawing)
+	}
+}
+
+func TestLink(t *testing.T) {
+	var impl = Greeter{
+		SayHello: func(ctx context.Context, req HelloRequest) (HelloReply, error) {
+			if req.Name == "" {
+				return HelloReply{}, api.ErrAccessDenied
+			}
+			return HelloReply{Message: "Hello " + req.Name, Count: 1}, nil
+		},
+		Add: func(a, b int) int { return a + b },
+		Count: func(n int) <-chan HelloReply {
+			ch := make(chan HelloReply)
+			go func() {
+				defer close(ch)
+				for i := range n {
+					ch <- HelloReply{Count: int32(i + 1)}
+				}
+			}()
+			return ch
+		},
+		Failures: func(n int) iter.Seq2[HelloReply, error] {
+			return func(yield func(HelloReply, error) bool) {
+				for i := range n {
+					if !yield(HelloReply{Count: int32(i + 1)}, nil) {
+						return
+					}
+				}
+				yield(HelloReply{}, errors.New("failed"))
+			}
+		},
+	}
+	handler, err := grpc.Handler(nil, impl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	client := api.Import[Greeter](grpc.API, server.URL, nil)
+	reply, err := client.SayHello(context.Background(), HelloRequest{Name: "World"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Message != "Hello World" || reply.Count != 1 {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+	_, err = client.SayHello(context.Background(), HelloRequest{})
+	var status *grpc.Error
+	if !errors.As(err, &status) || status.Code != grpc.PermissionDenied {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if sum := client.Add(2, 3); sum != 5 {
+		t.Fatalf("expected 5, got %v", sum)
+	}
+	var count int32
+	for reply := range client.Count(3) {
+		count += reply.Count
+	}
+	if count != 6 {
+		t.Fatalf("expected 6, got %v", count)
+	}
+	count = 0
+	for reply, err := range client.Failures(2) {
+		if err != nil {
+			if err.Error() != "failed" {
+				t.Fatalf("unexpected error %v", err)
+			}
+			break
+		}
+		count += reply.Count
+	}
+	if count != 3 {
+		t.Fatalf("expected 3, got %v", count)
+	}
+	if err := client.Missing(); !errors.Is(err, api.ErrNotImplemented) {
+		t.Fatalf("expected not implemented, got %v", err)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	type Message struct {
+		Name   string            `grpc:"1"`
+		Values []int64           `grpc:"2"`
+		Bytes  []byte            `grpc:"4"`
+		Labels map[string]string `grpc:"5"`
+		Nested *HelloReply       `grpc:"6"`
+	}
+	var buf bytes.Buffer
+	in := Message{
+		Name:   "name",
+		Values: []int64{1, -2, 3},
+		Bytes:  []byte("raw"),
+		Labels: map[string]string{"a": "1", "bc": "23"},
+		Nested: &HelloReply{Message: "nested", Count: 2},
+	}
+	if err := grpc.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out Message
+	if err := grpc.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != in.Name || len(out.Values) != 3 || out.Values[1] != -2 || string(out.Bytes) != "raw" ||
+		out.Labels["bc"] != "23" || out.Labels["a"] != "1" || out.Nested == nil || *out.Nested != *in.Nested {
+		t.Fatalf("unexpected message %+v", out)
+	}
+}
+
+type GreeterError api.Error[struct {
+	NotFound xyz.Case[GreeterError, error] `grpc:"5"
+		no such greeting`
+	Busy GreeterError `http:"503"`
+}]
+
+type Shape xyz.Tagged[any, struct {
+	Circle xyz.Case[Shape, float64]
+	Square xyz.Case[Shape, [2]int32]
+}]
+
+type Drawing struct {
+	Title  string            `grpc:"1"`
+	Shapes []Shape           `grpc:"2"`
+	Labels map[string]uint32 `grpc:"4"`
+	Scale  *float32          `grpc:"5"`
+}
+
+func TestWriteProto(t *testing.T) {
+	var API struct {
+		api.Specification `grpc:"example.Greeter"
+			Greeter greets people.`
+
+		Errors api.Register[error, GreeterError]
+
+		SayHello func(context.Context, HelloRequest) (HelloReply, error) `grpc:"SayHello"
+			SayHello returns a greeting.`
+		Add  func(a, b int) int
+		Draw func(Drawing) iter.Seq2[Shape, error]
+
+		Admin struct {
+			api.Specification `grpc:"example.Admin"`
+
+			Reset func() error
+		}
+	}
+	var buf bytes.Buffer
+	if err := grpc.WriteProto(&buf, api.StructureOf(&API)); err != nil {
+		t.Fatal(err)
+	}
+	const expected = `syntax = "proto3";
+
+package example;
+
+// Greeter greets people.
+service Greeter {
+  // SayHello returns a greeting.
+  rpc SayHello(HelloRequest) returns (HelloReply);
+  rpc Add(AddRequest) returns (AddResponse);
+  rpc Draw(Drawing) returns (stream Shape);
+}
+
+service Admin {
+  rpc Reset(ResetRequest) returns (ResetResponse);
+}
+
+message HelloRequest {
+  string name = 1;
+}
+
+message HelloReply {
+  string message = 1;
+  int32 count = 3;
+}
+
+message AddRequest {
+  int64 arg1 = 1;
+  int64 arg2 = 2;
+}
+
+message AddResponse {
+  int64 result1 = 1;
+}
+
+message Drawing {
+  string title = 1;
+  repeated Shape shapes = 2;
+  map<string, uint32> labels = 4;
+  optional float scale = 5;
+}
+
+message Shape {
+  oneof variant {
+    double circle = 1;
+    Int32Array2 square = 2;
+  }
+}
+
+message Int32Array2 {
+  int32 item1 = 1;
+  int32 item2 = 2;
+}
+
+message ResetRequest {}
+
+message ResetResponse {}
+
+// GreeterError is sent as a detail of a failed status, where the
+// code of each case is documented next to it.
+message GreeterError {
+  oneof variant {
+    // NOT_FOUND: no such greeting
+    string not_found = 1;
+    // UNAVAILABLE
+    bool busy = 2;
+  }
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected proto:\n%s", buf.String())
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	type Ticker struct {
+		api.Specification `grpc:"example.Ticker"`
+
+		Ticks func(context.Context) iter.Seq[HelloReply]
+	}
+	stopped := make(chan struct{})
+	handler, err := grpc.Handler(nil, Ticker{
+		Ticks: func(ctx context.Context) iter.Seq[HelloReply] {
+			return func(yield func(HelloReply) bool) {
+				defer close(stopped)
+				for i := int32(0); ctx.Err() == nil; i++ {
+					if !yield(HelloReply{Count: i}) {
+						return
+					}
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	client := api.Import[Ticker](grpc.API, server.URL, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = client.Ticks(ctx) // never ranged over.
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to be closed once the context was cancelled")
+	}
+}
@@ -0,0 +1,271 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"runtime.link/api"
+	http_api "runtime.link/api/internal/http"
+	"runtime.link/api/xray"
+)
+
+func init() {
+	http_api.Register(http_api.Protocol{
+		Name: "grpc",
+		Match: func(r *http.Request) bool {
+			return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+		},
+		Handler: func(auth any, impl any) (http.Handler, error) {
+			shared, _ := auth.(api.Auth[*http.Request])
+			return Handler(shared, impl)
+		},
+	})
+}
+
+// Handler returns a HTTP handler that serves the unary and server-streaming
+// gRPC methods of the given implementation. If auth is nil, requests will
+// not require any authentication.
+func Handler(auth api.Auth[*http.Request], impl any) (http.Handler, error) {
+	spec, err := specificationOf(api.StructureOf(impl))
+	if err != nil {
+		return nil, xray.New(err)
+	}
+	var router = http.NewServeMux()
+	for path, m := range spec.Methods {
+		router.Handle("POST "+path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(auth, w, r)
+		}))
+	}
+	router.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, &Error{Code: Unimplemented, Message: "unknown method " + r.URL.Path})
+	}))
+	return router, nil
+}
+
+// ListenAndServe starts a HTTP server that serves the gRPC methods of
+// the given implementation over HTTP/2 without TLS (h2c). If auth is
+// nil, requests will not require any authentication.
+func ListenAndServe(addr string, auth api.Auth[*http.Request], impl any) error {
+	handler, err := Handler(auth, impl)
+	if err != nil {
+		return xray.New(err)
+	}
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		Protocols: &protocols,
+	}
+	return server.ListenAndServe()
+}
+
+// writeStatus writes a 'Trailers-Only' response with the given status.
+func writeStatus(w http.ResponseWriter, status *Error) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+	if status.Message != "" {
+		w.Header().Set("Grpc-Message", encodeMessage(status.Message))
+	}
+	if status.details != nil {
+		w.Header().Set("Grpc-Status-Details-Bin", base64.RawStdEncoding.EncodeToString(status.details))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m method) serve(auth api.Auth[*http.Request], w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var (
+		fn  = m.Function
+		ctx = r.Context()
+		err error
+	)
+	fail := func(err error) {
+		if auth != nil {
+			err = auth.Redact(ctx, err)
+		}
+		writeStatus(w, statusOf(fn, err))
+	}
+	if ctype := r.Header.Get("Content-Type"); !strings.HasPrefix(ctype, "application/grpc") {
+		http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if auth != nil {
+		ctx, err = auth.Authenticate(r, fn)
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+	if timeout, ok := parseTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	message, err := readFrame(r.Body)
+	if err != nil {
+		fail(&Error{Code: InvalidArgument, Message: err.Error()})
+		return
+	}
+	var types = make([]reflect.Type, fn.NumIn())
+	for i := range types {
+		types[i] = fn.In(i)
+	}
+	args, err := unmarshal(message, types)
+	if err != nil {
+		fail(&Error{Code: InvalidArgument, Message: err.Error()})
+		return
+	}
+	if auth != nil {
+		if err := auth.Authorize(ctx, r, fn, args); err != nil {
+			fail(err)
+			return
+		}
+	}
+	results, err := fn.Call(ctx, args)
+	if err != nil {
+		fail(err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin")
+	w.WriteHeader(http.StatusOK)
+	if m.streaming {
+		err = stream(ctx, w, results[0])
+	} else {
+		message, err = marshal(results)
+		if err == nil {
+			err = writeFrame(w, message)
+		}
+	}
+	var status = &Error{Code: OK}
+	if err != nil {
+		if auth != nil {
+			err = auth.Redact(ctx, err)
+		}
+		status = statusOf(fn, err)
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+	if status.Message != "" {
+		w.Header().Set("Grpc-Message", encodeMessage(status.Message))
+	}
+	if status.details != nil {
+		w.Header().Set("Grpc-Status-Details-Bin", base64.RawStdEncoding.EncodeToString(status.details))
+	}
+}
+
+// stream writes each value of the given channel or iterator as a
+// separate message.
+func stream(ctx context.Context, w http.ResponseWriter, result reflect.Value) error {
+	flusher, _ := w.(http.Flusher)
+	send := func(values ...reflect.Value) error {
+		message, err := marshal(values)
+		if err != nil {
+			return err
+		}
+		if err := writeFrame(w, message); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	if result.Kind() == reflect.Chan {
+		if result.IsNil() {
+			return nil
+		}
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: result},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		}
+		for {
+			chosen, value, ok := reflect.Select(cases)
+			if chosen == 1 {
+				return &Error{Code: Canceled, Message: ctx.Err().Error()}
+			}
+			if !ok {
+				return nil
+			}
+			if err := send(value); err != nil {
+				return err
+			}
+		}
+	}
+	if result.IsNil() {
+		return nil
+	}
+	var failure error
+	var withError = isSeq2Error(result.Type())
+	yield := reflect.MakeFunc(result.Type().In(0), func(args []reflect.Value) []reflect.Value {
+		if err := ctx.Err(); err != nil {
+			failure = &Error{Code: Canceled, Message: err.Error()}
+			return []reflect.Value{reflect.ValueOf(false)}
+		}
+		if withError {
+			if err, _ := args[1].Interface().(error); err != nil {
+				failure = err
+				return []reflect.Value{reflect.ValueOf(false)}
+			}
+			args = args[:1]
+		}
+		if err := send(args...); err != nil {
+			failure = err
+			return []reflect.Value{reflect.ValueOf(false)}
+		}
+		return []reflect.Value{reflect.ValueOf(true)}
+	})
+	result.Call([]reflect.Value{yield})
+	return failure
+}
+
+// parseTimeout parses a Grpc-Timeout header value.
+func parseTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// formatTimeout formats a Grpc-Timeout header value.
+func formatTimeout(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "1n"
+	}
+	for _, unit := range []struct {
+		d time.Duration
+		s string
+	}{{time.Nanosecond, "n"}, {time.Microsecond, "u"}, {time.Millisecond, "m"}, {time.Second, "S"}, {time.Minute, "M"}, {time.Hour, "H"}} {
+		if n := timeout / unit.d; n < 1e8 {
+			return strconv.FormatInt(int64(n), 10) + unit.s
+		}
+	}
+	return "99999999H"
+}
@@ -0,0 +1,189 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"time"
+
+	"runtime.link/api"
+	"runtime.link/api/xray"
+)
+
+// API implements the [api.Linker] interface, if the client is nil, a
+// client that speaks HTTP/2 (h2c for http:// hosts) is used.
+var API api.Linker[string, *http.Client] = linker{}
+
+// Client returns a new HTTP client that can call gRPC methods over
+// HTTP/2, including over unencrypted connections (h2c).
+func Client() *http.Client {
+	var protocols http.Protocols
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Protocols = &protocols
+	return &http.Client{Transport: transport}
+}
+
+type linker struct{}
+
+// Link implements the [api.Linker] interface.
+func (linker) Link(structure api.Structure, host string, client *http.Client) error {
+	spec, err := specificationOf(structure)
+	if err != nil {
+		return xray.New(err)
+	}
+	if host == "" {
+		host = spec.Host.Get("www")
+	}
+	if client == nil {
+		client = Client()
+	}
+	for _, m := range spec.Methods {
+		m := m
+		m.Make(func(ctx context.Context, args []reflect.Value) ([]reflect.Value, error) {
+			if host == "" {
+				return nil, fmt.Errorf("failed to call %v, %s host URL is empty", m.Route, spec.Name)
+			}
+			message, err := marshal(args)
+			if err != nil {
+				return nil, xray.New(err)
+			}
+			var body bytes.Buffer
+			if err := writeFrame(&body, message); err != nil {
+				return nil, xray.New(err)
+			}
+			req, err := http.NewRequestWithContext(ctx, "POST", host+m.Route, &body)
+			if err != nil {
+				return nil, xray.New(err)
+			}
+			req.Header.Set("Content-Type", "application/grpc")
+			req.Header.Set("Te", "trailers")
+			if deadline, ok := ctx.Deadline(); ok {
+				req.Header.Set("Grpc-Timeout", formatTimeout(time.Until(deadline)))
+			}
+			xray.ContextAdd(ctx, req)
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			xray.ContextAdd(ctx, resp)
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return nil, &Error{Code: Unknown, Message: "unexpected status : " + resp.Status}
+			}
+			if status, ok := statusFrom(resp.Header); ok && status != nil {
+				resp.Body.Close()
+				return nil, status
+			}
+			if m.streaming {
+				return []reflect.Value{m.stream(ctx, resp)}, nil
+			}
+			defer resp.Body.Close()
+			message, err = readFrame(resp.Body)
+			if err != nil {
+				if status := trailerStatus(resp); status != nil {
+					return nil, status
+				}
+				return nil, err
+			}
+			if status := trailerStatus(resp); status != nil {
+				return nil, status
+			}
+			var types = make([]reflect.Type, m.NumOut())
+			for i := range types {
+				types[i] = m.Type.Out(i)
+			}
+			return unmarshal(message, types)
+		})
+	}
+	return nil
+}
+
+// trailerStatus drains the response body and returns the status
+// recorded in the trailers (nil if OK).
+func trailerStatus(resp *http.Response) *Error {
+	io.Copy(io.Discard, resp.Body)
+	status, ok := statusFrom(resp.Trailer)
+	if !ok {
+		return nil
+	}
+	return status
+}
+
+// stream returns a channel or iterator that receives each message of
+// the streaming response.
+func (m method) stream(ctx context.Context, resp *http.Response) reflect.Value {
+	var (
+		rtype     = m.Type.Out(0)
+		types     = elementsOf(rtype)
+		withError = isSeq2Error(rtype)
+	)
+	// the stream is closed once the call's context is done, in case it
+	// is never received to the end.
+	stop := context.AfterFunc(ctx, func() { resp.Body.Close() })
+	// next reads the next message, returning false at the end of the stream.
+	next := func() ([]reflect.Value, error, bool) {
+		message, err := readFrame(resp.Body)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if status := trailerStatus(resp); status != nil {
+					return nil, status, false
+				}
+				return nil, nil, false
+			}
+			return nil, err, false
+		}
+		values, err := unmarshal(message, types)
+		if err != nil {
+			return nil, err, false
+		}
+		return values, nil, true
+	}
+	if rtype.Kind() == reflect.Chan {
+		ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, rtype.Elem()), 0)
+		go func() {
+			defer stop()
+			defer resp.Body.Close()
+			defer ch.Close()
+			for {
+				values, _, ok := next()
+				if !ok {
+					return
+				}
+				chosen, _, _ := reflect.Select([]reflect.SelectCase{
+					{Dir: reflect.SelectSend, Chan: ch, Send: values[0]},
+					{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+				})
+				if chosen == 1 {
+					return
+				}
+			}
+		}()
+		return ch.Convert(rtype)
+	}
+	return reflect.MakeFunc(rtype, func(args []reflect.Value) []reflect.Value {
+		defer stop()
+		defer resp.Body.Close()
+		yield := args[0]
+		for {
+			values, err, ok := next()
+			if !ok {
+				if err != nil && withError {
+					yield.Call([]reflect.Value{reflect.Zero(types[0]), reflect.ValueOf(&err).Elem()})
+				}
+				return nil
+			}
+			if withError {
+				values = append(values, reflect.Zero(errType))
+			}
+			if !yield.Call(values)[0].Bool() {
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,510 @@
+package grpc
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"runtime.link/api"
+	"runtime.link/api/xray"
+)
+
+// WriteProto writes a proto3 definition of the gRPC services of the given
+// structure to w, so that the API can be consumed with standard protobuf
+// tooling. Messages are named after their Go types (or else after the
+// method), [xyz.Tagged] unions are written as a oneof (with each case
+// numbered by its position) and the [api.Error] types registered on the
+// structure are written as messages, annotated with their status codes,
+// that are sent as details of a failed status.
+func WriteProto(w io.Writer, structure api.Structure) error {
+	spec, err := specificationOf(structure)
+	if err != nil {
+		return xray.New(err)
+	}
+	var gen = protoGenerator{
+		names: make(map[string]reflect.Type),
+		types: make(map[reflect.Type]string),
+	}
+	var (
+		pkg      string
+		services []protoService
+	)
+	for _, route := range spec.Routes {
+		m := spec.Methods[route]
+		service, name, ok := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+		if !ok || service == "" || !isIdentifier(name) {
+			return fmt.Errorf("grpc: cannot write method '%s' as a protobuf rpc", route)
+		}
+		if len(services) == 0 {
+			pkg = packageOf(service)
+		} else if packageOf(service) != pkg {
+			return fmt.Errorf("grpc: service '%s' is not in package '%s' (all services must share a package)", service, pkg)
+		}
+		short := strings.TrimPrefix(service, pkg+".")
+		if !isIdentifier(short) {
+			return fmt.Errorf("grpc: invalid service name '%s'", service)
+		}
+		idx := slices.IndexFunc(services, func(s protoService) bool { return s.Name == short })
+		if idx < 0 {
+			idx = len(services)
+			services = append(services, protoService{
+				Name: short,
+				Docs: structureAt(structure, m.Path).Docs,
+			})
+		}
+		var ins = make([]reflect.Type, m.NumIn())
+		for i := range ins {
+			ins[i] = m.In(i)
+		}
+		var outs = make([]reflect.Type, m.NumOut())
+		for i := range outs {
+			outs[i] = m.Type.Out(i)
+		}
+		if m.streaming {
+			outs = elementsOf(outs[0])
+		}
+		input, err := gen.messageFor(ins, "arg", short, name+"Request")
+		if err != nil {
+			return xray.New(err)
+		}
+		output, err := gen.messageFor(outs, "result", short, name+"Response")
+		if err != nil {
+			return xray.New(err)
+		}
+		services[idx].Methods = append(services[idx].Methods, protoMethod{
+			Name:      name,
+			Docs:      m.Docs,
+			Input:     input,
+			Output:    output,
+			Streaming: m.streaming,
+		})
+	}
+	for _, rtype := range errorsOf(structure) {
+		if rtype.Kind() != reflect.Struct {
+			continue
+		}
+		if _, err := gen.message(rtype, ""); err != nil {
+			return xray.New(err)
+		}
+	}
+	var b strings.Builder
+	b.WriteString("syntax = \"proto3\";\n")
+	if pkg != "" {
+		fmt.Fprintf(&b, "\npackage %s;\n", pkg)
+	}
+	for _, service := range services {
+		b.WriteString("\n")
+		writeComment(&b, "", service.Docs)
+		fmt.Fprintf(&b, "service %s {\n", service.Name)
+		for _, m := range service.Methods {
+			writeComment(&b, "  ", m.Docs)
+			var stream string
+			if m.Streaming {
+				stream = "stream "
+			}
+			fmt.Fprintf(&b, "  rpc %s(%s) returns (%s%s);\n", m.Name, m.Input, stream, m.Output)
+		}
+		b.WriteString("}\n")
+	}
+	for _, message := range gen.messages {
+		b.WriteString("\n")
+		writeComment(&b, "", message.Docs)
+		if len(message.Fields) == 0 {
+			fmt.Fprintf(&b, "message %s {}\n", message.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "message %s {\n", message.Name)
+		var indent = "  "
+		if message.Oneof {
+			b.WriteString("  oneof variant {\n")
+			indent = "    "
+		}
+		for _, field := range message.Fields {
+			writeComment(&b, indent, field.Docs)
+			b.WriteString(indent)
+			if field.Label != "" {
+				b.WriteString(field.Label + " ")
+			}
+			fmt.Fprintf(&b, "%s %s = %d;\n", field.Type, field.Name, field.Number)
+		}
+		if message.Oneof {
+			b.WriteString("  }\n")
+		}
+		b.WriteString("}\n")
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+type protoService struct {
+	Name    string
+	Docs    string
+	Methods []protoMethod
+}
+
+type protoMethod struct {
+	Name      string
+	Docs      string
+	Input     string
+	Output    string
+	Streaming bool
+}
+
+type protoMessage struct {
+	Name   string
+	Docs   string
+	Oneof  bool
+	Fields []protoField
+}
+
+type protoField struct {
+	Name   string
+	Docs   string
+	Label  string // repeated or optional
+	Type   string
+	Number wireNumber
+}
+
+// protoGenerator collects the messages referred to by a set of services.
+type protoGenerator struct {
+	messages []protoMessage
+	names    map[string]reflect.Type // nil for synthesized messages.
+	types    map[reflect.Type]string
+}
+
+// claim reserves the given message name, for the given type (nil
+// if the message is synthesized).
+func (gen *protoGenerator) claim(name string, rtype reflect.Type) error {
+	if !isIdentifier(name) {
+		return fmt.Errorf("grpc: invalid message name '%s' for %v", name, rtype)
+	}
+	if existing, ok := gen.names[name]; ok {
+		return fmt.Errorf("grpc: message name '%s' is used by both %v and %v", name, existing, rtype)
+	}
+	gen.names[name] = rtype
+	if rtype != nil {
+		gen.types[rtype] = name
+	}
+	return nil
+}
+
+// messageFor returns the name of the message that represents the given
+// values, as encoded by [marshal]. A single struct is the message itself,
+// otherwise a message is synthesized with each value as a field, named
+// after the method (prefixed by the service if the name is taken).
+func (gen *protoGenerator) messageFor(types []reflect.Type, prefix, service, name string) (string, error) {
+	if len(types) == 1 && isMessage(types[0]) {
+		rtype := types[0]
+		for rtype.Kind() == reflect.Pointer {
+			rtype = rtype.Elem()
+		}
+		if rtype.Name() == "" {
+			if _, ok := gen.names[name]; ok {
+				name = service + name
+			}
+		}
+		return gen.message(rtype, name)
+	}
+	if _, ok := gen.names[name]; ok {
+		name = service + name
+	}
+	if err := gen.claim(name, nil); err != nil {
+		return "", err
+	}
+	var message = protoMessage{Name: name}
+	for i, rtype := range types {
+		field, err := gen.field(prefix+strconv.Itoa(i+1), wireNumber(i+1), rtype)
+		if err != nil {
+			return "", err
+		}
+		message.Fields = append(message.Fields, field)
+	}
+	gen.messages = append(gen.messages, message)
+	return name, nil
+}
+
+// message returns the name of the message for the given struct type,
+// adding it (and any messages it refers to) to the generator, fallback
+// is used to name anonymous structs.
+func (gen *protoGenerator) message(rtype reflect.Type, fallback string) (string, error) {
+	if name, ok := gen.types[rtype]; ok {
+		return name, nil
+	}
+	name := messageName(rtype)
+	if name == "" {
+		name = fallback
+	}
+	if err := gen.claim(name, rtype); err != nil {
+		return "", err
+	}
+	var message = protoMessage{Name: name}
+	idx := len(gen.messages)
+	gen.messages = append(gen.messages, message)
+	if rtype.Implements(taggedType) {
+		message.Oneof = true
+		for i, c := range reflect.Zero(rtype).Interface().(taggedValue).Reflection() {
+			var field = protoField{
+				Name:   snakeCase(c.Name),
+				Docs:   api.DocumentationOf(reflect.StructField{Name: c.Name, Tag: c.Tags}),
+				Type:   "bool",
+				Number: wireNumber(i + 1),
+			}
+			if c.Vary != nil {
+				label, ptype, err := gen.typeOf(c.Vary)
+				if err != nil {
+					return "", err
+				}
+				if label == "repeated" || strings.HasPrefix(ptype, "map<") {
+					return "", fmt.Errorf("grpc: case %s of %v cannot be a repeated field (within a oneof)", c.Name, rtype)
+				}
+				field.Type = ptype
+			}
+			if rtype.Implements(errType) {
+				if code, ok := codeOf(api.Scenario{Tags: c.Tags}); ok {
+					if field.Docs == "" {
+						field.Docs = code.String()
+					} else {
+						field.Docs = code.String() + ": " + field.Docs
+					}
+				}
+			}
+			message.Fields = append(message.Fields, field)
+		}
+		if rtype.Implements(errType) {
+			message.Docs = name + " is sent as a detail of a failed status, where the\ncode of each case is documented next to it."
+		}
+		gen.messages[idx] = message
+		return name, nil
+	}
+	for i := range rtype.NumField() {
+		field := rtype.Field(i)
+		num, ok := fieldNumber(field)
+		if !ok {
+			continue
+		}
+		pfield, err := gen.field(snakeCase(field.Name), num, field.Type)
+		if err != nil {
+			return "", err
+		}
+		pfield.Docs = api.DocumentationOf(field)
+		message.Fields = append(message.Fields, pfield)
+	}
+	gen.messages[idx] = message
+	return name, nil
+}
+
+func (gen *protoGenerator) field(name string, num wireNumber, rtype reflect.Type) (protoField, error) {
+	label, ptype, err := gen.typeOf(rtype)
+	if err != nil {
+		return protoField{}, err
+	}
+	return protoField{Name: name, Label: label, Type: ptype, Number: num}, nil
+}
+
+// typeOf returns the protobuf label and type for a field of the given
+// Go type, consistent with the way it is encoded by an [Encoder].
+func (gen *protoGenerator) typeOf(rtype reflect.Type) (label, ptype string, err error) {
+	switch rtype.Kind() {
+	case reflect.Bool:
+		return "", "bool", nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return "", "int32", nil
+	case reflect.Int, reflect.Int64:
+		return "", "int64", nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "", "uint32", nil
+	case reflect.Uint, reflect.Uint64:
+		return "", "uint64", nil
+	case reflect.Float32:
+		return "", "float", nil
+	case reflect.Float64:
+		return "", "double", nil
+	case reflect.String:
+		return "", "string", nil
+	case reflect.Pointer:
+		label, ptype, err := gen.typeOf(rtype.Elem())
+		if err != nil {
+			return "", "", err
+		}
+		if label == "" && rtype.Elem().Kind() != reflect.Struct && !strings.HasPrefix(ptype, "map<") {
+			label = "optional"
+		}
+		return label, ptype, nil
+	case reflect.Slice:
+		if rtype.Elem().Kind() == reflect.Uint8 {
+			return "", "bytes", nil
+		}
+		label, ptype, err := gen.typeOf(rtype.Elem())
+		if err != nil {
+			return "", "", err
+		}
+		if label == "repeated" || strings.HasPrefix(ptype, "map<") {
+			return "", "", fmt.Errorf("grpc: nested repeated type %v has no protobuf representation", rtype)
+		}
+		return "repeated", ptype, nil
+	case reflect.Array:
+		_, elem, err := gen.typeOf(rtype.Elem())
+		if err != nil {
+			return "", "", err
+		}
+		name := strings.ToUpper(elem[:1]) + elem[1:] + "Array" + strconv.Itoa(rtype.Len())
+		if _, ok := gen.types[rtype]; ok {
+			return "", name, nil
+		}
+		if err := gen.claim(name, rtype); err != nil {
+			return "", "", err
+		}
+		var message = protoMessage{Name: name}
+		for i := range rtype.Len() {
+			field, err := gen.field("item"+strconv.Itoa(i+1), wireNumber(i+1), rtype.Elem())
+			if err != nil {
+				return "", "", err
+			}
+			message.Fields = append(message.Fields, field)
+		}
+		gen.messages = append(gen.messages, message)
+		return "", name, nil
+	case reflect.Map:
+		switch rtype.Key().Kind() {
+		case reflect.Float32, reflect.Float64, reflect.Pointer, reflect.Struct, reflect.Array, reflect.Interface:
+			return "", "", fmt.Errorf("grpc: map key type %v has no protobuf representation", rtype.Key())
+		}
+		_, key, err := gen.typeOf(rtype.Key())
+		if err != nil {
+			return "", "", err
+		}
+		label, value, err := gen.typeOf(rtype.Elem())
+		if err != nil {
+			return "", "", err
+		}
+		if label == "repeated" || strings.HasPrefix(value, "map<") {
+			return "", "", fmt.Errorf("grpc: map value type %v has no protobuf representation", rtype.Elem())
+		}
+		return "", "map<" + key + ", " + value + ">", nil
+	case reflect.Struct:
+		name, err := gen.message(rtype, "")
+		return "", name, err
+	case reflect.Interface:
+		if rtype == errType {
+			return "", "string", nil
+		}
+	}
+	return "", "", fmt.Errorf("grpc: unsupported type %v", rtype)
+}
+
+// errorsOf returns the error types registered on the structure (and
+// its namespaces).
+func errorsOf(structure api.Structure) []reflect.Type {
+	var types = slices.Clone(structure.Instances[errType])
+	for _, name := range slices.Sorted(maps.Keys(structure.Namespace)) {
+		for _, rtype := range errorsOf(structure.Namespace[name]) {
+			if !slices.Contains(types, rtype) {
+				types = append(types, rtype)
+			}
+		}
+	}
+	return types
+}
+
+// structureAt returns the namespace of the structure at the given path.
+func structureAt(structure api.Structure, path []string) api.Structure {
+	for _, name := range path {
+		structure = structure.Namespace[name]
+	}
+	return structure
+}
+
+// packageOf returns the protobuf package of the given service name.
+func packageOf(service string) string {
+	idx := strings.LastIndexByte(service, '.')
+	if idx < 0 {
+		return ""
+	}
+	return service[:idx]
+}
+
+// qualify returns the fully qualified name of a message in the package.
+func qualify(pkg, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+// messageName returns the protobuf message name for the given Go type,
+// type arguments of generic types are appended to the name.
+func messageName(rtype reflect.Type) string {
+	base, args, generic := strings.Cut(rtype.Name(), "[")
+	if !generic {
+		return base
+	}
+	var b strings.Builder
+	b.WriteString(base)
+	for _, arg := range strings.Split(args, ",") {
+		arg = arg[strings.LastIndexAny(arg, "./")+1:]
+		var upper = true
+		for _, r := range arg {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// snakeCase converts a Go identifier to a protobuf field name, ie.
+// UserID becomes user_id.
+func snakeCase(name string) string {
+	var runes = []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeComment writes the given documentation as a comment.
+func writeComment(b *strings.Builder, indent, docs string) {
+	docs = strings.TrimSpace(docs)
+	if docs == "" {
+		return
+	}
+	for _, line := range strings.Split(docs, "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			b.WriteString(indent + "//\n")
+			continue
+		}
+		b.WriteString(indent + "// " + line + "\n")
+	}
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Protocol that can be served by api.ListenAndServe, each linker
+// package registers its protocol when it is imported.
+type Protocol struct {
+	Name string
+
+	// Match reports whether the request is meant for this protocol,
+	// if nil, the protocol handles any requests that are not matched
+	// by another protocol.
+	Match func(*http.Request) bool
+
+	// Handler returns a HTTP handler for the implementation, auth
+	// is an api.Auth[*http.Request] (or nil).
+	Handler func(auth any, impl any) (http.Handler, error)
+}
+
+var (
+	mutex     sync.RWMutex
+	protocols []Protocol
+)
+
+// Register the given protocol, so that it can be served by api.ListenAndServe.
+func Register(protocol Protocol) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	protocols = append(protocols, protocol)
+}
+
+// Protocols returns the registered protocols.
+func Protocols() []Protocol {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return append([]Protocol(nil), protocols...)
+}
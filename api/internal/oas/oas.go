@@ -131,6 +131,8 @@ type ExternalDocumentation struct {
 }
 
 type Parameter struct {
+	Ref URI `json:"$ref,omitempty"`
+
 	Name            Readable             `json:"name"`
 	In              ParameterLocation    `json:"in"`
 	Description     Readable             `json:"description,omitempty"`
@@ -168,6 +170,8 @@ type ParameterStyle xyz.Switch[string, struct {
 var ParameterStyles = xyz.AccessorFor(ParameterStyle.Values)
 
 type RequestBody struct {
+	Ref URI `json:"$ref,omitempty"`
+
 	Description Readable                  `json:"description,omitempty"`
 	Content     map[ContentType]MediaType `json:"content"`
 	Required    bool                      `json:"required,omitempty"`
@@ -195,6 +199,8 @@ type Encoding struct {
 }
 
 type Response struct {
+	Ref URI `json:"$ref,omitempty"`
+
 	Description Readable                  `json:"description"`
 	Headers     map[string]*Header        `json:"headers,omitempty"`
 	Content     map[ContentType]MediaType `json:"content,omitempty"`
@@ -0,0 +1,545 @@
+package oas
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Decode an OpenAPI document, in either JSON or YAML format.
+func Decode(data []byte, doc *Document) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return json.Unmarshal(trimmed, doc)
+	}
+	converted, err := yamlToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(converted, doc)
+}
+
+// UnmarshalJSON accepts versions written as numbers, as YAML
+// documents often contain 'version: 1.0'.
+func (v *Version) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = Version(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*v = Version(n)
+	return nil
+}
+
+// yamlToJSON converts the subset of YAML used by OpenAPI documents into
+// JSON: block mappings and sequences, flow collections, plain, quoted and
+// block scalars. Anchors, aliases, tags and complex keys are not supported.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var p yamlParser
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if raw == "---" || raw == "..." || strings.HasPrefix(raw, "%") {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		p.lines = append(p.lines, yamlLine{
+			num:    i + 1,
+			indent: indent,
+			text:   strings.TrimRight(stripComment(raw[indent:]), " \t"),
+			raw:    raw,
+		})
+	}
+	value, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if line, ok := p.peek(); ok {
+		return nil, fmt.Errorf("oas: yaml line %d: unexpected content", line.num)
+	}
+	return json.Marshal(value)
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // without indentation or comments.
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// peek returns the next non-blank line.
+func (p *yamlParser) peek() (yamlLine, bool) {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+	if p.pos >= len(p.lines) {
+		return yamlLine{}, false
+	}
+	return p.lines[p.pos], true
+}
+
+// parse the node that begins on the next line, if it is indented by at
+// least indent spaces.
+func (p *yamlParser) parse(indent int) (any, error) {
+	line, ok := p.peek()
+	if !ok || line.indent < indent {
+		return nil, nil
+	}
+	switch {
+	case isSequenceItem(line.text):
+		return p.sequence(line.indent)
+	case entryColon(line.text) >= 0:
+		return p.mapping(line.indent)
+	default:
+		p.pos++
+		return p.value(line.num, line.indent-1, line.text)
+	}
+}
+
+func (p *yamlParser) sequence(indent int) (any, error) {
+	var list = []any{}
+	for {
+		line, ok := p.peek()
+		if !ok || line.indent != indent || !isSequenceItem(line.text) {
+			return list, nil
+		}
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			value, err := p.parse(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+			continue
+		}
+		// the item continues on the same line, so treat the remainder
+		// as if it were a line of its own (at the same column).
+		p.lines[p.pos].indent = indent + len(line.text) - len(rest)
+		p.lines[p.pos].text = rest
+		value, err := p.parse(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	var object = map[string]any{}
+	for {
+		line, ok := p.peek()
+		if !ok || line.indent < indent {
+			return object, nil
+		}
+		if line.indent > indent || isSequenceItem(line.text) {
+			return nil, fmt.Errorf("oas: yaml line %d: unexpected indentation", line.num)
+		}
+		colon := entryColon(line.text)
+		if colon < 0 {
+			return nil, fmt.Errorf("oas: yaml line %d: expected a mapping entry", line.num)
+		}
+		key, err := yamlKey(line.text[:colon])
+		if err != nil {
+			return nil, fmt.Errorf("oas: yaml line %d: %w", line.num, err)
+		}
+		p.pos++
+		value, err := p.value(line.num, indent, strings.TrimLeft(line.text[colon+1:], " "))
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+	}
+}
+
+// value parses the value of an entry (that is indented by indent spaces),
+// given the remaining text on its line.
+func (p *yamlParser) value(num, indent int, text string) (any, error) {
+	switch {
+	case text == "":
+		line, ok := p.peek()
+		if !ok {
+			return nil, nil
+		}
+		if line.indent > indent {
+			return p.parse(line.indent)
+		}
+		if line.indent == indent && isSequenceItem(line.text) {
+			return p.sequence(indent)
+		}
+		return nil, nil
+	case text[0] == '|' || text[0] == '>':
+		return p.block(num, indent, text)
+	case text[0] == '&' || text[0] == '*' || text[0] == '!':
+		return nil, fmt.Errorf("oas: yaml line %d: anchors, aliases and tags are not supported", num)
+	}
+	// plain, quoted and flow scalars can continue onto more indented lines.
+	for {
+		line, ok := p.peek()
+		if !ok || line.indent <= indent {
+			break
+		}
+		text += " " + line.text
+		p.pos++
+	}
+	if text[0] == '[' || text[0] == '{' {
+		value, rest, err := flow(text)
+		if err != nil {
+			return nil, fmt.Errorf("oas: yaml line %d: %w", num, err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("oas: yaml line %d: unexpected %q", num, rest)
+		}
+		return value, nil
+	}
+	value, err := scalar(text)
+	if err != nil {
+		return nil, fmt.Errorf("oas: yaml line %d: %w", num, err)
+	}
+	return value, nil
+}
+
+// block parses a literal (|) or folded (>) block scalar.
+func (p *yamlParser) block(num, indent int, header string) (any, error) {
+	var (
+		folded = header[0] == '>'
+		chomp  byte
+		width  int
+	)
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+			width = int(c - '0')
+		default:
+			return nil, fmt.Errorf("oas: yaml line %d: invalid block scalar header %q", num, header)
+		}
+	}
+	var lines []string
+	var content = -1
+	if width > 0 {
+		content = indent + width
+	}
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos].raw
+		if strings.TrimSpace(raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		spaces := len(raw) - len(strings.TrimLeft(raw, " "))
+		if spaces <= indent {
+			break
+		}
+		if content < 0 {
+			content = spaces
+		}
+		if spaces < content {
+			break
+		}
+		lines = append(lines, raw[content:])
+	}
+	var trailing int
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case !folded:
+				b.WriteByte('\n')
+			case prev == "" || line == "" || line[0] == ' ' || prev[0] == ' ':
+				if line != "" {
+					b.WriteByte('\n')
+				}
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	switch chomp {
+	case '-':
+	case '+':
+		if len(lines) > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Repeat("\n", trailing))
+	default:
+		if len(lines) > 0 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), nil
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// entryColon returns the index of the colon that separates the key
+// of a mapping entry from its value, or -1.
+func entryColon(text string) int {
+	if text == "" || text[0] == '[' || text[0] == '{' || text[0] == '-' && isSequenceItem(text) {
+		return -1
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return -1
+		}
+		rest := strings.TrimLeft(text[end+1:], " ")
+		if rest == "" || rest[0] != ':' {
+			return -1
+		}
+		return len(text) - len(rest)
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+func yamlKey(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		value, err := scalar(text)
+		if err != nil {
+			return "", err
+		}
+		return value.(string), nil
+	}
+	return text, nil
+}
+
+// closingQuote returns the index of the quote that closes the quoted
+// scalar at the start of text, or -1.
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripComment removes any trailing comment from the line.
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:-", text[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// scalar converts a plain or quoted scalar into a JSON-compatible value.
+func scalar(text string) (any, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	switch text[0] {
+	case '"':
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("unterminated string %s", text)
+		}
+		return unescape(text[1 : len(text)-1])
+	case '\'':
+		if closingQuote(text) != len(text)-1 {
+			return nil, fmt.Errorf("unterminated string %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if strings.Trim(text, "0123456789+-.eE") == "" && strings.ContainsAny(text, "0123456789") {
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(i, 10)), nil
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+		}
+	}
+	return text, nil
+}
+
+// unescape the contents of a double-quoted scalar.
+func unescape(text string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(text) {
+			return "", errors.New("invalid escape at end of string")
+		}
+		switch text[i] {
+		case '0':
+			b.WriteByte(0)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 't', '\t':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'v':
+			b.WriteByte('\v')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte(0x1b)
+		case ' ', '"', '/', '\\':
+			b.WriteByte(text[i])
+		case 'x', 'u', 'U':
+			size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[text[i]]
+			if i+1+size > len(text) {
+				return "", errors.New("invalid escape " + text[i-1:])
+			}
+			code, err := strconv.ParseUint(text[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", errors.New("invalid escape " + text[i-1:i+1+size])
+			}
+			b.WriteString(string(rune(code)))
+			i += size
+		default:
+			return "", errors.New("invalid escape \\" + string(text[i]))
+		}
+	}
+	if !utf8.ValidString(b.String()) {
+		return "", errors.New("invalid utf-8 in string")
+	}
+	return b.String(), nil
+}
+
+// flow parses a flow collection (or scalar within one) from the start of
+// text, returning the remaining text.
+func flow(text string) (any, string, error) {
+	text = strings.TrimLeft(text, " ")
+	if text == "" {
+		return nil, "", errors.New("unexpected end of flow collection")
+	}
+	switch text[0] {
+	case '[':
+		var list = []any{}
+		text = strings.TrimLeft(text[1:], " ")
+		for {
+			if text == "" {
+				return nil, "", errors.New("unterminated flow sequence")
+			}
+			if text[0] == ']' {
+				return list, text[1:], nil
+			}
+			value, rest, err := flow(text)
+			if err != nil {
+				return nil, "", err
+			}
+			list = append(list, value)
+			text, err = flowSeparator(rest, ']')
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	case '{':
+		var object = map[string]any{}
+		text = strings.TrimLeft(text[1:], " ")
+		for {
+			if text == "" {
+				return nil, "", errors.New("unterminated flow mapping")
+			}
+			if text[0] == '}' {
+				return object, text[1:], nil
+			}
+			colon := entryColon(text)
+			if end := strings.IndexAny(text, ",}"); colon < 0 || (end >= 0 && end < colon && text[0] != '"' && text[0] != '\'') {
+				return nil, "", fmt.Errorf("expected a key in flow mapping %q", text)
+			}
+			key, err := yamlKey(text[:colon])
+			if err != nil {
+				return nil, "", err
+			}
+			value, rest, err := flow(text[colon+1:])
+			if err != nil {
+				return nil, "", err
+			}
+			object[key] = value
+			text, err = flowSeparator(rest, '}')
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	case '"', '\'':
+		end := closingQuote(text)
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string %s", text)
+		}
+		value, err := scalar(text[:end+1])
+		return value, text[end+1:], err
+	}
+	end := strings.IndexAny(text, ",]}")
+	if end < 0 {
+		end = len(text)
+	}
+	value, err := scalar(text[:end])
+	return value, text[end:], err
+}
+
+// flowSeparator consumes the comma between the items of a flow collection.
+func flowSeparator(text string, closing byte) (string, error) {
+	text = strings.TrimLeft(text, " ")
+	switch {
+	case text == "":
+		return "", nil
+	case text[0] == ',':
+		return strings.TrimLeft(text[1:], " "), nil
+	case text[0] == closing:
+		return text, nil
+	}
+	return "", fmt.Errorf("unexpected %q in flow collection", text)
+}
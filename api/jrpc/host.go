@@ -0,0 +1,228 @@
+package jrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"runtime.link/api"
+	http_api "runtime.link/api/internal/http"
+	"runtime.link/api/xray"
+)
+
+func init() {
+	http_api.Register(http_api.Protocol{
+		Name: "jrpc",
+		Match: func(r *http.Request) bool {
+			if r.Method != http.MethodPost {
+				return false
+			}
+			ctype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			return r.URL.Path == Path || ctype == "application/json-rpc"
+		},
+		Handler: func(auth any, impl any) (http.Handler, error) {
+			shared, _ := auth.(api.Auth[*http.Request])
+			return Handler(shared, impl)
+		},
+	})
+}
+
+// Handler returns a HTTP handler that serves JSON-RPC 2.0 requests
+// (including batches) POSTed to it for the given implementation. If
+// auth is nil, requests will not require any authentication.
+func Handler(auth api.Auth[*http.Request], impl any) (http.Handler, error) {
+	spec, err := specificationOf(api.StructureOf(impl))
+	if err != nil {
+		return nil, xray.New(err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply := serve(r.Context(), spec, auth, r, body)
+		if reply == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(reply)))
+		w.Write(reply)
+	}), nil
+}
+
+// ListenAndServe starts a HTTP server that serves JSON-RPC 2.0 requests
+// for the given implementation. If auth is nil, requests will not
+// require any authentication.
+func ListenAndServe(addr string, auth api.Auth[*http.Request], impl any) error {
+	handler, err := Handler(auth, impl)
+	if err != nil {
+		return xray.New(err)
+	}
+	return http.ListenAndServe(addr, handler)
+}
+
+// Serve newline-delimited JSON-RPC 2.0 requests read from r and write each
+// response on its own line to w, until r is exhausted or the context is
+// cancelled. Suitable for serving an implementation over stdio.
+func Serve(ctx context.Context, impl any, r io.Reader, w io.Writer) error {
+	spec, err := specificationOf(api.StructureOf(impl))
+	if err != nil {
+		return xray.New(err)
+	}
+	var reader = bufio.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			reply := serve[*http.Request](ctx, spec, nil, nil, line)
+			if reply != nil {
+				if _, err := w.Write(append(reply, '\n')); err != nil {
+					return xray.New(err)
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return xray.New(err)
+		}
+	}
+}
+
+// serve the JSON-RPC 2.0 message in body, returning the encoded reply or
+// nil if the message only consisted of notifications.
+func serve[Conn any](ctx context.Context, spec specification, auth api.Auth[Conn], conn Conn, body []byte) []byte {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return encode(response{Version: Version, Error: &Error{Code: CodeParseError, Message: "Parse error"}})
+		}
+		if len(batch) == 0 {
+			return encode(response{Version: Version, Error: &Error{Code: CodeInvalidRequest, Message: "Invalid Request"}})
+		}
+		var replies []json.RawMessage
+		for _, message := range batch {
+			if reply, ok := call(ctx, spec, auth, conn, message); ok {
+				replies = append(replies, encode(reply))
+			}
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+		return encode(replies)
+	}
+	reply, ok := call(ctx, spec, auth, conn, body)
+	if !ok {
+		return nil
+	}
+	return encode(reply)
+}
+
+func encode(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(response{Error: &Error{Code: CodeInternalError, Message: err.Error()}})
+	}
+	return b
+}
+
+// call the method described by the given message, returning false if
+// the message is a notification that should not be replied to.
+func call[Conn any](ctx context.Context, spec specification, auth api.Auth[Conn], conn Conn, message []byte) (response, bool) {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return response{Version: Version, Error: &Error{Code: CodeParseError, Message: "Parse error"}}, true
+		}
+		return response{Version: Version, Error: &Error{Code: CodeInvalidRequest, Message: "Invalid Request"}}, true
+	}
+	var reply = response{Version: Version, ID: req.ID}
+	if req.Version != Version || req.Method == "" {
+		reply.Error = &Error{Code: CodeInvalidRequest, Message: "Invalid Request"}
+		return reply, true
+	}
+	notification := len(req.ID) == 0
+	m, ok := spec.Methods[req.Method]
+	if !ok {
+		reply.Error = &Error{Code: CodeMethodNotFound, Message: "Method not found"}
+		return reply, !notification
+	}
+	var redact = func(err error) error { return err }
+	if auth != nil {
+		var err error
+		ctx, err = auth.Authenticate(conn, m.Function)
+		if err != nil {
+			reply.Error = errorOf(m.Function, auth.Redact(ctx, err))
+			return reply, !notification
+		}
+		redact = func(err error) error { return auth.Redact(ctx, err) }
+	}
+	args, err := m.decodeParams(req.Params)
+	if err != nil {
+		reply.Error = &Error{Code: CodeInvalidParams, Message: "Invalid params: " + err.Error()}
+		return reply, !notification
+	}
+	if auth != nil {
+		if err := auth.Authorize(ctx, conn, m.Function, args); err != nil {
+			reply.Error = errorOf(m.Function, redact(err))
+			return reply, !notification
+		}
+	}
+	results, err := m.Call(ctx, args)
+	if err != nil {
+		reply.Error = errorOf(m.Function, redact(err))
+		return reply, !notification
+	}
+	reply.Result, err = m.encodeResults(results)
+	if err != nil {
+		reply.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+	}
+	return reply, !notification
+}
+
+// errorOf converts an error returned by the function into a JSON-RPC error object.
+func errorOf(fn api.Function, err error) *Error {
+	var already *Error
+	if errors.As(err, &already) {
+		return already
+	}
+	var e = &Error{Code: CodeServerError, Message: err.Error()}
+	if errors.Is(err, api.ErrNotImplemented) {
+		e.Code = CodeMethodNotFound
+	}
+	for _, scenario := range fn.Root.Scenarios {
+		if scenario.Test(err) {
+			if code, err := strconv.Atoi(scenario.Tags.Get("jrpc")); err == nil {
+				e.Code = code
+			}
+			if scenario.Text != "" {
+				e.Message = scenario.Text
+			}
+			break
+		}
+	}
+	if marshaler, ok := err.(json.Marshaler); ok {
+		if data, err := marshaler.MarshalJSON(); err == nil {
+			e.Data = data
+		}
+	}
+	return e
+}
@@ -0,0 +1,324 @@
+/*
+Package jrpc provides a JSON-RPC 2.0 transport.
+
+	var API struct {
+		api.Specification `www:"http://api.example.com/rpc"`
+
+		Subtract func(minuend, subtrahend int) int `jrpc:"subtract (minuend,subtrahend)"`
+	}
+
+When Subtract is called, it posts a JSON-RPC 2.0 request to the
+host URL and returns the result (if there is an error and the
+function doesn't return one, it will panic).
+
+	{"jsonrpc":"2.0","method":"subtract","params":{"minuend":42,"subtrahend":23},"id":1}
+
+The same structure can be served over HTTP with [Handler] or over
+newline-delimited stdio (one JSON-RPC message per line) with [Serve].
+
+# Tags
+
+Each API function can have a jrpc tag that names the remote method,
+each tag must follow the space-seperated pattern:
+
+	method (argument,mapping,rules) result,mapping,rules
+	[METHOD] (ARGUMENT_RULES) RESULT_RULES
+
+When the METHOD is omitted, the method name is the dot-separated
+namespace path to the function, ie. "Math.Add". Functions tagged
+with `jrpc:"-"` are not linked or served.
+
+ARGUMENT_RULES are optional, they are a comma separated list of
+names to give the arguments, when present, params are sent 'by-name'
+as a JSON object, otherwise they are sent 'by-position' as a JSON
+array. Hosts accept either form.
+
+RESULT_RULES are much like ARGUMENT_RULES, except they operate on the
+results of the function instead of the arguments. Without them, a
+single result is encoded as-is and multiple results are encoded as
+a JSON array.
+
+# Errors
+
+Errors are returned to callers as JSON-RPC error objects, represented
+by [Error]. Any [api.Scenario] that matches the error can specify the
+error code to use with a jrpc tag, ie. `jrpc:"-32001"`, otherwise the
+implementation-defined server error -32000 is used.
+*/
+package jrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"runtime.link/api"
+)
+
+// Version of the JSON-RPC protocol implemented by this package.
+const Version = "2.0"
+
+// Path that JSON-RPC requests are POSTed to, when the implementation is
+// served alongside other protocols by [api.ListenAndServe] (requests with
+// an application/json-rpc content type are also accepted on any path).
+const Path = "/jrpc"
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeServerError    = -32000
+)
+
+// Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error implements the [error] interface.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return "jrpc: error " + strconv.Itoa(e.Code)
+	}
+	return e.Message
+}
+
+// Is reports whether the error object represents the target error,
+// ie. [api.ErrNotImplemented] for [CodeMethodNotFound].
+func (e *Error) Is(target error) bool {
+	return e.Code == CodeMethodNotFound && target == api.ErrNotImplemented
+}
+
+// request is a JSON-RPC 2.0 request object, when ID is
+// absent, the request is a notification.
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// response is a JSON-RPC 2.0 response object.
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// method describes a JSON-RPC method.
+type method struct {
+	api.Function
+
+	Name    string
+	Params  []string
+	Results []string
+}
+
+// specification describes a JSON-RPC API specification.
+type specification struct {
+	api.Structure
+
+	Methods map[string]method
+}
+
+func specificationOf(structure api.Structure) (specification, error) {
+	var spec = specification{
+		Structure: structure,
+		Methods:   make(map[string]method),
+	}
+	for fn := range structure.Iter() {
+		tag := fn.Tags.Get("jrpc")
+		if tag == "-" {
+			continue
+		}
+		m, err := methodOf(fn, tag)
+		if err != nil {
+			return specification{}, err
+		}
+		if existing, ok := spec.Methods[m.Name]; ok {
+			return specification{}, fmt.Errorf("jrpc: duplicate method '%s' (%s and %s)", m.Name,
+				strings.Join(append(slices.Clone(existing.Path), existing.Function.Name), "."), strings.Join(append(slices.Clone(fn.Path), fn.Name), "."))
+		}
+		spec.Methods[m.Name] = m
+	}
+	return spec, nil
+}
+
+func methodOf(fn api.Function, tag string) (method, error) {
+	var m = method{Function: fn}
+	for _, field := range strings.Fields(tag) {
+		switch {
+		case strings.HasPrefix(field, "("):
+			if !strings.HasSuffix(field, ")") {
+				return method{}, fmt.Errorf("make sure the %s 'jrpc' argument rules have a closing bracket", fn.Name)
+			}
+			m.Params = strings.Split(field[1:len(field)-1], ",")
+		case m.Name == "" && m.Params == nil:
+			m.Name = field
+		default:
+			m.Results = strings.Split(field, ",")
+		}
+	}
+	if m.Name == "" {
+		m.Name = strings.Join(append(append([]string(nil), fn.Path...), fn.Name), ".")
+	}
+	if m.Params != nil && len(m.Params) != fn.NumIn() {
+		return method{}, fmt.Errorf("the number of argument rules for %s must match the number of arguments", fn.Name)
+	}
+	if m.Results != nil && len(m.Results) != fn.NumOut() {
+		return method{}, fmt.Errorf("the number of result rules for %s must match the number of results (not including the error)", fn.Name)
+	}
+	return m, nil
+}
+
+// encodeParams encodes the given arguments as JSON-RPC params.
+func (m method) encodeParams(args []reflect.Value) (json.RawMessage, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if m.Params != nil {
+		var fields = make([]string, len(args))
+		for i, arg := range args {
+			value, err := json.Marshal(arg.Interface())
+			if err != nil {
+				return nil, err
+			}
+			name, _ := json.Marshal(m.Params[i])
+			fields[i] = string(name) + ":" + string(value)
+		}
+		return json.RawMessage("{" + strings.Join(fields, ",") + "}"), nil
+	}
+	var values = make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Interface()
+	}
+	return json.Marshal(values)
+}
+
+// decodeParams decodes JSON-RPC params into arguments for the function.
+func (m method) decodeParams(params json.RawMessage) ([]reflect.Value, error) {
+	var args = make([]reflect.Value, m.NumIn())
+	for i := range args {
+		args[i] = reflect.New(m.In(i)).Elem()
+	}
+	params = json.RawMessage(strings.TrimSpace(string(params)))
+	if len(params) == 0 || string(params) == "null" {
+		return args, nil
+	}
+	switch params[0] {
+	case '[':
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return nil, err
+		}
+		if len(positional) > len(args) {
+			return nil, fmt.Errorf("too many params for %s (expected %d)", m.Name, len(args))
+		}
+		for i, raw := range positional {
+			if err := json.Unmarshal(raw, args[i].Addr().Interface()); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		if m.Params == nil {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("%s does not accept params by-name", m.Name)
+			}
+			if err := json.Unmarshal(params, args[0].Addr().Interface()); err != nil {
+				return nil, err
+			}
+			return args, nil
+		}
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(params, &named); err != nil {
+			return nil, err
+		}
+		for i, name := range m.Params {
+			raw, ok := named[name]
+			if !ok {
+				continue
+			}
+			if err := json.Unmarshal(raw, args[i].Addr().Interface()); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("params must be an array or an object")
+	}
+	return args, nil
+}
+
+// encodeResults encodes the given results as a JSON-RPC result.
+func (m method) encodeResults(results []reflect.Value) (json.RawMessage, error) {
+	switch {
+	case len(results) == 0:
+		return json.RawMessage("null"), nil
+	case m.Results != nil:
+		var fields = make([]string, len(results))
+		for i, result := range results {
+			value, err := json.Marshal(result.Interface())
+			if err != nil {
+				return nil, err
+			}
+			name, _ := json.Marshal(m.Results[i])
+			fields[i] = string(name) + ":" + string(value)
+		}
+		return json.RawMessage("{" + strings.Join(fields, ",") + "}"), nil
+	case len(results) == 1:
+		return json.Marshal(results[0].Interface())
+	}
+	var values = make([]any, len(results))
+	for i, result := range results {
+		values[i] = result.Interface()
+	}
+	return json.Marshal(values)
+}
+
+// decodeResults decodes a JSON-RPC result into the results of the function.
+func (m method) decodeResults(result json.RawMessage) ([]reflect.Value, error) {
+	var results = make([]reflect.Value, m.NumOut())
+	for i := range results {
+		results[i] = reflect.New(m.Type.Out(i)).Elem()
+	}
+	if len(results) == 0 || len(result) == 0 || string(result) == "null" {
+		return results, nil
+	}
+	switch {
+	case m.Results != nil:
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(result, &named); err != nil {
+			return nil, err
+		}
+		for i, name := range m.Results {
+			if raw, ok := named[name]; ok {
+				if err := json.Unmarshal(raw, results[i].Addr().Interface()); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case len(results) == 1:
+		if err := json.Unmarshal(result, results[0].Addr().Interface()); err != nil {
+			return nil, err
+		}
+	default:
+		var positional []json.RawMessage
+		if err := json.Unmarshal(result, &positional); err != nil {
+			return nil, err
+		}
+		for i := range min(len(positional), len(results)) {
+			if err := json.Unmarshal(positional[i], results[i].Addr().Interface()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return results, nil
+}
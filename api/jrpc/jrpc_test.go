@@ -0,0 +1,133 @@
+package jrpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"runtime.link/api"
+	"runtime.link/api/jrpc"
+)
+
+type Calculator struct {
+	api.Specification
+
+	Subtract func(minuend, subtrahend int) int                        `jrpc:"subtract (minuend,subtrahend)"`
+	Divide   func(ctx context.Context, a, b float64) (float64, error) `jrpc:"divide"`
+	Split    func(s string) (string, string)                          `jrpc:"split head,tail"`
+
+	Strings struct {
+		Upper func(string) string
+	}
+
+	Hidden func() `jrpc:"-"`
+}
+
+func newCalculator() Calculator {
+	var impl Calculator
+	impl.Subtract = func(minuend, subtrahend int) int { return minuend - subtrahend }
+	impl.Divide = func(ctx context.Context, a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	}
+	impl.Split = func(s string) (string, string) {
+		head, tail, _ := strings.Cut(s, " ")
+		return head, tail
+	}
+	impl.Strings.Upper = strings.ToUpper
+	return impl
+}
+
+func TestLink(t *testing.T) {
+	handler, err := jrpc.Handler(nil, newCalculator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := api.Import[Calculator](jrpc.API, server.URL, server.Client())
+	if got := client.Subtract(42, 23); got != 19 {
+		t.Fatalf("got %v, want 19", got)
+	}
+	if got, err := client.Divide(context.Background(), 1, 4); err != nil || got != 0.25 {
+		t.Fatalf("got %v (%v), want 0.25", got, err)
+	}
+	if _, err := client.Divide(context.Background(), 1, 0); err == nil || err.Error() != "division by zero" {
+		t.Fatalf("got %v, want division by zero", err)
+	}
+	if head, tail := client.Split("hello world"); head != "hello" || tail != "world" {
+		t.Fatalf("got %q %q", head, tail)
+	}
+	if got := client.Strings.Upper("abc"); got != "ABC" {
+		t.Fatalf("got %q, want ABC", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler, err := jrpc.Handler(nil, newCalculator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ req, resp string }{
+		{`{"jsonrpc":"2.0","method":"subtract","params":[42,23],"id":1}`,
+			`{"jsonrpc":"2.0","result":19,"id":1}`},
+		{`{"jsonrpc":"2.0","method":"subtract","params":{"subtrahend":23,"minuend":42},"id":"a"}`,
+			`{"jsonrpc":"2.0","result":19,"id":"a"}`},
+		{`{"jsonrpc":"2.0","method":"Hidden","id":2}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":2}`},
+		{`{"jsonrpc":"2.0","method":"subtract","params":[1,2]}`, ``},
+		{`[{"jsonrpc":"2.0","method":"Strings.Upper","params":["x"],"id":1},{"jsonrpc":"2.0","method":"subtract","params":[1,2]},{"foo":"boo"}]`,
+			`[{"jsonrpc":"2.0","result":"X","id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`},
+		{`{"jsonrpc":"2.0","method":"foobar,"params":"bar","baz]`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(tc.req)))
+		if tc.resp == "" {
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("%s: got %v, want 204", tc.req, rec.Code)
+			}
+			continue
+		}
+		if rec.Body.String() != tc.resp {
+			t.Errorf("%s:\ngot  %s\nwant %s", tc.req, rec.Body.String(), tc.resp)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader(`{"jsonrpc":"2.0","method":"subtract","params":[42,23],"id":1}
+{"jsonrpc":"2.0","method":"split","params":["a b"],"id":2}
+`)
+	if err := jrpc.Serve(context.Background(), newCalculator(), in, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"jsonrpc":"2.0","result":19,"id":1}
+{"jsonrpc":"2.0","result":{"head":"a","tail":"b"},"id":2}
+`
+	if out.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestDuplicateMethods(t *testing.T) {
+	type API struct {
+		api.Specification
+
+		Add  func(a, b int) int `jrpc:"sum"`
+		Plus func(a, b int) int `jrpc:"sum"`
+	}
+	add := func(a, b int) int { return a + b }
+	_, err := jrpc.Handler(nil, API{Add: add, Plus: add})
+	if err == nil || !strings.Contains(err.Error(), "Add") || !strings.Contains(err.Error(), "Plus") {
+		t.Fatalf("expected a duplicate method error naming both functions, got %v", err)
+	}
+}
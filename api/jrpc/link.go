@@ -0,0 +1,98 @@
+package jrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+
+	"runtime.link/api"
+	"runtime.link/api/xray"
+)
+
+// API implements the [api.Linker] interface.
+var API api.Linker[string, *http.Client] = linker{}
+
+type linker struct{}
+
+// Link implements the [api.Linker] interface.
+func (linker) Link(structure api.Structure, host string, client *http.Client) error {
+	spec, err := specificationOf(structure)
+	if err != nil {
+		return xray.New(err)
+	}
+	if host == "" {
+		host = spec.Host.Get("www")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var id atomic.Int64
+	for _, m := range spec.Methods {
+		m := m
+		m.Make(func(ctx context.Context, args []reflect.Value) ([]reflect.Value, error) {
+			if host == "" {
+				return nil, fmt.Errorf("failed to call %v, %s host URL is empty", m.Name, spec.Name)
+			}
+			params, err := m.encodeParams(args)
+			if err != nil {
+				return nil, xray.New(err)
+			}
+			body, err := json.Marshal(request{
+				Version: Version,
+				Method:  m.Name,
+				Params:  params,
+				ID:      json.RawMessage(strconv.FormatInt(id.Add(1), 10)),
+			})
+			if err != nil {
+				return nil, xray.New(err)
+			}
+			req, err := http.NewRequestWithContext(ctx, "POST", host, bytes.NewReader(body))
+			if err != nil {
+				return nil, xray.New(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			xray.ContextAdd(ctx, req)
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			xray.ContextAdd(ctx, resp)
+			var decoded response
+			if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+				if resp.StatusCode < 200 || resp.StatusCode > 299 {
+					return nil, errors.New("unexpected status : " + resp.Status)
+				}
+				return nil, xray.New(err)
+			}
+			if decoded.Error != nil {
+				return nil, decodeError(spec, decoded.Error)
+			}
+			return m.decodeResults(decoded.Result)
+		})
+	}
+	return nil
+}
+
+var errType = reflect.TypeOf([0]error{}).Elem()
+
+// decodeError returns the registered error type of the specification when
+// there is exactly one and the error object has data, otherwise the [Error]
+// itself is returned.
+func decodeError(spec specification, e *Error) error {
+	errortypes := spec.Instances[errType]
+	if len(e.Data) > 0 && len(errortypes) == 1 && errortypes[0].Implements(errType) {
+		err := reflect.New(errortypes[0])
+		if json.Unmarshal(e.Data, err.Interface()) == nil {
+			return err.Elem().Interface().(error)
+		}
+	}
+	return e
+}
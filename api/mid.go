@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"reflect"
+)
+
+// Middleware intercepts calls to a [Function], such that cross-cutting
+// concerns (rate limiting, metrics, caching, logging etc) can be implemented
+// once and then applied to any implementation of a runtime.link API structure,
+// regardless of which link layer it is hosted with. The middleware should
+// call next to continue the call, or else return early to short-circuit it.
+//
+//	logging := func(ctx context.Context, fn api.Function, args []reflect.Value, next api.Next) ([]reflect.Value, error) {
+//		results, err := next(ctx, args)
+//		log.Println(fn.Name, err)
+//		return results, err
+//	}
+//	handler, err := rest.Handler(nil, api.Wrap(&impl, logging))
+type Middleware func(ctx context.Context, fn Function, args []reflect.Value, next Next) ([]reflect.Value, error)
+
+// Next continues a call that has been intercepted by a [Middleware], the
+// arguments and results exclude any leading [context.Context] and trailing
+// [error] values.
+type Next func(ctx context.Context, args []reflect.Value) ([]reflect.Value, error)
+
+// Use wraps each implemented function within the structure (including any
+// namespaces) with the given middleware. The first middleware is the
+// outermost, so it sees each call first and its results last.
+func (s Structure) Use(middleware ...Middleware) {
+	if len(middleware) == 0 {
+		return
+	}
+	for fn := range s.Iter() {
+		if fn.Impl.IsNil() {
+			continue
+		}
+		var (
+			original = fn.Copy()
+			next     = Next(original.Call)
+		)
+		for i := len(middleware) - 1; i >= 0; i-- {
+			var (
+				wrap  = middleware[i]
+				inner = next
+			)
+			next = func(ctx context.Context, args []reflect.Value) ([]reflect.Value, error) {
+				return wrap(ctx, original, args, inner)
+			}
+		}
+		fn.Make(func(ctx context.Context, args []reflect.Value) ([]reflect.Value, error) {
+			return next(ctx, args)
+		})
+	}
+}
+
+// Wrap returns a copy of the given implementation of a runtime.link API
+// structure (or a pointer to one), with each of its functions wrapped with
+// the given middleware, see [Structure.Use].
+func Wrap[API any](impl API, middleware ...Middleware) API {
+	rvalue := reflect.ValueOf(impl)
+	if rvalue.Kind() == reflect.Pointer && !rvalue.IsNil() {
+		copied := reflect.New(rvalue.Type().Elem())
+		copied.Elem().Set(rvalue.Elem())
+		StructureOf(copied.Interface()).Use(middleware...)
+		return copied.Interface().(API)
+	}
+	StructureOf(&impl).Use(middleware...)
+	return impl
+}
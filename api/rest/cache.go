@@ -0,0 +1,360 @@
+package rest
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"runtime.link/api"
+)
+
+// cacheControlOf returns the Cache-Control header for the responses of a
+// GET function, from its 'cache' tag (or from the tag on the specification).
+func cacheControlOf(spec specification, fn api.Function) string {
+	if control := fn.Tags.Get("cache"); control != "" {
+		return control
+	}
+	return spec.Tags.Get("cache")
+}
+
+// conditional reports whether the results of a GET function can be buffered
+// by a [conditionalWriter], ie. they are not streamed.
+func conditional(results []reflect.Value) bool {
+	for _, result := range results {
+		if result.Kind() == reflect.Chan {
+			return false
+		}
+		if isSeq, isSeq2 := isIteratorType(result.Type()); isSeq || isSeq2 {
+			return false
+		}
+		switch result.Interface().(type) {
+		case io.Reader, io.WriterTo:
+			return false
+		}
+	}
+	return true
+}
+
+// maxConditionalSize is the largest response that a [conditionalWriter] will
+// buffer, larger responses are streamed without a generated ETag.
+const maxConditionalSize = 1 << 20
+
+// conditionalWriter buffers a successful response, so that it can be given
+// an ETag (if it does not already have one) and then replaced with a '304 Not
+// Modified' response, if the request already has a matching representation.
+// Responses larger than [maxConditionalSize] are only replaced if the function
+// returned its own validators.
+type conditionalWriter struct {
+	http.ResponseWriter
+
+	request *http.Request
+	status  int
+	body    bytes.Buffer
+	started bool // the header has been written.
+	skipped bool // a 304 has been written, so the body is discarded.
+}
+
+func (cw *conditionalWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *conditionalWriter) Write(b []byte) (int, error) {
+	cw.WriteHeader(http.StatusOK)
+	switch {
+	case cw.skipped:
+		return len(b), nil
+	case cw.started:
+		return cw.ResponseWriter.Write(b)
+	case cw.body.Len()+len(b) > maxConditionalSize:
+		cw.start(false)
+		return cw.Write(b)
+	}
+	return cw.body.Write(b)
+}
+
+// start writes the header (or a 304) to the underlying writer, followed by
+// the buffered body. The body is only hashed into an ETag when it is complete.
+func (cw *conditionalWriter) start(complete bool) {
+	cw.started = true
+	header := cw.Header()
+	if cw.status == http.StatusOK {
+		if header.Get("ETag") == "" && complete {
+			sum := sha256.Sum256(cw.body.Bytes())
+			header.Set("ETag", `"`+base64.RawURLEncoding.EncodeToString(sum[:18])+`"`)
+		}
+		header.Add("Vary", "Accept")
+		if notModified(cw.request, header) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			cw.ResponseWriter.WriteHeader(http.StatusNotModified)
+			cw.skipped = true
+			return
+		}
+	}
+	if complete && cw.status != http.StatusNoContent && header.Get("Content-Length") == "" {
+		header.Set("Content-Length", strconv.Itoa(cw.body.Len()))
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.ResponseWriter.Write(cw.body.Bytes())
+	cw.body = bytes.Buffer{}
+}
+
+// finish writes the buffered response (or a 304) to the underlying writer.
+func (cw *conditionalWriter) finish() {
+	cw.WriteHeader(http.StatusOK)
+	if !cw.started {
+		cw.start(true)
+	}
+}
+
+// notModified reports whether the validators of the request match the
+// response headers, If-Modified-Since is only checked when the request does
+// not have an If-None-Match header.
+func notModified(r *http.Request, header http.Header) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		etag := strings.TrimPrefix(header.Get("ETag"), "W/")
+		for candidate := range strings.SplitSeq(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || etag != "" && strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	return err == nil && !modified.After(since)
+}
+
+// WithCache returns a copy of the client (or of the default client if nil),
+// that keeps a private cache of up to size GET responses (defaults to 1000),
+// so that functions linked with it only download a response again when it
+// has changed. Responses are reused without a request while they are fresh
+// (according to their Cache-Control max-age), after which they are revalidated
+// with their ETag or Last-Modified header. Responses with a no-store
+// directive are not cached. Responses are only reused for requests with the
+// same credentials (Authorization and Cookie headers), so the client can be
+// shared between callers.
+func WithCache(client *http.Client, size int) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if size <= 0 {
+		size = 1000
+	}
+	var copied = *client
+	copied.Transport = &cacheTransport{
+		base:    client.Transport,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+	return &copied
+}
+
+type cacheTransport struct {
+	base http.RoundTripper
+	size int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element // of *cacheEntry
+	recent  *list.List               // most recently used at the front.
+}
+
+type cacheEntry struct {
+	key    string
+	vary   map[string]string // request headers that the response varies by.
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+}
+
+// credentials are the request headers that identify the caller, responses
+// are never shared between requests that differ in them.
+var credentials = []string{"Authorization", "Cookie"}
+
+// cacheKey returns the key of the entry for the request, along with the
+// request headers that any entry for it must match.
+func cacheKey(req *http.Request) (string, map[string]string) {
+	var (
+		key  = req.URL.String()
+		vary = map[string]string{"Accept": req.Header.Get("Accept")}
+	)
+	for _, name := range credentials {
+		if value := req.Header.Get(name); value != "" {
+			vary[name] = value
+			sum := sha256.Sum256([]byte(name + ": " + value))
+			key += " " + base64.RawURLEncoding.EncodeToString(sum[:])
+		}
+	}
+	return key, vary
+}
+
+func (t *cacheTransport) unwrap() http.RoundTripper { return t.base }
+
+func (t *cacheTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.base == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// directives parses a Cache-Control header.
+func directives(header http.Header) map[string]string {
+	var parsed = make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for directive := range strings.SplitSeq(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			parsed[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return parsed
+}
+
+// fresh reports whether the entry can be reused without revalidation.
+func (entry *cacheEntry) fresh(now time.Time) bool {
+	control := directives(entry.header)
+	if _, ok := control["no-cache"]; ok {
+		return false
+	}
+	age, err := strconv.Atoi(control["max-age"])
+	return err == nil && now.Sub(entry.stored) < time.Duration(age)*time.Second
+}
+
+func (entry *cacheEntry) matches(req *http.Request) bool {
+	for name, value := range entry.vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (entry *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(entry.status) + " " + http.StatusText(entry.status),
+		StatusCode:    entry.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(entry.body)),
+		ContentLength: int64(len(entry.body)),
+		Request:       req,
+	}
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" || req.Header.Get("Range") != "" {
+		return t.roundTrip(req)
+	}
+	key, vary := cacheKey(req)
+	t.mutex.Lock()
+	var entry *cacheEntry
+	if element, ok := t.entries[key]; ok {
+		entry = element.Value.(*cacheEntry)
+		t.recent.MoveToFront(element)
+		if !entry.matches(req) {
+			entry = nil
+		}
+	}
+	t.mutex.Unlock()
+	if entry != nil {
+		if _, ok := directives(req.Header)["no-cache"]; !ok && entry.fresh(time.Now()) {
+			return entry.response(req), nil
+		}
+		req = req.Clone(req.Context())
+		if etag := entry.header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := entry.header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+	resp, err := t.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		updated := *entry
+		updated.header = entry.header.Clone()
+		for _, name := range []string{"Cache-Control", "ETag", "Expires", "Last-Modified"} {
+			if values := resp.Header.Values(name); len(values) > 0 {
+				updated.header[name] = values
+			}
+		}
+		updated.stored = time.Now()
+		t.store(&updated)
+		return updated.response(req), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	control := directives(resp.Header)
+	if _, ok := control["no-store"]; ok {
+		return resp, nil
+	}
+	if _, ok := control["max-age"]; !ok && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	entry = &cacheEntry{
+		key:    key,
+		vary:   vary,
+		status: resp.StatusCode,
+		header: resp.Header.Clone(),
+		body:   body,
+		stored: time.Now(),
+	}
+	for _, value := range resp.Header.Values("Vary") {
+		for name := range strings.SplitSeq(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return resp, nil
+			}
+			entry.vary[name] = req.Header.Get(name)
+		}
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.store(entry)
+	return resp, nil
+}
+
+// store the entry, evicting the least recently used entries to make room for
+// it. The mutex must be held.
+func (t *cacheTransport) store(entry *cacheEntry) {
+	if element, ok := t.entries[entry.key]; ok {
+		element.Value = entry
+		t.recent.MoveToFront(element)
+		return
+	}
+	t.entries[entry.key] = t.recent.PushFront(entry)
+	for t.recent.Len() > t.size {
+		oldest := t.recent.Back()
+		t.recent.Remove(oldest)
+		delete(t.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
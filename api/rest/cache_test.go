@@ -0,0 +1,174 @@
+package rest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"runtime.link/api"
+	"runtime.link/api/rest"
+)
+
+type Forecast struct {
+	Summary string `json:"summary"`
+}
+
+type Article struct {
+	Title   string    `json:"title"`
+	Updated time.Time `json:"-"`
+}
+
+func (a Article) WriteHeadersHTTP(header http.Header) {
+	header.Set("Last-Modified", a.Updated.UTC().Format(http.TimeFormat))
+}
+
+type statusRecorder struct {
+	statuses []int
+}
+
+func (r *statusRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		r.statuses = append(r.statuses, resp.StatusCode)
+	}
+	return resp, err
+}
+
+func TestCaching(t *testing.T) {
+	type API struct {
+		api.Specification
+
+		Forecast func(context.Context) (Forecast, error) `rest:"GET /forecast"`
+		Today    func(context.Context) (Forecast, error) `rest:"GET /today" cache:"max-age=60"`
+		Article  func(context.Context) (Article, error)  `rest:"GET /article"`
+	}
+	var (
+		calls   atomic.Int32
+		summary atomic.Value
+	)
+	summary.Store("sunny")
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	handler, err := rest.Handler(nil, &API{
+		Forecast: func(ctx context.Context) (Forecast, error) {
+			calls.Add(1)
+			return Forecast{Summary: summary.Load().(string)}, nil
+		},
+		Today: func(ctx context.Context) (Forecast, error) {
+			calls.Add(1)
+			return Forecast{Summary: summary.Load().(string)}, nil
+		},
+		Article: func(ctx context.Context) (Article, error) {
+			return Article{Title: "News", Updated: updated}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func(path string, header http.Header) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = header
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	resp := get("/forecast", http.Header{"Accept": {"application/json"}})
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected an ETag, got %v %v", resp.Status, resp.Header)
+	}
+	resp = get("/forecast", http.Header{"Accept": {"application/json"}, "If-None-Match": {`"other", ` + etag}})
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != etag {
+		t.Fatalf("expected 304, got %v %v", resp.Status, resp.Header)
+	}
+	if resp := get("/today", nil); resp.Header.Get("Cache-Control") != "max-age=60" {
+		t.Fatalf("unexpected Cache-Control %q", resp.Header.Get("Cache-Control"))
+	}
+	since := http.Header{"If-Modified-Since": {updated.Add(time.Hour).Format(http.TimeFormat)}}
+	if resp := get("/article", since); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304, got %v", resp.Status)
+	}
+	since = http.Header{"If-Modified-Since": {updated.Add(-time.Hour).Format(http.TimeFormat)}}
+	if resp := get("/article", since); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %v", resp.Status)
+	}
+
+	var recorder statusRecorder
+	client := api.Import[API](rest.API, server.URL, rest.WithCache(&http.Client{Transport: &recorder}, 0))
+	calls.Store(0)
+	for range 3 {
+		forecast, err := client.Forecast(context.Background())
+		if err != nil || forecast.Summary != "sunny" {
+			t.Fatal(forecast, err)
+		}
+	}
+	if statuses := recorder.statuses; len(statuses) != 3 || statuses[0] != 200 || statuses[1] != 304 || statuses[2] != 304 {
+		t.Fatalf("expected revalidations, got %v", statuses)
+	}
+	summary.Store("rainy")
+	if forecast, err := client.Forecast(context.Background()); err != nil || forecast.Summary != "rainy" {
+		t.Fatal(forecast, err)
+	}
+	for range 3 {
+		if _, err := client.Today(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 5 {
+		t.Fatalf("expected fresh responses to be reused, got %d calls", calls.Load())
+	}
+}
+
+func TestCacheCredentials(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	client := rest.WithCache(nil, 0)
+	get := func(auth string) string {
+		t.Helper()
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	for range 2 {
+		for _, auth := range []string{"Bearer alice", "Bearer bob", ""} {
+			if body := get(auth); body != auth {
+				t.Fatalf("expected the response for %q, got %q", auth, body)
+			}
+		}
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected a fresh response for each caller, got %d calls", calls.Load())
+	}
+}
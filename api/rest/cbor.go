@@ -0,0 +1,261 @@
+package rest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// cbor implements the Concise Binary Object Representation (RFC 8949), as
+// the 'application/cbor' content type. Tags are ignored when decoding, such
+// that the tagged item is decoded as if it were untagged.
+type cbor struct{}
+
+var cborFormat binaryFormat = cbor{}
+
+const (
+	cborUint   = 0
+	cborNegint = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+
+	cborIndefinite = 31
+	cborBreak      = 0xFF
+)
+
+// head appends the initial byte of an item, followed by its argument.
+func (cbor) head(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), n)
+	}
+}
+
+func (cbor) appendNull(b []byte) []byte { return append(b, 0xF6) }
+
+func (cbor) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xF5)
+	}
+	return append(b, 0xF4)
+}
+
+func (c cbor) appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return c.head(b, cborNegint, uint64(-1-v))
+	}
+	return c.head(b, cborUint, uint64(v))
+}
+
+func (c cbor) appendUint(b []byte, v uint64) []byte { return c.head(b, cborUint, v) }
+
+func (cbor) appendFloat(b []byte, v float64) []byte {
+	if float64(float32(v)) == v {
+		return binary.BigEndian.AppendUint32(append(b, 0xFA), math.Float32bits(float32(v)))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xFB), math.Float64bits(v))
+}
+
+func (c cbor) appendString(b []byte, v string) []byte {
+	return append(c.head(b, cborText, uint64(len(v))), v...)
+}
+
+func (c cbor) appendBytes(b []byte, v []byte) []byte {
+	return append(c.head(b, cborBytes, uint64(len(v))), v...)
+}
+
+func (c cbor) appendArray(b []byte, n int) []byte { return c.head(b, cborArray, uint64(n)) }
+func (c cbor) appendMap(b []byte, n int) []byte   { return c.head(b, cborMap, uint64(n)) }
+
+func (c cbor) parse(b []byte, i, depth int) (any, int, error) {
+	if depth > maxDepth {
+		return nil, 0, errTooDeep
+	}
+	if i >= len(b) {
+		return nil, 0, errTruncated
+	}
+	major, info := b[i]>>5, b[i]&0x1F
+	i++
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(b)-i < size {
+			return nil, 0, errTruncated
+		}
+		for _, c := range b[i : i+size] {
+			n = n<<8 | uint64(c)
+		}
+		i += size
+	case info == cborIndefinite && major >= cborBytes && major <= cborMap:
+		return c.parseIndefinite(b, i, major, depth)
+	default:
+		return nil, 0, fmt.Errorf("cbor: invalid initial byte %#x", b[i-1])
+	}
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return n, i, nil
+		}
+		return int64(n), i, nil
+	case cborNegint:
+		if n > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: negative integer overflows int64")
+		}
+		return -1 - int64(n), i, nil
+	case cborBytes, cborText:
+		if n > uint64(len(b)-i) {
+			return nil, 0, errTruncated
+		}
+		data := b[i : i+int(n)]
+		if major == cborText {
+			return string(data), i + int(n), nil
+		}
+		return append([]byte(nil), data...), i + int(n), nil
+	case cborArray:
+		if n > uint64(len(b)-i) { // each item is at least one byte.
+			return nil, 0, errTruncated
+		}
+		var items = make([]any, n)
+		for j := range items {
+			var err error
+			if items[j], i, err = c.parse(b, i, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return items, i, nil
+	case cborMap:
+		if n > uint64(len(b)-i)/2 {
+			return nil, 0, errTruncated
+		}
+		var pairs = make(binaryMap, n)
+		for j := range pairs {
+			var err error
+			if pairs[j].key, i, err = c.parse(b, i, depth+1); err != nil {
+				return nil, 0, err
+			}
+			if pairs[j].value, i, err = c.parse(b, i, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return pairs, i, nil
+	case cborTag:
+		return c.parse(b, i, depth+1)
+	default:
+		switch info {
+		case 20:
+			return false, i, nil
+		case 21:
+			return true, i, nil
+		case 22, 23: // null, undefined
+			return nil, i, nil
+		case 25:
+			return halfFloat(uint16(n)), i, nil
+		case 26:
+			return float64(math.Float32frombits(uint32(n))), i, nil
+		case 27:
+			return math.Float64frombits(n), i, nil
+		}
+		return nil, 0, fmt.Errorf("cbor: unsupported simple value %d", n)
+	}
+}
+
+// parseIndefinite parses the chunks or items of an indefinite length item, up to
+// its break.
+func (c cbor) parseIndefinite(b []byte, i int, major byte, depth int) (any, int, error) {
+	var (
+		data  []byte
+		items []any
+		pairs binaryMap
+	)
+	for {
+		if i >= len(b) {
+			return nil, 0, errTruncated
+		}
+		if b[i] == cborBreak {
+			i++
+			break
+		}
+		item, next, err := c.parse(b, i, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch major {
+		case cborBytes, cborText:
+			if b[i]>>5 != major {
+				return nil, 0, errors.New("cbor: invalid chunk in indefinite length string")
+			}
+			switch chunk := item.(type) {
+			case []byte:
+				data = append(data, chunk...)
+			case string:
+				data = append(data, chunk...)
+			}
+		case cborArray:
+			items = append(items, item)
+		case cborMap:
+			value, after, err := c.parse(b, next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			pairs = append(pairs, binaryPair{key: item, value: value})
+			next = after
+		}
+		i = next
+	}
+	switch major {
+	case cborBytes:
+		return data, i, nil
+	case cborText:
+		return string(data), i, nil
+	case cborArray:
+		if items == nil {
+			items = []any{}
+		}
+		return items, i, nil
+	default:
+		if pairs == nil {
+			pairs = binaryMap{}
+		}
+		return pairs, i, nil
+	}
+}
+
+// halfFloat converts an IEEE 754 half-precision float.
+func halfFloat(bits uint16) float64 {
+	var (
+		sign     = 1.0
+		exponent = int(bits>>10) & 0x1F
+		fraction = float64(bits & 0x3FF)
+	)
+	if bits&0x8000 != 0 {
+		sign = -1
+	}
+	switch exponent {
+	case 0:
+		return sign * math.Ldexp(fraction, -24)
+	case 0x1F:
+		if fraction == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	default:
+		return sign * math.Ldexp(fraction+1024, exponent-25)
+	}
+}
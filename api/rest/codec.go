@@ -0,0 +1,646 @@
+package rest
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"runtime.link/api/xray"
+)
+
+// binaryFormat is a self-describing binary encoding (ie. CBOR or MessagePack)
+// that Go values are converted to and from with the same rules as encoding/json,
+// so that field names, omitempty and marshalers are respected, except that
+// byte slices are encoded as binary strings rather than as base64.
+type binaryFormat interface {
+	appendNull(b []byte) []byte
+	appendBool(b []byte, v bool) []byte
+	appendInt(b []byte, v int64) []byte
+	appendUint(b []byte, v uint64) []byte
+	appendFloat(b []byte, v float64) []byte
+	appendString(b []byte, v string) []byte
+	appendBytes(b []byte, v []byte) []byte
+	appendArray(b []byte, n int) []byte
+	appendMap(b []byte, n int) []byte
+
+	// parse the item at i, returning the offset after it. Items are decoded
+	// as nil, bool, int64 (or uint64 if it overflows), float64, string,
+	// []byte, time.Time, []any or binaryMap.
+	parse(b []byte, i, depth int) (any, int, error)
+}
+
+// binaryMap preserves the order and the (possibly non-string) keys of a map.
+type binaryMap []binaryPair
+
+type binaryPair struct {
+	key, value any
+}
+
+// maxDepth limits the nesting of decoded arrays and maps.
+const maxDepth = 1000
+
+var (
+	errTruncated = errors.New("truncated value")
+	errTooDeep   = errors.New("value is nested too deeply")
+)
+
+func encodeWith(format binaryFormat) func(http.ResponseWriter, any) error {
+	return func(w http.ResponseWriter, v any) error {
+		b, err := appendBinary(format, nil, reflect.ValueOf(v))
+		if err != nil {
+			return xray.New(err)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		_, err = w.Write(b)
+		return xray.New(err)
+	}
+}
+
+func decodeWith(format binaryFormat) func(io.Reader, any) error {
+	return func(r io.Reader, v any) error {
+		rvalue := reflect.ValueOf(v)
+		if rvalue.Kind() != reflect.Pointer || rvalue.IsNil() {
+			return xray.New(fmt.Errorf("cannot decode into non-pointer %T", v))
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return xray.New(err)
+		}
+		if len(b) == 0 {
+			return io.EOF
+		}
+		item, _, err := format.parse(b, 0, 0)
+		if err != nil {
+			return xray.New(err)
+		}
+		return xray.New(assignBinary(rvalue.Elem(), item))
+	}
+}
+
+var (
+	jsonMarshaler     = reflect.TypeFor[json.Marshaler]()
+	jsonUnmarshaler   = reflect.TypeFor[json.Unmarshaler]()
+	textMarshaler     = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshaler   = reflect.TypeFor[encoding.TextUnmarshaler]()
+	timeType          = reflect.TypeFor[time.Time]()
+	binaryFieldsCache sync.Map // map[reflect.Type][]binaryField
+)
+
+func appendBinary(f binaryFormat, b []byte, rvalue reflect.Value) ([]byte, error) {
+	if !rvalue.IsValid() {
+		return f.appendNull(b), nil
+	}
+	rtype := rvalue.Type()
+	if (rtype.Kind() == reflect.Pointer || rtype.Kind() == reflect.Interface) && rvalue.IsNil() {
+		return f.appendNull(b), nil
+	}
+	// marshalers cannot be called on the exported fields of unexported embedded structs.
+	marshalable := rvalue.CanInterface()
+	if marshalable && rtype.Kind() != reflect.Pointer && rvalue.CanAddr() && !rtype.Implements(jsonMarshaler) && !rtype.Implements(textMarshaler) {
+		if ptr := reflect.PointerTo(rtype); ptr.Implements(jsonMarshaler) || ptr.Implements(textMarshaler) {
+			rvalue, rtype = rvalue.Addr(), ptr
+		}
+	}
+	if marshalable && rtype.Implements(jsonMarshaler) {
+		raw, err := rvalue.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var decoder = json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		return appendBinary(f, b, reflect.ValueOf(fromJSON(value)))
+	}
+	if marshalable && rtype.Implements(textMarshaler) {
+		text, err := rvalue.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return f.appendString(b, string(text)), nil
+	}
+	switch rtype.Kind() {
+	case reflect.Bool:
+		return f.appendBool(b, rvalue.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.appendInt(b, rvalue.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return f.appendUint(b, rvalue.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return f.appendFloat(b, rvalue.Float()), nil
+	case reflect.String:
+		return f.appendString(b, rvalue.String()), nil
+	case reflect.Pointer, reflect.Interface:
+		return appendBinary(f, b, rvalue.Elem())
+	case reflect.Slice:
+		if rvalue.IsNil() {
+			return f.appendNull(b), nil
+		}
+		if rtype.Elem().Kind() == reflect.Uint8 {
+			return f.appendBytes(b, rvalue.Bytes()), nil
+		}
+		fallthrough
+	case reflect.Array:
+		if rtype.Elem().Kind() == reflect.Uint8 {
+			var data = make([]byte, rvalue.Len())
+			reflect.Copy(reflect.ValueOf(data), rvalue)
+			return f.appendBytes(b, data), nil
+		}
+		b = f.appendArray(b, rvalue.Len())
+		for i := range rvalue.Len() {
+			var err error
+			if b, err = appendBinary(f, b, rvalue.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		if rvalue.IsNil() {
+			return f.appendNull(b), nil
+		}
+		keys := rvalue.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			switch a.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return cmp.Compare(a.Int(), b.Int())
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				return cmp.Compare(a.Uint(), b.Uint())
+			case reflect.String:
+				return strings.Compare(a.String(), b.String())
+			default:
+				return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+			}
+		})
+		b = f.appendMap(b, len(keys))
+		for _, key := range keys {
+			var err error
+			if key.Kind() == reflect.String {
+				b = f.appendString(b, key.String())
+			} else if b, err = appendBinary(f, b, key); err != nil {
+				return nil, err
+			}
+			if b, err = appendBinary(f, b, rvalue.MapIndex(key)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Struct:
+		var fields []reflect.Value
+		var names []string
+		for _, field := range binaryFieldsOf(rtype) {
+			value, ok := fieldOf(rvalue, field.index)
+			if !ok || field.omitempty && isEmpty(value) || field.omitzero && value.IsZero() {
+				continue
+			}
+			fields = append(fields, value)
+			names = append(names, field.name)
+		}
+		b = f.appendMap(b, len(fields))
+		for i, value := range fields {
+			var err error
+			b = f.appendString(b, names[i])
+			if b, err = appendBinary(f, b, value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %v", rtype)
+	}
+}
+
+// binaryField is an encoded field of a struct, named the same way as encoding/json.
+type binaryField struct {
+	name      string
+	index     []int
+	omitempty bool
+	omitzero  bool
+}
+
+func binaryFieldsOf(rtype reflect.Type) []binaryField {
+	if cached, ok := binaryFieldsCache.Load(rtype); ok {
+		return cached.([]binaryField)
+	}
+	var (
+		fields []binaryField
+		seen   = make(map[string]bool)
+		embeds []binaryField
+	)
+	for i := range rtype.NumField() {
+		field := rtype.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for _, inner := range binaryFieldsOf(embedded) {
+					inner.index = append([]int{i}, inner.index...)
+					embeds = append(embeds, inner)
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		seen[name] = true
+		fields = append(fields, binaryField{
+			name:      name,
+			index:     []int{i},
+			omitempty: strings.Contains(options, "omitempty"),
+			omitzero:  strings.Contains(options, "omitzero"),
+		})
+	}
+	for _, field := range embeds { // fields of the outer struct take precedence.
+		if !seen[field.name] {
+			seen[field.name] = true
+			fields = append(fields, field)
+		}
+	}
+	binaryFieldsCache.Store(rtype, fields)
+	return fields
+}
+
+// fieldOf returns the field at the given index, false if it is within a nil
+// embedded pointer.
+func fieldOf(rvalue reflect.Value, index []int) (reflect.Value, bool) {
+	for i, j := range index {
+		if i > 0 && rvalue.Kind() == reflect.Pointer {
+			if rvalue.IsNil() {
+				return reflect.Value{}, false
+			}
+			rvalue = rvalue.Elem()
+		}
+		rvalue = rvalue.Field(j)
+	}
+	return rvalue, true
+}
+
+// settableFieldOf returns the field at the given index, allocating any nil
+// embedded pointers along the way.
+func settableFieldOf(rvalue reflect.Value, index []int) (reflect.Value, error) {
+	for i, j := range index {
+		if i > 0 && rvalue.Kind() == reflect.Pointer {
+			if rvalue.IsNil() {
+				if !rvalue.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported %v", rvalue.Type().Elem())
+				}
+				rvalue.Set(reflect.New(rvalue.Type().Elem()))
+			}
+			rvalue = rvalue.Elem()
+		}
+		rvalue = rvalue.Field(j)
+	}
+	return rvalue, nil
+}
+
+func isEmpty(rvalue reflect.Value) bool {
+	switch rvalue.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rvalue.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return rvalue.IsZero()
+	}
+	return false
+}
+
+// fromJSON converts a value decoded from JSON (with UseNumber) into items
+// that can be encoded by a [binaryFormat].
+func fromJSON(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i := range v {
+			v[i] = fromJSON(v[i])
+		}
+		return v
+	case map[string]any:
+		for key := range v {
+			v[key] = fromJSON(v[key])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// toJSON converts a decoded item into a value that can be marshaled as JSON.
+func toJSON(item any) any {
+	switch v := item.(type) {
+	case []any:
+		var values = make([]any, len(v))
+		for i := range v {
+			values[i] = toJSON(v[i])
+		}
+		return values
+	case binaryMap:
+		var values = make(map[string]any, len(v))
+		for _, pair := range v {
+			key, ok := pair.key.(string)
+			if !ok {
+				key = fmt.Sprint(pair.key)
+			}
+			values[key] = toJSON(pair.value)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// natural converts a decoded item into the Go value that it is decoded
+// into, when the destination is an empty interface.
+func natural(item any) any {
+	switch v := item.(type) {
+	case []any:
+		for i := range v {
+			v[i] = natural(v[i])
+		}
+		return v
+	case binaryMap:
+		var strings = make(map[string]any, len(v))
+		for _, pair := range v {
+			key, ok := pair.key.(string)
+			if !ok {
+				break
+			}
+			strings[key] = natural(pair.value)
+		}
+		if len(strings) == len(v) {
+			return strings
+		}
+		var values = make(map[any]any, len(v))
+		for _, pair := range v {
+			key := pair.key
+			switch k := key.(type) {
+			case []byte:
+				key = string(k)
+			case []any, binaryMap:
+				key = fmt.Sprint(natural(k))
+			}
+			values[key] = natural(pair.value)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+func assignBinary(rvalue reflect.Value, item any) error {
+	rtype := rvalue.Type()
+	if item == nil {
+		switch rtype.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+			rvalue.SetZero()
+		}
+		return nil
+	}
+	if rtype.Kind() == reflect.Pointer {
+		if rvalue.IsNil() {
+			rvalue.Set(reflect.New(rtype.Elem()))
+		}
+		return assignBinary(rvalue.Elem(), item)
+	}
+	if t, ok := item.(time.Time); ok && rtype == timeType {
+		rvalue.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if ptr := rvalue.Addr(); ptr.Type().Implements(jsonUnmarshaler) {
+		raw, err := json.Marshal(toJSON(item))
+		if err != nil {
+			return err
+		}
+		return ptr.Interface().(json.Unmarshaler).UnmarshalJSON(raw)
+	} else if ptr.Type().Implements(textUnmarshaler) {
+		switch v := item.(type) {
+		case string:
+			return ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(v))
+		case []byte:
+			return ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText(v)
+		}
+	}
+	mismatch := func() error {
+		return fmt.Errorf("cannot decode %T into %v", item, rtype)
+	}
+	switch rtype.Kind() {
+	case reflect.Interface:
+		if rtype.NumMethod() > 0 {
+			return mismatch()
+		}
+		rvalue.Set(reflect.ValueOf(natural(item)))
+	case reflect.Bool:
+		v, ok := item.(bool)
+		if !ok {
+			return mismatch()
+		}
+		rvalue.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch v := item.(type) {
+		case int64:
+			i = v
+		case float64:
+			if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+				return mismatch()
+			}
+			i = int64(v)
+		default:
+			return mismatch()
+		}
+		if rvalue.OverflowInt(i) {
+			return fmt.Errorf("%v overflows %v", i, rtype)
+		}
+		rvalue.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch v := item.(type) {
+		case int64:
+			if v < 0 {
+				return fmt.Errorf("%v overflows %v", v, rtype)
+			}
+			u = uint64(v)
+		case uint64:
+			u = v
+		case float64:
+			if v != math.Trunc(v) || v < 0 || v >= math.MaxUint64 {
+				return mismatch()
+			}
+			u = uint64(v)
+		default:
+			return mismatch()
+		}
+		if rvalue.OverflowUint(u) {
+			return fmt.Errorf("%v overflows %v", u, rtype)
+		}
+		rvalue.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		switch v := item.(type) {
+		case float64:
+			rvalue.SetFloat(v)
+		case int64:
+			rvalue.SetFloat(float64(v))
+		case uint64:
+			rvalue.SetFloat(float64(v))
+		default:
+			return mismatch()
+		}
+	case reflect.String:
+		switch v := item.(type) {
+		case string:
+			rvalue.SetString(v)
+		case []byte:
+			rvalue.SetString(string(v))
+		default:
+			return mismatch()
+		}
+	case reflect.Slice:
+		if rtype.Elem().Kind() == reflect.Uint8 {
+			switch v := item.(type) {
+			case []byte:
+				rvalue.SetBytes(v)
+				return nil
+			case string:
+				rvalue.SetBytes([]byte(v))
+				return nil
+			}
+		}
+		items, ok := item.([]any)
+		if !ok {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(rtype, len(items), len(items))
+		for i := range items {
+			if err := assignBinary(slice.Index(i), items[i]); err != nil {
+				return err
+			}
+		}
+		rvalue.Set(slice)
+	case reflect.Array:
+		if data, ok := item.([]byte); ok && rtype.Elem().Kind() == reflect.Uint8 {
+			rvalue.SetZero()
+			reflect.Copy(rvalue, reflect.ValueOf(data))
+			return nil
+		}
+		items, ok := item.([]any)
+		if !ok {
+			return mismatch()
+		}
+		rvalue.SetZero()
+		for i := range min(len(items), rvalue.Len()) {
+			if err := assignBinary(rvalue.Index(i), items[i]); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		pairs, ok := item.(binaryMap)
+		if !ok {
+			return mismatch()
+		}
+		if rvalue.IsNil() {
+			rvalue.Set(reflect.MakeMapWithSize(rtype, len(pairs)))
+		}
+		for _, pair := range pairs {
+			key := reflect.New(rtype.Key()).Elem()
+			if err := assignKey(key, pair.key); err != nil {
+				return err
+			}
+			value := reflect.New(rtype.Elem()).Elem()
+			if err := assignBinary(value, pair.value); err != nil {
+				return err
+			}
+			rvalue.SetMapIndex(key, value)
+		}
+	case reflect.Struct:
+		pairs, ok := item.(binaryMap)
+		if !ok {
+			return mismatch()
+		}
+		fields := binaryFieldsOf(rtype)
+		for _, pair := range pairs {
+			name, ok := pair.key.(string)
+			if !ok {
+				continue
+			}
+			i := slices.IndexFunc(fields, func(field binaryField) bool { return field.name == name })
+			if i < 0 {
+				i = slices.IndexFunc(fields, func(field binaryField) bool { return strings.EqualFold(field.name, name) })
+			}
+			if i < 0 {
+				continue
+			}
+			field, err := settableFieldOf(rvalue, fields[i].index)
+			if err != nil {
+				return err
+			}
+			if err := assignBinary(field, pair.value); err != nil {
+				return fmt.Errorf("%v.%v: %w", rtype, fields[i].name, err)
+			}
+		}
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+// assignKey assigns a map key, converting between strings and numbers
+// where necessary (as map keys may have been encoded as JSON strings).
+func assignKey(key reflect.Value, item any) error {
+	if ptr := key.Addr(); ptr.Type().Implements(textUnmarshaler) {
+		if text, ok := item.(string); ok {
+			return ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+		}
+	}
+	text, ok := item.(string)
+	if !ok {
+		return assignBinary(key, item)
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || key.OverflowInt(i) {
+			return fmt.Errorf("invalid map key %q for %v", text, key.Type())
+		}
+		key.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(text, 10, 64)
+		if err != nil || key.OverflowUint(u) {
+			return fmt.Errorf("invalid map key %q for %v", text, key.Type())
+		}
+		key.SetUint(u)
+		return nil
+	}
+	return assignBinary(key, item)
+}
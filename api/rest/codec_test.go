@@ -0,0 +1,134 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"runtime.link/api"
+	"runtime.link/api/rest"
+)
+
+type Shipment struct {
+	ID       uint64            `json:"id"`
+	Weight   float64           `json:"weight"`
+	Fragile  bool              `json:"fragile"`
+	Offset   int8              `json:"offset"`
+	Tags     []string          `json:"tags"`
+	Sizes    map[string]int    `json:"sizes"`
+	Label    []byte            `json:"label"`
+	Sent     time.Time         `json:"sent"`
+	Note     *string           `json:"note,omitempty"`
+	Previous *Shipment         `json:"previous"`
+	Extra    map[int]time.Time `json:"extra,omitempty"`
+}
+
+type contentTypeRecorder struct {
+	requests, responses []string
+}
+
+func (r *contentTypeRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.requests = append(r.requests, req.Header.Get("Content-Type"))
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		r.responses = append(r.responses, resp.Header.Get("Content-Type"))
+	}
+	return resp, err
+}
+
+func TestContentTypes(t *testing.T) {
+	type API struct {
+		api.Specification
+
+		Echo func(context.Context, Shipment) (Shipment, error) `rest:"POST /echo"`
+		Sum  func(context.Context, []int) (int, error)         `rest:"POST /sum"`
+	}
+	handler, err := rest.Handler(nil, &API{
+		Echo: func(ctx context.Context, s Shipment) (Shipment, error) { return s, nil },
+		Sum: func(ctx context.Context, values []int) (int, error) {
+			var sum int
+			for _, value := range values {
+				sum += value
+			}
+			return sum, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	note := "handle with care"
+	shipment := Shipment{
+		ID:       1 << 40,
+		Weight:   12.25,
+		Fragile:  true,
+		Offset:   -100,
+		Tags:     []string{"a", "b"},
+		Sizes:    map[string]int{"width": 300, "height": -70000},
+		Label:    []byte{0, 1, 2, 0xFF},
+		Sent:     time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Note:     &note,
+		Previous: &Shipment{ID: 1, Weight: 0.1, Tags: []string{}},
+		Extra:    map[int]time.Time{-1: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, mime := range []string{"application/cbor", "application/msgpack"} {
+		var recorder contentTypeRecorder
+		client := api.Import[API](rest.API, server.URL, rest.WithContentType(&http.Client{Transport: &recorder}, mime))
+		echo, err := client.Echo(context.Background(), shipment)
+		if err != nil {
+			t.Fatal(mime, err)
+		}
+		if !reflect.DeepEqual(echo, shipment) {
+			t.Fatalf("%s: got %+v, want %+v", mime, echo, shipment)
+		}
+		if recorder.requests[0] != mime || recorder.responses[0] != mime {
+			t.Fatalf("%s: unexpected content types %v %v", mime, recorder.requests, recorder.responses)
+		}
+	}
+
+	post := func(ctype, accept string, body []byte) (string, []byte) {
+		req, err := http.NewRequest("POST", server.URL+"/sum", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", ctype)
+		req.Header.Set("Accept", accept)
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Header.Get("Content-Type"), data
+	}
+	ctype, body := post("application/cbor", "application/cbor", []byte{0x83, 0x01, 0x02, 0x18, 0x64}) // [1, 2, 100]
+	if ctype != "application/cbor" || !bytes.Equal(body, []byte{0x18, 0x67}) {
+		t.Fatalf("unexpected cbor response %s %x", ctype, body)
+	}
+	ctype, body = post("application/msgpack", "application/msgpack", []byte{0x93, 0x01, 0x02, 0xCC, 0xC8}) // [1, 2, 200]
+	if ctype != "application/msgpack" || !bytes.Equal(body, []byte{0xCC, 0xCB}) {
+		t.Fatalf("unexpected msgpack response %s %x", ctype, body)
+	}
+
+	rest.RegisterContentType("text/csv", rest.Codec{
+		Encode: func(w http.ResponseWriter, v any) error {
+			_, err := fmt.Fprintf(w, "sum\n%v\n", v)
+			return err
+		},
+	})
+	ctype, body = post("application/json", "application/json;q=0.5, text/csv", []byte(`[1, 2, 3]`))
+	if ctype != "text/csv" || string(body) != "sum\n6\n" {
+		t.Fatalf("unexpected csv response %s %q", ctype, body)
+	}
+}
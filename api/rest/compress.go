@@ -0,0 +1,340 @@
+package rest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentEncoding compresses and decompresses a Content-Encoding. If the
+// io.WriteCloser returned by Compress has a 'Flush() error' method, it is
+// called whenever the response is flushed.
+type ContentEncoding struct {
+	Compress   func(w io.Writer) io.WriteCloser
+	Decompress func(r io.Reader) (io.ReadCloser, error)
+}
+
+// RegisterContentEncoding registers the content encoding with the given name,
+// such that it can be used to compress responses that Accept-Encoding it and
+// to decompress request bodies (and, for clients, responses) with a matching
+// Content-Encoding. Any existing encoding with the name is replaced, otherwise
+// the encoding is preferred after those that have already been registered.
+// The package registers 'gzip' and 'deflate' by default, other encodings such
+// as 'br' or 'zstd' can be registered from a third party implementation.
+func RegisterContentEncoding(name string, encoding ContentEncoding) {
+	contentEncodingsMutex.Lock()
+	defer contentEncodingsMutex.Unlock()
+	name = strings.ToLower(name)
+	for i := range contentEncodings {
+		if contentEncodings[i].name == name {
+			contentEncodings[i].encoding = encoding
+			return
+		}
+	}
+	contentEncodings = append(contentEncodings, namedEncoding{name, encoding})
+}
+
+type namedEncoding struct {
+	name     string
+	encoding ContentEncoding
+}
+
+var contentEncodingsMutex sync.RWMutex
+
+// contentEncodings in order of preference.
+var contentEncodings = []namedEncoding{
+	{"gzip", ContentEncoding{
+		Compress: func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		Decompress: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	}},
+	{"deflate", ContentEncoding{ // as HTTP defines it, ie. zlib.
+		Compress: func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		Decompress: func(r io.Reader) (io.ReadCloser, error) {
+			return zlib.NewReader(r)
+		},
+	}},
+}
+
+// encodingFor returns the registered content encoding with the given name.
+func encodingFor(name string) (ContentEncoding, bool) {
+	contentEncodingsMutex.RLock()
+	defer contentEncodingsMutex.RUnlock()
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, named := range contentEncodings {
+		if named.name == name {
+			return named.encoding, true
+		}
+	}
+	return ContentEncoding{}, false
+}
+
+// acceptedEncodings returns an Accept-Encoding header for the registered
+// content encodings.
+func acceptedEncodings() string {
+	contentEncodingsMutex.RLock()
+	defer contentEncodingsMutex.RUnlock()
+	var names = make([]string, len(contentEncodings))
+	for i, named := range contentEncodings {
+		names[i] = named.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// negotiateEncoding returns the registered content encoding with the highest
+// quality in the Accept-Encoding header (ties are broken by the preference of
+// the server). False is returned if the response should not be encoded.
+func negotiateEncoding(accept string) (string, ContentEncoding, bool) {
+	var (
+		qualities = make(map[string]float64)
+		wildcard  = -1.0
+	)
+	for part := range strings.SplitSeq(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && key == "q" {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					continue
+				}
+				quality = parsed
+			}
+		}
+		if name == "*" {
+			wildcard = quality
+			continue
+		}
+		qualities[name] = quality
+	}
+	contentEncodingsMutex.RLock()
+	defer contentEncodingsMutex.RUnlock()
+	var (
+		best     namedEncoding
+		bestness float64
+	)
+	for _, named := range contentEncodings {
+		quality, ok := qualities[named.name]
+		if !ok {
+			quality = wildcard
+		}
+		if quality > bestness {
+			best, bestness = named, quality
+		}
+	}
+	return best.name, best.encoding, bestness > 0
+}
+
+// minCompressSize is the size below which responses are not worth compressing.
+const minCompressSize = 1024
+
+// compressible reports whether a response of the given content type is worth
+// compressing, ie. it is not already compressed, nor an event stream (which
+// should reach the client as soon as each event is flushed).
+func compressible(ctype string) bool {
+	media, _, _ := mime.ParseMediaType(ctype)
+	switch {
+	case media == "image/svg+xml":
+		return true
+	case strings.HasPrefix(media, "image/"), strings.HasPrefix(media, "audio/"), strings.HasPrefix(media, "video/"),
+		strings.HasPrefix(media, "font/woff"):
+		return false
+	}
+	switch media {
+	case "text/event-stream", "application/zip", "application/gzip", "application/zstd",
+		"application/x-7z-compressed", "application/x-bzip2", "application/x-xz", "application/x-rar-compressed":
+		return false
+	}
+	return true
+}
+
+// compressWriter compresses a response with the negotiated content encoding.
+// The first [minCompressSize] bytes are buffered, so that smaller responses can
+// be written uncompressed, after which the response is streamed through the
+// compressor.
+type compressWriter struct {
+	http.ResponseWriter
+
+	name     string
+	encoding ContentEncoding
+
+	status  int
+	buffer  []byte
+	started bool           // the header has been written.
+	writer  io.WriteCloser // non-nil when the response is being compressed.
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.started {
+		return
+	}
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.ResponseWriter.WriteHeader(status) // passed through, for websockets.
+		if status >= 200 {
+			cw.started = true
+		}
+		return
+	}
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	cw.WriteHeader(http.StatusOK)
+	if cw.started {
+		if cw.writer != nil {
+			return cw.writer.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buffer = append(cw.buffer, b...)
+	if len(cw.buffer) >= minCompressSize {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// start writes the header, deciding whether or not to compress the response,
+// followed by anything that has been buffered.
+func (cw *compressWriter) start(compress bool) error {
+	cw.started = true
+	header := cw.Header()
+	if compress && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.name)
+		header.Add("Vary", "Accept-Encoding")
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag) // the encoded bytes differ from the representation.
+		}
+		cw.writer = cw.encoding.Compress(cw.ResponseWriter)
+	} else if header.Get("Content-Length") == "" && !compress {
+		header.Set("Content-Length", strconv.Itoa(len(cw.buffer)))
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buffered := cw.buffer
+	cw.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	var err error
+	if cw.writer != nil {
+		_, err = cw.writer.Write(buffered)
+	} else {
+		_, err = cw.ResponseWriter.Write(buffered)
+	}
+	return err
+}
+
+// Flush starts (and compresses) the response, if it has not already been
+// started, such that the client receives everything written so far.
+func (cw *compressWriter) Flush() {
+	if !cw.started {
+		cw.WriteHeader(http.StatusOK)
+		cw.start(true)
+	}
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("rest: response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// close writes any buffered response uncompressed, or else finishes the
+// compressed response.
+func (cw *compressWriter) close() {
+	if !cw.started {
+		if cw.status == 0 {
+			return
+		}
+		cw.start(false)
+	}
+	if cw.writer != nil {
+		cw.writer.Close()
+	}
+}
+
+// decompressTransport asks for any of the registered content encodings and
+// decompresses the response bodies that use them.
+type decompressTransport struct {
+	base http.RoundTripper
+}
+
+func (t decompressTransport) unwrap() http.RoundTripper { return t.base }
+
+func (t decompressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var base = t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return base.RoundTrip(req) // the caller wants the encoded bytes.
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", acceptedEncodings())
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	name := resp.Header.Get("Content-Encoding")
+	if name == "" || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, nil
+	}
+	encoding, ok := encodingFor(name)
+	if !ok {
+		return resp, nil
+	}
+	body, err := encoding.Decompress(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = decompressedBody{body, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decompressedBody closes both the decompressor and the underlying body.
+type decompressedBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (d decompressedBody) Close() error {
+	return errors.Join(d.ReadCloser.Close(), d.body.Close())
+}
+
+// withDecompression returns a copy of the client that transparently
+// decompresses responses.
+func withDecompression(client *http.Client) *http.Client {
+	if _, ok := transportOf[decompressTransport](client); ok {
+		return client
+	}
+	var copied = *client
+	copied.Transport = decompressTransport{base: client.Transport}
+	return &copied
+}
@@ -0,0 +1,164 @@
+package rest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"runtime.link/api"
+	"runtime.link/api/rest"
+)
+
+type Reading struct {
+	Sensor string  `json:"sensor"`
+	Value  float64 `json:"value"`
+}
+
+type encodingRecorder struct {
+	encodings []string
+}
+
+func (r *encodingRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		r.encodings = append(r.encodings, resp.Header.Get("Content-Encoding"))
+	}
+	return resp, err
+}
+
+func TestCompression(t *testing.T) {
+	type API struct {
+		api.Specification
+
+		Readings func(context.Context, int) ([]Reading, error) `rest:"GET /readings?n=%v"`
+		Status   func(context.Context) (string, error)         `rest:"GET /status"`
+		Upload   func(context.Context, io.Reader) (int, error) `rest:"POST /upload"`
+	}
+	readings := func(n int) []Reading {
+		var readings = make([]Reading, n)
+		for i := range readings {
+			readings[i] = Reading{Sensor: fmt.Sprintf("sensor-%d", i%10), Value: float64(i) / 4}
+		}
+		return readings
+	}
+	handler, err := rest.Handler(nil, &API{
+		Readings: func(ctx context.Context, n int) ([]Reading, error) { return readings(n), nil },
+		Status:   func(ctx context.Context) (string, error) { return "ok", nil },
+		Upload: func(ctx context.Context, body io.Reader) (int, error) {
+			n, err := io.Copy(io.Discard, body)
+			return int(n), err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	raw := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	get := func(path string, header http.Header) (*http.Response, []byte) {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = header
+		resp, err := raw.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, body
+	}
+	expected, err := json.MarshalIndent(readings(5000), "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := get("/readings?n=5000", http.Header{"Accept-Encoding": {"deflate;q=0.5, gzip"}})
+	if resp.Header.Get("Content-Encoding") != "gzip" || !strings.HasPrefix(resp.Header.Get("ETag"), "W/") {
+		t.Fatalf("expected a gzipped response with a weak ETag, got %v", resp.Header)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decompressed, err := io.ReadAll(reader); err != nil || !bytes.Equal(decompressed, expected) {
+		t.Fatalf("unexpected gzipped body (%v)", err)
+	}
+	etag := resp.Header.Get("ETag")
+	if resp, _ := get("/readings?n=5000", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {etag}}); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304, got %v", resp.Status)
+	}
+	resp, body = get("/readings?n=5000", http.Header{"Accept-Encoding": {"deflate"}})
+	if resp.Header.Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expected a deflated response, got %v", resp.Header)
+	}
+	if reader, err := zlib.NewReader(bytes.NewReader(body)); err != nil {
+		t.Fatal(err)
+	} else if decompressed, err := io.ReadAll(reader); err != nil || !bytes.Equal(decompressed, expected) {
+		t.Fatalf("unexpected deflated body (%v)", err)
+	}
+	if resp, body := get("/readings?n=5000", nil); resp.Header.Get("Content-Encoding") != "" || !bytes.Equal(body, expected) {
+		t.Fatalf("expected an identity response, got %v", resp.Header)
+	}
+	if resp, body := get("/status", http.Header{"Accept-Encoding": {"gzip"}}); resp.Header.Get("Content-Encoding") != "" || string(body) != "ok" {
+		t.Fatalf("expected small responses to be uncompressed, got %v %q", resp.Header, body)
+	}
+	if resp, _ := get("/readings?n=50000", http.Header{"Accept-Encoding": {"gzip"}}); resp.Header.Get("ETag") != "" || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected large responses to be streamed, got %v", resp.Header)
+	}
+
+	var recorder encodingRecorder
+	client := api.Import[API](rest.API, server.URL, &http.Client{Transport: &recorder})
+	result, err := client.Readings(context.Background(), 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 5000 || result[4999] != (Reading{Sensor: "sensor-9", Value: 1249.75}) {
+		t.Fatalf("unexpected readings %v", len(result))
+	}
+	if recorder.encodings[0] != "gzip" {
+		t.Fatalf("expected the client to accept gzip, got %v", recorder.encodings)
+	}
+	n, err := client.Upload(context.Background(), io.LimitReader(zeros{}, 10<<20))
+	if err != nil || n != 10<<20 {
+		t.Fatal(n, err)
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte("hello world"))
+	writer.Close()
+	req, err := http.NewRequest("POST", server.URL+"/upload", &compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err = raw.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if strings.TrimSpace(string(body)) != "11" {
+		t.Fatalf("expected the request body to be decompressed, got %v %q", resp.Status, body)
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
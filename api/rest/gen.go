@@ -0,0 +1,592 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"runtime.link/api/internal/oas"
+	"runtime.link/api/xray"
+)
+
+// Generate writes the Go source for a package named pkg to w, containing an
+// API specification structure (named API) with rest tags for each operation
+// of the given OpenAPI 3.x document (either JSON or YAML). Component schemas
+// become named types (string enumerations become [xyz.Switch] types) and the
+// 4xx/5xx responses become the scenarios of an [api.Error] named Error.
+// Header and cookie parameters are not represented in the structure, nor are
+// responses for a range of status codes (ie. 4XX) or default responses, as
+// scenarios need an exact status code, these are listed in a comment at the
+// end of the structure instead.
+func Generate(w io.Writer, pkg string, document []byte) error {
+	var doc oas.Document
+	if err := oas.Decode(document, &doc); err != nil {
+		return xray.New(err)
+	}
+	var gen = generator{
+		doc:     &doc,
+		taken:   map[string]bool{"API": true, "Error": true, "Errors": true},
+		refs:    make(map[oas.URI]string),
+		imports: make(map[string]bool),
+		errors:  make(map[int]string),
+	}
+	if doc.Components != nil {
+		names := sortedKeys(doc.Components.Schemas)
+		for _, name := range names {
+			gen.refs[oas.URI("#/components/schemas/"+name)] = gen.unique(exported(name))
+		}
+		for _, name := range names {
+			gen.declare(gen.refs[oas.URI("#/components/schemas/"+name)], doc.Components.Schemas[name])
+		}
+	}
+	var functions []string
+	var fnames = make(map[string]bool)
+	for _, path := range sortedKeys(doc.Paths) {
+		item := doc.Paths[path]
+		for _, op := range []struct {
+			method    string
+			operation *oas.Operation
+		}{
+			{"GET", item.Get}, {"PUT", item.Put}, {"POST", item.Post}, {"DELETE", item.Delete},
+			{"OPTIONS", item.Options}, {"HEAD", item.Head}, {"PATCH", item.Patch}, {"TRACE", item.Trace},
+		} {
+			if op.operation == nil {
+				continue
+			}
+			name := exported(string(op.operation.ID))
+			if op.operation.ID == "" {
+				name = exported(strings.ToLower(op.method) + " " + path)
+			}
+			for fnames[name] {
+				name += "_"
+			}
+			fnames[name] = true
+			functions = append(functions, gen.operation(name, op.method, path, item, op.operation))
+		}
+	}
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by runtime.link/api/rest from an OpenAPI document. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	gen.imports["runtime.link/api"] = true
+	if len(functions) > 0 {
+		gen.imports["context"] = true
+	}
+	if len(gen.errors) > 0 {
+		gen.imports["runtime.link/xyz"] = true
+	}
+	src.WriteString("import (\n")
+	var paths = sortedKeys(gen.imports)
+	for _, std := range []bool{true, false} {
+		for _, path := range paths {
+			if std == !strings.Contains(path, ".") {
+				fmt.Fprintf(&src, "\t%q\n", path)
+			}
+		}
+		if std {
+			src.WriteString("\n")
+		}
+	}
+	src.WriteString(")\n\n")
+	var tags = fmt.Sprintf("api:%q", string(doc.Information.Title))
+	if len(doc.Servers) > 0 && strings.Contains(string(doc.Servers[0].URL), "://") {
+		tags += fmt.Sprintf(" www:%q", string(doc.Servers[0].URL))
+	}
+	fmt.Fprintf(&src, "// API specification for %s.\ntype API struct {\n", strings.TrimSpace(string(doc.Information.Title)))
+	fmt.Fprintf(&src, "\tapi.Specification `%s%s`\n", tags, documentation(string(doc.Information.Description)))
+	if len(gen.errors) > 0 {
+		src.WriteString("\n\tError api.Register[error, Error]\n")
+	}
+	if len(functions) > 0 {
+		src.WriteString("\n")
+	}
+	for _, fn := range functions {
+		src.WriteString(fn)
+	}
+	if len(gen.skipped) > 0 {
+		src.WriteString("\n\t// The following responses could not be represented:\n")
+		for _, reason := range gen.skipped {
+			fmt.Fprintf(&src, "\t//  - %s\n", reason)
+		}
+	}
+	src.WriteString("}\n")
+	if len(gen.errors) > 0 {
+		src.WriteString("\n// Error scenarios returned by the API.\ntype Error api.Error[struct {\n")
+		for _, code := range sortedKeys(gen.errors) {
+			name := exported(http.StatusText(code))
+			if name == "" {
+				name = "Status" + strconv.Itoa(code)
+			}
+			fmt.Fprintf(&src, "\t%s xyz.Case[Error, error] `http:\"%d\"%s`\n", name, code, documentation(gen.errors[code]))
+		}
+		src.WriteString("}]\n\nvar Errors = xyz.AccessorFor(Error.Values)\n")
+	}
+	for _, decl := range gen.decls {
+		src.WriteString("\n")
+		src.WriteString(decl)
+	}
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return xray.New(fmt.Errorf("rest: generated invalid Go source: %w", err))
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// generator of Go source for an OpenAPI document.
+type generator struct {
+	doc     *oas.Document
+	taken   map[string]bool    // package-level identifiers.
+	refs    map[oas.URI]string // component schemas to type names.
+	imports map[string]bool    // import paths.
+	errors  map[int]string     // error status codes to descriptions.
+	skipped []string           // responses that could not be represented.
+	decls   []string           // type declarations.
+}
+
+// unique returns a package-level identifier based on name.
+func (gen *generator) unique(name string) string {
+	for gen.taken[name] {
+		name += "_"
+	}
+	gen.taken[name] = true
+	return name
+}
+
+// operation returns the API structure field for the operation.
+func (gen *generator) operation(name, method, path string, item oas.PathItem, op *oas.Operation) string {
+	var (
+		params = make(map[string]*oas.Parameter)
+		order  []string
+	)
+	for _, param := range append(slices.Clone(item.Parameters), op.Parameters...) {
+		param = gen.parameter(param)
+		if param == nil {
+			continue
+		}
+		key := param.In.String() + ":" + string(param.Name)
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = param
+	}
+	var (
+		args   = []string{"ctx context.Context"}
+		names  = map[string]bool{"ctx": true}
+		query  []string
+		result string
+	)
+	argument := func(name string, schema *oas.Schema, hint string) {
+		arg := unexported(name)
+		for names[arg] {
+			arg += "_"
+		}
+		names[arg] = true
+		args = append(args, arg+" "+gen.typeOf(schema, hint))
+	}
+	// path parameters are ordered by their position in the path.
+	var pattern strings.Builder
+	for rest := path; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		end := strings.IndexByte(rest, '}')
+		if open < 0 || end < open {
+			pattern.WriteString(rest)
+			break
+		}
+		pname := rest[open+1 : end]
+		pattern.WriteString(rest[:open] + "{" + pname + "=%v}")
+		rest = rest[end+1:]
+		var schema *oas.Schema
+		if param, ok := params["path:"+pname]; ok {
+			schema = param.Schema
+		}
+		argument(pname, schema, name+exported(pname))
+	}
+	for _, key := range order {
+		param := params[key]
+		if param.In.String() != "query" {
+			continue
+		}
+		query = append(query, string(param.Name)+"=%v")
+		argument(string(param.Name), param.Schema, name+exported(string(param.Name)))
+	}
+	if body := gen.requestBody(op.RequestBody); body != nil {
+		ctype := preferredContentType(body.Content)
+		if ctype != "" {
+			if !strings.Contains(string(ctype), "json") {
+				method += "(" + string(ctype) + ")"
+			}
+			argument("body", body.Content[ctype].Schema, name+"Request")
+		}
+	}
+	for _, key := range sortedKeys(op.Responses) {
+		response := gen.response(op.Responses[key])
+		status := key.String()
+		if response == nil {
+			continue
+		}
+		if strings.HasPrefix(status, "2") {
+			if ctype := preferredContentType(response.Content); result == "" && ctype != "" {
+				result = gen.typeOf(response.Content[ctype].Schema, name+"Response")
+			}
+			continue
+		}
+		if code, err := strconv.Atoi(status); err == nil && code >= 400 {
+			if _, ok := gen.errors[code]; !ok {
+				gen.errors[code] = strings.TrimSpace(string(response.Description))
+			}
+			continue
+		}
+		reason := name + ": " + status + " response"
+		if description, _, _ := strings.Cut(strings.TrimSpace(string(response.Description)), "\n"); description != "" {
+			reason += " (" + description + ")"
+		}
+		gen.skipped = append(gen.skipped, reason)
+	}
+	var results = "error"
+	if result != "" {
+		results = "(" + result + ", error)"
+	}
+	var tag = method + " " + pattern.String()
+	if len(query) > 0 {
+		tag += "?" + strings.Join(query, "&")
+	}
+	var tags = fmt.Sprintf("rest:%q", tag)
+	if op.Deprecated {
+		tags += ` deprecated:"true"`
+	}
+	var docs = strings.TrimSpace(string(op.Summary))
+	if description := strings.TrimSpace(string(op.Description)); description != "" && description != docs {
+		if docs != "" {
+			docs += "\n\n"
+		}
+		docs += description
+	}
+	return fmt.Sprintf("\t%s func(%s) %s `%s%s`\n", name, strings.Join(args, ", "), results, tags, documentation(docs))
+}
+
+// parameter resolves any reference to a component parameter.
+func (gen *generator) parameter(param *oas.Parameter) *oas.Parameter {
+	if param == nil || param.Ref == "" {
+		return param
+	}
+	if gen.doc.Components == nil {
+		return nil
+	}
+	return gen.parameter(gen.doc.Components.Parameters[strings.TrimPrefix(string(param.Ref), "#/components/parameters/")])
+}
+
+// requestBody resolves any reference to a component request body.
+func (gen *generator) requestBody(body *oas.RequestBody) *oas.RequestBody {
+	if body == nil || body.Ref == "" {
+		return body
+	}
+	if gen.doc.Components == nil {
+		return nil
+	}
+	return gen.requestBody(gen.doc.Components.RequestBodies[strings.TrimPrefix(string(body.Ref), "#/components/requestBodies/")])
+}
+
+// response resolves any reference to a component response.
+func (gen *generator) response(response *oas.Response) *oas.Response {
+	if response == nil || response.Ref == "" {
+		return response
+	}
+	if gen.doc.Components == nil {
+		return nil
+	}
+	return gen.response(gen.doc.Components.Responses[strings.TrimPrefix(string(response.Ref), "#/components/responses/")])
+}
+
+// typeOf returns the Go type for the schema, hint is used to name any
+// types that need to be declared for it.
+func (gen *generator) typeOf(schema *oas.Schema, hint string) string {
+	if schema == nil {
+		gen.imports["encoding/json"] = true
+		return "json.RawMessage"
+	}
+	if schema.Ref != "" {
+		if name, ok := gen.refs[schema.Ref]; ok {
+			return name
+		}
+		gen.imports["encoding/json"] = true
+		return "json.RawMessage"
+	}
+	if len(schema.AllOf) == 1 && len(schema.Properties) == 0 {
+		return gen.typeOf(schema.AllOf[0], hint)
+	}
+	switch typeName(schema) {
+	case "string":
+		switch {
+		case schema.Format != nil && schema.Format.String() == "date-time":
+			gen.imports["time"] = true
+			return "time.Time"
+		case schema.Format != nil && (schema.Format.String() == "byte" || schema.Format.String() == "binary"):
+			return "[]byte"
+		case len(schema.Enum) > 0:
+			name := gen.unique(hint)
+			gen.declare(name, schema)
+			return name
+		}
+		return "string"
+	case "integer":
+		if schema.Format != nil && schema.Format.String() == "int32" {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		if schema.Format != nil && schema.Format.String() == "float" {
+			return "float32"
+		}
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + gen.typeOf(schema.Items, hint+"Item")
+	case "object":
+		if len(schema.Properties) == 0 && len(schema.AllOf) == 0 {
+			if schema.AdditionalProperties != nil {
+				return "map[string]" + gen.typeOf(schema.AdditionalProperties, hint+"Value")
+			}
+			return "map[string]any"
+		}
+		name := gen.unique(hint)
+		gen.declare(name, schema)
+		return name
+	}
+	gen.imports["encoding/json"] = true
+	return "json.RawMessage"
+}
+
+// declare a named type for the schema.
+func (gen *generator) declare(name string, schema *oas.Schema) {
+	idx := len(gen.decls)
+	gen.decls = append(gen.decls, "")
+	var b strings.Builder
+	writeComment(&b, name, string(schema.Description))
+	switch kind := typeName(schema); {
+	case schema.Ref == "" && kind == "string" && len(schema.Enum) > 0:
+		gen.imports["runtime.link/xyz"] = true
+		fmt.Fprintf(&b, "type %s xyz.Switch[string, struct {\n", name)
+		var cases = make(map[string]bool)
+		for _, raw := range schema.Enum {
+			value, err := strconv.Unquote(string(raw))
+			if err != nil {
+				continue
+			}
+			cname := exported(value)
+			if cname == "" {
+				cname = "Empty"
+			}
+			for cases[cname] {
+				cname += "_"
+			}
+			cases[cname] = true
+			fmt.Fprintf(&b, "\t%s %s `json:%q`\n", cname, name, value)
+		}
+		fmt.Fprintf(&b, "}]\n\nvar %sValues = xyz.AccessorFor(%s.Values)\n", name, name)
+	case schema.Ref == "" && (kind == "object" && (len(schema.Properties) > 0 || len(schema.AllOf) > 0) || kind == "" && len(schema.AllOf) > 1):
+		fmt.Fprintf(&b, "type %s struct {\n", name)
+		var fields = make(map[string]bool)
+		var properties = []*oas.Schema{schema}
+		for _, embed := range schema.AllOf {
+			if embed.Ref != "" {
+				if ename, ok := gen.refs[embed.Ref]; ok {
+					fmt.Fprintf(&b, "\t%s\n", ename)
+					fields[ename] = true
+					continue
+				}
+			}
+			properties = append(properties, embed)
+		}
+		for _, props := range properties {
+			for _, pname := range sortedKeys(props.Properties) {
+				prop := props.Properties[pname]
+				fname := exported(string(pname))
+				if fname == "" {
+					continue
+				}
+				for fields[fname] {
+					fname += "_"
+				}
+				fields[fname] = true
+				tag := string(pname)
+				if !slices.Contains(schema.Required, pname) && !slices.Contains(props.Required, pname) {
+					tag += ",omitempty"
+				}
+				var docs string
+				if prop != nil {
+					docs = string(prop.Description)
+				}
+				fmt.Fprintf(&b, "\t%s %s `json:%q%s`\n", fname, gen.typeOf(prop, name+fname), tag, documentation(docs))
+			}
+		}
+		b.WriteString("}\n")
+	default:
+		fmt.Fprintf(&b, "type %s %s\n", name, gen.typeOf(withoutDescription(schema), name+"Value"))
+	}
+	gen.decls[idx] = b.String()
+}
+
+// withoutDescription returns a copy of the schema without its description,
+// (so that it isn't written twice).
+func withoutDescription(schema *oas.Schema) *oas.Schema {
+	var copied = *schema
+	copied.Description = ""
+	return &copied
+}
+
+// typeName returns the (non-null) JSON schema type of the schema.
+func typeName(schema *oas.Schema) string {
+	for _, t := range schema.Type {
+		if s := t.String(); s != "null" {
+			return s
+		}
+	}
+	if len(schema.Properties) > 0 {
+		return "object"
+	}
+	return ""
+}
+
+// preferredContentType returns the JSON content type (if any), or else
+// the first content type (in lexical order).
+func preferredContentType[T any](content map[oas.ContentType]T) oas.ContentType {
+	ctypes := sortedKeys(content)
+	for _, ctype := range ctypes {
+		if strings.Contains(string(ctype), "json") {
+			return ctype
+		}
+	}
+	if len(ctypes) > 0 {
+		return ctypes[0]
+	}
+	return ""
+}
+
+func sortedKeys[K interface {
+	~string | ~int | oas.ResponseKey
+}, V any](m map[K]V) []K {
+	var keys = make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b K) int { return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)) })
+	return keys
+}
+
+// documentation returns the docs in the format of the trailing lines of
+// a runtime.link struct tag.
+func documentation(docs string) string {
+	docs = strings.TrimSpace(strings.ReplaceAll(docs, "`", "'"))
+	if docs == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(docs, "\n") {
+		b.WriteString("\n\t\t" + strings.TrimRightFunc(line, unicode.IsSpace))
+	}
+	return b.String()
+}
+
+// writeComment writes the description as a Go comment for the named type.
+func writeComment(b *strings.Builder, name, description string) {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return
+	}
+	for _, line := range strings.Split(name+" "+description, "\n") {
+		b.WriteString(strings.TrimRightFunc("// "+line, unicode.IsSpace) + "\n")
+	}
+}
+
+// initialisms are capitalised within identifiers.
+var initialisms = map[string]string{
+	"Api": "API", "Http": "HTTP", "Id": "ID", "Ids": "IDs", "Json": "JSON",
+	"Uri": "URI", "Url": "URL", "Urls": "URLs", "Uuid": "UUID",
+}
+
+// exported converts the name into an exported Go identifier, ie.
+// get_pet-byId becomes GetPetByID.
+func exported(name string) string {
+	var b strings.Builder
+	for _, word := range words(name) {
+		word = strings.ToUpper(word[:1]) + word[1:]
+		if initialism, ok := initialisms[word]; ok {
+			word = initialism
+		}
+		b.WriteString(word)
+	}
+	s := b.String()
+	if s != "" && !unicode.IsLetter(rune(s[0])) {
+		s = "X" + s
+	}
+	return s
+}
+
+// unexported converts the name into an unexported Go identifier.
+func unexported(name string) string {
+	var b strings.Builder
+	for i, word := range words(name) {
+		if i == 0 {
+			word = strings.ToLower(word)
+		} else if initialism, ok := initialisms[strings.ToUpper(word[:1])+word[1:]]; ok {
+			word = initialism
+		} else {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		b.WriteString(word)
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter(rune(s[0])) {
+		s = "v" + s
+	}
+	if token.IsKeyword(s) {
+		s += "_"
+	}
+	return s
+}
+
+// words splits the name on any non-alphanumeric characters and at the
+// start of each capitalised word.
+func words(name string) []string {
+	var words []string
+	var runes []rune
+	flush := func() {
+		if len(runes) > 0 {
+			words = append(words, string(runes))
+			runes = runes[:0]
+		}
+	}
+	for i, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(runes) > 0 {
+			prev := runes[len(runes)-1]
+			next, _ := nextRune(name, i)
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && unicode.IsLower(next)) {
+				flush()
+			}
+		}
+		runes = append(runes, r)
+	}
+	flush()
+	return words
+}
+
+func nextRune(s string, i int) (rune, bool) {
+	for j, r := range s[i:] {
+		if j > 0 {
+			return r, true
+		}
+	}
+	return 0, false
+}
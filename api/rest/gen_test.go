@@ -0,0 +1,150 @@
+package rest_test
+
+import (
+	"bytes"
+	"testing"
+
+	"runtime.link/api/rest"
+)
+
+func TestGenerate(t *testing.T) {
+	const document = `
+openapi: 3.0.3
+info:
+  title: Petstore
+  description: |
+    is an example petstore API.
+  version: 1.0
+servers:
+  - url: https://petstore.example.com/v1 # production
+paths:
+  /pet/{petId}:
+    parameters:
+      - $ref: '#/components/parameters/PetID'
+    get:
+      operationId: getPetById
+      summary: returns a single pet.
+      responses:
+        '200':
+          description: successful operation
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+        "404":
+          description: Pet not found
+        4XX:
+          description: Invalid request
+        default:
+          description: Unexpected error
+  /pet/findByStatus:
+    get:
+      operationId: findPetsByStatus
+      description: >
+        multiple status values can be
+        provided with comma separated strings.
+      deprecated: true
+      parameters:
+        - name: status
+          in: query
+          schema: {type: array, items: {$ref: "#/components/schemas/Status"}}
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/Pet'
+components:
+  parameters:
+    PetID:
+      name: petId
+      in: path
+      required: true
+      schema:
+        type: integer
+        format: int64
+  schemas:
+    Status:
+      type: string
+      description: of a pet in the store.
+      enum: [available, pending, sold]
+    Pet:
+      type: object
+      required:
+        - name
+      properties:
+        id:
+          type: integer
+          format: int64
+        name:
+          type: string
+          description: of the pet.
+        photoUrls:
+          type: array
+          items:
+            type: string
+        status:
+          $ref: '#/components/schemas/Status'
+`
+	const expected = "// Code generated by runtime.link/api/rest from an OpenAPI document. DO NOT EDIT.\n" + `
+package petstore
+
+import (
+	"context"
+
+	"runtime.link/api"
+	"runtime.link/xyz"
+)
+
+// API specification for Petstore.
+type API struct {
+	api.Specification ` + "`" + `api:"Petstore" www:"https://petstore.example.com/v1"
+		is an example petstore API.` + "`" + `
+
+	Error api.Register[error, Error]
+
+	FindPetsByStatus func(ctx context.Context, status []Status) ([]Pet, error) ` + "`" + `rest:"GET /pet/findByStatus?status=%v" deprecated:"true"
+		multiple status values can be provided with comma separated strings.` + "`" + `
+	GetPetByID func(ctx context.Context, petID int64) (Pet, error) ` + "`" + `rest:"GET /pet/{petId=%v}"
+		returns a single pet.` + "`" + `
+
+	// The following responses could not be represented:
+	//  - GetPetByID: 4XX response (Invalid request)
+	//  - GetPetByID: default response (Unexpected error)
+}
+
+// Error scenarios returned by the API.
+type Error api.Error[struct {
+	NotFound xyz.Case[Error, error] ` + "`" + `http:"404"
+		Pet not found` + "`" + `
+}]
+
+var Errors = xyz.AccessorFor(Error.Values)
+
+type Pet struct {
+	ID   int64  ` + "`" + `json:"id,omitempty"` + "`" + `
+	Name string ` + "`" + `json:"name"
+		of the pet.` + "`" + `
+	PhotoURLs []string ` + "`" + `json:"photoUrls,omitempty"` + "`" + `
+	Status    Status   ` + "`" + `json:"status,omitempty"` + "`" + `
+}
+
+// Status of a pet in the store.
+type Status xyz.Switch[string, struct {
+	Available Status ` + "`" + `json:"available"` + "`" + `
+	Pending   Status ` + "`" + `json:"pending"` + "`" + `
+	Sold      Status ` + "`" + `json:"sold"` + "`" + `
+}]
+
+var StatusValues = xyz.AccessorFor(Status.Values)
+`
+	var buf bytes.Buffer
+	if err := rest.Generate(&buf, "petstore", []byte(document)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("unexpected source:\n%s", buf.String())
+	}
+}
@@ -16,7 +16,6 @@ import (
 	"path"
 	"reflect"
 	"slices"
-	"sort"
 	"strconv"
 	"strings"
 
@@ -144,6 +143,26 @@ func (cs channelSource) Iterate(ctx context.Context, fn func(item any) bool) err
 }
 
 func (is iteratorSource) Iterate(ctx context.Context, fn func(item any) bool) error {
+	if is.isSeq2 && is.iterator.Type().In(0).In(1) == errType {
+		var failure error
+		yieldFunc := reflect.MakeFunc(
+			is.iterator.Type().In(0),
+			func(args []reflect.Value) []reflect.Value {
+				if err, _ := args[1].Interface().(error); err != nil {
+					failure = err
+					return []reflect.Value{reflect.ValueOf(false)}
+				}
+				select {
+				case <-ctx.Done():
+					return []reflect.Value{reflect.ValueOf(false)}
+				default:
+					return []reflect.Value{reflect.ValueOf(fn(args[0].Interface()))}
+				}
+			},
+		)
+		is.iterator.Call([]reflect.Value{yieldFunc})
+		return failure
+	}
 	if is.isSeq2 {
 		if is.streamMode {
 			yieldFunc := reflect.MakeFunc(
@@ -226,7 +245,7 @@ func (jsw *jsonStreamWriter) Finalize(ctx context.Context, items []any) error {
 		jsw.w.WriteHeader(http.StatusNoContent)
 		return nil
 	}
-	encoder := contentTypes["application/json"]
+	encoder, _ := codecFor("application/json")
 	return encoder.Encode(jsw.w, jsw.items)
 }
 
@@ -311,6 +330,19 @@ func handleStreamingResult(ctx context.Context, r *http.Request, w http.Response
 		writer = &sseStreamWriter{w: w}
 	} else if strings.Contains(accept, "application/json") {
 		writer = &jsonStreamWriter{w: w}
+		if _, ok := source.(iteratorSource); ok {
+			page, err := paginationOf(fn)
+			if err != nil {
+				handle(ctx, fn, auth, w, err)
+				return
+			}
+			if page.style != "" {
+				if source, err = page.paginate(r, w, source); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		}
 	} else {
 		if result.Kind() == reflect.Chan {
 			websocketServeHTTP(ctx, r, w, result, reflect.Value{})
@@ -641,6 +673,8 @@ func attach(auth api.Auth[*http.Request], yield func(string, http.Handler) bool,
 
 				responseNeedsMapping  = len(resultRules) > 0
 				argumentsNeedsMapping = len(rtags.ArgumentRulesOf(string(fn.Tags.Get("rest")))) > 0
+
+				cacheControl = cacheControlOf(spec, fn)
 			)
 			if method == "GET" {
 				if !yield("OPTIONS "+path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -668,6 +702,24 @@ func attach(auth api.Auth[*http.Request], yield func(string, http.Handler) bool,
 						r.Body.Close()
 					}
 				}()
+				if name := r.Header.Get("Content-Encoding"); name != "" && r.Body != nil {
+					encoding, ok := encodingFor(name)
+					if !ok {
+						http.Error(w, "unsupported content encoding: "+name, http.StatusUnsupportedMediaType)
+						return
+					}
+					body, err := encoding.Decompress(r.Body)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+					r.Body = decompressedBody{body, r.Body}
+				}
+				if name, encoding, ok := negotiateEncoding(r.Header.Get("Accept-Encoding")); ok {
+					cw := &compressWriter{ResponseWriter: w, name: name, encoding: encoding}
+					defer cw.close()
+					w = cw
+				}
 				if auth != nil {
 					ctx, err = auth.Authenticate(r, fn)
 					if err != nil {
@@ -686,7 +738,7 @@ func attach(auth api.Auth[*http.Request], yield func(string, http.Handler) bool,
 				if ctype == "" {
 					ctype = "application/json"
 				}
-				decoder, decoderOk := contentTypes[ctype]
+				decoder, decoderOk := codecFor(ctype)
 				var args = make([]reflect.Value, fn.NumIn())
 				for i := range args {
 					args[i] = reflect.New(fn.In(i)).Elem()
@@ -834,6 +886,14 @@ func attach(auth api.Auth[*http.Request], yield func(string, http.Handler) bool,
 					handle(ctx, fn, auth, w, err)
 					return
 				}
+				if method == "GET" && conditional(results) {
+					if cacheControl != "" {
+						w.Header().Set("Cache-Control", cacheControl)
+					}
+					cw := &conditionalWriter{ResponseWriter: w, request: r}
+					defer cw.finish()
+					w = cw
+				}
 				// Custom HTTP Headers Support
 				// TODO cache whether or not we need to do this loop?
 				header := w.Header()
@@ -892,24 +952,18 @@ func attach(auth api.Auth[*http.Request], yield func(string, http.Handler) bool,
 					}
 				}
 				accept := r.Header.Get("Accept")
-				if accept == "" || accept == "*/*" {
-					if len(results) == 1 {
-						switch results[0].Type().Kind() {
-						case reflect.Struct, reflect.Slice, reflect.Map, reflect.Array:
-							accept = "application/json"
-						default:
-							accept = "text/plain"
-						}
-					} else {
-						accept = "application/json"
-					}
+				if accept == "" {
+					accept = "*/*"
 				}
-				for ctype := range strings.SplitSeq(accept, ",") {
-					ctype, _, _ = mime.ParseMediaType(ctype)
-					encoder, ok := contentTypes[ctype]
-					if !ok {
-						continue
+				fallback := "application/json"
+				if len(results) == 1 {
+					switch results[0].Type().Kind() {
+					case reflect.Struct, reflect.Slice, reflect.Map, reflect.Array:
+					default:
+						fallback = "text/plain"
 					}
+				}
+				if ctype, encoder, ok := negotiate(accept, fallback); ok {
 					w.Header().Set("Content-Type", ctype)
 					if responseNeedsMapping {
 						mapping := make(map[string]any)
@@ -926,12 +980,7 @@ func attach(auth api.Auth[*http.Request], yield func(string, http.Handler) bool,
 					}
 					return
 				}
-				var supported []string
-				for k := range contentTypes {
-					supported = append(supported, k)
-				}
-				sort.Strings(supported)
-				w.Header().Set("Accept-Encoding", strings.Join(supported, ", "))
+				w.Header().Set("Accept-Encoding", strings.Join(supportedContentTypes(), ", "))
 				http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
 			})) {
 				return
@@ -7,9 +7,20 @@ import (
 	"net/http"
 
 	"runtime.link/api"
+	http_api "runtime.link/api/internal/http"
 	"runtime.link/api/xray"
 )
 
+func init() {
+	http_api.Register(http_api.Protocol{
+		Name: "rest",
+		Handler: func(auth any, impl any) (http.Handler, error) {
+			shared, _ := auth.(api.Auth[*http.Request])
+			return Handler(shared, impl)
+		},
+	})
+}
+
 // ListenAndServe starts a HTTP server that serves supported API
 // types. If the [Authenticator] is nil, requests will not require
 // any authentication.
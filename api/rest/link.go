@@ -19,6 +19,7 @@ import (
 
 	"runtime.link/api"
 	http_api "runtime.link/api/internal/http"
+	"runtime.link/api/internal/oas"
 	"runtime.link/api/internal/rtags"
 	"runtime.link/api/xray"
 	"runtime.link/xyz"
@@ -105,8 +106,8 @@ func (w RequestWriter) Header() http.Header {
 func (op operation) clientWrite(header http.Header, path string, args []reflect.Value, body io.Writer, _ bool) (endpoint, contentType string, err error) {
 	var encoder func(http.ResponseWriter, any) error
 	contentType = string(op.DefaultContentType)
-	ctype, ok := contentTypes[contentType]
-	if !ok {
+	ctype, ok := codecFor(contentType)
+	if !ok || ctype.Encode == nil {
 		return "", "", fmt.Errorf("unsupported content type: %v", op.DefaultContentType)
 	}
 
@@ -155,6 +156,12 @@ func (op operation) clientWrite(header http.Header, path string, args []reflect.
 		if param.Location == parameterInBody {
 			if op.argumentsNeedsMapping {
 				mapping[param.Name] = deref(param.Index).Interface()
+			} else if reader, ok := deref(param.Index).Interface().(io.Reader); ok {
+				if streamed, ok := body.(*requestBody); ok {
+					streamed.stream = reader
+				} else if _, err := io.Copy(body, reader); err != nil {
+					return "", "", err
+				}
 			} else {
 				if err := encoder(writer, deref(param.Index).Interface()); err != nil {
 					return "", "", err
@@ -177,6 +184,91 @@ func (op operation) clientWrite(header http.Header, path string, args []reflect.
 	return path + "?" + query.Encode(), contentType, nil
 }
 
+// clientStream returns an iterator of the given type over the items of an
+// unpaginated response. JSON arrays are decoded one item at a time, as they
+// are received. The body is closed once the iterator finishes, or once the
+// context is done.
+func clientStream(ctx context.Context, rtype reflect.Type, ctype string, resp *http.Response) (reflect.Value, error) {
+	var (
+		yieldType = rtype.In(0)
+		elem      = yieldType.In(0)
+		isSeq2    = yieldType.NumIn() == 2
+		body      = resp.Body
+	)
+	if isSeq2 && yieldType.In(1) != errType {
+		body.Close()
+		return reflect.Value{}, fmt.Errorf("unsupported iterator type %v (the second value must be an error)", rtype)
+	}
+	codec, ok := codecFor(ctype)
+	if !ok || codec.Decode == nil {
+		body.Close()
+		return reflect.Value{}, fmt.Errorf("unsupported content type: %v", ctype)
+	}
+	stop := context.AfterFunc(ctx, func() { body.Close() })
+	return reflect.MakeFunc(rtype, func(args []reflect.Value) []reflect.Value {
+		defer stop()
+		defer body.Close()
+		yield := args[0]
+		send := func(item reflect.Value) bool {
+			in := []reflect.Value{item}
+			if isSeq2 {
+				in = append(in, reflect.Zero(errType))
+			}
+			return yield.Call(in)[0].Bool()
+		}
+		fail := func(err error) {
+			if isSeq2 {
+				yield.Call([]reflect.Value{reflect.Zero(elem), reflect.ValueOf(&err).Elem()})
+			}
+		}
+		if resp.StatusCode == http.StatusNoContent {
+			return nil
+		}
+		if ctype != "application/json" {
+			items := reflect.New(reflect.SliceOf(elem))
+			if err := codec.Decode(body, items.Interface()); err != nil {
+				fail(xray.New(err))
+				return nil
+			}
+			for i := range items.Elem().Len() {
+				if !send(items.Elem().Index(i)) {
+					return nil
+				}
+			}
+			return nil
+		}
+		decoder := json.NewDecoder(body)
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			if err == nil {
+				err = fmt.Errorf("expected a JSON array, got %v", token)
+			}
+			fail(xray.New(err))
+			return nil
+		}
+		for decoder.More() {
+			item := reflect.New(elem)
+			if err := decoder.Decode(item.Interface()); err != nil {
+				fail(xray.New(err))
+				return nil
+			}
+			if !send(item.Elem()) {
+				return nil
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			fail(xray.New(err))
+		}
+		return nil
+	}), nil
+}
+
+// requestBody buffers the body of a request, unless the body is an io.Reader
+// argument, in which case it is streamed to the server as-is.
+type requestBody struct {
+	bytes.Buffer
+	stream io.Reader
+}
+
 type copier struct {
 	from io.Reader
 }
@@ -216,8 +308,8 @@ func (op operation) clientRead(mime string, results []reflect.Value, response io
 	var (
 		decoder func(io.Reader, any) error
 	)
-	ctype, ok := contentTypes[mime]
-	if !ok {
+	ctype, ok := codecFor(mime)
+	if !ok || ctype.Decode == nil {
 		return true, fmt.Errorf("unsupported content type: %v", mime)
 	}
 	decoder = ctype.Decode
@@ -249,6 +341,7 @@ func link(client *http.Client, spec specification, host string) error {
 	if client == nil {
 		client = http.DefaultClient
 	}
+	client = withDecompression(client)
 	for path, resource := range spec.Resources {
 		for method, operation := range resource.Operations {
 			var (
@@ -262,6 +355,31 @@ func link(client *http.Client, spec specification, host string) error {
 				method = string(method) //Determine the HTTP method of this request.
 				path   = rtags.CleanupPattern(path)
 			)
+			policy, err := policyOf(client, spec, fn, method)
+			if err != nil {
+				return xray.New(err)
+			}
+			accept := "application/json"
+			if transport, ok := transportOf[contentTypeTransport](client); ok && op.DefaultContentType == "application/json" {
+				codec, ok := codecFor(transport.mime)
+				if !ok {
+					return xray.New(fmt.Errorf("unsupported content type: %v", transport.mime))
+				}
+				op.DefaultContentType = oas.ContentType(transport.mime)
+				if codec.Decode != nil {
+					accept = transport.mime + ", application/json;q=0.9"
+				}
+			}
+			page, err := paginationOf(fn)
+			if err != nil {
+				return xray.New(err)
+			}
+			var iterating bool
+			if fn.NumOut() == 1 {
+				isSeq, isSeq2 := isIteratorType(fn.Type.Out(0))
+				iterating = isSeq || isSeq2
+			}
+			paged := iterating && page.style != ""
 			//Create an implementation of the function that calls the REST
 			//endpoint over the network and returns the results.
 			fn.Make(func(ctx context.Context, args []reflect.Value) (results []reflect.Value, err error) {
@@ -313,7 +431,7 @@ func link(client *http.Client, spec specification, host string) error {
 				}
 				
 				//body buffers what we will be sending to the endpoint.
-				var writer = new(bytes.Buffer)
+				var writer = new(requestBody)
 				//Figure out the REST endpoint to send a request to.
 				//args are interpolated into the path and query as
 				//defined in the "rest" tag for this function.
@@ -327,34 +445,74 @@ func link(client *http.Client, spec specification, host string) error {
 					fmt.Println(method, host+endpoint)
 					fmt.Println("body:\n", writer.String())
 				}
-				var body io.ReadCloser
+				var (
+					payload []byte
+					stream  io.Reader
+				)
 				// These methods should not have a body.
 				switch method {
 				case "GET", "HEAD", "DELETE", "OPTIONS", "TRACE":
-					body = http.NoBody
 				default:
-					body = io.NopCloser(writer)
+					payload = writer.Bytes()
+					stream = writer.stream
 				}
-				req, err := http.NewRequestWithContext(ctx, method, host+endpoint, xray.NewReader(ctx, body))
-				if err != nil {
-					return nil, err
+				var policy = policy
+				if stream != nil {
+					streamed := *policy
+					streamed.idempotent = false // the stream cannot be sent again.
+					policy = &streamed
 				}
-				maps.Copy(req.Header, headers)
-				xray.ContextAdd(ctx, req)
+				newRequest := func(ctx context.Context, location string) (*http.Request, error) {
+					var body io.ReadCloser = http.NoBody
+					if payload != nil {
+						body = io.NopCloser(bytes.NewReader(payload))
+					}
+					if stream != nil {
+						body = io.NopCloser(stream)
+					}
+					req, err := http.NewRequestWithContext(ctx, method, location, xray.NewReader(ctx, body))
+					if err != nil {
+						return nil, err
+					}
+					maps.Copy(req.Header, headers)
+					xray.ContextAdd(ctx, req)
 
-				//We are expecting JSON.
-				req.Header.Add("Accept", "application/json")
-				if req.Header.Get("Content-Type") == "" {
-					req.Header.Set("Content-Type", contentType)
+					//We are expecting JSON, unless another content type is preferred.
+					req.Header.Add("Accept", accept)
+					if req.Header.Get("Content-Type") == "" {
+						req.Header.Set("Content-Type", contentType)
+					}
+					if debug {
+						fmt.Println("headers:\n", req.Header)
+					}
+					return req, nil
 				}
-				if debug {
-					fmt.Println("headers:\n", req.Header)
+				if paged {
+					results[0], err = pager{
+						pagination: page,
+						op:         op,
+						spec:       spec,
+						client:     client,
+						policy:     policy,
+						request:    newRequest,
+					}.iterator(ctx, fn.Type.Out(0), host+endpoint)
+					if err != nil {
+						return nil, err
+					}
+					return results, nil
+				}
+				cancel := context.CancelFunc(func() {})
+				if policy.Timeout > 0 {
+					ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
 				}
-				resp, err := client.Do(req)
+				req, resp, err := policy.do(ctx, client, func(ctx context.Context) (*http.Request, error) {
+					return newRequest(ctx, host+endpoint)
+				})
 				if err != nil {
+					cancel()
 					return nil, err
-
 				}
+				resp.Body = cancelOnClose{resp.Body, cancel}
 				var shouldClose = true
 				defer func() {
 					if shouldClose {
@@ -385,6 +543,13 @@ func link(client *http.Client, spec specification, host string) error {
 				if ctype == "" {
 					ctype = "application/json"
 				}
+				if iterating {
+					shouldClose = false
+					if results[0], err = clientStream(ctx, fn.Type.Out(0), ctype, resp); err != nil {
+						return nil, err
+					}
+					return results, nil
+				}
 				if shouldClose, err = op.clientRead(ctype, results, resp.Body); err != nil {
 					return nil, err
 				}
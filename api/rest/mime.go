@@ -1,11 +1,13 @@
 package rest
 
 import (
+	"bufio"
 	"encoding"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
@@ -13,6 +15,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"runtime.link/api/xray"
 )
@@ -132,21 +135,162 @@ func (m multipartEncoder) encode(name string, value any) error {
 	return nil
 }
 
-type contentType struct {
+// Codec for a content type, either function may be nil if the content
+// type can only be encoded or decoded.
+type Codec struct {
 	Encode func(w http.ResponseWriter, v any) error
 	Decode func(r io.Reader, v any) error
 }
 
-var contentTypes = map[string]contentType{
-	"application/json": {
-		Encode: func(w http.ResponseWriter, v any) error {
-			b, err := json.MarshalIndent(v, "", "\t")
+// RegisterContentType registers the codec for the given MIME type, such
+// that it can be used to decode request bodies with a matching Content-Type
+// and to encode responses that Accept it. Clients use it for functions with
+// the content type in their rest tag (or for any JSON function, when linked
+// with a [WithContentType] client). Any existing codec for the MIME type
+// is replaced. The package registers 'application/json', 'application/xml',
+// 'text/plain', 'multipart/form-data' (encode only), 'application/cbor' and
+// 'application/msgpack' by default.
+func RegisterContentType(mime string, codec Codec) {
+	contentTypesMutex.Lock()
+	defer contentTypesMutex.Unlock()
+	contentTypes[mime] = codec
+}
+
+// WithContentType returns a copy of the client (or of the default client if nil),
+// that encodes the request bodies of the functions it is linked with as the given
+// (registered) MIME type, instead of JSON, and that prefers responses of it.
+// Functions with a different content type in their rest tag are unaffected.
+//
+//	client := rest.WithContentType(nil, "application/cbor")
+func WithContentType(client *http.Client, mime string) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var copied = *client
+	copied.Transport = contentTypeTransport{mime: mime, base: client.Transport}
+	return &copied
+}
+
+type contentTypeTransport struct {
+	mime string
+	base http.RoundTripper
+}
+
+func (t contentTypeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.base == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func (t contentTypeTransport) unwrap() http.RoundTripper { return t.base }
+
+// codecFor returns the registered codec for the given MIME type.
+func codecFor(mime string) (Codec, bool) {
+	contentTypesMutex.RLock()
+	defer contentTypesMutex.RUnlock()
+	codec, ok := contentTypes[mime]
+	return codec, ok
+}
+
+// supportedContentTypes returns the sorted MIME types that can be encoded.
+func supportedContentTypes() []string {
+	contentTypesMutex.RLock()
+	defer contentTypesMutex.RUnlock()
+	var supported []string
+	for mime, codec := range contentTypes {
+		if codec.Encode != nil {
+			supported = append(supported, mime)
+		}
+	}
+	sort.Strings(supported)
+	return supported
+}
+
+// negotiate returns the registered content type that best matches the
+// given Accept header, preferring the fallback for any wildcards.
+func negotiate(accept, fallback string) (string, Codec, bool) {
+	type option struct {
+		mime    string
+		quality float64
+	}
+	var options []option
+	for part := range strings.SplitSeq(accept, ",") {
+		media, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			options = append(options, option{media, quality})
+		}
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].quality > options[j].quality })
+	for _, option := range options {
+		media := option.mime
+		if media == "*/*" || strings.HasSuffix(media, "/*") && strings.HasPrefix(fallback, strings.TrimSuffix(media, "*")) {
+			media = fallback
+		}
+		if codec, ok := codecFor(media); ok && codec.Encode != nil {
+			return media, codec, true
+		}
+	}
+	return "", Codec{}, false
+}
+
+var contentTypesMutex sync.RWMutex
+
+// streamJSON writes the value as indented JSON. Slices and arrays are written
+// one element at a time, so that large results are never held in memory as
+// JSON all at once.
+func streamJSON(w io.Writer, v any) error {
+	buffered := bufio.NewWriterSize(w, 32<<10)
+	rvalue := reflect.ValueOf(v)
+	switch {
+	case !rvalue.IsValid(),
+		rvalue.Kind() != reflect.Slice && rvalue.Kind() != reflect.Array,
+		rvalue.Kind() == reflect.Slice && (rvalue.IsNil() || rvalue.Type().Elem().Kind() == reflect.Uint8),
+		rvalue.Type().Implements(jsonMarshaler), rvalue.Type().Implements(textMarshaler):
+		b, err := json.MarshalIndent(v, "", "\t")
+		if err != nil {
+			return err
+		}
+		buffered.Write(b)
+	case rvalue.Len() == 0:
+		buffered.WriteString("[]")
+	default:
+		buffered.WriteString("[\n")
+		for i := range rvalue.Len() {
+			elem := rvalue.Index(i)
+			if elem.CanAddr() {
+				elem = elem.Addr() // so that pointer receiver methods are used, as they would be by json.Marshal.
+			}
+			b, err := json.MarshalIndent(elem.Interface(), "\t", "\t")
 			if err != nil {
-				return xray.New(err)
+				return err
 			}
-			w.Header().Set("Content-Length", strconv.Itoa(len(b)))
-			_, err = w.Write(b)
-			return xray.New(err)
+			if i > 0 {
+				buffered.WriteString(",\n")
+			}
+			buffered.WriteByte('\t')
+			if _, err := buffered.Write(b); err != nil {
+				return err
+			}
+		}
+		buffered.WriteString("\n]")
+	}
+	return buffered.Flush()
+}
+
+var contentTypes = map[string]Codec{
+	"application/json": {
+		Encode: func(w http.ResponseWriter, v any) error {
+			return xray.New(streamJSON(w, v))
 		},
 		Decode: func(r io.Reader, v any) error {
 			return xray.New(json.NewDecoder(r).Decode(v))
@@ -241,6 +385,8 @@ var contentTypes = map[string]contentType{
 			return newMultipartEncoder(w).Encode(v)
 		},
 	},
+	"application/cbor":    {Encode: encodeWith(cborFormat), Decode: decodeWith(cborFormat)},
+	"application/msgpack": {Encode: encodeWith(msgpackFormat), Decode: decodeWith(msgpackFormat)},
 	"application/json+schema": {
 		Encode: func(w http.ResponseWriter, v any) error {
 			if err := json.NewEncoder(w).Encode(schemaFor(nil, v)); err != nil {
@@ -0,0 +1,260 @@
+package rest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// msgpack implements the MessagePack format (https://msgpack.org), as the
+// 'application/msgpack' content type. The timestamp extension is decoded
+// into a [time.Time], any other extension types are rejected.
+type msgpack struct{}
+
+var msgpackFormat binaryFormat = msgpack{}
+
+func (msgpack) appendNull(b []byte) []byte { return append(b, 0xC0) }
+
+func (msgpack) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xC3)
+	}
+	return append(b, 0xC2)
+}
+
+func (m msgpack) appendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return m.appendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xD0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xD1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xD2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xD3), uint64(v))
+	}
+}
+
+func (msgpack) appendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= math.MaxInt8:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xCC, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xCD), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xCE), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xCF), v)
+	}
+}
+
+func (msgpack) appendFloat(b []byte, v float64) []byte {
+	if float64(float32(v)) == v {
+		return binary.BigEndian.AppendUint32(append(b, 0xCA), math.Float32bits(float32(v)))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xCB), math.Float64bits(v))
+}
+
+// header appends the smallest of the fix, 8, 16 or 32 bit headers for a
+// string, binary, array or map of length n. A fix of zero means that there
+// is no fix header, an 8-bit code of zero means that there is no 8-bit
+// header.
+func (msgpack) header(b []byte, n int, fix byte, fixes int, code8 byte, code16 byte, code32 byte) []byte {
+	switch {
+	case fix != 0 && n < fixes:
+		return append(b, fix|byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		return append(b, code8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, code16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, code32), uint32(n))
+	}
+}
+
+func (m msgpack) appendString(b []byte, v string) []byte {
+	return append(m.header(b, len(v), 0xA0, 32, 0xD9, 0xDA, 0xDB), v...)
+}
+
+func (m msgpack) appendBytes(b []byte, v []byte) []byte {
+	return append(m.header(b, len(v), 0, 0, 0xC4, 0xC5, 0xC6), v...)
+}
+
+func (m msgpack) appendArray(b []byte, n int) []byte {
+	return m.header(b, n, 0x90, 16, 0, 0xDC, 0xDD)
+}
+
+func (m msgpack) appendMap(b []byte, n int) []byte {
+	return m.header(b, n, 0x80, 16, 0, 0xDE, 0xDF)
+}
+
+// uint reads a big-endian unsigned integer of the given size at i.
+func (msgpack) uint(b []byte, i, size int) (uint64, int, error) {
+	if len(b)-i < size {
+		return 0, 0, errTruncated
+	}
+	var n uint64
+	for _, c := range b[i : i+size] {
+		n = n<<8 | uint64(c)
+	}
+	return n, i + size, nil
+}
+
+func (m msgpack) parse(b []byte, i, depth int) (any, int, error) {
+	if depth > maxDepth {
+		return nil, 0, errTooDeep
+	}
+	if i >= len(b) {
+		return nil, 0, errTruncated
+	}
+	c := b[i]
+	i++
+	var (
+		n    uint64
+		size int
+		err  error
+	)
+	switch {
+	case c <= 0x7F:
+		return int64(c), i, nil
+	case c >= 0xE0:
+		return int64(int8(c)), i, nil
+	case c <= 0x8F:
+		return m.parseMap(b, i, uint64(c&0x0F), depth)
+	case c <= 0x9F:
+		return m.parseArray(b, i, uint64(c&0x0F), depth)
+	case c <= 0xBF:
+		return m.parseString(b, i, uint64(c&0x1F))
+	}
+	switch c {
+	case 0xC0:
+		return nil, i, nil
+	case 0xC2:
+		return false, i, nil
+	case 0xC3:
+		return true, i, nil
+	case 0xC4, 0xC5, 0xC6: // bin 8, 16, 32
+		if n, i, err = m.uint(b, i, 1<<(c-0xC4)); err != nil {
+			return nil, 0, err
+		}
+		if n > uint64(len(b)-i) {
+			return nil, 0, errTruncated
+		}
+		return append([]byte(nil), b[i:i+int(n)]...), i + int(n), nil
+	case 0xC7, 0xC8, 0xC9: // ext 8, 16, 32
+		if n, i, err = m.uint(b, i, 1<<(c-0xC7)); err != nil {
+			return nil, 0, err
+		}
+		return m.parseExtension(b, i, n)
+	case 0xCA:
+		if n, i, err = m.uint(b, i, 4); err != nil {
+			return nil, 0, err
+		}
+		return float64(math.Float32frombits(uint32(n))), i, nil
+	case 0xCB:
+		if n, i, err = m.uint(b, i, 8); err != nil {
+			return nil, 0, err
+		}
+		return math.Float64frombits(n), i, nil
+	case 0xCC, 0xCD, 0xCE, 0xCF: // uint 8, 16, 32, 64
+		if n, i, err = m.uint(b, i, 1<<(c-0xCC)); err != nil {
+			return nil, 0, err
+		}
+		if n > math.MaxInt64 {
+			return n, i, nil
+		}
+		return int64(n), i, nil
+	case 0xD0, 0xD1, 0xD2, 0xD3: // int 8, 16, 32, 64
+		size = 1 << (c - 0xD0)
+		if n, i, err = m.uint(b, i, size); err != nil {
+			return nil, 0, err
+		}
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, i, nil
+	case 0xD4, 0xD5, 0xD6, 0xD7, 0xD8: // fixext 1, 2, 4, 8, 16
+		return m.parseExtension(b, i, 1<<(c-0xD4))
+	case 0xD9, 0xDA, 0xDB: // str 8, 16, 32
+		if n, i, err = m.uint(b, i, 1<<(c-0xD9)); err != nil {
+			return nil, 0, err
+		}
+		return m.parseString(b, i, n)
+	case 0xDC, 0xDD: // array 16, 32
+		if n, i, err = m.uint(b, i, 2<<(c-0xDC)); err != nil {
+			return nil, 0, err
+		}
+		return m.parseArray(b, i, n, depth)
+	case 0xDE, 0xDF: // map 16, 32
+		if n, i, err = m.uint(b, i, 2<<(c-0xDE)); err != nil {
+			return nil, 0, err
+		}
+		return m.parseMap(b, i, n, depth)
+	}
+	return nil, 0, fmt.Errorf("msgpack: invalid type %#x", c)
+}
+
+func (msgpack) parseString(b []byte, i int, n uint64) (any, int, error) {
+	if n > uint64(len(b)-i) {
+		return nil, 0, errTruncated
+	}
+	return string(b[i : i+int(n)]), i + int(n), nil
+}
+
+func (m msgpack) parseArray(b []byte, i int, n uint64, depth int) (any, int, error) {
+	if n > uint64(len(b)-i) { // each item is at least one byte.
+		return nil, 0, errTruncated
+	}
+	var items = make([]any, n)
+	for j := range items {
+		var err error
+		if items[j], i, err = m.parse(b, i, depth+1); err != nil {
+			return nil, 0, err
+		}
+	}
+	return items, i, nil
+}
+
+func (m msgpack) parseMap(b []byte, i int, n uint64, depth int) (any, int, error) {
+	if n > uint64(len(b)-i)/2 {
+		return nil, 0, errTruncated
+	}
+	var pairs = make(binaryMap, n)
+	for j := range pairs {
+		var err error
+		if pairs[j].key, i, err = m.parse(b, i, depth+1); err != nil {
+			return nil, 0, err
+		}
+		if pairs[j].value, i, err = m.parse(b, i, depth+1); err != nil {
+			return nil, 0, err
+		}
+	}
+	return pairs, i, nil
+}
+
+// parseExtension parses the type and n bytes of data of an extension at i.
+func (msgpack) parseExtension(b []byte, i int, n uint64) (any, int, error) {
+	if n >= uint64(len(b)-i) {
+		return nil, 0, errTruncated
+	}
+	kind, data := int8(b[i]), b[i+1:i+1+int(n)]
+	i += 1 + int(n)
+	if kind != -1 {
+		return nil, 0, fmt.Errorf("msgpack: unsupported extension type %d", kind)
+	}
+	switch len(data) { // timestamp 32, 64 and 96
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), i, nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), i, nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), i, nil
+	}
+	return nil, 0, fmt.Errorf("msgpack: invalid timestamp length %d", len(data))
+}
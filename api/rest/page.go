@@ -0,0 +1,342 @@
+package rest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"runtime.link/api"
+)
+
+// pagination of a function that returns an iter.Seq[T] or iter.Seq2[T, error],
+// as declared by its 'page' tag (see the package documentation).
+type pagination struct {
+	style  string
+	param  string
+	limit  string
+	size   int
+	header string
+	field  string
+	items  string
+}
+
+// paginationOf returns the pagination for the function, the style is
+// empty when the function does not have a 'page' tag.
+func paginationOf(fn api.Function) (pagination, error) {
+	tag := fn.Tags.Get("page")
+	if tag == "" {
+		return pagination{}, nil
+	}
+	var page = pagination{limit: "limit", header: "Next-Cursor"}
+	options := strings.Fields(tag)
+	page.style = options[0]
+	switch page.style {
+	case "link", "cursor":
+		page.param = "cursor"
+	case "offset":
+		page.param = "offset"
+	default:
+		return page, fmt.Errorf("%s: unsupported page style '%s'", fn.Name, page.style)
+	}
+	for _, option := range options[1:] {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || value == "" {
+			return page, fmt.Errorf("%s: invalid page option '%s'", fn.Name, option)
+		}
+		switch key {
+		case "param":
+			page.param = value
+		case "limit":
+			page.limit = value
+		case "size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return page, fmt.Errorf("%s: invalid page size '%s'", fn.Name, value)
+			}
+			page.size = size
+		case "header":
+			page.header = value
+		case "field":
+			page.field = value
+		case "items":
+			page.items = value
+		default:
+			return page, fmt.Errorf("%s: unknown page option '%s'", fn.Name, key)
+		}
+	}
+	if page.field != "" && page.items == "" {
+		return page, fmt.Errorf("%s: page option 'field' requires 'items'", fn.Name)
+	}
+	return page, nil
+}
+
+// pagedSource serves a single page of a [dataSource].
+type pagedSource struct {
+	dataSource
+
+	offset int
+	size   int
+	next   func(offset int) // called when there are more items after the page.
+}
+
+func (ps pagedSource) Iterate(ctx context.Context, fn func(item any) bool) error {
+	var i int
+	var more bool
+	err := ps.dataSource.Iterate(ctx, func(item any) bool {
+		i++
+		if i <= ps.offset {
+			return true
+		}
+		if i > ps.offset+ps.size {
+			more = true
+			return false
+		}
+		return fn(item)
+	})
+	if err == nil && more {
+		ps.next(ps.offset + ps.size)
+	}
+	return err
+}
+
+// paginate the source according to the query of the request, so that the
+// response only includes a single page, followed by a 'Link' to the next.
+func (page pagination) paginate(r *http.Request, w http.ResponseWriter, source dataSource) (dataSource, error) {
+	query := r.URL.Query()
+	size := page.size
+	if size == 0 {
+		size = 100
+	}
+	if limit, err := strconv.Atoi(query.Get(page.limit)); err == nil && limit > 0 && limit < size {
+		size = limit
+	}
+	var offset int
+	if value := query.Get(page.param); value != "" {
+		var err error
+		if page.style == "offset" {
+			offset, err = strconv.Atoi(value)
+		} else {
+			offset, err = decodeCursor(value)
+		}
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("invalid %s '%s'", page.param, value)
+		}
+	}
+	return pagedSource{dataSource: source, offset: offset, size: size, next: func(offset int) {
+		var token string
+		if page.style == "offset" {
+			token = strconv.Itoa(offset)
+		} else {
+			token = encodeCursor(offset)
+			w.Header().Set(page.header, token)
+		}
+		query.Set(page.param, token)
+		next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		if uri, err := url.ParseRequestURI(r.RequestURI); err == nil {
+			next.Path = uri.Path
+		}
+		w.Header().Add("Link", "<"+next.String()+`>; rel="next"`)
+	}}, nil
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(b))
+}
+
+// pager fetches the pages of a linked function that returns an iterator.
+type pager struct {
+	pagination
+
+	op      operation
+	spec    specification
+	client  *http.Client
+	policy  *callPolicy
+	request func(ctx context.Context, url string) (*http.Request, error)
+}
+
+// iterator returns an iterator of the given type, over each item in each
+// page, starting with the given URL. The first page is fetched immediately,
+// so that any error can be returned to the caller.
+func (p pager) iterator(ctx context.Context, rtype reflect.Type, location string) (reflect.Value, error) {
+	var (
+		yieldType = rtype.In(0)
+		elem      = yieldType.In(0)
+		isSeq2    = yieldType.NumIn() == 2
+	)
+	if isSeq2 && yieldType.In(1) != errType {
+		return reflect.Value{}, fmt.Errorf("unsupported iterator type %v (the second value must be an error)", rtype)
+	}
+	if p.size > 0 {
+		u, err := url.Parse(location)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		query := u.Query()
+		query.Set(p.limit, strconv.Itoa(p.size))
+		u.RawQuery = query.Encode()
+		location = u.String()
+	}
+	first, next, err := p.fetch(ctx, location, elem)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.MakeFunc(rtype, func(args []reflect.Value) []reflect.Value {
+		var (
+			yield = args[0]
+			items = first
+			next  = next
+		)
+		for {
+			for i := range items.Len() {
+				in := []reflect.Value{items.Index(i)}
+				if isSeq2 {
+					in = append(in, reflect.Zero(errType))
+				}
+				if !yield.Call(in)[0].Bool() {
+					return nil
+				}
+			}
+			if next == "" {
+				return nil
+			}
+			var err error
+			items, next, err = p.fetch(ctx, next, elem)
+			if err != nil {
+				if isSeq2 {
+					yield.Call([]reflect.Value{reflect.Zero(elem), reflect.ValueOf(&err).Elem()})
+				}
+				return nil
+			}
+		}
+	}), nil
+}
+
+// fetch a page of items from the given URL, returning the URL of the next page
+// (if any).
+func (p pager) fetch(ctx context.Context, location string, elem reflect.Type) (items reflect.Value, next string, err error) {
+	items = reflect.New(reflect.SliceOf(elem))
+	if p.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.policy.Timeout)
+		defer cancel()
+	}
+	req, resp, err := p.policy.do(ctx, p.client, func(ctx context.Context) (*http.Request, error) {
+		return p.request(ctx, location)
+	})
+	if err != nil {
+		return items.Elem(), "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return items.Elem(), "", decodeError(req, resp, p.spec)
+	}
+	var token string
+	if resp.StatusCode != http.StatusNoContent {
+		if p.items != "" {
+			var envelope map[string]json.RawMessage
+			if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+				return items.Elem(), "", err
+			}
+			if raw, ok := envelope[p.items]; ok {
+				if err := json.Unmarshal(raw, items.Interface()); err != nil {
+					return items.Elem(), "", err
+				}
+			}
+			if raw, ok := envelope[p.field]; ok && p.field != "" {
+				if json.Unmarshal(raw, &token) != nil && string(raw) != "null" {
+					token = string(raw)
+				}
+			}
+		} else {
+			ctype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+			if ctype == "" {
+				ctype = string(p.op.DefaultContentType)
+			}
+			if ctype == "" {
+				ctype = "application/json"
+			}
+			content, ok := codecFor(ctype)
+			if !ok {
+				return items.Elem(), "", fmt.Errorf("unsupported content type: %v", ctype)
+			}
+			if err := content.Decode(resp.Body, items.Interface()); err != nil && err != io.EOF {
+				return items.Elem(), "", err
+			}
+		}
+	}
+	items = items.Elem()
+	switch p.style {
+	case "link":
+		next = nextLink(req.URL, resp.Header.Values("Link"))
+	case "cursor":
+		if p.field == "" {
+			token = resp.Header.Get(p.header)
+		}
+		if token != "" {
+			next = withQuery(req.URL, p.param, token)
+		}
+	case "offset":
+		if items.Len() > 0 && (p.size == 0 || items.Len() >= p.size) {
+			offset, _ := strconv.Atoi(req.URL.Query().Get(p.param))
+			next = withQuery(req.URL, p.param, strconv.Itoa(offset+items.Len()))
+		}
+	}
+	if next == req.URL.String() {
+		next = ""
+	}
+	return items, next, nil
+}
+
+// withQuery returns the URL with the given query parameter set to value.
+func withQuery(u *url.URL, param, value string) string {
+	query := u.Query()
+	query.Set(param, value)
+	next := *u
+	next.RawQuery = query.Encode()
+	return next.String()
+}
+
+// nextLink returns the rel="next" URL in the given 'Link' header values,
+// resolved against the URL of the request.
+func nextLink(base *url.URL, links []string) string {
+	for _, header := range links {
+		for link := range strings.SplitSeq(header, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for param := range strings.SplitSeq(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") {
+					continue
+				}
+				for rel := range strings.FieldsSeq(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						next, err := base.Parse(target[1 : len(target)-1])
+						if err != nil {
+							return ""
+						}
+						return next.String()
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,333 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"runtime.link/api"
+)
+
+// Policy for calling REST functions. Each field can also be set with a tag
+// (of the same name in lowercase) on the [api.Specification] or on an
+// individual function, function tags take precedence over the tags on the
+// specification, which take precedence over any [WithPolicy] client.
+//
+//	GetPet func(context.Context, PetID) (Pet, error) `rest:"GET /pet/{id=%v}" retries:"3" timeout:"5s"`
+//
+// Only idempotent calls are retried, these are GET, HEAD, PUT, DELETE,
+// OPTIONS and TRACE requests, along with any functions tagged with
+// `idempotent:"true"`. Calls are retried when the request fails to reach
+// the server, or when the response has a 408, 429, 502, 503 or 504 status
+// code (a 'Retry-After' header is respected). The [api.Scenario] for a
+// status code can be tagged with `retry:"true"` or `retry:"false"` to
+// override this.
+type Policy struct {
+	Retries    int           // maximum number of times to retry a call.
+	Backoff    time.Duration // delay before the first retry (doubled for each retry), defaults to 100ms.
+	MaxBackoff time.Duration // maximum delay between retries, defaults to 10s.
+	Timeout    time.Duration // for each call, including any retries.
+
+	// Breaker is the number of consecutive failures (5xx responses or
+	// transport errors) that will open the circuit breaker of a function,
+	// such that calls fail immediately with [ErrCircuitOpen] until the
+	// Cooldown (defaults to 30s) has passed, then a single call is let
+	// through to test whether the circuit can be closed again.
+	Breaker  int
+	Cooldown time.Duration
+}
+
+// ErrCircuitOpen is returned by functions when their circuit breaker is open.
+var ErrCircuitOpen error = circuitOpen{}
+
+type circuitOpen struct{}
+
+func (circuitOpen) Error() string   { return "circuit breaker is open" }
+func (circuitOpen) StatusHTTP() int { return http.StatusServiceUnavailable }
+
+// WithPolicy returns a copy of the client (or of the default client if nil),
+// that applies the given policy to the functions it is linked with.
+func WithPolicy(client *http.Client, policy Policy) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var copied = *client
+	copied.Transport = policyTransport{Policy: policy, base: client.Transport}
+	return &copied
+}
+
+type policyTransport struct {
+	Policy
+
+	base http.RoundTripper
+}
+
+func (t policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.base == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func (t policyTransport) unwrap() http.RoundTripper { return t.base }
+
+// transportOf returns the first transport of type T, in the chain of
+// transports wrapped by the client (ie. by [WithPolicy]).
+func transportOf[T http.RoundTripper](client *http.Client) (T, bool) {
+	for transport := client.Transport; transport != nil; {
+		if found, ok := transport.(T); ok {
+			return found, true
+		}
+		wrapper, ok := transport.(interface{ unwrap() http.RoundTripper })
+		if !ok {
+			break
+		}
+		transport = wrapper.unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// callPolicy is the policy for a linked function.
+type callPolicy struct {
+	Policy
+
+	scenarios  []api.Scenario
+	idempotent bool
+	breaker    *breaker
+}
+
+// policyOf returns the policy for the function.
+func policyOf(client *http.Client, spec specification, fn api.Function, method string) (*callPolicy, error) {
+	var policy callPolicy
+	if transport, ok := transportOf[policyTransport](client); ok {
+		policy.Policy = transport.Policy
+	}
+	for _, tags := range []interface{ Get(string) string }{spec.Tags, fn.Tags} {
+		for _, field := range []struct {
+			tag string
+			int *int
+			dur *time.Duration
+		}{
+			{tag: "retries", int: &policy.Retries},
+			{tag: "backoff", dur: &policy.Backoff},
+			{tag: "maxbackoff", dur: &policy.MaxBackoff},
+			{tag: "timeout", dur: &policy.Timeout},
+			{tag: "breaker", int: &policy.Breaker},
+			{tag: "cooldown", dur: &policy.Cooldown},
+		} {
+			value := tags.Get(field.tag)
+			if value == "" {
+				continue
+			}
+			var err error
+			if field.int != nil {
+				*field.int, err = strconv.Atoi(value)
+			} else {
+				*field.dur, err = time.ParseDuration(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid %s tag '%s' (%w)", fn.Name, field.tag, value, err)
+			}
+		}
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 10 * time.Second
+	}
+	if policy.Cooldown <= 0 {
+		policy.Cooldown = 30 * time.Second
+	}
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS", "TRACE":
+		policy.idempotent = true
+	}
+	if idempotent, err := strconv.ParseBool(fn.Tags.Get("idempotent")); err == nil {
+		policy.idempotent = idempotent
+	}
+	if policy.Breaker > 0 {
+		policy.breaker = new(breaker)
+	}
+	policy.scenarios = spec.Scenarios
+	return &policy, nil
+}
+
+// do sends the request created by newRequest, retrying it according to
+// the policy. The final response is returned, regardless of its status.
+func (p *callPolicy) do(ctx context.Context, client *http.Client, newRequest func(context.Context) (*http.Request, error)) (*http.Request, *http.Response, error) {
+	var trial bool
+	if p.breaker != nil {
+		var err error
+		if trial, err = p.breaker.allow(time.Now()); err != nil {
+			return nil, nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest(ctx)
+		if err != nil {
+			p.release(trial) // a local failure says nothing about the endpoint.
+			return nil, nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				p.release(trial)
+			} else {
+				p.report(true)
+			}
+			if attempt >= p.Retries || !p.idempotent || ctx.Err() != nil || !p.wait(ctx, attempt, 0) {
+				return nil, nil, err
+			}
+			continue
+		}
+		p.report(resp.StatusCode >= 500)
+		if attempt >= p.Retries || !p.idempotent || !p.retryable(resp.StatusCode) {
+			return req, resp, nil
+		}
+		if !p.wait(ctx, attempt, retryAfter(resp.Header.Get("Retry-After"), time.Now())) {
+			return req, resp, nil
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+	}
+}
+
+// report the outcome of an attempt to the circuit breaker.
+func (p *callPolicy) report(failed bool) {
+	if p.breaker != nil {
+		p.breaker.report(failed, p.Breaker, p.Cooldown, time.Now())
+	}
+}
+
+// release the half-open trial of the circuit breaker (if the call was the
+// trial) without reporting an outcome, for calls that failed locally or were
+// cancelled before the endpoint could respond.
+func (p *callPolicy) release(trial bool) {
+	if p.breaker != nil && trial {
+		p.breaker.release()
+	}
+}
+
+// retryable reports whether a response with the given status code
+// should be retried.
+func (p *callPolicy) retryable(status int) bool {
+	for _, scenario := range p.scenarios {
+		if scenario.Tags.Get("http") != strconv.Itoa(status) {
+			continue
+		}
+		if retry, err := strconv.ParseBool(scenario.Tags.Get("retry")); err == nil {
+			return retry
+		}
+	}
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// wait before the next attempt, returning false if the context would be
+// done before then.
+func (p *callPolicy) wait(ctx context.Context, attempt int, after time.Duration) bool {
+	delay := p.Backoff << attempt
+	if delay > p.MaxBackoff || delay <= 0 {
+		delay = p.MaxBackoff
+	}
+	delay = delay/2 + rand.N(delay/2+1) // jitter
+	if after > delay {
+		delay = after
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// retryAfter parses the value of a Retry-After header.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// breaker is a circuit breaker for a linked function.
+type breaker struct {
+	mutex    sync.Mutex
+	failures int
+	until    time.Time // when the circuit may be half-opened.
+	trial    bool      // a half-open trial call is in flight.
+}
+
+// allow reports whether a call is allowed, and whether it is the half-open
+// trial call.
+func (b *breaker) allow(now time.Time) (trial bool, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.until.IsZero() {
+		return false, nil
+	}
+	if now.Before(b.until) || b.trial {
+		return false, ErrCircuitOpen
+	}
+	b.trial = true
+	return true, nil
+}
+
+// release the half-open trial, leaving the circuit open, such that the
+// next call becomes the trial.
+func (b *breaker) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trial = false
+}
+
+func (b *breaker) report(failed bool, threshold int, cooldown time.Duration, now time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !failed {
+		b.failures = 0
+		b.until = time.Time{}
+		b.trial = false
+		return
+	}
+	b.failures++
+	if b.trial || b.failures >= threshold {
+		b.until = now.Add(cooldown)
+		b.trial = false
+	}
+}
+
+// cancelOnClose cancels the context of a call once its response
+// body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
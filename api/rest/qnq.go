@@ -0,0 +1,336 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"runtime.link/api/xray"
+	"runtime.link/qnq"
+)
+
+// ChannelHandler returns a [http.Handler] that serves each [qnq.Chan] field
+// (with a 'qnq' tag) of the given pointer to a struct (ie. as returned by
+// [qnq.Open]), so that the topics can be shared with other processes through
+// [OpenChannels]. Each topic is served at its (path escaped) name:
+//
+//	POST /{topic}
+//	  sends the JSON body to the topic.
+//	GET /{topic}?subscription=ID
+//	  subscribes to the topic, each message is sent as {"id":1,"data":...}
+//	  over a websocket, or as a text/event-stream when the connection is not
+//	  upgraded. Each message must be acknowledged with {"id":1} or rejected
+//	  with {"id":1,"error":"..."}, either by sending it over the websocket or
+//	  by posting it to /{topic}/acks.
+//
+// Messages that are not acknowledged before the subscriber disconnects are
+// considered to have failed. The handler does not authenticate requests, so
+// it should be wrapped with any required authentication.
+func ChannelHandler(channels any) (http.Handler, error) {
+	rvalue := reflect.ValueOf(channels)
+	if rvalue.Kind() != reflect.Pointer || rvalue.Elem().Kind() != reflect.Struct {
+		return nil, xray.New(errors.New("rest: channels must be a pointer to a struct"))
+	}
+	rvalue = rvalue.Elem()
+	var handler = &channelHandler{
+		topics:  make(map[qnq.Topic]reflect.Value),
+		pending: make(map[uint64]pendingAck),
+	}
+	for i := range rvalue.NumField() {
+		field := rvalue.Type().Field(i)
+		topic, ok := field.Tag.Lookup("qnq")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		ch := rvalue.Field(i).Addr()
+		if !ch.MethodByName("Send").IsValid() || !ch.MethodByName("Listen").IsValid() {
+			return nil, xray.New(errors.New("rest: " + field.Name + " is not a qnq.Chan"))
+		}
+		handler.topics[qnq.Topic(topic)] = ch
+	}
+	var mux = http.NewServeMux()
+	mux.HandleFunc("POST /{topic}", handler.send)
+	mux.HandleFunc("GET /{topic}", handler.recv)
+	mux.HandleFunc("POST /{topic}/acks", handler.ack)
+	return mux, nil
+}
+
+// channelMessage is sent to subscribers.
+type channelMessage struct {
+	ID   uint64          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// channelAck is sent back by subscribers, to acknowledge a message.
+type channelAck struct {
+	ID    uint64 `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+type pendingAck struct {
+	topic qnq.Topic
+	done  chan error
+}
+
+type channelHandler struct {
+	topics map[qnq.Topic]reflect.Value // of *qnq.Chan[T]
+
+	mutex   sync.Mutex
+	counter uint64
+	pending map[uint64]pendingAck
+}
+
+func (h *channelHandler) lookup(w http.ResponseWriter, r *http.Request) (qnq.Topic, reflect.Value, bool) {
+	topic := qnq.Topic(r.PathValue("topic"))
+	ch, ok := h.topics[topic]
+	if !ok {
+		http.Error(w, "topic not found", http.StatusNotFound)
+	}
+	return topic, ch, ok
+}
+
+func (h *channelHandler) send(w http.ResponseWriter, r *http.Request) {
+	_, ch, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	send := ch.MethodByName("Send")
+	value := reflect.New(send.Type().In(1))
+	if err := json.NewDecoder(r.Body).Decode(value.Interface()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results := send.Call([]reflect.Value{reflect.ValueOf(r.Context()), value.Elem()})
+	if err, _ := results[0].Interface().(error); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, qnq.ErrEmptyChannel) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *channelHandler) recv(w http.ResponseWriter, r *http.Request) {
+	topic, ch, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	subscription := r.URL.Query().Get("subscription")
+	if subscription == "" {
+		http.Error(w, "missing subscription", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	var (
+		messages = make(chan channelMessage)
+		acks     = make(chan channelAck)
+	)
+	listen := ch.MethodByName("Listen")
+	listener := reflect.MakeFunc(listen.Type().In(2), func(args []reflect.Value) []reflect.Value {
+		err := h.deliver(args[0].Interface().(context.Context), ctx, topic, args[1].Interface(), messages)
+		if err == nil {
+			return []reflect.Value{reflect.Zero(listen.Type().In(2).Out(0))}
+		}
+		return []reflect.Value{reflect.ValueOf(&err).Elem()}
+	})
+	listen.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(subscription), listener})
+	if r.Header.Get("Upgrade") == "websocket" {
+		go func() {
+			for {
+				select {
+				case ack := <-acks:
+					h.acknowledge(topic, ack)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		websocketServeHTTP(ctx, r, w, reflect.ValueOf((<-chan channelMessage)(messages)), reflect.ValueOf((chan<- channelAck)(acks)))
+		return
+	}
+	sseServeHTTP(ctx, r, w, reflect.ValueOf((<-chan channelMessage)(messages)))
+}
+
+// deliver the value to the subscriber and wait for it to be acknowledged,
+// the subscribed context may differ from the context of the delivery, when
+// the message is being sent synchronously.
+func (h *channelHandler) deliver(ctx, subscribed context.Context, topic qnq.Topic, value any, messages chan<- channelMessage) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return xray.New(err)
+	}
+	var done = make(chan error, 1)
+	h.mutex.Lock()
+	h.counter++
+	id := h.counter
+	h.pending[id] = pendingAck{topic: topic, done: done}
+	h.mutex.Unlock()
+	defer func() {
+		h.mutex.Lock()
+		delete(h.pending, id)
+		h.mutex.Unlock()
+	}()
+	select {
+	case messages <- channelMessage{ID: id, Data: data}:
+	case <-ctx.Done():
+		return xray.New(ctx.Err())
+	case <-subscribed.Done():
+		return xray.New(subscribed.Err())
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return xray.New(ctx.Err())
+	case <-subscribed.Done():
+		return xray.New(subscribed.Err())
+	}
+}
+
+func (h *channelHandler) acknowledge(topic qnq.Topic, ack channelAck) bool {
+	h.mutex.Lock()
+	pending, ok := h.pending[ack.ID]
+	h.mutex.Unlock()
+	if !ok || pending.topic != topic {
+		return false
+	}
+	var err error
+	if ack.Error != "" {
+		err = errors.New(ack.Error)
+	}
+	select {
+	case pending.done <- err:
+	default: // already acknowledged.
+	}
+	return true
+}
+
+func (h *channelHandler) ack(w http.ResponseWriter, r *http.Request) {
+	topic, _, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	var ack channelAck
+	if err := json.NewDecoder(r.Body).Decode(&ack); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.acknowledge(topic, ack) {
+		http.Error(w, "message not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// OpenChannels returns a [qnq.Channels] implementation for the topics served
+// by a [ChannelHandler] at the given URL. If the client is nil, then the
+// [http.DefaultClient] is used. Subscriptions are received over a websocket
+// and are reconnected (after a second) until their context is done.
+func OpenChannels(host string, client *http.Client) qnq.Channels {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return remoteChannels{url: strings.TrimSuffix(host, "/"), client: client}
+}
+
+type remoteChannels struct {
+	url    string
+	client *http.Client
+}
+
+func (c remoteChannels) endpoint(topic qnq.Topic) string {
+	return c.url + "/" + url.PathEscape(string(topic))
+}
+
+func (c remoteChannels) Send(ctx context.Context, topic qnq.Topic, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return xray.New(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint(topic), strings.NewReader(string(data)))
+	if err != nil {
+		return xray.New(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return xray.New(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return xray.New(qnq.ErrEmptyChannel)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return xray.New(errors.New(resp.Status + ": " + strings.TrimSpace(string(body))))
+}
+
+func (c remoteChannels) Recv(ctx context.Context, topic qnq.Topic, subscription string) <-chan func(any) (func(error), error) {
+	var out = make(chan func(any) (func(error), error))
+	go func() {
+		defer close(out)
+		for ctx.Err() == nil {
+			c.subscribe(ctx, topic, subscription, out)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+	return out
+}
+
+// subscribe to the topic, until the connection is closed.
+func (c remoteChannels) subscribe(ctx context.Context, topic qnq.Topic, subscription string, out chan<- func(any) (func(error), error)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", c.endpoint(topic)+"?subscription="+url.QueryEscape(subscription), nil)
+	if err != nil {
+		return
+	}
+	var (
+		messages = make(chan channelMessage)
+		acks     = make(chan channelAck)
+	)
+	go func() {
+		defer cancel()
+		websocketOpen(ctx, c.client, req, reflect.ValueOf((<-chan channelAck)(acks)), reflect.ValueOf((chan<- channelMessage)(messages)))
+	}()
+	for {
+		select {
+		case message := <-messages:
+			ack := func(err error) {
+				var reply = channelAck{ID: message.ID}
+				if err != nil {
+					reply.Error = err.Error()
+				}
+				select {
+				case acks <- reply:
+				case <-ctx.Done(): // the message will be redelivered.
+				}
+			}
+			decode := func(value any) (func(error), error) {
+				return ack, json.Unmarshal(message.Data, value)
+			}
+			select {
+			case out <- decode:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package rest_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"runtime.link/api/rest"
+	"runtime.link/qnq"
+)
+
+type Events struct {
+	Names qnq.Chan[string] `qnq:"names"`
+	Dead  qnq.Chan[string] `qnq:"names.billing.dead"`
+}
+
+func receive(t *testing.T, received <-chan string, expected string) {
+	t.Helper()
+	select {
+	case name := <-received:
+		if name != expected {
+			t.Fatalf("expected %q, got %q", expected, name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected %q", expected)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	dir, err := qnq.OpenDir(t.TempDir(), qnq.DirOptions{MaxAttempts: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	handler, err := rest.ChannelHandler(qnq.Open[Events](dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remote := qnq.Open[Events](rest.OpenChannels(server.URL, server.Client()))
+	var attempts int
+	received := make(chan string, 10)
+	remote.Names.Listen(ctx, "billing", func(ctx context.Context, name string) error {
+		if strings.HasPrefix(name, "fail") {
+			attempts++
+			return errors.New("failed")
+		}
+		received <- name
+		return nil
+	})
+	dead := make(chan string, 1)
+	remote.Dead.Listen(ctx, "audit", func(ctx context.Context, name string) error {
+		dead <- name
+		return nil
+	})
+	for _, name := range []string{"alice", "fail-bob", "carol"} {
+		if err := remote.Names.Send(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive(t, received, "alice")
+	receive(t, dead, "fail-bob")
+	receive(t, received, "carol")
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+
+	// subscribers can also receive messages as server-sent events, with
+	// acknowledgements posted back to the server.
+	if err := remote.Names.Send(ctx, "dave"); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/names?subscription=billing.sse", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	receive(t, received, "dave")
+	scanner := bufio.NewScanner(resp.Body)
+	for _, expected := range []string{"alice", "fail-bob", "carol", "dave"} {
+		if !scanner.Scan() {
+			t.Fatal(scanner.Err())
+		}
+		line := scanner.Text()
+		if !strings.HasPrefix(line, `data: {"id":`) || !strings.HasSuffix(line, `,"data":"`+expected+`"}`) {
+			t.Fatalf("unexpected event %q", line)
+		}
+		scanner.Scan() // blank line between events.
+		id := strings.TrimPrefix(line, `data: {"id":`)
+		id = id[:strings.Index(id, ",")]
+		ack, err := http.Post(server.URL+"/names/acks", "application/json", strings.NewReader(`{"id":`+id+`}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ack.Body.Close()
+		if ack.StatusCode != http.StatusNoContent {
+			t.Fatalf("unexpected ack status %v", ack.Status)
+		}
+	}
+
+	missing := qnq.Open[struct {
+		Missing qnq.Chan[string] `qnq:"missing"`
+	}](rest.OpenChannels(server.URL, server.Client()))
+	if err := missing.Missing.Send(ctx, "erin"); err == nil {
+		t.Fatal("expected an error for a missing topic")
+	}
+}
@@ -16,7 +16,7 @@ the Content-Type of the request is 'application/json' and the
 API has suitable rest tags.
 
 	API.Echo = func(message string) string { return message }
-	api.ListenAndServe(":"+os.Getenv("PORT"), &API)
+	api.ListenAndServe(":"+os.Getenv("PORT"), nil, &API)
 
 This starts a local HTTP server and listens on PORT
 for requests to /echo and responds to these requests with the
@@ -26,6 +26,10 @@ converted to the Content-Type where possible.
 You can return receive-only channels which will be served to
 clients as a websocket.
 
+The [qnq.Chan] topics of a struct opened with [qnq.Open] can be
+served with a [ChannelHandler] and shared with other processes
+that open them with [OpenChannels].
+
 # Tags
 
 Each API function can have a rest tag that formats
@@ -94,6 +98,17 @@ map named json fields to the result values.
 	getLatLong func() (float64, float64) `rest:"GET /latlong latitude,longitude"`
 	{"latitude": 12.2, "longitude": 15.0} => lat, lon := getLatLong()
 
+# Content Types
+
+Request bodies are decoded according to their Content-Type and responses
+are encoded according to the Accept header of the request (respecting any
+q-values). JSON, XML, text/plain, CBOR ('application/cbor') and MessagePack
+('application/msgpack') are supported, additional codecs can be added with
+[RegisterContentType]. Clients can use a compact binary format instead of
+JSON with [WithContentType].
+
+	client := rest.WithContentType(nil, "application/msgpack")
+
 # Response Headers
 
 In order to read and write HTTP headers in a request, values should implement the
@@ -124,6 +139,79 @@ they are returned. Here's an example:
 		GetProfilePicture func() (ProfilePicture, error)
 	}
 
+# OpenAPI
+
+Third-party APIs that are described by an OpenAPI 3.x document (JSON or
+YAML) can be converted into Go source for an API structure with the
+appropriate rest tags, using [Generate].
+
+	err := rest.Generate(os.Stdout, "petstore", document)
+
+# Pagination
+
+Functions that return an iter.Seq[T] or iter.Seq2[T, error] are served
+as a JSON array (or as server-sent events). A 'page' tag declares how the
+results are split into pages, such that clients transparently follow each
+page as they iterate, while servers only respond with a single page at a
+time, along with a 'Link' header to the next page. Without a 'page' tag,
+clients decode each item of the array as it is received.
+
+	ListPets func(context.Context) iter.Seq2[Pet, error] `rest:"GET /pets" page:"link"`
+
+The style of pagination can be "link" (follow rel="next" Link headers),
+"cursor" (pass the cursor from the 'Next-Cursor' header of the previous page)
+or "offset" (pass the number of items seen so far). Space separated options
+can follow the style:
+
+  - param: the query parameter for the cursor or offset (defaults to the style).
+  - limit: the query parameter for the page size (defaults to limit).
+  - size: the page size to request (or to serve, defaults to 100).
+  - header: the response header with the next cursor (defaults to Next-Cursor).
+  - items: the JSON field with the items of a page, when pages are an object.
+  - field: the JSON field of the page object with the next cursor.
+
+For example, to iterate over a third-party API that wraps each page in
+an object:
+
+	ListCustomers func(context.Context) iter.Seq2[Customer, error] `rest:"GET /customers" page:"cursor param=starting_after items=data field=next_cursor"`
+
+# Caching
+
+Successful responses to GET functions are given an ETag (a hash of the
+response, unless the result sets its own ETag header, see Response Headers)
+and requests with a matching If-None-Match header (or an If-Modified-Since
+header that is not before the Last-Modified header of the result) receive
+a '304 Not Modified' response without a body. A 'cache' tag (on the
+function or the specification) sets the Cache-Control header.
+
+	GetForecast func(context.Context) (Forecast, error) `rest:"GET /forecast" cache:"max-age=60"`
+
+Clients created with [WithCache] keep the responses, reuse them while
+they are fresh and then revalidate them, so that unchanged responses are
+not downloaded again.
+
+# Compression
+
+Responses are compressed with the best content encoding in the request's
+Accept-Encoding header ('gzip' and 'deflate' by default, others such as 'br'
+or 'zstd' can be added with [RegisterContentEncoding]), unless they are
+smaller than 1KB, already compressed or an event stream. Request bodies with
+a registered Content-Encoding are decompressed, and clients accept and
+decompress the registered encodings transparently.
+
+JSON slices and arrays are encoded one element at a time, so that large
+results are streamed to the client (GET responses over 1MB are streamed
+without a generated ETag). An io.Reader body argument is streamed to the
+server as-is, rather than being buffered, so such calls are not retried.
+
+# Retries
+
+Clients can retry failed calls, time them out and stop calling endpoints
+that keep failing, see [Policy] for the tags that control this, or use
+[WithPolicy] to apply a default policy to every function.
+
+	GetPet func(context.Context, PetID) (Pet, error) `rest:"GET /pet/{id=%v}" retries:"3" timeout:"5s"`
+
 # Framework Compatibility
 
 Echo
@@ -3,10 +3,13 @@ package rest_test
 import (
 	"context"
 	"errors"
+	"iter"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"runtime.link/api"
 	"runtime.link/api/rest"
@@ -316,3 +319,276 @@ func TestMapping(t *testing.T) {
 		t.Fatal("unexpected result: ", a, b)
 	}
 }
+
+func TestPolicy(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch n := calls.Add(1); {
+		case r.URL.Path == "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		case r.URL.Path == "/down":
+			w.WriteHeader(http.StatusInternalServerError)
+		case n < 3:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`"ok"`))
+		}
+	}))
+	defer server.Close()
+
+	type API struct {
+		api.Specification `backoff:"1ms"`
+
+		Get  func(context.Context) (string, error) `rest:"GET /get" retries:"3"`
+		Post func(context.Context) (string, error) `rest:"POST /post"`
+		Down func(context.Context) error           `rest:"GET /down" breaker:"2" cooldown:"1h"`
+		Slow func(context.Context) (string, error) `rest:"GET /slow" timeout:"50ms"`
+	}
+	client := api.Import[API](rest.API, server.URL, rest.WithPolicy(nil, rest.Policy{Retries: 1}))
+	ctx := context.Background()
+
+	reply, err := client.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "ok" || calls.Load() != 3 {
+		t.Fatalf("got %q after %d calls, want %q after 3 calls", reply, calls.Load(), "ok")
+	}
+
+	calls.Store(0)
+	if _, err := client.Post(ctx); err == nil {
+		t.Fatal("expected POST to fail without being retried")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("got %d calls, want 1", calls.Load())
+	}
+
+	calls.Store(0)
+	for range 2 {
+		if err := client.Down(ctx); err == nil || errors.Is(err, rest.ErrCircuitOpen) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if err := client.Down(ctx); !errors.Is(err, rest.ErrCircuitOpen) {
+		t.Fatalf("got %v, want %v", err, rest.ErrCircuitOpen)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("got %d calls, want 2", calls.Load())
+	}
+
+	calls.Store(0)
+	start := time.Now()
+	if _, err := client.Slow(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("timeout took %v", elapsed)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	type API struct {
+		api.Specification
+
+		Down func(ctx context.Context, path string) error `rest:"GET /down/{path*=%v}" breaker:"2" cooldown:"50ms"`
+	}
+	client := api.Import[API](rest.API, server.URL, nil)
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	for name, trial := range map[string]func() error{
+		"invalid request": func() error { return client.Down(ctx, "%zz") },
+		"cancelled":       func() error { return client.Down(cancelled, "ok") },
+	} {
+		for range 2 {
+			client.Down(ctx, "ok")
+		}
+		if err := client.Down(ctx, "ok"); !errors.Is(err, rest.ErrCircuitOpen) {
+			t.Fatalf("%s: got %v, want %v", name, err, rest.ErrCircuitOpen)
+		}
+		time.Sleep(60 * time.Millisecond)
+		calls.Store(0)
+		if err := trial(); err == nil || errors.Is(err, rest.ErrCircuitOpen) {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		// the circuit must still be half-open, so the next call is the
+		// trial, which fails and opens the circuit again.
+		if err := client.Down(ctx, "ok"); err == nil || errors.Is(err, rest.ErrCircuitOpen) {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		if err := client.Down(ctx, "ok"); !errors.Is(err, rest.ErrCircuitOpen) {
+			t.Fatalf("%s: got %v, want %v", name, err, rest.ErrCircuitOpen)
+		}
+		if calls.Load() != 1 {
+			t.Fatalf("%s: got %d calls, want 1", name, calls.Load())
+		}
+		time.Sleep(60 * time.Millisecond)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	type API struct {
+		api.Specification
+
+		Link   func(context.Context) iter.Seq2[int, error] `rest:"GET /link" page:"link size=100"`
+		Cursor func(context.Context) iter.Seq2[int, error] `rest:"GET /cursor" page:"cursor param=after size=100"`
+		Offset func(context.Context) iter.Seq2[int, error] `rest:"GET /offset" page:"offset size=100"`
+		Single func(context.Context) iter.Seq[int]         `rest:"GET /single"`
+	}
+	var requests atomic.Int32
+	numbers := func(context.Context) iter.Seq2[int, error] {
+		requests.Add(1)
+		return func(yield func(int, error) bool) {
+			for i := range 250 {
+				if !yield(i, nil) {
+					return
+				}
+			}
+		}
+	}
+	handler, err := rest.Handler(nil, &API{
+		Link:   numbers,
+		Cursor: numbers,
+		Offset: numbers,
+		Single: func(ctx context.Context) iter.Seq[int] {
+			requests.Add(1)
+			return func(yield func(int) bool) {
+				for i := range 250 {
+					if !yield(i) {
+						return
+					}
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := api.Import[API](rest.API, server.URL, nil)
+	ctx := context.Background()
+	for name, fn := range map[string]func(context.Context) iter.Seq2[int, error]{
+		"link":   client.Link,
+		"cursor": client.Cursor,
+		"offset": client.Offset,
+	} {
+		requests.Store(0)
+		var count int
+		for i, err := range fn(ctx) {
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if i != count {
+				t.Fatalf("%s: got %d, want %d", name, i, count)
+			}
+			count++
+		}
+		if count != 250 || requests.Load() != 3 {
+			t.Fatalf("%s: got %d items in %d requests, want 250 in 3", name, count, requests.Load())
+		}
+	}
+	requests.Store(0)
+	var count int
+	for range client.Single(ctx) {
+		count++
+	}
+	if count != 250 || requests.Load() != 1 {
+		t.Fatalf("single: got %d items in %d requests, want 250 in 1", count, requests.Load())
+	}
+}
+
+func TestIteratorStreaming(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/numbers":
+			w.Write([]byte("[1,\n2,"))
+			w.(http.Flusher).Flush()
+			<-release
+			w.Write([]byte("3]"))
+		case "/broken":
+			w.Write([]byte("[1, oops"))
+		}
+	}))
+	defer server.Close()
+
+	type API struct {
+		api.Specification
+
+		Numbers func(context.Context) iter.Seq[int]         `rest:"GET /numbers"`
+		Broken  func(context.Context) iter.Seq2[int, error] `rest:"GET /broken"`
+	}
+	client := api.Import[API](rest.API, server.URL, nil)
+	var sum int
+	for n := range client.Numbers(context.Background()) {
+		if n == 2 {
+			close(release) // the rest of the array is only sent once the first items are received.
+		}
+		sum += n
+	}
+	if sum != 6 {
+		t.Fatalf("got %d, want 6", sum)
+	}
+	var (
+		items  []int
+		failed error
+	)
+	for n, err := range client.Broken(context.Background()) {
+		if err != nil {
+			failed = err
+			break
+		}
+		items = append(items, n)
+	}
+	if failed == nil || len(items) != 1 {
+		t.Fatalf("expected one item and then an error, got %v %v", items, failed)
+	}
+}
+
+func TestPaginationEnvelope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("starting_after") {
+		case "":
+			w.Write([]byte(`{"data": ["a", "b"], "next": "b"}`))
+		case "b":
+			w.Write([]byte(`{"data": ["c"], "next": null}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := api.Import[struct {
+		api.Specification
+
+		List func(context.Context) iter.Seq2[string, error] `rest:"GET /list" page:"cursor param=starting_after field=next items=data"`
+	}](rest.API, server.URL, nil)
+
+	var letters []string
+	for letter, err := range client.List(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		letters = append(letters, letter)
+	}
+	if strings.Join(letters, "") != "abc" {
+		t.Fatalf("got %v, want [a b c]", letters)
+	}
+}
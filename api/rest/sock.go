@@ -11,7 +11,6 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"net"
 	"net/http"
 	"reflect"
 	"strings"
@@ -61,18 +60,20 @@ func websocketServeHTTP(ctx context.Context, r *http.Request, rw http.ResponseWr
 	hijacker, ok := w.(http.Hijacker)
 	if ok {
 		conn, brw, err := hijacker.Hijack()
-		brw.Flush()
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		brw.Flush()
 		defer conn.Close()
 		w = conn
-		body = conn
+		body = brw
 	}
 	if flusher, ok := w.(http.Flusher); ok {
 		flusher.Flush()
 	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	var pongs = make(chan struct{})
 	cases := []reflect.SelectCase{
 		{Dir: reflect.SelectRecv, Chan: send},
@@ -80,6 +81,7 @@ func websocketServeHTTP(ctx context.Context, r *http.Request, rw http.ResponseWr
 		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(pongs)},
 	}
 	go func() { // handle reads.
+		defer cancel() // the connection has been closed.
 		const (
 			opcode = 0b00001111
 			length = 0b01111111
@@ -128,15 +130,22 @@ func websocketServeHTTP(ctx context.Context, r *http.Request, rw http.ResponseWr
 				if _, err := io.ReadAtLeast(body, buf, int(size)); err != nil {
 					return
 				}
+				if !recv.IsValid() {
+					continue
+				}
 				for i := range buf {
 					j := i % 4
-					buf[i] = buf[i] ^ byte(key>>(8*j))
+					buf[i] = buf[i] ^ byte(key>>(8*(3-j)))
 				}
 				var value = reflect.New(recv.Type().Elem())
 				if err := json.Unmarshal(buf, value.Interface()); err != nil {
 					return
 				}
-				recv.Send(value.Elem())
+				if !sendContext(ctx, recv, value.Elem()) {
+					return
+				}
+			case sockClose:
+				return
 			default:
 				if size > 0 {
 					io.CopyN(io.Discard, body, int64(size))
@@ -177,10 +186,10 @@ func websocketServeHTTP(ctx context.Context, r *http.Request, rw http.ResponseWr
 			frame = append(frame, byte(len(b)))
 		case len(b) < math.MaxUint16:
 			frame = append(frame, 126)
-			binary.BigEndian.AppendUint16(frame, uint16(len(b)))
+			frame = binary.BigEndian.AppendUint16(frame, uint16(len(b)))
 		default:
 			frame = append(frame, 127)
-			binary.BigEndian.AppendUint64(frame, uint64(len(b)))
+			frame = binary.BigEndian.AppendUint64(frame, uint64(len(b)))
 		}
 		if _, err := w.Write(frame); err != nil {
 			return
@@ -298,14 +307,9 @@ func websocketOpen(ctx context.Context, client *http.Client, r *http.Request, se
 		return
 	}
 	
-	wsURL := r.URL.String()
-	if strings.HasPrefix(wsURL, "http://") {
-		wsURL = "ws://" + wsURL[7:]
-	} else if strings.HasPrefix(wsURL, "https://") {
-		wsURL = "wss://" + wsURL[8:]
-	}
-	
-	wsReq, err := http.NewRequestWithContext(ctx, "GET", wsURL, nil)
+	// the websocket handshake is made over http(s), as the [http.Client]
+	// does not support ws:// and wss:// URLs.
+	wsReq, err := http.NewRequestWithContext(ctx, "GET", r.URL.String(), nil)
 	if err != nil {
 		return
 	}
@@ -344,22 +348,14 @@ func websocketOpen(ctx context.Context, client *http.Client, r *http.Request, se
 		return
 	}
 	
-	hijacker, ok := resp.Body.(interface {
-		Hijack() (net.Conn, *bufio.ReadWriter, error)
-	})
+	// the body of a 101 response is the upgraded connection.
+	conn, ok := resp.Body.(io.ReadWriteCloser)
 	if !ok {
 		return
 	}
 	
-	conn, brw, err := hijacker.Hijack()
-	if err != nil {
-		return
-	}
-	defer conn.Close()
-	
-	if brw != nil {
-		brw.Flush()
-	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	
 	var pongs = make(chan struct{})
 	var cases []reflect.SelectCase
@@ -373,6 +369,7 @@ func websocketOpen(ctx context.Context, client *http.Client, r *http.Request, se
 	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(pongs)})
 	
 	go func() {
+		defer cancel() // the connection has been closed.
 		const (
 			opcode = 0b00001111
 			length = 0b01111111
@@ -429,19 +426,23 @@ func websocketOpen(ctx context.Context, client *http.Client, r *http.Request, se
 					
 					for i := range buf {
 						j := i % 4
-						buf[i] = buf[i] ^ byte(key>>(8*j))
+						buf[i] = buf[i] ^ byte(key>>(8*(3-j)))
 					}
 					
 					var value = reflect.New(recv.Type().Elem())
 					if err := json.Unmarshal(buf, value.Interface()); err != nil {
 						return
 					}
-					recv.Send(value.Elem())
+					if !sendContext(ctx, recv, value.Elem()) {
+						return
+					}
 				} else {
 					if size > 0 {
 						io.CopyN(io.Discard, conn, int64(size))
 					}
 				}
+			case sockClose:
+				return
 			default:
 				if size > 0 {
 					io.CopyN(io.Discard, conn, int64(size))
@@ -496,10 +497,10 @@ func websocketOpen(ctx context.Context, client *http.Client, r *http.Request, se
 			frame = append(frame, byte(len(b))|mask) // Client must mask
 		case len(b) < math.MaxUint16:
 			frame = append(frame, 126|mask)
-			binary.BigEndian.AppendUint16(frame, uint16(len(b)))
+			frame = binary.BigEndian.AppendUint16(frame, uint16(len(b)))
 		default:
 			frame = append(frame, 127|mask)
-			binary.BigEndian.AppendUint64(frame, uint64(len(b)))
+			frame = binary.BigEndian.AppendUint64(frame, uint64(len(b)))
 		}
 		
 		var maskKey [4]byte
@@ -527,3 +528,12 @@ func websocketOpen(ctx context.Context, client *http.Client, r *http.Request, se
 		}
 	}
 }
+
+// sendContext sends the value to the channel, unless the context is done first.
+func sendContext(ctx context.Context, ch, value reflect.Value) bool {
+	chosen, _, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: ch, Send: value},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	})
+	return chosen == 0
+}
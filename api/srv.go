@@ -1,11 +1,75 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
-	_ "embed"
+	http_api "runtime.link/api/internal/http"
+	"runtime.link/api/xray"
 )
 
+// Handler returns a HTTP handler that serves the implementation over each
+// protocol that has been imported, ie. runtime.link/api/rest for REST
+// (and websockets), runtime.link/api/jrpc for JSON-RPC and runtime.link/api/grpc
+// for gRPC. Requests are routed to a protocol by their content type and
+// path, any requests that no other protocol matches are served with REST.
+// If auth is nil, requests will not require any authentication.
+func Handler(auth Auth[*http.Request], implementation any) (http.Handler, error) {
+	var (
+		routes   []http_api.Protocol
+		handlers []http.Handler
+		fallback http.Handler
+	)
+	for _, protocol := range http_api.Protocols() {
+		var shared any
+		if auth != nil {
+			shared = auth
+		}
+		handler, err := protocol.Handler(shared, implementation)
+		if err != nil {
+			return nil, xray.New(err)
+		}
+		if protocol.Match == nil {
+			fallback = handler
+			continue
+		}
+		routes = append(routes, protocol)
+		handlers = append(handlers, handler)
+	}
+	if len(routes) == 0 && fallback == nil {
+		return nil, errors.New("api: no protocols to serve, import runtime.link/api/rest (or another linker)")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i, protocol := range routes {
+			if protocol.Match(r) {
+				handlers[i].ServeHTTP(w, r)
+				return
+			}
+		}
+		if fallback == nil {
+			http.NotFound(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	}), nil
+}
+
+// ListenAndServe starts a HTTP server (that supports HTTP/2 without TLS)
+// which serves the implementation over each imported protocol on the given
+// address, see [Handler]. If auth is nil, requests will not require any
+// authentication.
 func ListenAndServe(addr string, auth Auth[*http.Request], implementation any) error {
-	return ErrNotImplemented
+	handler, err := Handler(auth, implementation)
+	if err != nil {
+		return xray.New(err)
+	}
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		Protocols: &protocols,
+	}
+	return server.ListenAndServe()
 }
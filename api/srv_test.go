@@ -0,0 +1,51 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"runtime.link/api"
+	"runtime.link/api/grpc"
+	"runtime.link/api/jrpc"
+	"runtime.link/api/rest"
+)
+
+type Greeter struct {
+	api.Specification `grpc:"example.Greeter"`
+
+	Hello func(ctx context.Context, name string) (string, error) `rest:"GET /hello/{name=%v}" jrpc:"hello"`
+}
+
+func TestHandler(t *testing.T) {
+	handler, err := api.Handler(nil, Greeter{
+		Hello: func(ctx context.Context, name string) (string, error) {
+			return "Hello " + name, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	ctx := context.Background()
+	for name, client := range map[string]Greeter{
+		"rest": api.Import[Greeter](rest.API, server.URL, nil),
+		"jrpc": api.Import[Greeter](jrpc.API, server.URL+jrpc.Path, nil),
+		"grpc": api.Import[Greeter](grpc.API, server.URL, nil),
+	} {
+		reply, err := client.Hello(ctx, "World")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if reply != "Hello World" {
+			t.Fatalf("%s: got %q, want %q", name, reply, "Hello World")
+		}
+	}
+}
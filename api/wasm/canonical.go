@@ -0,0 +1,914 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	wasm_api "github.com/tetratelabs/wazero/api"
+
+	"runtime.link/api"
+	"runtime.link/xyz"
+)
+
+// The canonical ABI limits the number of core values used to pass arguments
+// and results, beyond these limits, values are passed through memory.
+const (
+	maxFlatParams  = 16
+	maxFlatResults = 1
+)
+
+// ckind is the kind of a component model value type.
+type ckind uint8
+
+const (
+	cBool ckind = iota + 1
+	cS8
+	cS16
+	cS32
+	cS64
+	cU8
+	cU16
+	cU32
+	cU64
+	cF32
+	cF64
+	cString
+	cList
+	cRecord
+	cTuple
+	cVariant
+	cEnum
+	cOption
+	cResult
+)
+
+// ctype is a component model value type, along with its Go representation.
+type ctype struct {
+	kind   ckind
+	name   string       // WIT name of a record, variant or enum.
+	rtype  reflect.Type // Go representation.
+	elem   *ctype       // list element, option payload or result value (nil when empty).
+	fields []cfield     // record and tuple fields.
+	cases  []ccase      // variant and enum cases.
+}
+
+type cfield struct {
+	name  string
+	docs  string
+	index int // of the Go struct field or array element.
+	ctype *ctype
+}
+
+type ccase struct {
+	name  string
+	ctype *ctype // payload, nil when the case has no payload.
+}
+
+// taggedValue is implemented by [xyz.Tagged] unions.
+type taggedValue interface {
+	Reflection() []xyz.CaseReflection
+	Interface() any
+}
+
+var (
+	taggedType = reflect.TypeFor[taggedValue]()
+	errorType  = reflect.TypeFor[error]()
+	stringType = reflect.TypeFor[string]()
+)
+
+// ctypes converts Go types into component model types, named types are
+// only converted once, so that they can be declared once in WIT.
+type ctypes struct {
+	named map[reflect.Type]*ctype
+	names map[string]reflect.Type
+	order []*ctype // named types, in the order they should be declared.
+	stack map[reflect.Type]bool
+}
+
+func newTypes() *ctypes {
+	return &ctypes{
+		named: make(map[reflect.Type]*ctype),
+		names: make(map[string]reflect.Type),
+		stack: make(map[reflect.Type]bool),
+	}
+}
+
+// typeOf returns the component model type for the given Go type.
+func (c *ctypes) typeOf(rtype reflect.Type) (*ctype, error) {
+	if named, ok := c.named[rtype]; ok {
+		return named, nil
+	}
+	if c.stack[rtype] {
+		return nil, fmt.Errorf("recursive type %s cannot be represented in WIT", rtype)
+	}
+	c.stack[rtype] = true
+	defer delete(c.stack, rtype)
+	if rtype.Implements(taggedType) {
+		return c.variantOf(rtype)
+	}
+	var t = &ctype{rtype: rtype}
+	switch rtype.Kind() {
+	case reflect.Bool:
+		t.kind = cBool
+	case reflect.Int8:
+		t.kind = cS8
+	case reflect.Int16:
+		t.kind = cS16
+	case reflect.Int32:
+		t.kind = cS32
+	case reflect.Int, reflect.Int64:
+		t.kind = cS64
+	case reflect.Uint8:
+		t.kind = cU8
+	case reflect.Uint16:
+		t.kind = cU16
+	case reflect.Uint32:
+		t.kind = cU32
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		t.kind = cU64
+	case reflect.Float32:
+		t.kind = cF32
+	case reflect.Float64:
+		t.kind = cF64
+	case reflect.String:
+		t.kind = cString
+	case reflect.Slice:
+		elem, err := c.typeOf(rtype.Elem())
+		if err != nil {
+			return nil, err
+		}
+		t.kind, t.elem = cList, elem
+	case reflect.Pointer:
+		elem, err := c.typeOf(rtype.Elem())
+		if err != nil {
+			return nil, err
+		}
+		t.kind, t.elem = cOption, elem
+	case reflect.Array:
+		if rtype.Len() == 0 {
+			return nil, fmt.Errorf("empty array %s cannot be represented in WIT", rtype)
+		}
+		elem, err := c.typeOf(rtype.Elem())
+		if err != nil {
+			return nil, err
+		}
+		t.kind = cTuple
+		for i := range rtype.Len() {
+			t.fields = append(t.fields, cfield{index: i, ctype: elem})
+		}
+	case reflect.Struct:
+		t.kind = cTuple
+		if rtype.Name() != "" {
+			t.kind, t.name = cRecord, kebab(rtype.Name())
+		}
+		for i := range rtype.NumField() {
+			field := rtype.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			ftype, err := c.typeOf(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			t.fields = append(t.fields, cfield{
+				name:  kebab(field.Name),
+				docs:  api.DocumentationOf(field),
+				index: i,
+				ctype: ftype,
+			})
+		}
+		if len(t.fields) == 0 {
+			return nil, fmt.Errorf("struct %s without any exported fields cannot be represented in WIT", rtype)
+		}
+		if t.kind == cRecord {
+			if err := c.declare(t); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s values cannot be represented in WIT", rtype)
+	}
+	return t, nil
+}
+
+// variantOf returns the variant (or enum) type for an [xyz.Tagged] union.
+func (c *ctypes) variantOf(rtype reflect.Type) (*ctype, error) {
+	if rtype.Name() == "" {
+		return nil, fmt.Errorf("unnamed variant %s cannot be represented in WIT", rtype)
+	}
+	var t = &ctype{kind: cEnum, name: kebab(rtype.Name()), rtype: rtype}
+	for _, c2 := range reflect.Zero(rtype).Interface().(taggedValue).Reflection() {
+		var payload *ctype
+		switch c2.Vary {
+		case nil:
+		case errorType:
+			payload = &ctype{kind: cString, rtype: stringType}
+		default:
+			var err error
+			payload, err = c.typeOf(c2.Vary)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if payload != nil {
+			t.kind = cVariant
+		}
+		t.cases = append(t.cases, ccase{name: kebab(c2.Name), ctype: payload})
+	}
+	if len(t.cases) == 0 {
+		return nil, fmt.Errorf("variant %s without any cases cannot be represented in WIT", rtype)
+	}
+	if err := c.declare(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// declare a named type, so that it can be written as a WIT type definition.
+func (c *ctypes) declare(t *ctype) error {
+	if existing, ok := c.names[t.name]; ok && existing != t.rtype {
+		return fmt.Errorf("%s and %s share the same WIT name '%s'", existing, t.rtype, t.name)
+	}
+	c.names[t.name] = t.rtype
+	c.named[t.rtype] = t
+	c.order = append(c.order, t)
+	return nil
+}
+
+// tupleOf returns a tuple of the given types, represented in Go as a struct
+// with a field for each type, named after the given prefix.
+func (c *ctypes) tupleOf(prefix string, types []reflect.Type) (*ctype, error) {
+	var (
+		fields = make([]reflect.StructField, len(types))
+		t      = &ctype{kind: cTuple}
+	)
+	for i, rtype := range types {
+		ftype, err := c.typeOf(rtype)
+		if err != nil {
+			return nil, err
+		}
+		name := prefix + strconv.Itoa(i+1)
+		fields[i] = reflect.StructField{Name: strings.ToUpper(name[:1]) + name[1:], Type: rtype}
+		t.fields = append(t.fields, cfield{name: name, index: i, ctype: ftype})
+	}
+	t.rtype = reflect.StructOf(fields)
+	return t, nil
+}
+
+// csignature is the component model signature of a function.
+type csignature struct {
+	name    string
+	docs    string
+	args    *ctype // tuple of arguments.
+	result  *ctype // nil when the function has no result.
+	params  []wasm_api.ValueType
+	results []wasm_api.ValueType
+	spill   bool // arguments are passed through memory.
+	retptr  bool // result is written to memory.
+}
+
+// signatureOf returns the component model signature of the given function,
+// errors are returned as a result<_, string>.
+func (c *ctypes) signatureOf(fn api.Function) (csignature, error) {
+	var sig = csignature{
+		name: kebab(strings.Join(append(slices.Clone(fn.Path), fn.Name), "")),
+		docs: fn.Docs,
+	}
+	var ins = make([]reflect.Type, fn.NumIn())
+	for i := range ins {
+		ins[i] = fn.In(i)
+	}
+	var err error
+	sig.args, err = c.tupleOf("arg", ins)
+	if err != nil {
+		return sig, fmt.Errorf("%s: %w", fn.Name, err)
+	}
+	var outs = make([]reflect.Type, fn.NumOut())
+	for i := range outs {
+		outs[i] = fn.Type.Out(i)
+	}
+	var value *ctype
+	switch len(outs) {
+	case 0:
+	case 1:
+		value, err = c.typeOf(outs[0])
+	default:
+		value, err = c.tupleOf("result", outs)
+	}
+	if err != nil {
+		return sig, fmt.Errorf("%s: %w", fn.Name, err)
+	}
+	sig.result = value
+	if fn.Type.NumOut() > fn.NumOut() {
+		fields := []reflect.StructField{{Name: "Error", Type: errorType}}
+		if value != nil {
+			fields = append(fields, reflect.StructField{Name: "Value", Type: value.rtype})
+		}
+		sig.result = &ctype{kind: cResult, elem: value, rtype: reflect.StructOf(fields)}
+	}
+	sig.params = sig.args.flat()
+	if len(sig.params) > maxFlatParams {
+		sig.params, sig.spill = []wasm_api.ValueType{wasm_api.ValueTypeI32}, true
+	}
+	if sig.result != nil {
+		sig.results = sig.result.flat()
+		if len(sig.results) > maxFlatResults {
+			sig.params, sig.results, sig.retptr = append(sig.params, wasm_api.ValueTypeI32), nil, true
+		}
+	}
+	return sig, nil
+}
+
+// casesOf returns the cases of a variant-like type.
+func (t *ctype) casesOf() []ccase {
+	switch t.kind {
+	case cOption:
+		return []ccase{{name: "none"}, {name: "some", ctype: t.elem}}
+	case cResult:
+		return []ccase{{name: "ok", ctype: t.elem}, {name: "error", ctype: &ctype{kind: cString, rtype: stringType}}}
+	default:
+		return t.cases
+	}
+}
+
+// discriminant returns the type used to store the case of a variant.
+func (t *ctype) discriminant() *ctype {
+	switch n := len(t.casesOf()); {
+	case n <= 1<<8:
+		return &ctype{kind: cU8}
+	case n <= 1<<16:
+		return &ctype{kind: cU16}
+	default:
+		return &ctype{kind: cU32}
+	}
+}
+
+func (t *ctype) align() uint32 {
+	switch t.kind {
+	case cBool, cS8, cU8:
+		return 1
+	case cS16, cU16:
+		return 2
+	case cS32, cU32, cF32, cString, cList:
+		return 4
+	case cS64, cU64, cF64:
+		return 8
+	case cRecord, cTuple:
+		var align uint32 = 1
+		for _, field := range t.fields {
+			align = max(align, field.ctype.align())
+		}
+		return align
+	default:
+		return max(t.discriminant().align(), t.payloadAlign())
+	}
+}
+
+// payloadAlign returns the alignment of the payloads of a variant.
+func (t *ctype) payloadAlign() uint32 {
+	var align uint32 = 1
+	for _, c := range t.casesOf() {
+		if c.ctype != nil {
+			align = max(align, c.ctype.align())
+		}
+	}
+	return align
+}
+
+func (t *ctype) size() uint32 {
+	switch t.kind {
+	case cBool, cS8, cU8:
+		return 1
+	case cS16, cU16:
+		return 2
+	case cS32, cU32, cF32:
+		return 4
+	case cS64, cU64, cF64, cString, cList:
+		return 8
+	case cRecord, cTuple:
+		var size uint32
+		for _, field := range t.fields {
+			size = alignTo(size, field.ctype.align()) + field.ctype.size()
+		}
+		return alignTo(size, t.align())
+	default:
+		size := alignTo(t.discriminant().size(), t.payloadAlign())
+		var payload uint32
+		for _, c := range t.casesOf() {
+			if c.ctype != nil {
+				payload = max(payload, c.ctype.size())
+			}
+		}
+		return alignTo(size+payload, t.align())
+	}
+}
+
+func alignTo(n, align uint32) uint32 {
+	return (n + align - 1) &^ (align - 1)
+}
+
+// flat returns the core WASM values that the type is flattened into.
+func (t *ctype) flat() []wasm_api.ValueType {
+	switch t.kind {
+	case cBool, cS8, cS16, cS32, cU8, cU16, cU32:
+		return []wasm_api.ValueType{wasm_api.ValueTypeI32}
+	case cS64, cU64:
+		return []wasm_api.ValueType{wasm_api.ValueTypeI64}
+	case cF32:
+		return []wasm_api.ValueType{wasm_api.ValueTypeF32}
+	case cF64:
+		return []wasm_api.ValueType{wasm_api.ValueTypeF64}
+	case cString, cList:
+		return []wasm_api.ValueType{wasm_api.ValueTypeI32, wasm_api.ValueTypeI32}
+	case cRecord, cTuple:
+		var flat []wasm_api.ValueType
+		for _, field := range t.fields {
+			flat = append(flat, field.ctype.flat()...)
+		}
+		return flat
+	default:
+		var payload []wasm_api.ValueType
+		for _, c := range t.casesOf() {
+			if c.ctype == nil {
+				continue
+			}
+			for i, have := range c.ctype.flat() {
+				if i < len(payload) {
+					payload[i] = join(payload[i], have)
+				} else {
+					payload = append(payload, have)
+				}
+			}
+		}
+		return append([]wasm_api.ValueType{wasm_api.ValueTypeI32}, payload...)
+	}
+}
+
+// join returns the core type that can hold values of both a and b.
+func join(a, b wasm_api.ValueType) wasm_api.ValueType {
+	switch {
+	case a == b:
+		return a
+	case (a == wasm_api.ValueTypeI32 && b == wasm_api.ValueTypeF32) || (a == wasm_api.ValueTypeF32 && b == wasm_api.ValueTypeI32):
+		return wasm_api.ValueTypeI32
+	default:
+		return wasm_api.ValueTypeI64
+	}
+}
+
+// caseOf returns the active case of a variant-like value, along with its payload.
+func (t *ctype) caseOf(value reflect.Value) (int, reflect.Value) {
+	switch t.kind {
+	case cOption:
+		if value.IsNil() {
+			return 0, reflect.Value{}
+		}
+		return 1, value.Elem()
+	case cResult:
+		if err := value.FieldByName("Error").Interface(); err != nil {
+			return 1, reflect.ValueOf(err.(error).Error())
+		}
+		if t.elem == nil {
+			return 0, reflect.Value{}
+		}
+		return 0, value.FieldByName("Value")
+	default:
+		union := value.Interface().(taggedValue)
+		for i, c := range union.Reflection() {
+			if !c.Test(union) {
+				continue
+			}
+			if t.cases[i].ctype == nil {
+				return i, reflect.Value{}
+			}
+			payload := union.Interface()
+			if err, ok := payload.(error); ok && c.Vary == errorType {
+				return i, reflect.ValueOf(err.Error())
+			}
+			return i, reflect.ValueOf(payload)
+		}
+		if t.cases[0].ctype == nil {
+			return 0, reflect.Value{}
+		}
+		return 0, reflect.Zero(t.cases[0].ctype.rtype)
+	}
+}
+
+// makeCase returns a variant-like value for the given case and payload.
+func (t *ctype) makeCase(index int, payload reflect.Value) (reflect.Value, error) {
+	cases := t.casesOf()
+	if index < 0 || index >= len(cases) {
+		return reflect.Value{}, fmt.Errorf("invalid case %d for %s", index, t.rtype)
+	}
+	switch t.kind {
+	case cOption:
+		if index == 0 {
+			return reflect.Zero(t.rtype), nil
+		}
+		ptr := reflect.New(t.rtype.Elem())
+		ptr.Elem().Set(payload)
+		return ptr, nil
+	case cResult:
+		value := reflect.New(t.rtype).Elem()
+		if index == 1 {
+			value.FieldByName("Error").Set(reflect.ValueOf(errors.New(payload.String())))
+		} else if t.elem != nil {
+			value.FieldByName("Value").Set(payload)
+		}
+		return value, nil
+	default:
+		values := reflect.Zero(t.rtype).MethodByName("Values")
+		field := values.Call([]reflect.Value{reflect.Zero(values.Type().In(0))})[0].Field(index)
+		if field.Type() == t.rtype {
+			return field, nil
+		}
+		as := field.MethodByName("As")
+		if as.Type().In(0) == errorType {
+			payload = reflect.ValueOf(errors.New(payload.String()))
+		}
+		return as.Call([]reflect.Value{payload.Convert(as.Type().In(0))})[0], nil
+	}
+}
+
+// fieldOf returns the Go value of the given record or tuple field.
+func fieldOf(value reflect.Value, field cfield) reflect.Value {
+	if value.Kind() == reflect.Array {
+		return value.Index(field.index)
+	}
+	return value.Field(field.index)
+}
+
+// canon lifts and lowers values, to and from the memory of a guest module.
+type canon struct {
+	ctx context.Context
+	mod wasm_api.Module
+}
+
+func (c canon) fail(format string, args ...any) {
+	panic(fmt.Errorf("wasm: "+format, args...))
+}
+
+func (c canon) read(ptr, n uint32) []byte {
+	data, ok := c.mod.Memory().Read(ptr, n)
+	if !ok {
+		c.fail("out of bounds memory access at %d (%d bytes)", ptr, n)
+	}
+	return data
+}
+
+// alloc allocates memory within the guest, using its cabi_realloc export.
+func (c canon) alloc(align, size uint32) uint32 {
+	realloc := c.mod.ExportedFunction("cabi_realloc")
+	if realloc == nil {
+		c.fail("module must export cabi_realloc, in order to receive strings and lists")
+	}
+	results, err := realloc.Call(c.ctx, 0, 0, uint64(align), uint64(size))
+	if err != nil {
+		panic(err)
+	}
+	return wasm_api.DecodeU32(results[0])
+}
+
+// load a value of the given type from memory.
+func (c canon) load(t *ctype, ptr uint32) reflect.Value {
+	switch t.kind {
+	case cBool, cS8, cU8, cS16, cU16, cS32, cU32, cS64, cU64, cF32, cF64:
+		var raw uint64
+		data := c.read(ptr, t.size())
+		for i := len(data) - 1; i >= 0; i-- {
+			raw = raw<<8 | uint64(data[i])
+		}
+		return c.scalar(t, raw)
+	case cString:
+		return reflect.ValueOf(string(c.read(c.pair(ptr)))).Convert(t.rtype)
+	case cList:
+		data, n := c.pair(ptr)
+		return c.liftList(t, data, n)
+	case cRecord, cTuple:
+		value := reflect.New(t.rtype).Elem()
+		for _, field := range t.fields {
+			ptr = alignTo(ptr, field.ctype.align())
+			fieldOf(value, field).Set(c.load(field.ctype, ptr))
+			ptr += field.ctype.size()
+		}
+		return value
+	default:
+		disc := t.discriminant()
+		index := int(c.load(&ctype{kind: disc.kind, rtype: reflect.TypeFor[uint32]()}, ptr).Uint())
+		cases := t.casesOf()
+		if index >= len(cases) {
+			c.fail("invalid case %d for %s", index, t.rtype)
+		}
+		var payload reflect.Value
+		if cases[index].ctype != nil {
+			payload = c.load(cases[index].ctype, ptr+alignTo(disc.size(), t.payloadAlign()))
+		}
+		value, err := t.makeCase(index, payload)
+		if err != nil {
+			panic(err)
+		}
+		return value
+	}
+}
+
+// pair loads the pointer and length of a string or list.
+func (c canon) pair(ptr uint32) (uint32, uint32) {
+	data := c.read(ptr, 8)
+	return uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24,
+		uint32(data[4]) | uint32(data[5])<<8 | uint32(data[6])<<16 | uint32(data[7])<<24
+}
+
+// liftList copies the list out of guest memory.
+func (c canon) liftList(t *ctype, data, n uint32) reflect.Value {
+	if t.elem.kind == cU8 {
+		return reflect.ValueOf(append([]byte(nil), c.read(data, n)...)).Convert(t.rtype)
+	}
+	size := t.elem.size()
+	c.read(data, n*size)
+	list := reflect.MakeSlice(t.rtype, int(n), int(n))
+	for i := range n {
+		list.Index(int(i)).Set(c.load(t.elem, data+i*size))
+	}
+	return list
+}
+
+// scalar converts the raw bits of a number into a Go value of the given type.
+func (c canon) scalar(t *ctype, raw uint64) reflect.Value {
+	var value reflect.Value
+	switch t.kind {
+	case cBool:
+		value = reflect.ValueOf(uint32(raw) != 0)
+	case cS8:
+		value = reflect.ValueOf(int8(raw))
+	case cS16:
+		value = reflect.ValueOf(int16(raw))
+	case cS32:
+		value = reflect.ValueOf(int32(raw))
+	case cS64:
+		value = reflect.ValueOf(int64(raw))
+	case cU8:
+		value = reflect.ValueOf(uint8(raw))
+	case cU16:
+		value = reflect.ValueOf(uint16(raw))
+	case cU32:
+		value = reflect.ValueOf(uint32(raw))
+	case cU64:
+		value = reflect.ValueOf(raw)
+	case cF32:
+		value = reflect.ValueOf(math.Float32frombits(uint32(raw)))
+	case cF64:
+		value = reflect.ValueOf(math.Float64frombits(raw))
+	}
+	return value.Convert(t.rtype)
+}
+
+// bits returns the raw bits of a number.
+func bits(t *ctype, value reflect.Value) uint64 {
+	switch t.kind {
+	case cBool:
+		if value.Bool() {
+			return 1
+		}
+		return 0
+	case cS8, cS16, cS32:
+		return uint64(uint32(value.Int()))
+	case cS64:
+		return uint64(value.Int())
+	case cU8, cU16, cU32, cU64:
+		return value.Uint()
+	case cF32:
+		return uint64(math.Float32bits(float32(value.Float())))
+	default:
+		return math.Float64bits(value.Float())
+	}
+}
+
+// store a value of the given type into memory.
+func (c canon) store(t *ctype, ptr uint32, value reflect.Value) {
+	switch t.kind {
+	case cBool, cS8, cU8, cS16, cU16, cS32, cU32, cS64, cU64, cF32, cF64:
+		raw := bits(t, value)
+		data := c.read(ptr, t.size())
+		for i := range data {
+			data[i] = byte(raw >> (8 * i))
+		}
+	case cString:
+		data, n := c.lowerString(value.String())
+		c.storePair(ptr, data, n)
+	case cList:
+		data, n := c.lowerList(t, value)
+		c.storePair(ptr, data, n)
+	case cRecord, cTuple:
+		for _, field := range t.fields {
+			ptr = alignTo(ptr, field.ctype.align())
+			c.store(field.ctype, ptr, fieldOf(value, field))
+			ptr += field.ctype.size()
+		}
+	default:
+		index, payload := t.caseOf(value)
+		disc := t.discriminant()
+		c.store(disc, ptr, reflect.ValueOf(uint64(index)))
+		if ctype := t.casesOf()[index].ctype; ctype != nil {
+			c.store(ctype, ptr+alignTo(disc.size(), t.payloadAlign()), payload)
+		}
+	}
+}
+
+func (c canon) storePair(ptr uint32, data, n uint32) {
+	c.store(&ctype{kind: cU32}, ptr, reflect.ValueOf(uint64(data)))
+	c.store(&ctype{kind: cU32}, ptr+4, reflect.ValueOf(uint64(n)))
+}
+
+// lowerString copies the string into guest memory.
+func (c canon) lowerString(s string) (uint32, uint32) {
+	if len(s) == 0 {
+		return 1, 0
+	}
+	ptr := c.alloc(1, uint32(len(s)))
+	copy(c.read(ptr, uint32(len(s))), s)
+	return ptr, uint32(len(s))
+}
+
+// lowerList copies the list into guest memory.
+func (c canon) lowerList(t *ctype, list reflect.Value) (uint32, uint32) {
+	n := uint32(list.Len())
+	if n == 0 {
+		return t.elem.align(), 0
+	}
+	size := t.elem.size()
+	ptr := c.alloc(t.elem.align(), n*size)
+	if t.elem.kind == cU8 {
+		copy(c.read(ptr, n), list.Bytes())
+		return ptr, n
+	}
+	for i := range n {
+		c.store(t.elem, ptr+i*size, list.Index(int(i)))
+	}
+	return ptr, n
+}
+
+// liftFlat lifts a value of the given type from core values, returning
+// the remaining core values.
+func (c canon) liftFlat(t *ctype, values []uint64) (reflect.Value, []uint64) {
+	switch t.kind {
+	case cBool, cS8, cU8, cS16, cU16, cS32, cU32, cS64, cU64, cF32, cF64:
+		return c.scalar(t, values[0]), values[1:]
+	case cString:
+		data, n := wasm_api.DecodeU32(values[0]), wasm_api.DecodeU32(values[1])
+		return reflect.ValueOf(string(c.read(data, n))).Convert(t.rtype), values[2:]
+	case cList:
+		return c.liftList(t, wasm_api.DecodeU32(values[0]), wasm_api.DecodeU32(values[1])), values[2:]
+	case cRecord, cTuple:
+		value := reflect.New(t.rtype).Elem()
+		for _, field := range t.fields {
+			var fvalue reflect.Value
+			fvalue, values = c.liftFlat(field.ctype, values)
+			fieldOf(value, field).Set(fvalue)
+		}
+		return value, values
+	default:
+		// the payload values are always the raw bits of the value,
+		// so they do not need to be coerced from the joined types.
+		flat := len(t.flat())
+		index := int(wasm_api.DecodeU32(values[0]))
+		cases := t.casesOf()
+		if index >= len(cases) {
+			c.fail("invalid case %d for %s", index, t.rtype)
+		}
+		var payload reflect.Value
+		if cases[index].ctype != nil {
+			payload, _ = c.liftFlat(cases[index].ctype, values[1:])
+		}
+		value, err := t.makeCase(index, payload)
+		if err != nil {
+			panic(err)
+		}
+		return value, values[flat:]
+	}
+}
+
+// lowerFlat lowers a value of the given type into core values.
+func (c canon) lowerFlat(t *ctype, value reflect.Value, values []uint64) []uint64 {
+	switch t.kind {
+	case cBool, cS8, cU8, cS16, cU16, cS32, cU32, cS64, cU64, cF32, cF64:
+		return append(values, bits(t, value))
+	case cString:
+		data, n := c.lowerString(value.String())
+		return append(values, uint64(data), uint64(n))
+	case cList:
+		data, n := c.lowerList(t, value)
+		return append(values, uint64(data), uint64(n))
+	case cRecord, cTuple:
+		for _, field := range t.fields {
+			values = c.lowerFlat(field.ctype, fieldOf(value, field), values)
+		}
+		return values
+	default:
+		flat := len(t.flat())
+		index, payload := t.caseOf(value)
+		start := len(values)
+		values = append(values, uint64(index))
+		if ctype := t.casesOf()[index].ctype; ctype != nil {
+			values = c.lowerFlat(ctype, payload, values)
+		}
+		for len(values) < start+flat {
+			values = append(values, 0)
+		}
+		return values
+	}
+}
+
+// call the function with the core values on the stack.
+func (c canon) call(sig csignature, fn api.Function, stack []uint64) {
+	var args reflect.Value
+	if sig.spill {
+		args = c.load(sig.args, wasm_api.DecodeU32(stack[0]))
+	} else {
+		args, _ = c.liftFlat(sig.args, stack)
+	}
+	var argv = make([]reflect.Value, len(sig.args.fields))
+	for i, field := range sig.args.fields {
+		argv[i] = fieldOf(args, field)
+	}
+	outs, err := fn.Call(c.ctx, argv)
+	if err != nil && (sig.result == nil || sig.result.kind != cResult) {
+		panic(err)
+	}
+	if sig.result == nil {
+		return
+	}
+	var result reflect.Value
+	var value = sig.result
+	if value.kind == cResult {
+		result = reflect.New(value.rtype).Elem()
+		if err != nil {
+			result.FieldByName("Error").Set(reflect.ValueOf(err))
+		}
+		value = value.elem
+	}
+	var out reflect.Value
+	switch {
+	case value == nil || err != nil:
+	case value.kind == cTuple && len(outs) > 1:
+		out = reflect.New(value.rtype).Elem()
+		for i := range outs {
+			out.Field(i).Set(outs[i])
+		}
+	default:
+		out = outs[0]
+	}
+	if result.IsValid() {
+		if out.IsValid() {
+			result.FieldByName("Value").Set(out)
+		}
+	} else {
+		result = out
+	}
+	if sig.retptr {
+		c.store(sig.result, wasm_api.DecodeU32(stack[len(sig.params)-1]), result)
+		return
+	}
+	copy(stack, c.lowerFlat(sig.result, result, nil))
+}
+
+// export_component exports the functions of the given structure as a host module,
+// named after its WIT interface, using the canonical ABI.
+func export_component(r wazero.Runtime, pkg string, impl api.WithSpecification) error {
+	var (
+		spec      = api.StructureOf(impl)
+		types     = newTypes()
+		namespace = interfaceName(pkg, spec)
+	)
+	module := r.NewHostModuleBuilder(namespace)
+	for fn := range spec.Iter() {
+		sig, err := types.signatureOf(fn)
+		if err != nil {
+			return err
+		}
+		name := hostName(spec, fn)
+		module = module.NewFunctionBuilder().WithGoModuleFunction(wasm_api.GoModuleFunc(func(ctx context.Context, mod wasm_api.Module, stack []uint64) {
+			defer hostCall(ctx, name)()
+			canon{ctx: ctx, mod: mod}.call(sig, fn, stack)
+		}), sig.params, sig.results).Export(sig.name)
+	}
+	_, err := module.Instantiate(context.Background())
+	return err
+}
+
+// interfaceName returns the module name that guests import the structure from,
+// ie. "example:api/example@1.0.0".
+func interfaceName(pkg string, spec api.Structure) string {
+	pkg, version, versioned := strings.Cut(pkg, "@")
+	name := pkg + "/" + kebab(spec.Name)
+	if versioned {
+		name += "@" + version
+	}
+	return name
+}
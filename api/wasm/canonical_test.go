@@ -0,0 +1,214 @@
+//go:build !wasm
+
+package wasm_test
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"runtime.link/api"
+	"runtime.link/api/wasm"
+	"runtime.link/xyz"
+)
+
+type Shape xyz.Tagged[any, struct {
+	Circle xyz.Case[Shape, float64]
+	Square xyz.Case[Shape, float64]
+	Empty  Shape
+}]
+
+var Shapes = xyz.AccessorFor(Shape.Values)
+
+type Point struct {
+	X int32 `wasm:""
+		horizontal position.`
+	Y int32
+}
+
+type Geometry struct {
+	api.Specification `wasm:""
+		Geometry of 2D shapes.`
+
+	Area    func(Shape) float64
+	Scale   func(Point, int32) Point
+	Join    func([]string, string) string
+	Split   func(string) []string
+	Divide  func(a, b int32) (int32, error)
+	Origin  func() *Point
+	Largest func([]Shape) Shape `wasm:""
+		Largest returns the shape with the largest area.`
+}
+
+func area(shape Shape) float64 {
+	switch xyz.ValueOf(shape) {
+	case Shapes.Circle:
+		r := Shapes.Circle.Get(shape)
+		return math.Pi * r * r
+	case Shapes.Square:
+		return Shapes.Square.Get(shape) * Shapes.Square.Get(shape)
+	default:
+		return 0
+	}
+}
+
+// guest builds the given package for wasip1.
+func guest(t *testing.T, pkg string, flags ...string) []byte {
+	output := filepath.Join(t.TempDir(), "guest.wasm")
+	cmd := exec.Command("go", append(append([]string{"build", "-o", output}, flags...), pkg)...)
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestCanonicalABI(t *testing.T) {
+	file := guest(t, "./internal/component")
+	var stdout bytes.Buffer
+	var runner wasm.Runner
+	runner.Set(file)
+	runner.SetCanonicalABI("example:geometry")
+	runner.SetSystemInterface(wasm.SystemInterface{Stdout: &stdout, Stderr: os.Stderr})
+	runner.Add(Geometry{
+		Area: area,
+		Scale: func(p Point, k int32) Point {
+			return Point{X: p.X * k, Y: p.Y * k}
+		},
+		Join:  strings.Join,
+		Split: strings.Fields,
+		Divide: func(a, b int32) (int32, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		},
+		Origin: func() *Point { return &Point{X: 1, Y: 2} },
+		Largest: func(shapes []Shape) Shape {
+			var largest Shape
+			for _, shape := range shapes {
+				if area(shape) > area(largest) {
+					largest = shape
+				}
+			}
+			return largest
+		},
+	})
+	if err := runner.Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	const expected = `3.141592653589793 9 0
+[8 -12]
+a, bc, def
+x;y;z;
+0 3
+1 division by zero
+1 1 2
+0 2
+`
+	if stdout.String() != expected {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+}
+
+func TestCanonicalExports(t *testing.T) {
+	var runner wasm.Runner
+	runner.Set(guest(t, "./internal/reactor", "-buildmode=c-shared"))
+	runner.SetSystemInterface(wasm.SystemInterface{Stderr: os.Stderr})
+	instance, err := runner.Instantiate(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer instance.Close(t.Context())
+	geometry := api.Import[Geometry](wasm.Component, "example:geometry", instance)
+	if a := geometry.Area(Shapes.Circle.As(1)); a != math.Pi {
+		t.Fatalf("unexpected area %v", a)
+	}
+	if a := geometry.Area(Shapes.Square.As(3)); a != 9 {
+		t.Fatalf("unexpected area %v", a)
+	}
+	if p := geometry.Scale(Point{X: 2, Y: -3}, 4); p != (Point{X: 8, Y: -12}) {
+		t.Fatalf("unexpected point %v", p)
+	}
+	if s := geometry.Join([]string{"a", "bc", "def"}, ", "); s != "a, bc, def" {
+		t.Fatalf("unexpected string %q", s)
+	}
+	for range 2 { // the guest frees the results of split after they have been lifted.
+		if words := geometry.Split("x y  z"); !slices.Equal(words, []string{"x", "y", "z"}) {
+			t.Fatalf("unexpected words %q", words)
+		}
+	}
+	if q, err := geometry.Divide(7, 2); q != 3 || err != nil {
+		t.Fatal(q, err)
+	}
+	if _, err := geometry.Divide(7, 0); err == nil || err.Error() != "division by zero" {
+		t.Fatalf("expected division by zero, got %v", err)
+	}
+	if p := geometry.Origin(); p == nil || *p != (Point{X: 1, Y: 2}) {
+		t.Fatalf("unexpected origin %v", p)
+	}
+	if shape := geometry.Largest([]Shape{Shapes.Square.As(2), Shapes.Circle.As(2), Shapes.Empty}); xyz.ValueOf(shape) != Shapes.Circle || Shapes.Circle.Get(shape) != 2 {
+		t.Fatalf("unexpected shape %v", shape)
+	}
+
+	type Missing struct {
+		api.Specification
+
+		Perimeter func(Shape) (float64, error)
+	}
+	missing := api.Import[Missing](wasm.Component, "example:geometry", instance)
+	if _, err := missing.Perimeter(Shapes.Empty); !errors.Is(err, api.ErrNotImplemented) {
+		t.Fatalf("expected not implemented, got %v", err)
+	}
+}
+
+func TestWriteWIT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := wasm.WriteWIT(&buf, "example:geometry", api.StructureOf(Geometry{})); err != nil {
+		t.Fatal(err)
+	}
+	const expected = `package example:geometry;
+
+/// Geometry of 2D shapes.
+interface geometry {
+    variant shape {
+        circle(f64),
+        square(f64),
+        empty,
+    }
+
+    record point {
+        /// horizontal position.
+        x: s32,
+        y: s32,
+    }
+
+    area: func(arg1: shape) -> f64;
+    scale: func(arg1: point, arg2: s32) -> point;
+    join: func(arg1: list<string>, arg2: string) -> string;
+    split: func(arg1: string) -> list<string>;
+    divide: func(arg1: s32, arg2: s32) -> result<s32, string>;
+    origin: func() -> option<point>;
+    /// Largest returns the shape with the largest area.
+    largest: func(arg1: list<shape>) -> shape;
+}
+
+world host {
+    import geometry;
+}
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected WIT:\n%s", buf.String())
+	}
+}
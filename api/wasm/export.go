@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"slices"
+	"strings"
 	"unsafe"
 
 	"github.com/tetratelabs/wazero"
@@ -168,6 +170,7 @@ func export_api(r wazero.Runtime, child *ffi.API, impl api.WithSpecification) {
 	spec := api.StructureOf(impl)
 	module := r.NewHostModuleBuilder(spec.Name)
 	for fn := range api.StructureOf(spec).Iter() {
+		name := hostName(spec, fn)
 		var params []wasm_api.ValueType
 		for i := range fn.Type.NumIn() {
 			params = goTypeToWasmTypes(params, fn.Type.In(i))
@@ -180,10 +183,14 @@ func export_api(r wazero.Runtime, child *ffi.API, impl api.WithSpecification) {
 			results = []wasm_api.ValueType{wasm_api.ValueTypeI64} // spill to pointer
 		}
 		if fn.NumIn() == 0 && fn.NumOut() == 0 { // trivial case
-			module = module.NewFunctionBuilder().WithGoFunction(wasm_api.GoFunc(func(ctx context.Context, stack []uint64) { fn.Call(ctx, nil) }), params, results).Export(fn.Name)
+			module = module.NewFunctionBuilder().WithGoFunction(wasm_api.GoFunc(func(ctx context.Context, stack []uint64) {
+				defer hostCall(ctx, name)()
+				fn.Call(ctx, nil)
+			}), params, results).Export(fn.Name)
 			continue
 		}
 		module = module.NewFunctionBuilder().WithGoFunction(wasm_api.GoFunc(func(ctx context.Context, stack []uint64) {
+			defer hostCall(ctx, name)()
 			var args = make([]reflect.Value, fn.Type.NumIn())
 			var param = stack
 			for i := range fn.Type.NumIn() {
@@ -208,6 +215,11 @@ func export_api(r wazero.Runtime, child *ffi.API, impl api.WithSpecification) {
 	}
 }
 
+// hostName returns the name of the host function, as used to account for its calls.
+func hostName(spec api.Structure, fn api.Function) string {
+	return strings.Join(append([]string{spec.Name}, append(slices.Clone(fn.Path), fn.Name)...), ".")
+}
+
 func dynamic_link(r wazero.Runtime, child *ffi.API, impls []api.WithSpecification) {
 	module := r.NewHostModuleBuilder("runtime.link")
 	type Function struct {
@@ -0,0 +1,396 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// fuelGlobal is the name of the mutable i64 global that holds the remaining fuel
+// of a metered module.
+const fuelGlobal = "runtime.link.fuel"
+
+// startExport is the name that the start function of a metered module is
+// exported as, so that it can be called once the fuel has been set.
+const startExport = "runtime.link.start"
+
+// section identifiers, in the order that they must appear within a module.
+const (
+	sectionCustom    = 0
+	sectionType      = 1
+	sectionImport    = 2
+	sectionFunction  = 3
+	sectionTable     = 4
+	sectionMemory    = 5
+	sectionGlobal    = 6
+	sectionExport    = 7
+	sectionStart     = 8
+	sectionElement   = 9
+	sectionCode      = 10
+	sectionData      = 11
+	sectionDataCount = 12
+	sectionTag       = 13
+)
+
+var sectionOrder = []byte{
+	sectionType, sectionImport, sectionFunction, sectionTable, sectionMemory, sectionTag,
+	sectionGlobal, sectionExport, sectionStart, sectionElement, sectionDataCount, sectionCode, sectionData,
+}
+
+type section struct {
+	id   byte
+	data []byte
+}
+
+// meter rewrites the module, so that each function call and each loop iteration
+// consumes a unit of fuel from an exported i64 global (named [fuelGlobal]) and
+// traps once the fuel runs out. The remaining fuel can then be set before each
+// call into the module and checked after it returns. Any start function would
+// run during instantiation, before the fuel can be set, so it is removed from
+// the start section and exported as [startExport] instead.
+func meter(module []byte) ([]byte, error) {
+	if len(module) < 8 || string(module[:4]) != "\x00asm" {
+		return nil, errors.New("wasm: not a WebAssembly module")
+	}
+	var sections []section
+	for b := module[8:]; len(b) > 0; {
+		id := b[0]
+		size, n, err := uleb(b, 1)
+		if err != nil {
+			return nil, err
+		}
+		if n+int(size) > len(b) {
+			return nil, errors.New("wasm: truncated section")
+		}
+		sections = append(sections, section{id: id, data: b[n : n+int(size)]})
+		b = b[n+int(size):]
+	}
+	var globals uint64 // number of globals, the fuel global is added after them.
+	for _, s := range sections {
+		switch s.id {
+		case sectionImport:
+			imported, err := importedGlobals(s.data)
+			if err != nil {
+				return nil, err
+			}
+			globals += imported
+		case sectionGlobal:
+			count, _, err := uleb(s.data, 0)
+			if err != nil {
+				return nil, err
+			}
+			globals += count
+		}
+	}
+	var charge = slices.Concat(
+		[]byte{0x23}, appendULEB(nil, globals), // global.get $fuel
+		[]byte{0x42, 0x01, 0x7D, 0x24}, appendULEB(nil, globals), // i64.const 1, i64.sub, global.set $fuel
+		[]byte{0x23}, appendULEB(nil, globals), // global.get $fuel
+		[]byte{0x42, 0x00, 0x53, 0x04, 0x40, 0x00, 0x0B}, // i64.const 0, i64.lt_s, if, unreachable, end
+	)
+	// mut i64 = math.MaxInt64, so that fuel is unlimited until it is set.
+	var global = []byte{0x7E, 0x01, 0x42, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x0B}
+	var err error
+	sections, err = appendToVector(sections, sectionGlobal, global)
+	if err != nil {
+		return nil, err
+	}
+	sections, err = appendToVector(sections, sectionExport, exportOf(fuelGlobal, 0x03, globals))
+	if err != nil {
+		return nil, err
+	}
+	if i := slices.IndexFunc(sections, func(s section) bool { return s.id == sectionStart }); i >= 0 {
+		start, _, err := uleb(sections[i].data, 0)
+		if err != nil {
+			return nil, err
+		}
+		sections = slices.Delete(sections, i, i+1)
+		sections, err = appendToVector(sections, sectionExport, exportOf(startExport, 0x00, start))
+		if err != nil {
+			return nil, err
+		}
+	}
+	var out = slices.Clone(module[:8])
+	for _, s := range sections {
+		if s.id == sectionCode {
+			if s.data, err = meterCode(s.data, charge); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, s.id)
+		out = appendULEB(out, uint64(len(s.data)))
+		out = append(out, s.data...)
+	}
+	return out, nil
+}
+
+// exportOf returns an entry of the export section.
+func exportOf(name string, kind byte, index uint64) []byte {
+	export := appendULEB(nil, uint64(len(name)))
+	export = append(export, name...)
+	export = append(export, kind)
+	return appendULEB(export, index)
+}
+
+// appendToVector appends the element to the vector of the given section,
+// adding the section if the module does not have one.
+func appendToVector(sections []section, id byte, element []byte) ([]section, error) {
+	for i, s := range sections {
+		if s.id != id {
+			continue
+		}
+		count, n, err := uleb(s.data, 0)
+		if err != nil {
+			return nil, err
+		}
+		sections[i].data = slices.Concat(appendULEB(nil, count+1), s.data[n:], element)
+		return sections, nil
+	}
+	rank := slices.Index(sectionOrder, id)
+	at := len(sections)
+	for i, s := range sections {
+		if s.id != sectionCustom && slices.Index(sectionOrder, s.id) > rank {
+			at = i
+			break
+		}
+	}
+	return slices.Insert(sections, at, section{id: id, data: append([]byte{1}, element...)}), nil
+}
+
+// importedGlobals returns the number of globals imported by the import section.
+func importedGlobals(data []byte) (uint64, error) {
+	count, i, err := uleb(data, 0)
+	if err != nil {
+		return 0, err
+	}
+	var globals uint64
+	for range count {
+		for range 2 { // module and field names.
+			size, n, err := uleb(data, i)
+			if err != nil {
+				return 0, err
+			}
+			i = n + int(size)
+		}
+		if i >= len(data) {
+			return 0, errors.New("wasm: truncated import")
+		}
+		kind := data[i]
+		i++
+		switch kind {
+		case 0x00: // function
+			_, i, err = uleb(data, i)
+		case 0x01: // table
+			i, err = skipLimits(data, i+1)
+		case 0x02: // memory
+			i, err = skipLimits(data, i)
+		case 0x03: // global
+			globals++
+			i += 2
+		case 0x04: // tag
+			_, i, err = uleb(data, i+1)
+		default:
+			return 0, fmt.Errorf("wasm: unsupported import kind %#x", kind)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return globals, nil
+}
+
+func skipLimits(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, errors.New("wasm: truncated limits")
+	}
+	flags := data[i]
+	_, i, err := uleb(data, i+1)
+	if err != nil {
+		return 0, err
+	}
+	if flags&1 != 0 {
+		_, i, err = uleb(data, i)
+	}
+	return i, err
+}
+
+// meterCode inserts the charge at the start of each function body and at
+// the start of each loop.
+func meterCode(data []byte, charge []byte) ([]byte, error) {
+	count, i, err := uleb(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out = appendULEB(nil, count)
+	for range count {
+		size, n, err := uleb(data, i)
+		if err != nil {
+			return nil, err
+		}
+		if n+int(size) > len(data) {
+			return nil, errors.New("wasm: truncated function body")
+		}
+		body, err := meterBody(data[n:n+int(size)], charge)
+		if err != nil {
+			return nil, err
+		}
+		out = appendULEB(out, uint64(len(body)))
+		out = append(out, body...)
+		i = n + int(size)
+	}
+	return out, nil
+}
+
+func meterBody(body []byte, charge []byte) ([]byte, error) {
+	groups, i, err := uleb(body, 0)
+	if err != nil {
+		return nil, err
+	}
+	for range groups { // locals
+		if _, i, err = uleb(body, i); err != nil {
+			return nil, err
+		}
+		i++
+	}
+	var out = slices.Concat(body[:i], charge)
+	for i < len(body) {
+		next, err := skipInstruction(body, i)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, body[i:next]...)
+		if body[i] == 0x03 { // loop
+			out = append(out, charge...)
+		}
+		i = next
+	}
+	return out, nil
+}
+
+// skipInstruction returns the offset of the instruction that follows the one at i.
+func skipInstruction(code []byte, i int) (int, error) {
+	var (
+		op  = code[i]
+		err error
+	)
+	i++
+	skip := func(n int) {
+		for range n {
+			if err == nil {
+				_, i, err = uleb(code, i)
+			}
+		}
+	}
+	memarg := func() {
+		var align uint64
+		if align, i, err = uleb(code, i); err == nil {
+			if align&0x40 != 0 { // multiple memories
+				skip(1)
+			}
+			skip(1)
+		}
+	}
+	switch {
+	case op <= 0x01, op == 0x05, op == 0x0B, op == 0x0F, op == 0x1A, op == 0x1B, op >= 0x45 && op <= 0xC4, op == 0xD1:
+	case op >= 0x02 && op <= 0x04: // block, loop, if
+		skip(1)
+	case op == 0x0C, op == 0x0D, op == 0x10, op == 0x12, op >= 0x20 && op <= 0x26, op == 0x3F, op == 0x40,
+		op == 0x41, op == 0x42, op == 0xD0, op == 0xD2:
+		skip(1)
+	case op == 0x0E: // br_table
+		var n uint64
+		if n, i, err = uleb(code, i); err == nil {
+			skip(int(n) + 1)
+		}
+	case op == 0x11, op == 0x13: // call_indirect
+		skip(2)
+	case op == 0x1C: // select t
+		var n uint64
+		if n, i, err = uleb(code, i); err == nil {
+			i += int(n)
+		}
+	case op >= 0x28 && op <= 0x3E:
+		memarg()
+	case op == 0x43:
+		i += 4
+	case op == 0x44:
+		i += 8
+	case op == 0xFC:
+		var sub uint64
+		if sub, i, err = uleb(code, i); err != nil {
+			break
+		}
+		switch {
+		case sub <= 7:
+		case sub == 8, sub == 10, sub == 12, sub == 14:
+			skip(2)
+		case sub <= 17:
+			skip(1)
+		default:
+			err = fmt.Errorf("wasm: unsupported instruction 0xFC %d", sub)
+		}
+	case op == 0xFD:
+		var sub uint64
+		if sub, i, err = uleb(code, i); err != nil {
+			break
+		}
+		switch {
+		case sub <= 11, sub == 92, sub == 93:
+			memarg()
+		case sub == 12, sub == 13:
+			i += 16
+		case sub >= 21 && sub <= 34:
+			i++
+		case sub >= 84 && sub <= 91:
+			memarg()
+			i++
+		}
+	case op == 0xFE:
+		var sub uint64
+		if sub, i, err = uleb(code, i); err != nil {
+			break
+		}
+		if sub == 3 {
+			i++
+		} else {
+			memarg()
+		}
+	default:
+		return 0, fmt.Errorf("wasm: unsupported instruction %#x", op)
+	}
+	if err == nil && i > len(code) {
+		err = errors.New("wasm: truncated instruction")
+	}
+	return i, err
+}
+
+// uleb decodes the LEB128 integer at i, returning the offset after it.
+// Signed integers are skipped the same way.
+func uleb(b []byte, i int) (uint64, int, error) {
+	var (
+		value uint64
+		shift uint
+	)
+	for ; i < len(b); i++ {
+		value |= uint64(b[i]&0x7F) << shift
+		if b[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+		if shift += 7; shift > 63 {
+			break
+		}
+	}
+	return 0, 0, errors.New("wasm: invalid LEB128 integer")
+}
+
+func appendULEB(b []byte, value uint64) []byte {
+	for {
+		c := byte(value & 0x7F)
+		value >>= 7
+		if value != 0 {
+			b = append(b, c|0x80)
+			continue
+		}
+		return append(b, c)
+	}
+}
@@ -0,0 +1,201 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	wasm_api "github.com/tetratelabs/wazero/api"
+
+	"runtime.link/api"
+	"runtime.link/api/xray"
+)
+
+// Instance of a guest module, that remains instantiated so that the host can call
+// the functions it exports, see [Component].
+type Instance struct {
+	mutex   sync.Mutex // guests are single threaded.
+	module  wasm_api.Module
+	acct    *accounting
+	timeout time.Duration
+	metered bool
+}
+
+// Instantiate the module as a reactor, within the limits of the [SystemInterface],
+// its '_initialize' function (if any) is called, instead of '_start', so a Go guest
+// should be built with -buildmode=c-shared. The Fuel and Rates of the [SystemInterface]
+// are shared by all calls into the instance, whereas the Timeout applies to each call
+// (an interrupted call closes the instance).
+func (r *Runner) Instantiate(ctx context.Context) (*Instance, error) {
+	r.assertRuntime()
+	var inst = &Instance{
+		acct:    newAccounting(r.wasi.Rates),
+		timeout: r.wasi.Timeout,
+		metered: r.wasi.Fuel > 0,
+	}
+	ctx = context.WithValue(ctx, accountingKey{}, inst.acct)
+	var err error
+	inst.module, err = r.instantiate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{startExport, "_initialize"} {
+		if name == startExport && !inst.metered {
+			continue
+		}
+		if fn := inst.module.ExportedFunction(name); fn != nil {
+			if _, err := inst.call(ctx, fn); err != nil {
+				inst.module.Close(context.Background())
+				return nil, err
+			}
+		}
+	}
+	return inst, nil
+}
+
+// Close the instance, releasing its memory.
+func (inst *Instance) Close(ctx context.Context) error {
+	return inst.module.Close(ctx)
+}
+
+// call the exported function with the given core values.
+func (inst *Instance) call(ctx context.Context, fn wasm_api.Function, params ...uint64) ([]uint64, error) {
+	results, err := fn.Call(ctx, params...)
+	if err != nil {
+		return nil, failure(err, inst.exhausted())
+	}
+	return results, nil
+}
+
+// exhausted reports whether the guest has run out of fuel.
+func (inst *Instance) exhausted() bool {
+	fuel, ok := inst.module.ExportedGlobal(fuelGlobal).(wasm_api.MutableGlobal)
+	return inst.metered && ok && int64(fuel.Get()) < 0
+}
+
+// Component implements the [api.Linker] interface, such that the functions of a
+// structure call the functions exported by the guest instance with the canonical ABI
+// of the WebAssembly component model, as the WIT interface of the structure within
+// the given package (ie. "example:geometry"). Each function is looked up under its
+// core export name (ie. "example:geometry/geometry#area") and any 'cabi_post_'
+// function of the guest is called once the results have been lifted. Arguments are
+// lowered and results lifted the same way as for [Runner.SetCanonicalABI].
+var Component api.Linker[string, *Instance] = component{}
+
+type component struct{}
+
+// Link implements the [api.Linker] interface.
+func (component) Link(structure api.Structure, pkg string, inst *Instance) error {
+	if inst == nil {
+		return xray.New(errors.New("wasm: cannot link to a nil instance"))
+	}
+	var (
+		types     = newTypes()
+		namespace = interfaceName(pkg, structure)
+	)
+	for fn := range structure.Iter() {
+		sig, err := types.signatureOf(fn)
+		if err != nil {
+			return xray.New(fmt.Errorf("wasm: %s.%w", structure.Name, err))
+		}
+		name := namespace + "#" + sig.name
+		export := inst.module.ExportedFunction(name)
+		if export == nil {
+			fn.MakeError(fmt.Errorf("wasm: %s is not exported by the guest: %w", name, api.ErrNotImplemented))
+			continue
+		}
+		params, results := sig.params, sig.results
+		if sig.retptr { // exports return a pointer to their results, instead of receiving one.
+			params, results = params[:len(params)-1], []wasm_api.ValueType{wasm_api.ValueTypeI32}
+		}
+		def := export.Definition()
+		if !slices.Equal(def.ParamTypes(), params) || !slices.Equal(def.ResultTypes(), results) {
+			fn.MakeError(fmt.Errorf("wasm: %s does not have the core signature of its WIT function", name))
+			continue
+		}
+		post := inst.module.ExportedFunction("cabi_post_" + name)
+		fn.Make(func(ctx context.Context, args []reflect.Value) ([]reflect.Value, error) {
+			return inst.invoke(ctx, sig, fn, export, post, args)
+		})
+	}
+	return nil
+}
+
+// invoke the exported function, lowering the arguments into the guest and then
+// lifting its results back out of it.
+func (inst *Instance) invoke(ctx context.Context, sig csignature, fn api.Function, export, post wasm_api.Function, args []reflect.Value) (outs []reflect.Value, err error) {
+	inst.mutex.Lock()
+	defer inst.mutex.Unlock()
+	ctx = context.WithValue(ctx, accountingKey{}, inst.acct)
+	if inst.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, inst.timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			failed, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			outs, err = nil, failure(failed, inst.exhausted())
+		}
+	}()
+	var c = canon{ctx: ctx, mod: inst.module}
+	var tuple = reflect.New(sig.args.rtype).Elem()
+	for i, field := range sig.args.fields {
+		fieldOf(tuple, field).Set(args[i])
+	}
+	var params []uint64
+	if sig.spill {
+		ptr := c.alloc(sig.args.align(), sig.args.size())
+		c.store(sig.args, ptr, tuple)
+		params = []uint64{uint64(ptr)}
+	} else {
+		params = c.lowerFlat(sig.args, tuple, nil)
+	}
+	results, err := inst.call(ctx, export, params...)
+	if err != nil {
+		return nil, err
+	}
+	if sig.result == nil {
+		return nil, nil
+	}
+	var result reflect.Value
+	if sig.retptr {
+		result = c.load(sig.result, wasm_api.DecodeU32(results[0]))
+	} else {
+		result, _ = c.liftFlat(sig.result, results)
+	}
+	if post != nil {
+		if _, err := inst.call(ctx, post, results...); err != nil {
+			return nil, err
+		}
+	}
+	return sig.outs(fn, result)
+}
+
+// outs returns the Go results of a function from its lifted result.
+func (sig csignature) outs(fn api.Function, result reflect.Value) ([]reflect.Value, error) {
+	if sig.result.kind == cResult {
+		if err := result.FieldByName("Error").Interface(); err != nil {
+			return nil, err.(error)
+		}
+		if sig.result.elem == nil {
+			return nil, nil
+		}
+		result = result.FieldByName("Value")
+	}
+	if fn.NumOut() == 1 {
+		return []reflect.Value{result}, nil
+	}
+	var outs = make([]reflect.Value, fn.NumOut())
+	for i := range outs {
+		outs[i] = result.Field(i)
+	}
+	return outs, nil
+}
@@ -0,0 +1,100 @@
+//go:build wasip1
+
+// Package main is a guest that calls the 'example:geometry/geometry' interface through the
+// canonical ABI, without any generated bindings, each import is declared with its flattened
+// core signature.
+package main
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+//go:wasmimport example:geometry/geometry area
+func area(shape uint32, payload float64) float64
+
+//go:wasmimport example:geometry/geometry scale
+func scale(x, y, k int32, ret unsafe.Pointer)
+
+//go:wasmimport example:geometry/geometry join
+func join(list unsafe.Pointer, n uint32, sep unsafe.Pointer, sepn uint32, ret unsafe.Pointer)
+
+//go:wasmimport example:geometry/geometry split
+func split(s unsafe.Pointer, n uint32, ret unsafe.Pointer)
+
+//go:wasmimport example:geometry/geometry divide
+func divide(a, b int32, ret unsafe.Pointer)
+
+//go:wasmimport example:geometry/geometry origin
+func origin(ret unsafe.Pointer)
+
+//go:wasmimport example:geometry/geometry largest
+func largest(list unsafe.Pointer, n uint32, ret unsafe.Pointer)
+
+var allocations [][]byte
+
+//go:wasmexport cabi_realloc
+func cabi_realloc(ptr, size, align, n uint32) uint32 {
+	buf := make([]byte, n+align)
+	allocations = append(allocations, buf)
+	addr := uint32(uintptr(unsafe.Pointer(&buf[0])))
+	return (addr + align - 1) &^ (align - 1)
+}
+
+func pointer(addr uint32) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(addr))
+}
+
+func address(ptr unsafe.Pointer) uint32 {
+	return uint32(uintptr(ptr))
+}
+
+func text(addr, n uint32) string {
+	return unsafe.String((*byte)(pointer(addr)), n)
+}
+
+func main() {
+	fmt.Println(area(0, 1), area(1, 3), area(2, 0))
+
+	var point [2]int32
+	scale(2, -3, 4, unsafe.Pointer(&point))
+	fmt.Println(point)
+
+	words := []string{"a", "bc", "def"}
+	var list [][2]uint32
+	for _, word := range words {
+		list = append(list, [2]uint32{address(unsafe.Pointer(unsafe.StringData(word))), uint32(len(word))})
+	}
+	sep := ", "
+	var str [2]uint32
+	join(unsafe.Pointer(&list[0]), uint32(len(list)), unsafe.Pointer(unsafe.StringData(sep)), uint32(len(sep)), unsafe.Pointer(&str))
+	fmt.Println(text(str[0], str[1]))
+
+	s := "x y z"
+	split(unsafe.Pointer(unsafe.StringData(s)), uint32(len(s)), unsafe.Pointer(&str))
+	strs := unsafe.Slice((*[2]uint32)(pointer(str[0])), str[1])
+	for _, s := range strs {
+		fmt.Print(text(s[0], s[1]), ";")
+	}
+	fmt.Println()
+
+	var result [3]uint32
+	divide(7, 2, unsafe.Pointer(&result))
+	fmt.Println(result[0]&0xff, int32(result[1]))
+	divide(7, 0, unsafe.Pointer(&result))
+	fmt.Println(result[0]&0xff, text(result[1], result[2]))
+
+	var option [3]int32
+	origin(unsafe.Pointer(&option))
+	fmt.Println(option[0]&0xff, option[1], option[2])
+
+	type shape struct {
+		tag     uint8
+		_       [7]byte
+		payload float64
+	}
+	shapes := []shape{{tag: 1, payload: 2}, {tag: 0, payload: 2}, {tag: 2}}
+	var biggest shape
+	largest(unsafe.Pointer(&shapes[0]), uint32(len(shapes)), unsafe.Pointer(&biggest))
+	fmt.Println(biggest.tag, biggest.payload)
+}
@@ -0,0 +1,34 @@
+//go:build wasip1
+
+// Package main is a misbehaving guest, used to test the limits of a runner.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+//go:wasmimport Plugin Tick
+func tick()
+
+var counter int
+
+func main() {
+	switch os.Args[1] {
+	case "spin":
+		for {
+			counter++
+		}
+	case "tick":
+		n, _ := strconv.Atoi(os.Args[2])
+		for range n {
+			tick()
+		}
+	case "count":
+		for i := range 1000 {
+			counter += i
+		}
+	}
+	fmt.Println(counter)
+}
@@ -0,0 +1,142 @@
+//go:build wasip1
+
+// Package main is a reactor that exports the 'example:geometry/geometry' interface through the
+// canonical ABI, without any generated bindings, each export is declared with its flattened core
+// signature. It must be built with -buildmode=c-shared.
+package main
+
+import (
+	"math"
+	"strings"
+	"unsafe"
+)
+
+func main() {}
+
+var allocations [][]byte
+
+//go:wasmexport cabi_realloc
+func cabi_realloc(ptr, size, align, n uint32) uint32 {
+	buf := make([]byte, n+align)
+	allocations = append(allocations, buf)
+	addr := uint32(uintptr(unsafe.Pointer(&buf[0])))
+	return (addr + align - 1) &^ (align - 1)
+}
+
+// returned is the return area of any results that do not fit in a single core value.
+var returned [4]uint64
+
+// kept alive, until the host has lifted the results.
+var kept []any
+
+func pointer(addr uint32) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(addr))
+}
+
+func address(ptr unsafe.Pointer) uint32 {
+	return uint32(uintptr(ptr))
+}
+
+func text(addr, n uint32) string {
+	return unsafe.String((*byte)(pointer(addr)), n)
+}
+
+// lower the string into a pair, that is kept alive until the post-return.
+func lower(s string) [2]uint32 {
+	kept = append(kept, s)
+	return [2]uint32{address(unsafe.Pointer(unsafe.StringData(s))), uint32(len(s))}
+}
+
+// ret returns the return area, as the given type.
+func ret[T any]() *T {
+	returned = [4]uint64{}
+	return (*T)(unsafe.Pointer(&returned))
+}
+
+type shape struct {
+	tag     uint8
+	_       [7]byte
+	payload float64
+}
+
+//go:wasmexport example:geometry/geometry#area
+func area(tag uint32, payload float64) float64 {
+	switch tag {
+	case 0:
+		return math.Pi * payload * payload
+	case 1:
+		return payload * payload
+	default:
+		return 0
+	}
+}
+
+//go:wasmexport example:geometry/geometry#scale
+func scale(x, y, k int32) uint32 {
+	point := ret[[2]int32]()
+	point[0], point[1] = x*k, y*k
+	return address(unsafe.Pointer(point))
+}
+
+//go:wasmexport example:geometry/geometry#join
+func join(list, n, sep, sepn uint32) uint32 {
+	var words []string
+	for _, word := range unsafe.Slice((*[2]uint32)(pointer(list)), n) {
+		words = append(words, text(word[0], word[1]))
+	}
+	str := ret[[2]uint32]()
+	*str = lower(strings.Join(words, text(sep, sepn)))
+	return address(unsafe.Pointer(str))
+}
+
+//go:wasmexport example:geometry/geometry#split
+func split(s, n uint32) uint32 {
+	var list [][2]uint32
+	for _, field := range strings.Fields(text(s, n)) {
+		list = append(list, lower(field))
+	}
+	kept = append(kept, list)
+	pair := ret[[2]uint32]()
+	if len(list) > 0 {
+		*pair = [2]uint32{address(unsafe.Pointer(&list[0])), uint32(len(list))}
+	} else {
+		*pair = [2]uint32{4, 0}
+	}
+	return address(unsafe.Pointer(pair))
+}
+
+//go:wasmexport cabi_post_example:geometry/geometry#split
+func postSplit(uint32) {
+	kept, allocations = nil, nil
+}
+
+//go:wasmexport example:geometry/geometry#divide
+func divide(a, b int32) uint32 {
+	result := ret[[3]uint32]()
+	if b == 0 {
+		err := lower("division by zero")
+		*result = [3]uint32{1, err[0], err[1]}
+	} else {
+		*result = [3]uint32{0, uint32(a / b)}
+	}
+	return address(unsafe.Pointer(result))
+}
+
+//go:wasmexport example:geometry/geometry#origin
+func origin() uint32 {
+	option := ret[[3]int32]()
+	*option = [3]int32{1, 1, 2}
+	return address(unsafe.Pointer(option))
+}
+
+//go:wasmexport example:geometry/geometry#largest
+func largest(list, n uint32) uint32 {
+	biggest := ret[shape]()
+	*biggest = shape{tag: 2}
+	for _, s := range unsafe.Slice((*shape)(pointer(list)), n) {
+		if area(uint32(s.tag), s.payload) > area(uint32(biggest.tag), biggest.payload) {
+			*biggest = s
+		}
+	}
+	return address(unsafe.Pointer(biggest))
+}
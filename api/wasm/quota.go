@@ -0,0 +1,100 @@
+package wasm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	ErrFuelExhausted = errorString("wasm: fuel exhausted")
+	ErrRateLimited   = errorString("wasm: host call rate limit exceeded")
+)
+
+type errorString string
+
+func (err errorString) Error() string { return string(err) }
+
+// Rate limits the number of calls that a guest can make to a host function,
+// to Calls per duration (with bursts of up to Calls). When Per is zero, the
+// guest can make at most Calls calls during each run. Calls beyond the limit
+// trap the guest and the run fails with [ErrRateLimited].
+type Rate struct {
+	Calls int
+	Per   time.Duration
+}
+
+// Usage reports the resources consumed by a run of a guest, it is added to the
+// [xray] context of the run and is also available from [Runner.Usage].
+type Usage struct {
+	Fuel     uint64         // consumed by the guest, when [SystemInterface.Fuel] is set.
+	Time     time.Duration  // wall-clock time of the run.
+	HostTime time.Duration  // spent inside host functions.
+	Memory   int            // in bytes, linear memory only grows, so this is the peak.
+	Calls    map[string]int // number of calls to each host function.
+}
+
+// accounting for the host calls of a run.
+type accounting struct {
+	mutex   sync.Mutex
+	rates   map[string]Rate
+	buckets map[string]*bucket
+	usage   Usage
+}
+
+// bucket of tokens, each host call takes a token.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newAccounting(rates map[string]Rate) *accounting {
+	return &accounting{
+		rates:   rates,
+		buckets: make(map[string]*bucket),
+		usage:   Usage{Calls: make(map[string]int)},
+	}
+}
+
+type accountingKey struct{}
+
+// hostCall accounts for a call to the named host function, it panics (and
+// therefore traps the guest) when the rate limit of the function has been
+// exceeded. The returned function must be called once the call returns.
+func hostCall(ctx context.Context, name string) func() {
+	acct, ok := ctx.Value(accountingKey{}).(*accounting)
+	if !ok {
+		return func() {}
+	}
+	acct.mutex.Lock()
+	defer acct.mutex.Unlock()
+	acct.usage.Calls[name]++
+	if rate, ok := acct.rates[name]; ok && !acct.take(name, rate) {
+		panic(ErrRateLimited)
+	}
+	start := time.Now()
+	return func() {
+		acct.mutex.Lock()
+		defer acct.mutex.Unlock()
+		acct.usage.HostTime += time.Since(start)
+	}
+}
+
+// take a token from the bucket of the named function.
+func (acct *accounting) take(name string, rate Rate) bool {
+	now := time.Now()
+	b, ok := acct.buckets[name]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Calls), last: now}
+		acct.buckets[name] = b
+	}
+	if rate.Per > 0 {
+		b.tokens = min(float64(rate.Calls), b.tokens+float64(now.Sub(b.last))/float64(rate.Per)*float64(rate.Calls))
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
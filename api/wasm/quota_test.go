@@ -0,0 +1,101 @@
+//go:build !wasm
+
+package wasm_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"runtime.link/api"
+	"runtime.link/api/wasm"
+	"runtime.link/api/xray"
+)
+
+type Plugin struct {
+	api.Specification
+
+	Tick func()
+}
+
+// spinner is a module with a start function that loops forever.
+var spinner = []byte{
+	0x00, 0x61, 0x73, 0x6D, 0x01, 0x00, 0x00, 0x00, // magic, version
+	0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type section: func()
+	0x03, 0x02, 0x01, 0x00, // function section
+	0x08, 0x01, 0x00, // start section
+	0x0A, 0x09, 0x01, 0x07, 0x00, 0x03, 0x40, 0x0C, 0x00, 0x0B, 0x0B, // code section: loop br 0 end
+}
+
+func TestQuotas(t *testing.T) {
+	guest := filepath.Join(t.TempDir(), "plugin.wasm")
+	cmd := exec.Command("go", "build", "-o", guest, "./internal/plugin")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.ReadFile(guest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(ctx context.Context, si wasm.SystemInterface, args ...string) (string, wasm.Usage, error) {
+		var stdout strings.Builder
+		var runner wasm.Runner
+		var ticks int
+		runner.Set(file)
+		runner.Add(Plugin{Tick: func() { ticks++ }})
+		si.Args = append([]string{"plugin"}, args...)
+		si.Stdout = &stdout
+		si.Stderr = os.Stderr
+		runner.SetSystemInterface(si)
+		err := runner.Run(ctx)
+		return stdout.String(), runner.Usage(), err
+	}
+
+	ctx := xray.NewContext(t.Context())
+	out, usage, err := run(ctx, wasm.SystemInterface{Fuel: 1e9}, "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "499500\n" || usage.Fuel == 0 || usage.Fuel >= 1e9 || usage.Memory == 0 {
+		t.Fatalf("unexpected run %q %+v", out, usage)
+	}
+	if reported := xray.ContextGet[wasm.Usage](ctx); reported.Fuel != usage.Fuel {
+		t.Fatalf("unexpected xray usage %+v", reported)
+	}
+
+	_, usage, err = run(t.Context(), wasm.SystemInterface{Fuel: 1e6}, "spin")
+	if !errors.Is(err, wasm.ErrFuelExhausted) || usage.Fuel != 1e6 {
+		t.Fatalf("expected fuel to run out, got %v %+v", err, usage)
+	}
+
+	start := time.Now()
+	var runner wasm.Runner
+	runner.Set(spinner)
+	runner.SetSystemInterface(wasm.SystemInterface{Fuel: 1e6, Timeout: 10 * time.Second})
+	if err := runner.Run(t.Context()); !errors.Is(err, wasm.ErrFuelExhausted) || runner.Usage().Fuel != 1e6 {
+		t.Fatalf("expected the start function to run out of fuel, got %v %+v", err, runner.Usage())
+	}
+
+	start = time.Now()
+	_, _, err = run(t.Context(), wasm.SystemInterface{Timeout: 100 * time.Millisecond}, "spin")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 10*time.Second {
+		t.Fatalf("expected deadline to be exceeded, got %v", err)
+	}
+
+	rates := map[string]wasm.Rate{"Plugin.Tick": {Calls: 3}}
+	_, usage, err = run(t.Context(), wasm.SystemInterface{Rates: rates}, "tick", "3")
+	if err != nil || usage.Calls["Plugin.Tick"] != 3 {
+		t.Fatalf("unexpected run %v %+v", err, usage)
+	}
+	_, usage, err = run(t.Context(), wasm.SystemInterface{Rates: rates}, "tick", "5")
+	if !errors.Is(err, wasm.ErrRateLimited) || usage.Calls["Plugin.Tick"] != 4 {
+		t.Fatalf("expected rate limit to be exceeded, got %v %+v", err, usage)
+	}
+}
@@ -122,18 +122,82 @@
 // Every argument is passed in the least number of uint64 arguments that can completely contain it. uint64s
 // are not shared between arguments. If the results do not fit in a single uint64 return value, then a pointer
 // to the results is passed as the last argument.
+//
+// # Component Model
+//
+// When [Runner.SetCanonicalABI] is set to a WIT package name, APIs are instead exported with the
+// canonical ABI of the WebAssembly component model, so that guests built with standard toolchains
+// can call them. Each API is exported as a module named after its WIT interface (ie.
+// "example:geometry/geometry") and [WriteWIT] writes the WIT package that describes them.
+//
+//	type Geometry struct {
+//		api.Specification
+//
+//		Area   func(Shape) float64
+//		Divide func(a, b int32) (int32, error)
+//	}
+//
+//	interface geometry {
+//	    variant shape {
+//	        circle(f64),
+//	        square(f64),
+//	    }
+//
+//	    area: func(arg1: shape) -> f64;
+//	    divide: func(arg1: s32, arg2: s32) -> result<s32, string>;
+//	}
+//
+// Named structs are lifted and lowered as records, [xyz.Tagged] unions as variants (or enums, when
+// none of their cases have a value), slices as lists, arrays as tuples and pointers as options. Any
+// error returned by a function is lowered as the error case of a result<_, string>. Guests must
+// export 'cabi_realloc', so that strings and lists can be copied into their memory.
+//
+// In the other direction, [Runner.Instantiate] keeps a guest instantiated, so that the functions
+// it exports for an interface (ie. "example:geometry/geometry#area") can be called by the host
+// through an API imported with the [Component] linker.
+//
+//	instance, err := runner.Instantiate(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	defer instance.Close(ctx)
+//	geometry := api.Import[Geometry](wasm.Component, "example:geometry", instance)
+//	area := geometry.Area(Shapes.Circle.As(1))
+//
+// # Quotas
+//
+// Untrusted guests can be limited with the [SystemInterface] of a [Runner]. Fuel is a deterministic
+// budget, the module is rewritten so that each function call and loop iteration consumes a unit of
+// fuel, once it runs out, the guest traps and the run fails with [ErrFuelExhausted]. The Timeout (and
+// any deadline of the context passed to [Runner.Run]) interrupts the guest, wherever it is, and Rates
+// limit how often the guest can call each host function.
+//
+//	runner.SetSystemInterface(wasm.SystemInterface{
+//		Fuel:    100_000_000,
+//		Timeout: time.Second,
+//		Rates:   map[string]wasm.Rate{"Files.Open": {Calls: 10, Per: time.Second}},
+//	})
+//
+// The [Usage] of each run is added to its [xray] context.
 package wasm
 
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"math"
+	"reflect"
 	"time"
 
 	"github.com/tetratelabs/wazero"
+	wasm_api "github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
 	"runtime.link/api"
+	"runtime.link/api/xray"
 	"runtime.link/ffi"
 )
 
@@ -142,10 +206,12 @@ type Runner struct {
 	wasm    []byte
 	module  wazero.CompiledModule
 	apis    []api.WithSpecification
+	canon   string // WIT package name, when exporting with the canonical ABI.
 	dirty   bool
 	context context.Context
 	cancel  func()
 	runtime wazero.Runtime
+	usage   Usage
 }
 
 type SystemInterface struct {
@@ -161,6 +227,10 @@ type SystemInterface struct {
 	Sleep    func(time.Duration)
 	WallTime func() (int64, int32)
 	NanoTime func() int64
+
+	Fuel    uint64          // consumed by each guest function call and loop iteration, see [ErrFuelExhausted].
+	Timeout time.Duration   // for each run, in addition to any deadline of its context.
+	Rates   map[string]Rate // limits calls to host functions, by name (ie. "API.Function").
 }
 
 // Run the web assembly bytes.
@@ -200,12 +270,36 @@ func (r *Runner) SetSystemInterface(si SystemInterface) {
 
 func (r *Runner) Set(wasm []byte) { r.wasm = wasm }
 
+// SetCanonicalABI exports the APIs added to the runner with the canonical ABI of the
+// WebAssembly component model, as the interfaces of the given WIT package (ie.
+// "example:geometry@1.0.0"), see [WriteWIT]. These APIs are then no longer available
+// to the guest through the "runtime.link" dlopen and dlsym functions.
+func (r *Runner) SetCanonicalABI(pkg string) {
+	r.canon = pkg
+	r.dirty = true
+}
+
 func (r *Runner) Compile(ctx context.Context) (err error) {
 	r.assertRuntime()
-	r.module, err = r.runtime.CompileModule(ctx, r.wasm)
+	binary, err := r.binary()
+	if err != nil {
+		return err
+	}
+	r.module, err = r.runtime.CompileModule(ctx, binary)
 	return
 }
 
+// binary returns the module to compile, metered when the runner has a fuel limit.
+func (r *Runner) binary() ([]byte, error) {
+	if r.wasi.Fuel == 0 {
+		return r.wasm, nil
+	}
+	return meter(r.wasm)
+}
+
+// Usage returns the resources consumed by the last run.
+func (r *Runner) Usage() Usage { return r.usage }
+
 func (r *Runner) assertRuntime() error {
 	if r.dirty || r.runtime == nil {
 		r.context, r.cancel = context.WithCancel(context.Background())
@@ -213,7 +307,7 @@ func (r *Runner) assertRuntime() error {
 			r.runtime.Close(context.Background())
 			r.cancel()
 		}
-		config := wazero.NewRuntimeConfigCompiler()
+		config := wazero.NewRuntimeConfigCompiler().WithCloseOnContextDone(true)
 		if r.wasi.Memory > 0 {
 			config = config.WithMemoryLimitPages(uint32(r.wasi.Memory / 65536))
 		}
@@ -223,9 +317,76 @@ func (r *Runner) assertRuntime() error {
 	return nil
 }
 
+// Run the module, until its '_start' function returns (or the module exits), within the
+// limits of the [SystemInterface]. When the run is interrupted by the deadline of the
+// context (or by the Timeout), the error wraps [context.DeadlineExceeded].
 func (r *Runner) Run(ctx context.Context) error {
 	r.assertRuntime()
-	config := wazero.NewModuleConfig()
+	var acct = newAccounting(r.wasi.Rates)
+	ctx = context.WithValue(ctx, accountingKey{}, acct)
+	if r.wasi.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.wasi.Timeout)
+		defer cancel()
+	}
+	var start = time.Now()
+	module, err := r.instantiate(ctx)
+	if err != nil {
+		return err
+	}
+	defer module.Close(context.Background())
+	fuel, metered := module.ExportedGlobal(fuelGlobal).(wasm_api.MutableGlobal)
+	if fn := module.ExportedFunction(startExport); fn != nil && metered {
+		_, err = fn.Call(ctx)
+	}
+	if fn := module.ExportedFunction("_start"); fn != nil && err == nil {
+		_, err = fn.Call(ctx)
+	}
+	acct.mutex.Lock()
+	r.usage = acct.usage
+	acct.mutex.Unlock()
+	r.usage.Time = time.Since(start)
+	if memory := module.Memory(); memory != nil && !reflect.ValueOf(memory).IsNil() { // typed nil without a memory section.
+		r.usage.Memory = int(memory.Size())
+	}
+	var exhausted bool
+	if r.wasi.Fuel > 0 {
+		remaining := int64(fuel.Get())
+		if remaining < 0 {
+			exhausted, remaining = true, 0
+		}
+		r.usage.Fuel = min(r.wasi.Fuel, math.MaxInt64) - uint64(remaining)
+	}
+	xray.ContextAdd(ctx, r.usage)
+	var exit *sys.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 0 && !exhausted {
+		return nil
+	}
+	return failure(err, exhausted)
+}
+
+// failure returns the error of a guest that trapped (or exited), such that
+// interruptions wrap the error of their context.
+func failure(err error, exhausted bool) error {
+	var exit *sys.ExitError
+	switch {
+	case exhausted:
+		return xray.New(ErrFuelExhausted)
+	case errors.As(err, &exit):
+		switch exit.ExitCode() {
+		case sys.ExitCodeDeadlineExceeded:
+			return xray.New(fmt.Errorf("wasm: %w", context.DeadlineExceeded))
+		case sys.ExitCodeContextCanceled:
+			return xray.New(fmt.Errorf("wasm: %w", context.Canceled))
+		}
+	}
+	return err
+}
+
+// instantiate the module (without starting it), along with the host modules that
+// it imports, and fill up its fuel.
+func (r *Runner) instantiate(ctx context.Context) (wasm_api.Module, error) {
+	config := wazero.NewModuleConfig().WithStartFunctions()
 	if r.wasi.Stdout != nil {
 		config = config.WithStdout(r.wasi.Stdout)
 	}
@@ -268,17 +429,41 @@ func (r *Runner) Run(ctx context.Context) error {
 	import_api(r.runtime, &child, &child)
 	export_api(r.runtime, &child, ffi_api)
 	for _, impl := range r.apis {
+		if r.canon != "" {
+			if err := export_component(r.runtime, r.canon, impl); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		export_api(r.runtime, &child, impl)
 	}
-	dynamic_link(r.runtime, &child, append(r.apis, ffi_api))
+	var linkable = []api.WithSpecification{ffi_api}
+	if r.canon == "" {
+		linkable = append(r.apis, ffi_api)
+	}
+	dynamic_link(r.runtime, &child, linkable)
+	var module wasm_api.Module
 	if r.module == nil {
-		if _, err := r.runtime.InstantiateWithConfig(ctx, r.wasm, config); err != nil {
-			return err
+		binary, err := r.binary()
+		if err != nil {
+			return nil, err
+		}
+		if module, err = r.runtime.InstantiateWithConfig(ctx, binary, config); err != nil {
+			return nil, err
 		}
 	} else {
-		if _, err := r.runtime.InstantiateModule(ctx, r.module, config); err != nil {
-			return err
+		var err error
+		if module, err = r.runtime.InstantiateModule(ctx, r.module, config); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	fuel, metered := module.ExportedGlobal(fuelGlobal).(wasm_api.MutableGlobal)
+	if r.wasi.Fuel > 0 {
+		if !metered {
+			module.Close(context.Background())
+			return nil, xray.New(errors.New("wasm: module was compiled without a fuel limit"))
+		}
+		fuel.Set(uint64(min(r.wasi.Fuel, math.MaxInt64)))
+	}
+	return module, nil
 }
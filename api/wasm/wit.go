@@ -0,0 +1,199 @@
+package wasm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"runtime.link/api"
+)
+
+// WriteWIT writes a WIT package with an interface for each of the given
+// structures, along with a 'host' world that imports all of them, so that
+// standard guest toolchains can generate bindings for the functions that a
+// [Runner] exports with [Runner.SetCanonicalABI]. Records are written for
+// named structs, variants (or enums) for [xyz.Tagged] unions, options for
+// pointers and functions that return an error, return a result<_, string>.
+func WriteWIT(w io.Writer, pkg string, structures ...api.Structure) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s;\n", pkg)
+	var names []string
+	for _, structure := range structures {
+		var (
+			types = newTypes()
+			sigs  []csignature
+		)
+		for fn := range structure.Iter() {
+			sig, err := types.signatureOf(fn)
+			if err != nil {
+				return fmt.Errorf("wasm: %s.%w", structure.Name, err)
+			}
+			sigs = append(sigs, sig)
+		}
+		name := witIdentifier(kebab(structure.Name))
+		names = append(names, name)
+		b.WriteString("\n")
+		writeDocs(&b, "", structure.Docs)
+		fmt.Fprintf(&b, "interface %s {\n", name)
+		for _, t := range types.order {
+			writeTypeDefinition(&b, t)
+		}
+		for _, sig := range sigs {
+			writeDocs(&b, "    ", sig.docs)
+			fmt.Fprintf(&b, "    %s: func(", witIdentifier(sig.name))
+			for i, field := range sig.args.fields {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				fmt.Fprintf(&b, "%s: %s", field.name, witType(field.ctype))
+			}
+			b.WriteString(")")
+			if sig.result != nil {
+				fmt.Fprintf(&b, " -> %s", witType(sig.result))
+			}
+			b.WriteString(";\n")
+		}
+		b.WriteString("}\n")
+	}
+	b.WriteString("\nworld host {\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "    import %s;\n", name)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTypeDefinition writes the WIT definition of a named type.
+func writeTypeDefinition(b *strings.Builder, t *ctype) {
+	switch t.kind {
+	case cRecord:
+		fmt.Fprintf(b, "    record %s {\n", witIdentifier(t.name))
+		for _, field := range t.fields {
+			writeDocs(b, "        ", field.docs)
+			fmt.Fprintf(b, "        %s: %s,\n", witIdentifier(field.name), witType(field.ctype))
+		}
+	case cEnum:
+		fmt.Fprintf(b, "    enum %s {\n", witIdentifier(t.name))
+		for _, c := range t.cases {
+			fmt.Fprintf(b, "        %s,\n", witIdentifier(c.name))
+		}
+	case cVariant:
+		fmt.Fprintf(b, "    variant %s {\n", witIdentifier(t.name))
+		for _, c := range t.cases {
+			if c.ctype == nil {
+				fmt.Fprintf(b, "        %s,\n", witIdentifier(c.name))
+				continue
+			}
+			fmt.Fprintf(b, "        %s(%s),\n", witIdentifier(c.name), witType(c.ctype))
+		}
+	}
+	b.WriteString("    }\n\n")
+}
+
+// witType returns the WIT representation of the given type.
+func witType(t *ctype) string {
+	switch t.kind {
+	case cBool:
+		return "bool"
+	case cS8:
+		return "s8"
+	case cS16:
+		return "s16"
+	case cS32:
+		return "s32"
+	case cS64:
+		return "s64"
+	case cU8:
+		return "u8"
+	case cU16:
+		return "u16"
+	case cU32:
+		return "u32"
+	case cU64:
+		return "u64"
+	case cF32:
+		return "f32"
+	case cF64:
+		return "f64"
+	case cString:
+		return "string"
+	case cList:
+		return "list<" + witType(t.elem) + ">"
+	case cOption:
+		return "option<" + witType(t.elem) + ">"
+	case cResult:
+		if t.elem == nil {
+			return "result<_, string>"
+		}
+		return "result<" + witType(t.elem) + ", string>"
+	case cTuple:
+		var elems = make([]string, len(t.fields))
+		for i, field := range t.fields {
+			elems[i] = witType(field.ctype)
+		}
+		return "tuple<" + strings.Join(elems, ", ") + ">"
+	default:
+		return witIdentifier(t.name)
+	}
+}
+
+// witKeywords cannot be used as identifiers, unless they are escaped with '%'.
+var witKeywords = map[string]bool{
+	"as": true, "async": true, "bool": true, "borrow": true, "char": true, "constructor": true,
+	"enum": true, "export": true, "f32": true, "f64": true, "flags": true, "from": true,
+	"func": true, "future": true, "import": true, "include": true, "interface": true,
+	"list": true, "option": true, "own": true, "package": true, "record": true,
+	"resource": true, "result": true, "s16": true, "s32": true, "s64": true, "s8": true,
+	"static": true, "stream": true, "string": true, "tuple": true, "type": true,
+	"u16": true, "u32": true, "u64": true, "u8": true, "use": true, "variant": true,
+	"with": true, "world": true,
+}
+
+func witIdentifier(name string) string {
+	if witKeywords[name] {
+		return "%" + name
+	}
+	return name
+}
+
+// kebab converts a Go identifier to a WIT identifier, ie. UserID becomes
+// user-id. Digits cannot start a word, so they stay with the previous one.
+func kebab(name string) string {
+	var runes = []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case r == '_':
+			if b.Len() > 0 && i+1 < len(runes) && runes[i+1] != '_' {
+				b.WriteByte('-')
+			}
+			continue
+		case unicode.IsUpper(r):
+			if i > 0 && runes[i-1] != '_' && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// writeDocs writes the given documentation as a WIT doc comment.
+func writeDocs(b *strings.Builder, indent, docs string) {
+	docs = strings.TrimSpace(docs)
+	if docs == "" {
+		return
+	}
+	for _, line := range strings.Split(docs, "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			b.WriteString(indent + "///\n")
+			continue
+		}
+		b.WriteString(indent + "/// " + line + "\n")
+	}
+}
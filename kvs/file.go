@@ -0,0 +1,382 @@
+package kvs
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"runtime.link/api/xray"
+)
+
+// Committer may be implemented by a [Database] that can apply multiple
+// changes atomically, such that either all of them, or none of them
+// are persisted.
+type Committer interface {
+	Commit(ctx context.Context, fmt Format, insert map[any]any, delete ...any) error
+}
+
+// File is a durable [Database] stored within a single append-only log file,
+// each commit is appended as a single line of JSON and synced to disk before
+// it returns. The log is compacted whenever it grows to be more than twice
+// the size of the records it holds. Keys and values are encoded as JSON and
+// each [Format] has its own independent set of keys. Values are iterated in
+// key order (numeric for numbers, lexical for anything else).
+type File struct {
+	mutex sync.RWMutex
+	path  string
+	file  *os.File
+	size  int64                        // size of the log, in bytes.
+	live  int64                        // size of the records, in bytes.
+	data  map[Format]map[string]record // keyed by the JSON encoded key.
+}
+
+// record in the log, a nil Value indicates a deletion.
+type record struct {
+	Format Format          `json:"f"`
+	Key    json.RawMessage `json:"k"`
+	Value  json.RawMessage `json:"v,omitempty"`
+}
+
+// compactionThreshold is the minimum size of the log, before it will be
+// compacted.
+const compactionThreshold = 1 << 20
+
+// OpenFile opens (or creates) the file-backed [Database] at the given path.
+// If the last commit in the file was only partially written, it is discarded.
+func OpenFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, xray.New(err)
+	}
+	db := &File{
+		path: path,
+		file: file,
+		data: make(map[Format]map[string]record),
+	}
+	if err := db.load(); err != nil {
+		file.Close()
+		return nil, xray.New(err)
+	}
+	if db.size > compactionThreshold && db.size > 2*db.live {
+		if err := db.compact(); err != nil {
+			file.Close()
+			return nil, xray.New(err)
+		}
+	}
+	return db, nil
+}
+
+// load replays the log, truncating any trailing partial commit.
+func (db *File) load() error {
+	reader := bufio.NewReader(db.file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 { // partially written commit.
+				if err := db.file.Truncate(offset); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var records []record
+		if err := json.Unmarshal(line, &records); err != nil {
+			if _, peek := reader.Peek(1); errors.Is(peek, io.EOF) {
+				if err := db.file.Truncate(offset); err != nil {
+					return err
+				}
+				break
+			}
+			return xray.New(err)
+		}
+		offset += int64(len(line))
+		db.apply(records)
+	}
+	db.size = offset
+	_, err := db.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// apply the records to the in-memory state of the database.
+func (db *File) apply(records []record) {
+	for _, rec := range records {
+		keys := db.data[rec.Format]
+		if keys == nil {
+			keys = make(map[string]record)
+			db.data[rec.Format] = keys
+		}
+		if old, ok := keys[string(rec.Key)]; ok {
+			db.live -= old.size()
+			delete(keys, string(rec.Key))
+		}
+		if rec.Value != nil {
+			keys[string(rec.Key)] = rec
+			db.live += rec.size()
+		}
+	}
+}
+
+func (rec record) size() int64 {
+	return int64(len(rec.Format) + len(rec.Key) + len(rec.Value) + len(`{"f":"","k":,"v":},`))
+}
+
+// Close the file.
+func (db *File) Close() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.file.Close()
+}
+
+// Lookup implements [Database].
+func (db *File) Lookup(ctx context.Context, fmt Format, key, val any) (bool, error) {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return false, xray.New(err)
+	}
+	db.mutex.RLock()
+	rec, ok := db.data[fmt][string(k)]
+	db.mutex.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(rec.Value, val); err != nil {
+		return false, xray.New(err)
+	}
+	return true, nil
+}
+
+// Insert implements [Database].
+func (db *File) Insert(ctx context.Context, fmt Format, key, val any) error {
+	return db.Commit(ctx, fmt, map[any]any{key: val})
+}
+
+// Delete implements [Database].
+func (db *File) Delete(ctx context.Context, fmt Format, key any) error {
+	return db.Commit(ctx, fmt, nil, key)
+}
+
+// Commit implements [Committer], the changes are written to the log as a
+// single line, so they are either recovered together or not at all.
+func (db *File) Commit(ctx context.Context, fmt Format, insert map[any]any, delete ...any) error {
+	if err := ctx.Err(); err != nil {
+		return xray.New(err)
+	}
+	records := make([]record, 0, len(insert)+len(delete))
+	for key, val := range insert {
+		k, err := json.Marshal(key)
+		if err != nil {
+			return xray.New(err)
+		}
+		v, err := json.Marshal(val)
+		if err != nil {
+			return xray.New(err)
+		}
+		records = append(records, record{Format: fmt, Key: k, Value: v})
+	}
+	for _, key := range delete {
+		k, err := json.Marshal(key)
+		if err != nil {
+			return xray.New(err)
+		}
+		records = append(records, record{Format: fmt, Key: k})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(records)
+	if err != nil {
+		return xray.New(err)
+	}
+	line = append(line, '\n')
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if err := db.append(line); err != nil {
+		db.file.Truncate(db.size)
+		db.file.Seek(db.size, io.SeekStart)
+		return xray.New(err)
+	}
+	db.size += int64(len(line))
+	db.apply(records)
+	if db.size > compactionThreshold && db.size > 2*db.live {
+		if err := db.compact(); err != nil {
+			return xray.New(err)
+		}
+	}
+	return nil
+}
+
+func (db *File) append(line []byte) error {
+	if _, err := db.file.Write(line); err != nil {
+		return err
+	}
+	return db.file.Sync()
+}
+
+// Compact rewrites the log, so that it only contains the current records.
+func (db *File) Compact() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.compact()
+}
+
+func (db *File) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(db.path), filepath.Base(db.path)+".*")
+	if err != nil {
+		return xray.New(err)
+	}
+	defer os.Remove(tmp.Name())
+	if info, err := db.file.Stat(); err == nil {
+		tmp.Chmod(info.Mode())
+	}
+	w := bufio.NewWriter(tmp)
+	var size int64
+	for _, keys := range db.data {
+		for _, rec := range keys {
+			line, err := json.Marshal([]record{rec})
+			if err != nil {
+				tmp.Close()
+				return xray.New(err)
+			}
+			line = append(line, '\n')
+			if _, err := w.Write(line); err != nil {
+				tmp.Close()
+				return xray.New(err)
+			}
+			size += int64(len(line))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return xray.New(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return xray.New(err)
+	}
+	if err := os.Rename(tmp.Name(), db.path); err != nil {
+		tmp.Close()
+		return xray.New(err)
+	}
+	if dir, err := os.Open(filepath.Dir(db.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	db.file.Close()
+	db.file = tmp
+	db.size = size
+	return nil
+}
+
+// Values implements [Database], see [File] for the order that values are
+// iterated in. The Prefix of the filter (if non-zero) matches string keys
+// that start with it, or else keys that are equal to it. The Cursor (if
+// non-zero) skips to the values after that key.
+func (db *File) Values(ctx context.Context, issue *error, fmt Format, filter Filter[any]) iter.Seq[func(any, any)] {
+	return func(yield func(func(any, any)) bool) {
+		var (
+			prefix, cursor []byte
+			exact          bool // prefix must match the whole key.
+		)
+		if filter.Prefix != nil && !reflect.ValueOf(filter.Prefix).IsZero() {
+			p, err := json.Marshal(filter.Prefix)
+			if err != nil {
+				*issue = xray.New(err)
+				return
+			}
+			if reflect.TypeOf(filter.Prefix).Kind() == reflect.String {
+				p = p[:len(p)-1] // allow the string to continue.
+			} else {
+				exact = true
+			}
+			prefix = p
+		}
+		if filter.Cursor != nil && !reflect.ValueOf(filter.Cursor).IsZero() {
+			c, err := json.Marshal(filter.Cursor)
+			if err != nil {
+				*issue = xray.New(err)
+				return
+			}
+			cursor = c
+		}
+		db.mutex.RLock()
+		records := make([]record, 0, len(db.data[fmt]))
+		for _, rec := range db.data[fmt] {
+			if prefix != nil && !bytes.HasPrefix(rec.Key, prefix) {
+				continue
+			}
+			if exact && !bytes.Equal(rec.Key, prefix) {
+				continue
+			}
+			if cursor != nil && compareKeys(rec.Key, cursor) <= 0 {
+				continue
+			}
+			records = append(records, rec)
+		}
+		db.mutex.RUnlock()
+		slices.SortFunc(records, func(a, b record) int {
+			return compareKeys(a.Key, b.Key)
+		})
+		records = records[min(max(filter.Offset, 0), len(records)):]
+		for _, rec := range records {
+			if err := ctx.Err(); err != nil {
+				*issue = xray.New(err)
+				return
+			}
+			load := func(key, val any) {
+				if err := json.Unmarshal(rec.Key, key); err != nil {
+					*issue = xray.New(err)
+				}
+				if err := json.Unmarshal(rec.Value, val); err != nil {
+					*issue = xray.New(err)
+				}
+			}
+			if !yield(load) {
+				return
+			}
+		}
+	}
+}
+
+// numberOf parses a JSON number, with enough precision to represent any
+// 64bit integer exactly.
+func numberOf(key json.RawMessage) (*big.Float, bool) {
+	f, _, err := big.ParseFloat(string(key), 10, 128, big.ToNearestEven)
+	return f, err == nil
+}
+
+// compareKeys compares two JSON encoded keys, numbers are compared numerically
+// (integers exactly) and strings are compared lexically.
+func compareKeys(a, b json.RawMessage) int {
+	if x, err := strconv.ParseInt(string(a), 10, 64); err == nil {
+		if y, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			return cmp.Compare(x, y)
+		}
+	}
+	if x, ok := numberOf(a); ok {
+		if y, ok := numberOf(b); ok {
+			return x.Cmp(y)
+		}
+	}
+	var x, y string
+	if json.Unmarshal(a, &x) == nil && json.Unmarshal(b, &y) == nil {
+		return strings.Compare(x, y)
+	}
+	return bytes.Compare(a, b)
+}
@@ -0,0 +1,196 @@
+package kvs_test
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"runtime.link/kvs"
+)
+
+type Schema struct {
+	Names kvs.Map[string, string] `kvs:"names"`
+	Ages  kvs.Map[int, int]       `kvs:"ages"`
+}
+
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data.kvs")
+
+	file, err := kvs.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := kvs.Open[Schema](file)
+	if err := db.Names.Commit(ctx, map[string]string{
+		"user/alice": "Alice",
+		"user/bob":   "Bob",
+		"admin/tom":  "Tom",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, age := range []int{10, 2, 33} {
+		if err := db.Ages.Set(ctx, age, age*2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Names.Del(ctx, "user/bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash during a commit.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`[{"f":"names","k":"user/eve","v":"Ev`)
+	f.Close()
+
+	file, err = kvs.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	db = kvs.Open[Schema](file)
+
+	if name, ok, err := db.Names.Lookup(ctx, "user/alice"); err != nil || !ok || name != "Alice" {
+		t.Fatal("unexpected lookup", name, ok, err)
+	}
+	if _, ok, err := db.Names.Lookup(ctx, "user/bob"); err != nil || ok {
+		t.Fatal("expected bob to be deleted", ok, err)
+	}
+	if _, ok, err := db.Names.Lookup(ctx, "user/eve"); err != nil || ok {
+		t.Fatal("expected partial commit to be discarded", ok, err)
+	}
+	var names []string
+	for key := range db.Names.Values(ctx, &err, kvs.Filter[string]{Prefix: "user/"}) {
+		names = append(names, key)
+	}
+	if err != nil || !slices.Equal(names, []string{"user/alice"}) {
+		t.Fatal("unexpected prefix results", names, err)
+	}
+	var ages []int
+	for key := range db.Ages.All(ctx, &err) {
+		ages = append(ages, key)
+	}
+	if err != nil || !slices.Equal(ages, []int{2, 10, 33}) {
+		t.Fatal("unexpected order", ages, err)
+	}
+	ages = nil
+	for key := range db.Ages.Values(ctx, &err, kvs.Filter[int]{Cursor: 2, Offset: 1}) {
+		ages = append(ages, key)
+	}
+	if err != nil || !slices.Equal(ages, []int{33}) {
+		t.Fatal("unexpected cursor results", ages, err)
+	}
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Fatal("expected compaction to shrink the log", before.Size(), after.Size())
+	}
+	if err := db.Ages.Set(ctx, 4, 8); err != nil {
+		t.Fatal(err)
+	}
+	if age, err := db.Ages.Get(ctx, 4); err != nil || age != 8 {
+		t.Fatal("unexpected age after compaction", age, err)
+	}
+	reopened, err := kvs.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if age, err := kvs.Open[Schema](reopened).Ages.Get(ctx, 4); err != nil || age != 8 {
+		t.Fatal("unexpected age after reopening", age, err)
+	}
+}
+
+func TestFileLargeKeys(t *testing.T) {
+	type Large struct {
+		Signed   kvs.Map[int64, bool]  `kvs:"signed"`
+		Unsigned kvs.Map[uint64, bool] `kvs:"unsigned"`
+	}
+	ctx := context.Background()
+	file, err := kvs.OpenFile(filepath.Join(t.TempDir(), "data.kvs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	db := kvs.Open[Large](file)
+	const big = 1 << 53
+	signed := []int64{big + 2, -big - 1, big, big + 1, -big}
+	for _, key := range signed {
+		if err := db.Signed.Set(ctx, key, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var keys []int64
+	for key := range db.Signed.All(ctx, &err) {
+		keys = append(keys, key)
+	}
+	slices.Sort(signed)
+	if err != nil || !slices.Equal(keys, signed) {
+		t.Fatal("unexpected order", keys, err)
+	}
+	keys = nil
+	for key := range db.Signed.Values(ctx, &err, kvs.Filter[int64]{Cursor: big}) {
+		keys = append(keys, key)
+	}
+	if err != nil || !slices.Equal(keys, []int64{big + 1, big + 2}) {
+		t.Fatal("unexpected cursor results", keys, err)
+	}
+	unsigned := []uint64{math.MaxUint64, math.MaxUint64 - 1, big + 1}
+	for _, key := range unsigned {
+		if err := db.Unsigned.Set(ctx, key, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ukeys []uint64
+	for key := range db.Unsigned.Values(ctx, &err, kvs.Filter[uint64]{Cursor: big + 1}) {
+		ukeys = append(ukeys, key)
+	}
+	if err != nil || !slices.Equal(ukeys, []uint64{math.MaxUint64 - 1, math.MaxUint64}) {
+		t.Fatal("unexpected unsigned cursor results", ukeys, err)
+	}
+}
+
+func TestFileUntagged(t *testing.T) {
+	type Untagged struct {
+		Settings kvs.Map[string, string]
+	}
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data.kvs")
+	file, err := kvs.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kvs.Open[Untagged](file).Settings.Set(ctx, "theme", "dark"); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err = kvs.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if theme, err := kvs.Open[Untagged](file).Settings.Get(ctx, "theme"); err != nil || theme != "dark" {
+		t.Fatalf("got %q %v, want the untagged map to keep its empty format", theme, err)
+	}
+}
@@ -21,6 +21,17 @@ func (m *Map[K, V]) open(db Database, format Format) {
 			return val, ok, err
 		},
 		Commit: func(ctx context.Context, insert map[K]V, delete ...K) error {
+			if committer, ok := db.(Committer); ok {
+				inserts := make(map[any]any, len(insert))
+				for key, val := range insert {
+					inserts[key] = val
+				}
+				deletes := make([]any, len(delete))
+				for i, key := range delete {
+					deletes[i] = key
+				}
+				return committer.Commit(ctx, format, inserts, deletes...)
+			}
 			for key, val := range insert {
 				if err := db.Insert(ctx, format, key, val); err != nil {
 					return err
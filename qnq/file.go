@@ -0,0 +1,626 @@
+package qnq
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"runtime.link/api/xray"
+)
+
+// Dir is a durable [Channels] implementation stored within a directory. Each
+// [Topic] has its own sub-directory of append-only segment files, where each
+// message is encoded as a single line of JSON that is synced to disk before
+// [Dir.Send] returns. Segments are removed once every subscription to the
+// topic has acknowledged all of their messages.
+//
+// Each subscription stores the offset of its first unacknowledged message
+// (along with any acknowledgements beyond it), so that messages that were not
+// acknowledged are redelivered after a restart. Messages that fail are
+// redelivered with an exponential backoff, until they have failed MaxAttempts
+// times, at which point they are moved to the [DeadLetters] topic of the
+// subscription. A subscription is created by its first [Dir.Recv], starting
+// from the oldest message still held by the topic (so a topic without any
+// subscriptions holds onto all of its messages, ie. a dead-letter topic). To
+// remove a subscription, so that it no longer holds onto messages, delete its
+// '.sub' file while the directory is not open.
+//
+// A directory should only be opened by a single process at a time and each
+// subscription can only be received from once at a time, the channel returned
+// by any concurrent [Dir.Recv] for the same subscription will be closed.
+type Dir struct {
+	DirOptions
+
+	mutex  sync.Mutex
+	path   string
+	topics map[Topic]*topicLog
+	closed chan struct{}
+	group  sync.WaitGroup // of deliveries.
+}
+
+// DirOptions for a [Dir], zero values are replaced with their defaults.
+type DirOptions struct {
+	SegmentSize int64         // in bytes, before a new segment file is started (defaults to 64MB).
+	MaxAttempts int           // failed deliveries, before a message is dead-lettered (defaults to 5).
+	Backoff     time.Duration // before the first redelivery, doubled for each failure (defaults to 1s).
+	MaxBackoff  time.Duration // maximum delay between redeliveries (defaults to 1m).
+}
+
+// DeadLetters returns the topic that messages are moved to, after they have
+// failed too many times for the given subscription.
+func DeadLetters(topic Topic, subscription string) Topic {
+	return topic + "." + Topic(subscription) + ".dead"
+}
+
+// OpenDir opens (or creates) the file-backed [Channels] at the given path.
+func OpenDir(path string, options DirOptions) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, xray.New(err)
+	}
+	if options.SegmentSize <= 0 {
+		options.SegmentSize = 64 << 20
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 5
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = time.Second
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = time.Minute
+	}
+	return &Dir{
+		DirOptions: options,
+		path:       path,
+		topics:     make(map[Topic]*topicLog),
+		closed:     make(chan struct{}),
+	}, nil
+}
+
+// Close the directory, closing any channels returned by [Dir.Recv], once
+// their deliveries have stopped.
+func (d *Dir) Close() error {
+	d.mutex.Lock()
+	select {
+	case <-d.closed:
+	default:
+		close(d.closed)
+	}
+	d.mutex.Unlock()
+	d.group.Wait()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var errs []error
+	for name, t := range d.topics {
+		t.mutex.Lock()
+		errs = append(errs, t.file.Close())
+		t.mutex.Unlock()
+		delete(d.topics, name)
+	}
+	return xray.New(errors.Join(errs...))
+}
+
+// Send implements [Channels], the message is encoded as JSON.
+func (d *Dir) Send(ctx context.Context, topic Topic, val any) error {
+	if err := ctx.Err(); err != nil {
+		return xray.New(err)
+	}
+	line, err := json.Marshal(val)
+	if err != nil {
+		return xray.New(err)
+	}
+	t, err := d.topic(topic)
+	if err != nil {
+		return xray.New(err)
+	}
+	return t.append(append(line, '\n'))
+}
+
+// Recv implements [Channels], messages are delivered in order, except for
+// redeliveries, which are delivered as soon as their backoff has passed. The
+// channel is closed when the context is done (or if the topic cannot be read).
+func (d *Dir) Recv(ctx context.Context, topic Topic, subscription string) <-chan func(any) (func(error), error) {
+	out := make(chan func(any) (func(error), error))
+	d.mutex.Lock()
+	select {
+	case <-d.closed:
+		d.mutex.Unlock()
+		close(out)
+		return out
+	default:
+		d.group.Add(1)
+	}
+	d.mutex.Unlock()
+	t, err := d.topic(topic)
+	if err != nil {
+		d.group.Done()
+		close(out)
+		return out
+	}
+	s, err := t.subscribe(subscription)
+	if err != nil {
+		d.group.Done()
+		close(out)
+		return out
+	}
+	go d.deliver(ctx, t, s, out)
+	return out
+}
+
+func (d *Dir) topic(name Topic) (*topicLog, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if t, ok := d.topics[name]; ok {
+		return t, nil
+	}
+	if name == "" {
+		return nil, errors.New("qnq: empty topic name")
+	}
+	t, err := openTopic(name, filepath.Join(d.path, topicFile(name)), d.SegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	d.topics[name] = t
+	return t, nil
+}
+
+// topicFile returns the escaped file name of the topic's directory, such
+// that it always refers to a child of the [Dir]. [url.PathEscape] leaves
+// dots alone, so names made up entirely of dots (ie. "..") are escaped too.
+func topicFile(name Topic) string {
+	escaped := url.PathEscape(string(name))
+	if strings.Trim(escaped, ".") == "" {
+		return strings.Repeat("%2E", len(escaped))
+	}
+	return escaped
+}
+
+// topicLog is the segmented log of a topic, where each message is numbered
+// by its offset within the log.
+type topicLog struct {
+	mutex     sync.Mutex
+	name      Topic
+	dir       string
+	limit     int64            // size of each segment.
+	segments  []int64          // offset of the first message in each segment.
+	file      *os.File         // last segment, that messages are appended to.
+	size      int64            // of the last segment.
+	end       int64            // offset of the next message.
+	signal    chan struct{}    // closed whenever a message is appended.
+	committed map[string]int64 // offsets of every subscription.
+	open      map[string]bool  // subscriptions currently being received.
+}
+
+func segmentName(first int64) string { return fmt.Sprintf("%020d.log", first) }
+
+func openTopic(name Topic, dir string, limit int64) (*topicLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	t := &topicLog{
+		name:      name,
+		dir:       dir,
+		limit:     limit,
+		signal:    make(chan struct{}),
+		committed: make(map[string]int64),
+		open:      make(map[string]bool),
+	}
+	for _, entry := range entries {
+		switch file := entry.Name(); {
+		case strings.HasSuffix(file, ".log"):
+			first, err := strconv.ParseInt(strings.TrimSuffix(file, ".log"), 10, 64)
+			if err != nil {
+				continue
+			}
+			t.segments = append(t.segments, first)
+		case strings.HasSuffix(file, ".sub"):
+			subscription, err := url.PathUnescape(strings.TrimSuffix(file, ".sub"))
+			if err != nil {
+				continue
+			}
+			var state subscriptionState
+			if err := loadState(filepath.Join(dir, file), &state); err != nil {
+				return nil, err
+			}
+			t.committed[subscription] = state.Offset
+		}
+	}
+	slices.Sort(t.segments)
+	if len(t.segments) == 0 {
+		t.segments = []int64{0}
+	}
+	last := t.segments[len(t.segments)-1]
+	t.file, err = os.OpenFile(filepath.Join(dir, segmentName(last)), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(t.file)
+	var lines int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 { // partially written message.
+				if err := t.file.Truncate(t.size); err != nil {
+					t.file.Close()
+					return nil, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			t.file.Close()
+			return nil, err
+		}
+		t.size += int64(len(line))
+		lines++
+	}
+	if _, err := t.file.Seek(t.size, io.SeekStart); err != nil {
+		t.file.Close()
+		return nil, err
+	}
+	t.end = last + lines
+	return t, nil
+}
+
+// append the line to the log, starting a new segment if the current one is full.
+func (t *topicLog) append(line []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.size > 0 && t.size+int64(len(line)) > t.limit {
+		file, err := os.OpenFile(filepath.Join(t.dir, segmentName(t.end)), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return xray.New(err)
+		}
+		t.file.Close()
+		t.file, t.size = file, 0
+		t.segments = append(t.segments, t.end)
+		syncDir(t.dir)
+	}
+	if _, err := t.file.Write(line); err != nil {
+		t.file.Truncate(t.size)
+		t.file.Seek(t.size, io.SeekStart)
+		return xray.New(err)
+	}
+	if err := t.file.Sync(); err != nil {
+		t.file.Truncate(t.size)
+		t.file.Seek(t.size, io.SeekStart)
+		return xray.New(err)
+	}
+	t.size += int64(len(line))
+	t.end++
+	close(t.signal)
+	t.signal = make(chan struct{})
+	return nil
+}
+
+// changed returns a channel that is closed when the next message is appended,
+// along with the offset of that message.
+func (t *topicLog) changed() (<-chan struct{}, int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.signal, t.end
+}
+
+// segment returns the path of the segment that holds the message at the given offset,
+// along with the offset of its first message.
+func (t *topicLog) segment(offset int64) (string, int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	i, found := slices.BinarySearch(t.segments, offset)
+	if !found {
+		i--
+	}
+	first := t.segments[max(i, 0)]
+	return filepath.Join(t.dir, segmentName(first)), first
+}
+
+// commit the offset of the subscription, removing any segments that every
+// subscription has acknowledged.
+func (t *topicLog) commit(subscription string, offset int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.committed[subscription] = offset
+	var lowest = t.end
+	for _, committed := range t.committed {
+		lowest = min(lowest, committed)
+	}
+	for len(t.segments) > 1 && t.segments[1] <= lowest {
+		if err := os.Remove(filepath.Join(t.dir, segmentName(t.segments[0]))); err != nil {
+			return
+		}
+		t.segments = t.segments[1:]
+	}
+}
+
+// subscriptionState is stored in the '.sub' file of a subscription.
+type subscriptionState struct {
+	Offset   int64         `json:"offset"`             // all messages before this offset have been acknowledged.
+	Acked    []int64       `json:"acked,omitempty"`    // acknowledged messages after the offset.
+	Attempts map[int64]int `json:"attempts,omitempty"` // failed deliveries of unacknowledged messages.
+}
+
+func loadState(path string, state *subscriptionState) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, state)
+}
+
+// subscription being received from.
+type subscription struct {
+	name    string
+	path    string
+	state   subscriptionState
+	acked   map[int64]bool
+	retries []redelivery // in order of when they are due.
+	next    int64        // offset of the next message to read from the log.
+	reader  segmentReader
+	acks    chan acknowledgement
+	done    chan struct{}
+}
+
+type redelivery struct {
+	offset int64
+	data   []byte
+	due    time.Time
+}
+
+type acknowledgement struct {
+	offset int64
+	data   []byte
+	err    error
+}
+
+// subscribe opens the subscription, it is created at the start of the log if
+// it does not exist yet.
+func (t *topicLog) subscribe(name string) (*subscription, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.open[name] {
+		return nil, fmt.Errorf("qnq: subscription '%s' to '%s' is already being received", name, t.name)
+	}
+	s := &subscription{
+		name:  name,
+		path:  filepath.Join(t.dir, url.PathEscape(name)+".sub"),
+		acked: make(map[int64]bool),
+		acks:  make(chan acknowledgement),
+		done:  make(chan struct{}),
+	}
+	if err := loadState(s.path, &s.state); errors.Is(err, os.ErrNotExist) {
+		s.state.Offset = t.segments[0]
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if s.state.Attempts == nil {
+		s.state.Attempts = make(map[int64]int)
+	}
+	for _, offset := range s.state.Acked {
+		s.acked[offset] = true
+	}
+	s.next = s.state.Offset
+	t.committed[name] = s.state.Offset
+	t.open[name] = true
+	return s, nil
+}
+
+// save the state of the subscription, atomically.
+func (s *subscription) save() error {
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(s.path))
+	return nil
+}
+
+func syncDir(path string) {
+	if dir, err := os.Open(path); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+}
+
+// deliver the messages of the subscription to out, until the context is done.
+func (d *Dir) deliver(ctx context.Context, t *topicLog, s *subscription, out chan<- func(any) (func(error), error)) {
+	defer d.group.Done()
+	defer close(out)
+	defer close(s.done)
+	defer s.reader.close()
+	defer func() {
+		t.mutex.Lock()
+		delete(t.open, s.name)
+		t.mutex.Unlock()
+	}()
+	var pending func(any) (func(error), error)
+	for {
+		signal, end := t.changed()
+		if pending == nil {
+			var err error
+			if pending, err = s.prepare(t, end); err != nil {
+				return
+			}
+		}
+		var (
+			send chan<- func(any) (func(error), error)
+			wait <-chan time.Time
+		)
+		if pending != nil {
+			send = out
+		} else if len(s.retries) > 0 {
+			wait = time.After(time.Until(s.retries[0].due))
+		}
+		select {
+		case send <- pending:
+			pending = nil
+		case ack := <-s.acks:
+			if err := d.acknowledge(ctx, t, s, ack); err != nil {
+				return
+			}
+		case <-signal:
+		case <-wait:
+		case <-ctx.Done():
+			return
+		case <-d.closed:
+			return
+		}
+	}
+}
+
+// prepare the next message to deliver, if there is one.
+func (s *subscription) prepare(t *topicLog, end int64) (func(any) (func(error), error), error) {
+	if len(s.retries) > 0 && !s.retries[0].due.After(time.Now()) {
+		retry := s.retries[0]
+		s.retries = s.retries[1:]
+		return s.message(retry.offset, retry.data), nil
+	}
+	for s.next < end {
+		offset := s.next
+		data, err := s.reader.read(t, offset)
+		if err != nil {
+			return nil, err
+		}
+		s.next++
+		if s.acked[offset] {
+			continue
+		}
+		return s.message(offset, data), nil
+	}
+	return nil, nil
+}
+
+// message returns a decoder for the message, along with its acknowledgement
+// function, which only has an effect the first time it is called.
+func (s *subscription) message(offset int64, data []byte) func(any) (func(error), error) {
+	return func(ptr any) (func(error), error) {
+		var once sync.Once
+		ack := func(err error) {
+			once.Do(func() {
+				select {
+				case s.acks <- acknowledgement{offset: offset, data: data, err: err}:
+				case <-s.done:
+				}
+			})
+		}
+		if err := json.Unmarshal(data, ptr); err != nil {
+			return ack, xray.New(err)
+		}
+		return ack, nil
+	}
+}
+
+// acknowledge the delivery of a message, failed messages are scheduled for
+// redelivery, or else moved to the dead-letter topic.
+func (d *Dir) acknowledge(ctx context.Context, t *topicLog, s *subscription, ack acknowledgement) error {
+	if ack.err != nil {
+		attempts := s.state.Attempts[ack.offset] + 1
+		if attempts < d.MaxAttempts {
+			s.state.Attempts[ack.offset] = attempts
+			backoff := d.Backoff
+			for range attempts - 1 {
+				if backoff *= 2; backoff >= d.MaxBackoff {
+					break
+				}
+			}
+			retry := redelivery{offset: ack.offset, data: ack.data, due: time.Now().Add(min(backoff, d.MaxBackoff))}
+			i, _ := slices.BinarySearchFunc(s.retries, retry, func(a, b redelivery) int { return a.due.Compare(b.due) })
+			s.retries = slices.Insert(s.retries, i, retry)
+			return s.save()
+		}
+		if err := d.Send(ctx, DeadLetters(t.name, s.name), json.RawMessage(ack.data)); err != nil {
+			return err
+		}
+	}
+	delete(s.state.Attempts, ack.offset)
+	s.acked[ack.offset] = true
+	for s.acked[s.state.Offset] {
+		delete(s.acked, s.state.Offset)
+		s.state.Offset++
+	}
+	s.state.Acked = s.state.Acked[:0]
+	for offset := range s.acked {
+		s.state.Acked = append(s.state.Acked, offset)
+	}
+	slices.Sort(s.state.Acked)
+	if err := s.save(); err != nil {
+		return err
+	}
+	t.commit(s.name, s.state.Offset)
+	return nil
+}
+
+// segmentReader reads messages from the segments of a topic, in order.
+type segmentReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	first  int64 // offset of the first message in the segment.
+	next   int64 // offset of the next message to be read.
+}
+
+func (r *segmentReader) read(t *topicLog, offset int64) ([]byte, error) {
+	path, first := t.segment(offset)
+	if r.file == nil || r.first != first || r.next != offset {
+		r.close()
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		r.file, r.reader, r.first, r.next = file, bufio.NewReader(file), first, first
+		for r.next < offset {
+			if _, err := r.reader.ReadBytes('\n'); err != nil {
+				return nil, err
+			}
+			r.next++
+		}
+	}
+	line, err := r.reader.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	r.next++
+	return line[:len(line)-1], nil
+}
+
+func (r *segmentReader) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
@@ -0,0 +1,182 @@
+package qnq_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"runtime.link/qnq"
+)
+
+type Events struct {
+	Names qnq.Chan[string] `qnq:"names"`
+	Dead  qnq.Chan[string] `qnq:"names.billing.dead"`
+}
+
+// collect returns a listener that sends each message to the returned channel,
+// failing any message that starts with "fail".
+func collect(failures map[string]int) (qnq.Listener[string], <-chan string) {
+	received := make(chan string, 100)
+	return func(ctx context.Context, name string) error {
+		if strings.HasPrefix(name, "fail") {
+			failures[name]++
+			return errors.New("failed")
+		}
+		received <- name
+		return nil
+	}, received
+}
+
+func expect(t *testing.T, received <-chan string, expected ...string) {
+	t.Helper()
+	var names []string
+	for range expected {
+		select {
+		case name := <-received:
+			names = append(names, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %v, got %v", expected, names)
+		}
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+}
+
+func TestDir(t *testing.T) {
+	path := t.TempDir()
+	options := qnq.DirOptions{
+		SegmentSize: 64,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	}
+	dir, err := qnq.OpenDir(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := qnq.Open[Events](dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	failures := make(map[string]int)
+	listener, received := collect(failures)
+	events.Names.Listen(ctx, "billing", listener)
+	for _, name := range []string{"alice", "bob", "fail-carol"} {
+		if err := events.Names.Send(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect(t, received, "alice", "bob")
+
+	dlq := make(chan string, 1)
+	events.Dead.Listen(ctx, "audit", func(ctx context.Context, name string) error {
+		dlq <- name
+		return nil
+	})
+	expect(t, dlq, "fail-carol")
+	cancel()
+	if failures["fail-carol"] != 3 {
+		t.Fatalf("expected 3 attempts, got %d", failures["fail-carol"])
+	}
+
+	// messages sent while the listener is away are delivered after a restart.
+	if err := events.Names.Send(context.Background(), "dave"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dir, err = qnq.OpenDir(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	events = qnq.Open[Events](dir)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	listener, received = collect(failures)
+	events.Names.Listen(ctx, "billing", listener)
+	expect(t, received, "dave")
+	select {
+	case name := <-received:
+		t.Fatalf("unexpected redelivery of %s", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// segments that every subscription has acknowledged are removed.
+	segments, err := filepath.Glob(filepath.Join(path, "names", "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 {
+		t.Fatalf("expected a single segment, got %v", segments)
+	}
+}
+
+func TestDirTopicNames(t *testing.T) {
+	parent := t.TempDir()
+	path := filepath.Join(parent, "queue")
+	dir, err := qnq.OpenDir(path, qnq.DirOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	ctx := context.Background()
+	for _, topic := range []qnq.Topic{".", "..", "a/../.."} {
+		if err := dir.Send(ctx, topic, "escaped"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dir.Send(ctx, "", "empty"); err == nil {
+		t.Fatal("expected an error for an empty topic")
+	}
+	if entries, err := os.ReadDir(parent); err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the queue directory, got %v %v", entries, err)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			t.Fatalf("unexpected file %s", entry.Name())
+		}
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, []string{"%2E", "%2E%2E", "a%2F..%2F.."}) {
+		t.Fatalf("unexpected topic directories %v", names)
+	}
+}
+
+func TestDirRedelivery(t *testing.T) {
+	dir, err := qnq.OpenDir(t.TempDir(), qnq.DirOptions{Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+	events := qnq.Open[Events](dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var attempts int
+	received := make(chan string, 1)
+	events.Names.Listen(ctx, "flaky", func(ctx context.Context, name string) error {
+		if attempts++; attempts < 3 {
+			return os.ErrDeadlineExceeded
+		}
+		received <- name
+		return nil
+	})
+	if err := events.Names.Send(ctx, "erin"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, received, "erin")
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
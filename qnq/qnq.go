@@ -29,6 +29,18 @@ changes queue that was passed (as a dependency) to their execution.
 	   // update our copy of the customer
 	   return nil
 	})
+
+By default, messages are only delivered to listeners within the same process.
+A struct of [Chan] fields can be opened with a [Channels] implementation, such
+as the durable [Dir], so that messages survive process restarts and failed
+messages are redelivered.
+
+	type Events struct {
+	    NameChanges qnq.Chan[NameChangeEvent] `qnq:"name_changes"`
+	}
+
+	dir, err := qnq.OpenDir("/var/lib/events", qnq.DirOptions{})
+	events := qnq.Open[Events](dir)
 */
 package qnq
 
@@ -73,17 +85,13 @@ type Chan[T any] struct {
 // Send will broadcast the given message to all registered listeners, with at-least-once
 // delivery. If a reciever returns an error, it will be returned by [Chan.Send] and it
 // is the caller's responsibility to retry the send operation. [Chan.Send] will return
-// an error if no listeners are registered.
+// an error if no listeners are registered. When the [Chan] was opened with [Channels],
+// the message is only sent to them, such that listeners receive it through them.
 func (ch *Chan[T]) Send(ctx context.Context, value T) error {
 	if ch.impl != nil {
-		if err := ch.impl.Send(ctx, ch.name, value); err != nil {
-			return err
-		}
+		return ch.impl.Send(ctx, ch.name, value)
 	}
-	if err := ch.fast.send(ctx, value); err != nil && (err != ErrEmptyChannel || ch.impl == nil) {
-		return err
-	}
-	return nil
+	return ch.fast.send(ctx, value)
 }
 
 // Listen registers the given listener for the lifetime of the context,
@@ -100,10 +108,10 @@ func (ch *Chan[T]) Send(ctx context.Context, value T) error {
 // always process incoming messages idempotently, as they may be delivered
 // more than once.
 func (ch *Chan[T]) Listen(ctx context.Context, subscription string, listener Listener[T]) {
-	ch.fast.register(ctx, listener)
 	if ch.impl != nil {
+		messages := ch.impl.Recv(ctx, ch.name, subscription)
 		go func() {
-			for fn := range ch.impl.Recv(ctx, ch.name, subscription) {
+			for fn := range messages {
 				var message T
 				ack, err := fn(&message)
 				if err != nil {
@@ -113,7 +121,9 @@ func (ch *Chan[T]) Listen(ctx context.Context, subscription string, listener Lis
 				ack(listener(ctx, message))
 			}
 		}()
+		return
 	}
+	ch.fast.register(ctx, listener)
 }
 
 type Topic string
@@ -0,0 +1,844 @@
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"runtime.link/api/xray"
+	"runtime.link/sql/std/sodium"
+	"runtime.link/xyz"
+)
+
+// Dialect of SQL, used by [Connect] to translate SODIUM operations into
+// SQL text for a particular database engine.
+type Dialect interface {
+	// Quote the given table or column name.
+	Quote(name string) string
+	// Param returns the placeholder for the nth (starting from 1) parameter
+	// of a statement.
+	Param(n int) string
+	// Type returns the type of the column, as used in a CREATE TABLE statement.
+	// index is true for the columns of the primary key.
+	Type(column sodium.Column, index bool) string
+	// Conflict returns the text to insert between INSERT and INTO (prefix)
+	// and the text to append after the VALUES (suffix) of an insert statement,
+	// such that when a row with the same primary key (index) already exists,
+	// the values are either overwritten (upsert) or else the row is ignored.
+	// The columns are already quoted.
+	Conflict(index, value []string, upsert bool) (prefix, suffix string)
+}
+
+// Dialects of SQL supported by [Connect].
+var (
+	SQLite     Dialect = sqlite{}
+	PostgreSQL Dialect = postgres{}
+	MySQL      Dialect = mysql{}
+)
+
+// Connect returns a [Database] backed by the given [database/sql] database,
+// where each SODIUM operation is translated into SQL with the given [Dialect].
+// Tables (along with a primary key across the index columns) are created when
+// they are first used, if they do not already exist.
+func Connect(db *dbsql.DB, dialect Dialect) Database {
+	return &database{db: db, dialect: dialect}
+}
+
+type database struct {
+	db      *dbsql.DB
+	dialect Dialect
+	tables  sync.Map // names of tables that are known to exist.
+}
+
+// job implements [sodium.Job] for a statement that will be executed once it
+// has been sent to a transaction.
+type job struct {
+	table sodium.Table
+	exec  func(context.Context, *dbsql.Tx) (int, error)
+	out   chan<- []sodium.Value // closed once the transaction is complete.
+
+	done chan struct{}
+	n    int
+	err  error
+}
+
+func (db *database) job(table sodium.Table, out chan<- []sodium.Value, exec func(context.Context, *dbsql.Tx) (int, error)) *job {
+	return &job{table: table, exec: exec, out: out, done: make(chan struct{})}
+}
+
+// Wait implements [sodium.Job], it returns once the transaction that the job
+// was sent to has been committed (or rolled back).
+func (j *job) Wait(ctx context.Context) (int, error) {
+	select {
+	case <-j.done:
+		return j.n, j.err
+	case <-ctx.Done():
+		return 0, xray.New(ctx.Err())
+	}
+}
+
+// Manage implements [sodium.Database].
+func (db *database) Manage(ctx context.Context, level sodium.Transaction) (chan<- sodium.Job, error) {
+	var options dbsql.TxOptions
+	switch {
+	case level&(GlobalLock|LockWrites) != 0:
+		options.Isolation = dbsql.LevelSerializable
+	case level&DirtyReads != 0:
+		options.Isolation = dbsql.LevelReadUncommitted
+	}
+	jobs := make(chan sodium.Job)
+	go db.transact(ctx, &options, jobs)
+	return jobs, nil
+}
+
+var errRollback = errors.New("transaction was rolled back")
+
+// transact executes each job sent to the channel within a single transaction.
+func (db *database) transact(ctx context.Context, options *dbsql.TxOptions, jobs <-chan sodium.Job) {
+	var (
+		tx      *dbsql.Tx
+		ran     []*job
+		failure error
+		created []string
+	)
+	finish := func(err error) {
+		if tx != nil {
+			if err == nil {
+				err = tx.Commit()
+			} else {
+				tx.Rollback()
+			}
+		}
+		if err == nil {
+			for _, name := range created {
+				db.tables.Store(name, true)
+			}
+		}
+		for _, j := range ran {
+			if j.err == nil && err != nil {
+				j.err = xray.New(err)
+			}
+			close(j.done)
+			if j.out != nil {
+				if j.err != nil {
+					select {
+					case j.out <- nil: // signals that Wait should be checked for an error.
+					case <-ctx.Done():
+					}
+				}
+				close(j.out)
+			}
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			finish(ctx.Err())
+			return
+		case next, ok := <-jobs:
+			if !ok {
+				finish(failure)
+				return
+			}
+			if next == nil {
+				finish(errRollback)
+				return
+			}
+			j, ok := next.(*job)
+			if !ok {
+				failure = fmt.Errorf("sql: unsupported job %T", next)
+				continue
+			}
+			ran = append(ran, j)
+			if failure != nil {
+				j.err = failure
+				continue
+			}
+			if tx == nil {
+				var err error
+				if tx, err = db.db.BeginTx(ctx, options); err != nil {
+					j.err, failure = xray.New(err), err
+					continue
+				}
+			}
+			if _, ok := db.tables.Load(j.table.Name); !ok {
+				if err := db.create(ctx, tx, j.table); err != nil {
+					j.err, failure = xray.New(err), err
+					continue
+				}
+				created = append(created, j.table.Name)
+			}
+			j.n, j.err = j.exec(ctx, tx)
+			if j.err != nil {
+				failure = j.err
+			}
+		}
+	}
+}
+
+// create the table, if it does not already exist.
+func (db *database) create(ctx context.Context, tx *dbsql.Tx, table sodium.Table) error {
+	if len(table.Joins) > 0 {
+		return errors.New("sql: joins are not supported")
+	}
+	var s = statement{dialect: db.dialect}
+	s.WriteString("CREATE TABLE IF NOT EXISTS ")
+	s.WriteString(s.dialect.Quote(table.Name))
+	s.WriteString(" (")
+	for i, column := range append(table.Index, table.Value...) {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(s.dialect.Quote(column.Name))
+		s.WriteString(" ")
+		s.WriteString(s.dialect.Type(column, i < len(table.Index)))
+		s.WriteString(" NOT NULL")
+	}
+	if len(table.Index) > 0 {
+		s.WriteString(", PRIMARY KEY (")
+		s.columns(table.Index)
+		s.WriteString(")")
+	}
+	s.WriteString(")")
+	_, err := tx.ExecContext(ctx, s.String())
+	return err
+}
+
+// Search implements [sodium.Database].
+func (db *database) Search(table sodium.Table, query sodium.Query, out chan<- []sodium.Value) sodium.Job {
+	return db.job(table, out, func(ctx context.Context, tx *dbsql.Tx) (int, error) {
+		var s = statement{dialect: db.dialect}
+		if err := s.selection(table, table.Index, query, true); err != nil {
+			return 0, err
+		}
+		rows, err := tx.QueryContext(ctx, s.String(), s.args...)
+		if err != nil {
+			return 0, xray.New(err)
+		}
+		defer rows.Close()
+		var count int
+		for rows.Next() {
+			values, err := NewResult(table, rows.Scan)
+			if err != nil {
+				return count, err
+			}
+			select {
+			case out <- values:
+			case <-ctx.Done():
+				return count, xray.New(ctx.Err())
+			}
+			count++
+		}
+		return count, xray.New(rows.Err())
+	})
+}
+
+// Output implements [sodium.Database].
+func (db *database) Output(table sodium.Table, query sodium.Query, stats sodium.Stats, out chan<- []sodium.Value) sodium.Job {
+	return db.job(table, out, func(ctx context.Context, tx *dbsql.Tx) (int, error) {
+		var s = statement{dialect: db.dialect}
+		s.WriteString("SELECT ")
+		for i, calc := range stats {
+			if i > 0 {
+				s.WriteString(", ")
+			}
+			if err := s.calculation(calc); err != nil {
+				return 0, err
+			}
+		}
+		s.WriteString(" FROM ")
+		if err := s.source(table, query); err != nil {
+			return 0, err
+		}
+		values, err := NewOutput(stats, tx.QueryRowContext(ctx, s.String(), s.args...).Scan)
+		if err != nil {
+			return 0, err
+		}
+		select {
+		case out <- values:
+		case <-ctx.Done():
+			return 0, xray.New(ctx.Err())
+		}
+		return 1, nil
+	})
+}
+
+// Delete implements [sodium.Database].
+func (db *database) Delete(table sodium.Table, query sodium.Query) sodium.Job {
+	return db.job(table, nil, func(ctx context.Context, tx *dbsql.Tx) (int, error) {
+		var s = statement{dialect: db.dialect}
+		s.WriteString("DELETE FROM ")
+		s.WriteString(s.dialect.Quote(table.Name))
+		if err := s.within(table, query); err != nil {
+			return 0, err
+		}
+		return s.exec(ctx, tx)
+	})
+}
+
+// Insert implements [sodium.Database].
+func (db *database) Insert(table sodium.Table, index []sodium.Value, upsert bool, value []sodium.Value) sodium.Job {
+	return db.job(table, nil, func(ctx context.Context, tx *dbsql.Tx) (int, error) {
+		if len(index) != len(table.Index) || len(value) != len(table.Value) {
+			return 0, fmt.Errorf("sql: insert into %s expects %d index and %d values (not %d and %d)",
+				table.Name, len(table.Index), len(table.Value), len(index), len(value))
+		}
+		zero := true
+		for _, val := range index {
+			if !val.IsZero() {
+				zero = false
+			}
+		}
+		if zero {
+			return 0, ErrInsertOnly
+		}
+		var (
+			s         = statement{dialect: db.dialect}
+			indexCols = make([]string, len(table.Index))
+			valueCols = make([]string, len(table.Value))
+		)
+		for i, column := range table.Index {
+			indexCols[i] = s.dialect.Quote(column.Name)
+		}
+		for i, column := range table.Value {
+			valueCols[i] = s.dialect.Quote(column.Name)
+		}
+		prefix, suffix := s.dialect.Conflict(indexCols, valueCols, upsert)
+		s.WriteString("INSERT ")
+		if prefix != "" {
+			s.WriteString(prefix)
+			s.WriteString(" ")
+		}
+		s.WriteString("INTO ")
+		s.WriteString(s.dialect.Quote(table.Name))
+		s.WriteString(" (")
+		s.WriteString(strings.Join(append(indexCols, valueCols...), ", "))
+		s.WriteString(") VALUES (")
+		for i, val := range append(index, value...) {
+			if i > 0 {
+				s.WriteString(", ")
+			}
+			s.param(val)
+		}
+		s.WriteString(")")
+		if suffix != "" {
+			s.WriteString(" ")
+			s.WriteString(suffix)
+		}
+		n, err := s.exec(ctx, tx)
+		if err != nil {
+			return n, err
+		}
+		if n == 0 && !upsert {
+			return -1, nil
+		}
+		return n, nil
+	})
+}
+
+// Update implements [sodium.Database].
+func (db *database) Update(table sodium.Table, query sodium.Query, patch sodium.Patch) sodium.Job {
+	return db.job(table, nil, func(ctx context.Context, tx *dbsql.Tx) (int, error) {
+		var s = statement{dialect: db.dialect}
+		s.WriteString("UPDATE ")
+		s.WriteString(s.dialect.Quote(table.Name))
+		s.WriteString(" SET ")
+		var first = true
+		var set func(sodium.Modification) error
+		set = func(mod sodium.Modification) error {
+			switch xyz.ValueOf(mod) {
+			case sodium.Modifications.Set:
+				column, value := sodium.Modifications.Set.Get(mod).Split()
+				if !first {
+					s.WriteString(", ")
+				}
+				first = false
+				s.WriteString(s.dialect.Quote(column.Name))
+				s.WriteString(" = ")
+				s.param(value)
+			case sodium.Modifications.Arr:
+				for _, mod := range sodium.Modifications.Arr.Get(mod) {
+					if err := set(mod); err != nil {
+						return err
+					}
+				}
+			case nil:
+			default:
+				return fmt.Errorf("sql: unsupported modification %v", xyz.ValueOf(mod))
+			}
+			return nil
+		}
+		for _, mod := range patch {
+			if err := set(mod); err != nil {
+				return 0, err
+			}
+		}
+		if first {
+			return 0, nil
+		}
+		if err := s.within(table, query); err != nil {
+			return 0, err
+		}
+		return s.exec(ctx, tx)
+	})
+}
+
+// statement being written in a particular dialect.
+type statement struct {
+	strings.Builder
+
+	dialect Dialect
+	args    []any
+}
+
+func (s *statement) exec(ctx context.Context, tx *dbsql.Tx) (int, error) {
+	result, err := tx.ExecContext(ctx, s.String(), s.args...)
+	if err != nil {
+		return 0, xray.New(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, xray.New(err)
+	}
+	return int(n), nil
+}
+
+// param writes a placeholder for the given argument.
+func (s *statement) param(value any) {
+	if val, ok := value.(sodium.Value); ok {
+		value = argumentOf(val)
+	}
+	s.args = append(s.args, value)
+	s.WriteString(s.dialect.Param(len(s.args)))
+}
+
+func (s *statement) columns(columns []sodium.Column) {
+	for i, column := range columns {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(s.dialect.Quote(column.Name))
+	}
+}
+
+// selection writes a SELECT statement for the given columns (or all of the
+// columns, when all is true) in the table that match the query.
+func (s *statement) selection(table sodium.Table, columns []sodium.Column, query sodium.Query, all bool) error {
+	s.WriteString("SELECT ")
+	if all {
+		columns = append(append([]sodium.Column(nil), table.Index...), table.Value...)
+	}
+	s.columns(columns)
+	s.WriteString(" FROM ")
+	s.WriteString(s.dialect.Quote(table.Name))
+	return s.query(query)
+}
+
+// source writes the table to calculate stats from, which is a sub-query if
+// the query has a range.
+func (s *statement) source(table sodium.Table, query sodium.Query) error {
+	for _, expr := range query {
+		if xyz.ValueOf(expr) == sodium.Expressions.Range {
+			s.WriteString("(")
+			if err := s.selection(table, nil, query, true); err != nil {
+				return err
+			}
+			s.WriteString(") AS ")
+			s.WriteString(s.dialect.Quote("page"))
+			return nil
+		}
+	}
+	s.WriteString(s.dialect.Quote(table.Name))
+	return s.query(query)
+}
+
+// within writes a WHERE clause that restricts a DELETE or UPDATE to the rows
+// within the (required) range of the query.
+func (s *statement) within(table sodium.Table, query sodium.Query) error {
+	var finite bool
+	for _, expr := range query {
+		if xyz.ValueOf(expr) == sodium.Expressions.Range {
+			limit := sodium.Expressions.Range.Get(expr)
+			finite = limit.Upto > limit.From
+		}
+	}
+	if !finite {
+		return errors.New("sql: please provide a query with a finite range")
+	}
+	s.WriteString(" WHERE ")
+	if len(table.Index) > 1 {
+		s.WriteString("(")
+	}
+	s.columns(table.Index)
+	if len(table.Index) > 1 {
+		s.WriteString(")")
+	}
+	s.WriteString(" IN (SELECT * FROM (")
+	if err := s.selection(table, table.Index, query, false); err != nil {
+		return err
+	}
+	s.WriteString(") AS ")
+	s.WriteString(s.dialect.Quote("page"))
+	s.WriteString(")")
+	return nil
+}
+
+// query writes the WHERE, ORDER BY, LIMIT and OFFSET clauses for the query.
+func (s *statement) query(query sodium.Query) error {
+	var (
+		filter []sodium.Expression
+		orders []sodium.OrderExpression
+		limit  *sodium.Range
+	)
+	for _, expr := range query {
+		switch xyz.ValueOf(expr) {
+		case sodium.Expressions.Order:
+			orders = append(orders, sodium.Expressions.Order.Get(expr))
+		case sodium.Expressions.Range:
+			r := sodium.Expressions.Range.Get(expr)
+			limit = &r
+		default:
+			filter = append(filter, expr)
+		}
+	}
+	if len(filter) > 0 {
+		s.WriteString(" WHERE ")
+		if err := s.expressions(filter, " AND "); err != nil {
+			return err
+		}
+	}
+	for i, order := range orders {
+		if i == 0 {
+			s.WriteString(" ORDER BY ")
+		} else {
+			s.WriteString(", ")
+		}
+		switch xyz.ValueOf(order) {
+		case sodium.OrderExpressions.Increasing:
+			s.WriteString(s.dialect.Quote(sodium.OrderExpressions.Increasing.Get(order).Name))
+			s.WriteString(" ASC")
+		case sodium.OrderExpressions.Decreasing:
+			s.WriteString(s.dialect.Quote(sodium.OrderExpressions.Decreasing.Get(order).Name))
+			s.WriteString(" DESC")
+		default:
+			return fmt.Errorf("sql: unsupported order %v", xyz.ValueOf(order))
+		}
+	}
+	if limit != nil {
+		upto := limit.Upto
+		if upto < limit.From {
+			upto = limit.From
+		}
+		s.WriteString(" LIMIT ")
+		s.WriteString(strconv.Itoa(upto - limit.From))
+		if limit.From > 0 {
+			s.WriteString(" OFFSET ")
+			s.WriteString(strconv.Itoa(limit.From))
+		}
+	}
+	return nil
+}
+
+// expressions writes the given expressions joined by the given operator.
+func (s *statement) expressions(exprs []sodium.Expression, op string) error {
+	if len(exprs) == 0 {
+		if op == " AND " {
+			s.WriteString("1 = 1")
+		} else {
+			s.WriteString("1 = 0")
+		}
+		return nil
+	}
+	if len(exprs) > 1 {
+		s.WriteString("(")
+	}
+	for i, expr := range exprs {
+		if i > 0 {
+			s.WriteString(op)
+		}
+		if err := s.expression(expr); err != nil {
+			return err
+		}
+	}
+	if len(exprs) > 1 {
+		s.WriteString(")")
+	}
+	return nil
+}
+
+func (s *statement) compare(pair xyz.Pair[sodium.Column, sodium.Value], op string) {
+	column, value := pair.Split()
+	s.WriteString(s.dialect.Quote(column.Name))
+	s.WriteString(op)
+	s.param(value)
+}
+
+// match writes a LIKE expression, using '!' as the escape character, as it
+// has no special meaning in any of the supported dialects.
+func (s *statement) match(pair xyz.Pair[sodium.Column, string], prefix, suffix string) {
+	column, text := pair.Split()
+	s.WriteString(s.dialect.Quote(column.Name))
+	s.WriteString(" LIKE ")
+	s.param(prefix + strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(text) + suffix)
+	s.WriteString(" ESCAPE '!'")
+}
+
+func (s *statement) expression(expr sodium.Expression) error {
+	switch xyz.ValueOf(expr) {
+	case sodium.Expressions.Value:
+		if sodium.Expressions.Value.Get(expr) {
+			s.WriteString("1 = 1")
+		} else {
+			s.WriteString("1 = 0")
+		}
+	case sodium.Expressions.Index:
+		s.compare(sodium.Expressions.Index.Get(expr), " = ")
+	case sodium.Expressions.Where:
+		where := sodium.Expressions.Where.Get(expr)
+		switch xyz.ValueOf(where) {
+		case sodium.WhereExpressions.Min:
+			s.compare(sodium.WhereExpressions.Min.Get(where), " >= ")
+		case sodium.WhereExpressions.Max:
+			s.compare(sodium.WhereExpressions.Max.Get(where), " <= ")
+		case sodium.WhereExpressions.MoreThan:
+			s.compare(sodium.WhereExpressions.MoreThan.Get(where), " > ")
+		case sodium.WhereExpressions.LessThan:
+			s.compare(sodium.WhereExpressions.LessThan.Get(where), " < ")
+		default:
+			return fmt.Errorf("sql: unsupported where expression %v", xyz.ValueOf(where))
+		}
+	case sodium.Expressions.Match:
+		match := sodium.Expressions.Match.Get(expr)
+		switch xyz.ValueOf(match) {
+		case sodium.MatchExpressions.Contains:
+			s.match(sodium.MatchExpressions.Contains.Get(match), "%", "%")
+		case sodium.MatchExpressions.HasPrefix:
+			s.match(sodium.MatchExpressions.HasPrefix.Get(match), "", "%")
+		case sodium.MatchExpressions.HasSuffix:
+			s.match(sodium.MatchExpressions.HasSuffix.Get(match), "%", "")
+		default:
+			return fmt.Errorf("sql: unsupported match expression %v", xyz.ValueOf(match))
+		}
+	case sodium.Expressions.Empty:
+		column := sodium.Expressions.Empty.Get(expr)
+		s.WriteString(s.dialect.Quote(column.Name))
+		s.WriteString(" = ")
+		s.param(ValuesOf(newPointerFor(column))[0])
+	case sodium.Expressions.Avoid:
+		s.WriteString("NOT (")
+		if err := s.expression(sodium.Expressions.Avoid.Get(expr)); err != nil {
+			return err
+		}
+		s.WriteString(")")
+	case sodium.Expressions.Cases:
+		return s.expressions(sodium.Expressions.Cases.Get(expr), " OR ")
+	case sodium.Expressions.Group:
+		return s.expressions(sodium.Expressions.Group.Get(expr), " AND ")
+	default:
+		return fmt.Errorf("sql: unsupported expression %v", xyz.ValueOf(expr))
+	}
+	return nil
+}
+
+// calculation writes the SQL aggregate for the calculation, results are never
+// NULL, so that they can be scanned by [NewOutput].
+func (s *statement) calculation(calc sodium.Calculation) error {
+	if calc == sodium.Calculations.Add {
+		s.WriteString("COUNT(*)")
+		return nil
+	}
+	var (
+		fn     string
+		column sodium.Column
+	)
+	switch xyz.ValueOf(calc) {
+	case sodium.Calculations.Sum:
+		fn, column = "SUM", sodium.Calculations.Sum.Get(calc)
+	case sodium.Calculations.Avg:
+		fn, column = "AVG", sodium.Calculations.Avg.Get(calc)
+	case sodium.Calculations.Min:
+		fn, column = "MIN", sodium.Calculations.Min.Get(calc)
+	case sodium.Calculations.Max:
+		fn, column = "MAX", sodium.Calculations.Max.Get(calc)
+	default:
+		return fmt.Errorf("sql: unsupported calculation %v", xyz.ValueOf(calc))
+	}
+	s.WriteString("COALESCE(")
+	s.WriteString(fn)
+	s.WriteString("(")
+	s.WriteString(s.dialect.Quote(column.Name))
+	s.WriteString("), ")
+	s.param(ValuesOf(newPointerFor(column))[0])
+	s.WriteString(")")
+	return nil
+}
+
+// argumentOf converts a [sodium.Value] into a [database/sql] argument.
+func argumentOf(val sodium.Value) any {
+	switch xyz.ValueOf(val) {
+	case sodium.Values.Bool:
+		return sodium.Values.Bool.Get(val)
+	case sodium.Values.Int8:
+		return sodium.Values.Int8.Get(val)
+	case sodium.Values.Int16:
+		return sodium.Values.Int16.Get(val)
+	case sodium.Values.Int32:
+		return sodium.Values.Int32.Get(val)
+	case sodium.Values.Int64:
+		return sodium.Values.Int64.Get(val)
+	case sodium.Values.Uint8:
+		return sodium.Values.Uint8.Get(val)
+	case sodium.Values.Uint16:
+		return sodium.Values.Uint16.Get(val)
+	case sodium.Values.Uint32:
+		return sodium.Values.Uint32.Get(val)
+	case sodium.Values.Uint64:
+		return sodium.Values.Uint64.Get(val)
+	case sodium.Values.Float32:
+		return sodium.Values.Float32.Get(val)
+	case sodium.Values.Float64:
+		return sodium.Values.Float64.Get(val)
+	case sodium.Values.String:
+		return sodium.Values.String.Get(val)
+	case sodium.Values.Bytes:
+		return sodium.Values.Bytes.Get(val)
+	case sodium.Values.Time:
+		return sodium.Values.Time.Get(val)
+	default:
+		return nil
+	}
+}
+
+type sqlite struct{}
+
+func (sqlite) Quote(name string) string { return `"` + strings.ReplaceAll(name, `"`, `""`) + `"` }
+func (sqlite) Param(n int) string       { return "?" }
+
+func (sqlite) Type(column sodium.Column, index bool) string {
+	switch column.Type {
+	case sodium.Values.Bool:
+		return "BOOLEAN"
+	case sodium.Values.Float32, sodium.Values.Float64:
+		return "REAL"
+	case sodium.Values.String:
+		return "TEXT"
+	case sodium.Values.Bytes:
+		return "BLOB"
+	case sodium.Values.Time:
+		return "DATETIME"
+	default:
+		return "INTEGER"
+	}
+}
+
+func (sqlite) Conflict(index, value []string, upsert bool) (prefix, suffix string) {
+	return "", onConflict(index, value, upsert)
+}
+
+// onConflict clause, as supported by SQLite and PostgreSQL.
+func onConflict(index, value []string, upsert bool) string {
+	if !upsert || len(value) == 0 {
+		return "ON CONFLICT (" + strings.Join(index, ", ") + ") DO NOTHING"
+	}
+	var set = make([]string, len(value))
+	for i, column := range value {
+		set[i] = column + " = excluded." + column
+	}
+	return "ON CONFLICT (" + strings.Join(index, ", ") + ") DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+type postgres struct{}
+
+func (postgres) Quote(name string) string { return `"` + strings.ReplaceAll(name, `"`, `""`) + `"` }
+func (postgres) Param(n int) string       { return "$" + strconv.Itoa(n) }
+
+func (postgres) Type(column sodium.Column, index bool) string {
+	switch column.Type {
+	case sodium.Values.Bool:
+		return "BOOLEAN"
+	case sodium.Values.Int8, sodium.Values.Int16, sodium.Values.Uint8:
+		return "SMALLINT"
+	case sodium.Values.Int32, sodium.Values.Uint16:
+		return "INTEGER"
+	case sodium.Values.Int64, sodium.Values.Uint32:
+		return "BIGINT"
+	case sodium.Values.Uint64:
+		return "NUMERIC(20)"
+	case sodium.Values.Float32:
+		return "REAL"
+	case sodium.Values.Float64:
+		return "DOUBLE PRECISION"
+	case sodium.Values.Bytes:
+		return "BYTEA"
+	case sodium.Values.Time:
+		return "TIMESTAMPTZ"
+	default:
+		return "TEXT"
+	}
+}
+
+func (postgres) Conflict(index, value []string, upsert bool) (prefix, suffix string) {
+	return "", onConflict(index, value, upsert)
+}
+
+type mysql struct{}
+
+func (mysql) Quote(name string) string { return "`" + strings.ReplaceAll(name, "`", "``") + "`" }
+func (mysql) Param(n int) string       { return "?" }
+
+func (mysql) Type(column sodium.Column, index bool) string {
+	switch column.Type {
+	case sodium.Values.Bool:
+		return "BOOLEAN"
+	case sodium.Values.Int8:
+		return "TINYINT"
+	case sodium.Values.Int16:
+		return "SMALLINT"
+	case sodium.Values.Int32:
+		return "INT"
+	case sodium.Values.Int64:
+		return "BIGINT"
+	case sodium.Values.Uint8:
+		return "TINYINT UNSIGNED"
+	case sodium.Values.Uint16:
+		return "SMALLINT UNSIGNED"
+	case sodium.Values.Uint32:
+		return "INT UNSIGNED"
+	case sodium.Values.Uint64:
+		return "BIGINT UNSIGNED"
+	case sodium.Values.Float32:
+		return "FLOAT"
+	case sodium.Values.Float64:
+		return "DOUBLE"
+	case sodium.Values.Bytes:
+		if index {
+			return "VARBINARY(255)"
+		}
+		return "LONGBLOB"
+	case sodium.Values.Time:
+		return "DATETIME(6)"
+	default:
+		if index {
+			return "VARCHAR(255)"
+		}
+		return "LONGTEXT"
+	}
+}
+
+func (mysql) Conflict(index, value []string, upsert bool) (prefix, suffix string) {
+	if !upsert {
+		return "IGNORE", ""
+	}
+	var set = make([]string, len(value))
+	for i, column := range value {
+		set[i] = column + " = VALUES(" + column + ")"
+	}
+	if len(set) == 0 {
+		set = append(set, index[0]+" = "+index[0])
+	}
+	return "", "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
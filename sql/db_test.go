@@ -0,0 +1,153 @@
+package sql_test
+
+import (
+	"context"
+	dbsql "database/sql"
+	"testing"
+	"time"
+
+	"runtime.link/sql"
+	_ "runtime.link/sql/internal/sqlmem"
+)
+
+var dialects = map[string]sql.Dialect{
+	"SQLite":     sql.SQLite,
+	"PostgreSQL": sql.PostgreSQL,
+	"MySQL":      sql.MySQL,
+}
+
+func TestConnect(t *testing.T) {
+	for name, dialect := range dialects {
+		t.Run(name, func(t *testing.T) {
+			db, err := dbsql.Open("sqlmem", t.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if err := sql.Test(context.Background(), sql.Connect(db, dialect)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestConnectQueries(t *testing.T) {
+	for name, dialect := range dialects {
+		t.Run(name, func(t *testing.T) { testQueries(t, dialect) })
+	}
+}
+
+// testQueries executes the statements of the dialect, where the names of the
+// table and of a column need to be quoted.
+func testQueries(t *testing.T, dialect sql.Dialect) {
+	ctx := context.Background()
+	conn, err := dbsql.Open("sqlmem", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	type Pet struct {
+		Name string "sql:\"pet`s \\\"name\\\"\""
+		Age  int64
+	}
+	db := sql.Open[struct {
+		Pets sql.Map[int64, Pet] "sql:\"odd`\\\"pets\""
+	}](sql.Connect(conn, dialect))
+	for id, pet := range []Pet{{"Rex", 3}, {"Rover", 5}, {"Tom", 7}, {"100% Cat", 2}} {
+		if err := db.Pets.Insert(ctx, int64(id+1), sql.Create, pet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Pets.Insert(ctx, 1, sql.Create, Pet{"Max", 1}); err != sql.ErrDuplicate {
+		t.Fatal("expected a duplicate", err)
+	}
+	if err := db.Pets.Set(ctx, 3, Pet{"Tom", 7}); err != nil {
+		t.Fatal(err)
+	}
+	if pet, err := db.Pets.Get(ctx, 1); err != nil || pet.Name != "Rex" {
+		t.Fatal("unexpected pet", pet, err)
+	}
+	var names []string
+	for _, pet := range db.Pets.Search(ctx, func(id *int64, pet *Pet) sql.Query {
+		return sql.Query{sql.Match(&pet.Name).HasPrefix("r"), sql.Order(&pet.Age).Decreasing()}
+	}, &err) {
+		names = append(names, pet.Name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "Rover" || names[1] != "Rex" {
+		t.Fatal("unexpected search results", names)
+	}
+	count, err := db.Pets.Count(ctx, func(id *int64, pet *Pet) sql.Query {
+		return sql.Query{sql.Match(&pet.Name).Contains("%")}
+	})
+	if err != nil || count != 1 {
+		t.Fatal("unexpected count", count, err)
+	}
+	var total int64
+	err = db.Pets.Output(ctx, func(id *int64, pet *Pet) sql.Query {
+		return sql.Query{sql.Where(&pet.Age).MoreThan(2)}
+	}, func(id *int64, pet *Pet) sql.Stats {
+		return sql.Stats{sql.Sum(&pet.Age, &total)}
+	})
+	if err != nil || total != 15 {
+		t.Fatal("unexpected total", total, err)
+	}
+	deleted, err := db.Pets.UnsafeDelete(ctx, func(id *int64, pet *Pet) sql.Query {
+		return sql.Query{sql.Where(&pet.Age).LessThan(4), sql.Slice(0, 10)}
+	})
+	if err != nil || deleted != 2 {
+		t.Fatal("unexpected delete", deleted, err)
+	}
+	if n, err := db.Pets.Length(ctx); err != nil || n != 2 {
+		t.Fatal("unexpected length", n, err)
+	}
+}
+
+func TestSearchStop(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := dbsql.Open("sqlmem", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	db := sql.Open[struct {
+		Numbers sql.Map[int64, int64] `sql:"numbers"`
+	}](sql.Connect(conn, sql.SQLite))
+	for i := range int64(200) {
+		if err := db.Numbers.Insert(ctx, i+1, sql.Create, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range 3 {
+		if _, _, ok, err := db.Numbers.First(ctx, nil); !ok || err != nil {
+			t.Fatal("expected a first number", ok, err)
+		}
+	}
+	// the stopped searches must not hold on to the database.
+	if err := db.Numbers.Set(ctx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialects(t *testing.T) {
+	if p := sql.PostgreSQL.Param(2); p != "$2" {
+		t.Fatal("unexpected postgres param", p)
+	}
+	if q := sql.MySQL.Quote("a`b"); q != "`a``b`" {
+		t.Fatal("unexpected mysql quote", q)
+	}
+	if q := sql.SQLite.Quote(`a"b`); q != `"a""b"` {
+		t.Fatal("unexpected sqlite quote", q)
+	}
+	prefix, suffix := sql.MySQL.Conflict([]string{"`id`"}, []string{"`name`"}, false)
+	if prefix != "IGNORE" || suffix != "" {
+		t.Fatal("unexpected mysql conflict", prefix, suffix)
+	}
+	_, suffix = sql.PostgreSQL.Conflict([]string{`"id"`}, []string{`"name"`}, true)
+	if suffix != `ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name"` {
+		t.Fatal("unexpected postgres conflict", suffix)
+	}
+}
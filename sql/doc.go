@@ -123,5 +123,20 @@ Statistics can be reported on a map in the database with [sql.Counter].
 			sql.Where(&value.Name).Equals("Bob"),
 		}
 	})
+
+# Databases
+
+Maps are backed by an in-memory database by default. To store them in a
+real SQL database, [Connect] a [database/sql] database with the [Dialect]
+of its engine ([SQLite], [PostgreSQL] or [MySQL]) and pass it to [Open].
+
+	conn, err := dbsql.Open("sqlite", "customers.db")
+	if err != nil {
+		return xray.New(err)
+	}
+	db := sql.Open[Database](sql.Connect(conn, sql.SQLite))
+
+Each table is created (if it does not already exist) the first time it is
+used.
 */
 package sql
@@ -0,0 +1,449 @@
+package sqlmem
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+type result struct {
+	affected int64
+	columns  []string
+	rows     [][]driver.Value
+}
+
+// env for evaluating expressions against a row.
+type env struct {
+	columns  []string
+	row      []driver.Value
+	args     []driver.Value
+	tables   map[string]*table
+	excluded []driver.Value // row that conflicted with an insert.
+}
+
+func execute(tables map[string]*table, stmt any, args []driver.Value) (result, error) {
+	switch stmt := stmt.(type) {
+	case createTable:
+		if _, ok := tables[stmt.table]; ok {
+			return result{}, nil
+		}
+		t := &table{columns: stmt.columns}
+		for _, name := range stmt.key {
+			i, err := t.column(name)
+			if err != nil {
+				return result{}, err
+			}
+			t.key = append(t.key, i)
+		}
+		tables[stmt.table] = t
+		return result{}, nil
+	case insertInto:
+		return insert(tables, stmt, args)
+	case *selectFrom:
+		return query(tables, stmt, args)
+	case deleteFrom:
+		t, ok := tables[stmt.table]
+		if !ok {
+			return result{}, fmt.Errorf("no such table: %s", stmt.table)
+		}
+		var kept [][]driver.Value
+		var res result
+		for _, row := range t.rows {
+			match, err := truthy(env{columns: t.columns, row: row, args: args, tables: tables}, stmt.where)
+			if err != nil {
+				return res, err
+			}
+			if match {
+				res.affected++
+				continue
+			}
+			kept = append(kept, row)
+		}
+		t.rows = kept
+		return res, nil
+	case updateSet:
+		t, ok := tables[stmt.table]
+		if !ok {
+			return result{}, fmt.Errorf("no such table: %s", stmt.table)
+		}
+		var res result
+		for r, row := range t.rows {
+			e := env{columns: t.columns, row: row, args: args, tables: tables}
+			match, err := truthy(e, stmt.where)
+			if err != nil {
+				return res, err
+			}
+			if !match {
+				continue
+			}
+			updated := slices.Clone(row)
+			for _, set := range stmt.set {
+				i, err := t.column(set.column)
+				if err != nil {
+					return res, err
+				}
+				if updated[i], err = eval(e, set.value); err != nil {
+					return res, err
+				}
+			}
+			t.rows[r] = updated
+			res.affected++
+		}
+		return res, nil
+	default:
+		return result{}, fmt.Errorf("unsupported statement %T", stmt)
+	}
+}
+
+func insert(tables map[string]*table, stmt insertInto, args []driver.Value) (result, error) {
+	t, ok := tables[stmt.table]
+	if !ok {
+		return result{}, fmt.Errorf("no such table: %s", stmt.table)
+	}
+	if len(stmt.columns) != len(stmt.values) {
+		return result{}, errors.New("number of columns and values differ")
+	}
+	row := make([]driver.Value, len(t.columns))
+	for i, name := range stmt.columns {
+		c, err := t.column(name)
+		if err != nil {
+			return result{}, err
+		}
+		if row[c], err = eval(env{args: args}, stmt.values[i]); err != nil {
+			return result{}, err
+		}
+	}
+	for r, existing := range t.rows {
+		same := len(t.key) > 0
+		for _, k := range t.key {
+			if cmp, ok := compare(existing[k], row[k]); !ok || cmp != 0 {
+				same = false
+			}
+		}
+		if !same {
+			continue
+		}
+		switch {
+		case stmt.nothing:
+			return result{}, nil
+		case stmt.update != nil:
+			e := env{columns: t.columns, row: existing, args: args, excluded: row}
+			updated := slices.Clone(existing)
+			for _, set := range stmt.update {
+				i, err := t.column(set.column)
+				if err != nil {
+					return result{}, err
+				}
+				if updated[i], err = eval(e, set.value); err != nil {
+					return result{}, err
+				}
+			}
+			t.rows[r] = updated
+			return result{affected: 1}, nil
+		default:
+			return result{}, errors.New("UNIQUE constraint failed")
+		}
+	}
+	t.rows = append(t.rows, row)
+	return result{affected: 1}, nil
+}
+
+func query(tables map[string]*table, stmt *selectFrom, args []driver.Value) (result, error) {
+	var (
+		columns []string
+		rows    [][]driver.Value
+	)
+	if stmt.from != nil {
+		sub, err := query(tables, stmt.from, args)
+		if err != nil {
+			return result{}, err
+		}
+		columns, rows = sub.columns, sub.rows
+	} else {
+		t, ok := tables[stmt.table]
+		if !ok {
+			return result{}, fmt.Errorf("no such table: %s", stmt.table)
+		}
+		columns, rows = t.columns, t.rows
+	}
+	var matching [][]driver.Value
+	for _, row := range rows {
+		match, err := truthy(env{columns: columns, row: row, args: args, tables: tables}, stmt.where)
+		if err != nil {
+			return result{}, err
+		}
+		if match {
+			matching = append(matching, row)
+		}
+	}
+	var failure error
+	sort.SliceStable(matching, func(i, j int) bool {
+		for _, order := range stmt.order {
+			c := slices.Index(columns, order.column)
+			if c < 0 {
+				failure = fmt.Errorf("no such column: %s", order.column)
+				return false
+			}
+			cmp, _ := compare(matching[i][c], matching[j][c])
+			if order.desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	if failure != nil {
+		return result{}, failure
+	}
+	matching = matching[min(stmt.offset, len(matching)):]
+	if stmt.limit >= 0 {
+		matching = matching[:min(stmt.limit, len(matching))]
+	}
+	if stmt.items == nil {
+		return result{columns: columns, rows: matching}, nil
+	}
+	var res result
+	for _, item := range stmt.items {
+		if name, ok := item.(column); ok {
+			res.columns = append(res.columns, string(name))
+		} else {
+			res.columns = append(res.columns, fmt.Sprint(item))
+		}
+	}
+	if slices.ContainsFunc(stmt.items, aggregates) {
+		var row []driver.Value
+		for _, item := range stmt.items {
+			value, err := aggregate(env{columns: columns, args: args, tables: tables}, item, matching)
+			if err != nil {
+				return res, err
+			}
+			row = append(row, value)
+		}
+		res.rows = append(res.rows, row)
+		return res, nil
+	}
+	for _, values := range matching {
+		var row []driver.Value
+		for _, item := range stmt.items {
+			value, err := eval(env{columns: columns, row: values, args: args, tables: tables}, item)
+			if err != nil {
+				return res, err
+			}
+			row = append(row, value)
+		}
+		res.rows = append(res.rows, row)
+	}
+	return res, nil
+}
+
+func aggregates(item node) bool {
+	fn, ok := item.(call)
+	if !ok {
+		return false
+	}
+	return fn.fn != "COALESCE" || slices.ContainsFunc(fn.args, aggregates)
+}
+
+// aggregate evaluates the expression over the given rows.
+func aggregate(e env, expr node, rows [][]driver.Value) (driver.Value, error) {
+	fn, ok := expr.(call)
+	if !ok {
+		return eval(e, expr)
+	}
+	if fn.fn == "COALESCE" {
+		for _, arg := range fn.args {
+			value, err := aggregate(e, arg, rows)
+			if err != nil || value != nil {
+				return value, err
+			}
+		}
+		return nil, nil
+	}
+	if fn.fn == "COUNT" {
+		return int64(len(rows)), nil
+	}
+	if len(fn.args) != 1 {
+		return nil, fmt.Errorf("%s expects one argument", fn.fn)
+	}
+	var (
+		result driver.Value
+		sum    float64
+		ints   = true
+		total  int64
+		count  int
+	)
+	for _, row := range rows {
+		value, err := eval(env{columns: e.columns, row: row, args: e.args, tables: e.tables}, fn.args[0])
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		count++
+		switch fn.fn {
+		case "SUM", "AVG":
+			switch v := value.(type) {
+			case int64:
+				total += v
+				sum += float64(v)
+			case float64:
+				ints = false
+				sum += v
+			default:
+				return nil, fmt.Errorf("cannot %s %T", fn.fn, value)
+			}
+		case "MIN", "MAX":
+			cmp, _ := compare(value, result)
+			if result == nil || (fn.fn == "MIN" && cmp < 0) || (fn.fn == "MAX" && cmp > 0) {
+				result = value
+			}
+		}
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	switch fn.fn {
+	case "SUM":
+		if ints {
+			return total, nil
+		}
+		return sum, nil
+	case "AVG":
+		return sum / float64(count), nil
+	}
+	return result, nil
+}
+
+func truthy(e env, expr node) (bool, error) {
+	if expr == nil {
+		return true, nil
+	}
+	value, err := eval(e, expr)
+	if err != nil {
+		return false, err
+	}
+	b, _ := value.(bool)
+	return b, nil
+}
+
+func eval(e env, expr node) (driver.Value, error) {
+	switch x := expr.(type) {
+	case column:
+		i := slices.Index(e.columns, string(x))
+		if i < 0 || e.row == nil {
+			return nil, fmt.Errorf("no such column: %s", x)
+		}
+		return e.row[i], nil
+	case excluded:
+		i := slices.Index(e.columns, string(x))
+		if i < 0 || e.excluded == nil {
+			return nil, fmt.Errorf("no such column: excluded.%s", x)
+		}
+		return e.excluded[i], nil
+	case param:
+		if int(x) >= len(e.args) {
+			return nil, fmt.Errorf("missing argument %d", x+1)
+		}
+		return e.args[x], nil
+	case literal:
+		return x.value, nil
+	case not:
+		b, err := truthy(e, x.x)
+		return !b, err
+	case isNull:
+		value, err := eval(e, x.x)
+		return value == nil, err
+	case binary:
+		if x.op == "AND" || x.op == "OR" {
+			l, err := truthy(e, x.l)
+			if err != nil {
+				return nil, err
+			}
+			if (x.op == "AND" && !l) || (x.op == "OR" && l) {
+				return l, nil
+			}
+			return truthy(e, x.r)
+		}
+		l, err := eval(e, x.l)
+		if err != nil {
+			return nil, err
+		}
+		r, err := eval(e, x.r)
+		if err != nil {
+			return nil, err
+		}
+		cmp, ok := compare(l, r)
+		if !ok {
+			return false, nil
+		}
+		switch x.op {
+		case "=":
+			return cmp == 0, nil
+		case "!=":
+			return cmp != 0, nil
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		case ">=":
+			return cmp >= 0, nil
+		}
+		return nil, fmt.Errorf("unsupported operator %s", x.op)
+	case likeMatch:
+		text, err := eval(e, x.x)
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := eval(e, x.pattern)
+		if err != nil {
+			return nil, err
+		}
+		t, ok1 := text.(string)
+		p, ok2 := pattern.(string)
+		return ok1 && ok2 && like(t, p, x.escape), nil
+	case inSelect:
+		var values []driver.Value
+		if items, ok := x.x.(tuple); ok {
+			for _, item := range items {
+				value, err := eval(e, item)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+			}
+		} else {
+			value, err := eval(e, x.x)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		sub, err := query(e.tables, x.sub, e.args)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range sub.rows {
+			if len(row) != len(values) {
+				return nil, errors.New("row value misused")
+			}
+			same := true
+			for i := range row {
+				if cmp, ok := compare(row[i], values[i]); !ok || cmp != 0 {
+					same = false
+				}
+			}
+			if same {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return nil, fmt.Errorf("unsupported expression %T", expr)
+}
@@ -0,0 +1,643 @@
+package sqlmem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Statements.
+type (
+	createTable struct {
+		table   string
+		columns []string
+		key     []string
+	}
+	insertInto struct {
+		table    string
+		columns  []string
+		values   []node
+		conflict []string // primary key columns of an ON CONFLICT clause.
+		update   []assignment
+		nothing  bool
+	}
+	selectFrom struct {
+		items   []node // nil for *
+		table   string
+		from    *selectFrom // derived table.
+		where   node
+		order   []ordering
+		limit   int // -1 if unlimited
+		offset  int
+		columns []string // names of the items.
+	}
+	deleteFrom struct {
+		table string
+		where node
+	}
+	updateSet struct {
+		table string
+		set   []assignment
+		where node
+	}
+)
+
+type assignment struct {
+	column string
+	value  node
+}
+
+type ordering struct {
+	column string
+	desc   bool
+}
+
+// Expressions.
+type (
+	node     any
+	column   string
+	param    int
+	literal  struct{ value any }
+	tuple    []node
+	excluded string
+	binary   struct {
+		op   string
+		l, r node
+	}
+	not       struct{ x node }
+	isNull    struct{ x node }
+	likeMatch struct {
+		x, pattern node
+		escape     rune
+	}
+	inSelect struct {
+		x   node
+		sub *selectFrom
+	}
+	call struct {
+		fn   string
+		args []node // nil for *
+	}
+)
+
+type parser struct {
+	tokens []string
+	pos    int
+	params int
+}
+
+func parse(query string) (any, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	var stmt any
+	switch p.peek() {
+	case "CREATE":
+		stmt, err = p.createTable()
+	case "INSERT":
+		stmt, err = p.insertInto()
+	case "SELECT":
+		stmt, err = p.selectFrom()
+	case "DELETE":
+		stmt, err = p.deleteFrom()
+	case "UPDATE":
+		stmt, err = p.updateSet()
+	default:
+		return nil, fmt.Errorf("unsupported statement: %s", query)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, query)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q: %s", p.peek(), query)
+	}
+	return stmt, nil
+}
+
+func tokenize(query string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == c {
+					if j+1 < len(query) && query[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(query) {
+				return nil, fmt.Errorf("unterminated quote in %s", query)
+			}
+			tokens = append(tokens, query[i:j+1])
+			i = j + 1
+		case strings.ContainsRune("(),*?.", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '$':
+			j := i + 1
+			for j < len(query) && unicode.IsDigit(rune(query[j])) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		case c == '<' || c == '>' || c == '=' || c == '!':
+			if i+1 < len(query) && query[i+1] == '=' {
+				tokens = append(tokens, query[i:i+2])
+				i += 2
+			} else {
+				tokens = append(tokens, string(c))
+				i++
+			}
+		case c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i
+			for j < len(query) && (query[j] == '_' || unicode.IsLetter(rune(query[j])) || unicode.IsDigit(rune(query[j]))) {
+				j++
+			}
+			word := query[i:j]
+			if _, err := strconv.Atoi(word); err != nil {
+				word = strings.ToUpper(word)
+			}
+			tokens = append(tokens, word)
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *parser) accept(tokens ...string) bool {
+	for i, token := range tokens {
+		if p.pos+i >= len(p.tokens) || p.tokens[p.pos+i] != token {
+			return false
+		}
+	}
+	p.pos += len(tokens)
+	return true
+}
+
+func (p *parser) expect(tokens ...string) error {
+	if !p.accept(tokens...) {
+		return fmt.Errorf("expected %s near %q", strings.Join(tokens, " "), p.peek())
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	token := p.next()
+	if strings.HasPrefix(token, `"`) {
+		return strings.ReplaceAll(token[1:len(token)-1], `""`, `"`), nil
+	}
+	if strings.HasPrefix(token, "`") {
+		return strings.ReplaceAll(token[1:len(token)-1], "``", "`"), nil
+	}
+	if token == "" || !(token[0] == '_' || unicode.IsLetter(rune(token[0]))) {
+		return "", fmt.Errorf("expected a name near %q", token)
+	}
+	return strings.ToLower(token), nil
+}
+
+func (p *parser) names() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.accept(",") {
+			break
+		}
+	}
+	return names, p.expect(")")
+}
+
+func (p *parser) createTable() (any, error) {
+	if err := p.expect("CREATE", "TABLE"); err != nil {
+		return nil, err
+	}
+	p.accept("IF", "NOT", "EXISTS")
+	var stmt createTable
+	var err error
+	if stmt.table, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		if p.accept("PRIMARY", "KEY") {
+			if stmt.key, err = p.names(); err != nil {
+				return nil, err
+			}
+		} else {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			stmt.columns = append(stmt.columns, name)
+			for depth := 0; p.peek() != "" && (depth > 0 || p.peek() != "," && p.peek() != ")"); { // type and constraints are ignored.
+				switch p.next() {
+				case "(":
+					depth++
+				case ")":
+					depth--
+				}
+			}
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	return stmt, p.expect(")")
+}
+
+func (p *parser) insertInto() (any, error) {
+	if err := p.expect("INSERT"); err != nil {
+		return nil, err
+	}
+	var stmt insertInto
+	stmt.nothing = p.accept("IGNORE")
+	if err := p.expect("INTO"); err != nil {
+		return nil, err
+	}
+	var err error
+	if stmt.table, err = p.name(); err != nil {
+		return nil, err
+	}
+	if stmt.columns, err = p.names(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("VALUES", "("); err != nil {
+		return nil, err
+	}
+	for {
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		stmt.values = append(stmt.values, value)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if p.accept("ON", "CONFLICT") {
+		if stmt.conflict, err = p.names(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("DO"); err != nil {
+			return nil, err
+		}
+		if p.accept("NOTHING") {
+			stmt.nothing = true
+			return stmt, nil
+		}
+		if err := p.expect("UPDATE", "SET"); err != nil {
+			return nil, err
+		}
+		if stmt.update, err = p.assignments(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("ON", "DUPLICATE", "KEY", "UPDATE") {
+		if stmt.update, err = p.assignments(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) assignments() ([]assignment, error) {
+	var set []assignment
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, assignment{column: name, value: value})
+		if !p.accept(",") {
+			return set, nil
+		}
+	}
+}
+
+func (p *parser) selectFrom() (*selectFrom, error) {
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	var stmt = selectFrom{limit: -1}
+	if !p.accept("*") {
+		for {
+			item, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			stmt.items = append(stmt.items, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	if p.accept("(") {
+		sub, err := p.selectFrom()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")", "AS"); err != nil {
+			return nil, err
+		}
+		if _, err := p.name(); err != nil {
+			return nil, err
+		}
+		stmt.from = sub
+	} else {
+		var err error
+		if stmt.table, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if p.accept("WHERE") {
+		if stmt.where, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("ORDER", "BY") {
+		for {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			order := ordering{column: name}
+			if p.accept("DESC") {
+				order.desc = true
+			} else {
+				p.accept("ASC")
+			}
+			stmt.order = append(stmt.order, order)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if p.accept("LIMIT") {
+		if stmt.limit, err = strconv.Atoi(p.next()); err != nil {
+			return nil, err
+		}
+		if p.accept("OFFSET") {
+			if stmt.offset, err = strconv.Atoi(p.next()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &stmt, nil
+}
+
+func (p *parser) deleteFrom() (any, error) {
+	if err := p.expect("DELETE", "FROM"); err != nil {
+		return nil, err
+	}
+	var stmt deleteFrom
+	var err error
+	if stmt.table, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.accept("WHERE") {
+		if stmt.where, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) updateSet() (any, error) {
+	if err := p.expect("UPDATE"); err != nil {
+		return nil, err
+	}
+	var stmt updateSet
+	var err error
+	if stmt.table, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("SET"); err != nil {
+		return nil, err
+	}
+	if stmt.set, err = p.assignments(); err != nil {
+		return nil, err
+	}
+	if p.accept("WHERE") {
+		if stmt.where, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) expression() (node, error) {
+	left, err := p.conjunction()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.conjunction()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: "OR", l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *parser) conjunction() (node, error) {
+	left, err := p.negation()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.negation()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: "AND", l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *parser) negation() (node, error) {
+	if p.accept("NOT") {
+		x, err := p.negation()
+		return not{x}, err
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.primary()
+		return binary{op: op, l: left, r: right}, err
+	case "LIKE":
+		p.next()
+		pattern, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		var escape rune
+		if p.accept("ESCAPE") {
+			token := p.next()
+			if len(token) != 3 || token[0] != '\'' {
+				return nil, fmt.Errorf("invalid escape %s", token)
+			}
+			escape = rune(token[1])
+		}
+		return likeMatch{x: left, pattern: pattern, escape: escape}, nil
+	case "IS":
+		p.next()
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return isNull{left}, nil
+	case "IN":
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		sub, err := p.selectFrom()
+		if err != nil {
+			return nil, err
+		}
+		return inSelect{x: left, sub: sub}, p.expect(")")
+	}
+	return left, nil
+}
+
+func (p *parser) primary() (node, error) {
+	token := p.peek()
+	switch {
+	case token == "(":
+		p.next()
+		x, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.accept(",") {
+			var items = tuple{x}
+			for {
+				item, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if !p.accept(",") {
+					break
+				}
+			}
+			x = items
+		}
+		return x, p.expect(")")
+	case token == "?":
+		p.next()
+		p.params++
+		return param(p.params - 1), nil
+	case strings.HasPrefix(token, "$"):
+		p.next()
+		n, err := strconv.Atoi(token[1:])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid parameter %s", token)
+		}
+		return param(n - 1), nil
+	case strings.HasPrefix(token, "'"):
+		p.next()
+		return literal{strings.ReplaceAll(token[1:len(token)-1], "''", "'")}, nil
+	case token != "" && unicode.IsDigit(rune(token[0])):
+		p.next()
+		n, err := strconv.ParseInt(token, 10, 64)
+		return literal{n}, err
+	case token == "EXCLUDED":
+		p.next()
+		if err := p.expect("."); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return excluded(name), err
+	case token == "VALUES": // the value that conflicted with an insert, as in MySQL.
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return excluded(name), p.expect(")")
+	case token == "COUNT" || token == "SUM" || token == "AVG" || token == "MIN" || token == "MAX" || token == "COALESCE":
+		p.next()
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		fn := call{fn: token}
+		if p.accept("*") {
+			return fn, p.expect(")")
+		}
+		for {
+			arg, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			fn.args = append(fn.args, arg)
+			if !p.accept(",") {
+				break
+			}
+		}
+		return fn, p.expect(")")
+	}
+	name, err := p.name()
+	return column(name), err
+}
@@ -0,0 +1,258 @@
+// Package sqlmem provides an in-memory [database/sql] driver named "sqlmem", that
+// understands the subset of SQL generated by the dialects of [runtime.link/sql]
+// (the placeholders, quoting and conflict clauses of SQLite, PostgreSQL and MySQL),
+// so that the SQL translation can be tested without a real database engine.
+// Connections that are opened with the same data source name share the same database.
+package sqlmem
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	sql.Register("sqlmem", drv{})
+}
+
+var (
+	mutex     sync.Mutex
+	databases = make(map[string]*database)
+)
+
+type drv struct{}
+
+func (drv) Open(name string) (driver.Conn, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	db, ok := databases[name]
+	if !ok {
+		db = &database{tables: make(map[string]*table)}
+		databases[name] = db
+	}
+	return &conn{db: db}, nil
+}
+
+type database struct {
+	sync.Mutex
+	tables map[string]*table
+}
+
+type table struct {
+	columns []string
+	key     []int
+	rows    [][]driver.Value
+}
+
+func (t *table) clone() *table {
+	var copied = *t
+	copied.rows = make([][]driver.Value, len(t.rows))
+	for i, row := range t.rows {
+		copied.rows[i] = slices.Clone(row)
+	}
+	return &copied
+}
+
+func (t *table) column(name string) (int, error) {
+	if i := slices.Index(t.columns, name); i >= 0 {
+		return i, nil
+	}
+	return 0, fmt.Errorf("no such column: %s", name)
+}
+
+type conn struct {
+	db *database
+	tx map[string]*table // snapshot of the tables, while in a transaction.
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	parsed, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{conn: c, node: parsed}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("transaction already in progress")
+	}
+	c.db.Lock()
+	c.tx = make(map[string]*table, len(c.db.tables))
+	for name, t := range c.db.tables {
+		c.tx[name] = t.clone()
+	}
+	return c, nil
+}
+
+func (c *conn) Commit() error {
+	if c.tx == nil {
+		return errors.New("no transaction in progress")
+	}
+	c.db.tables = c.tx
+	c.tx = nil
+	c.db.Unlock()
+	return nil
+}
+
+func (c *conn) Rollback() error {
+	if c.tx == nil {
+		return errors.New("no transaction in progress")
+	}
+	c.tx = nil
+	c.db.Unlock()
+	return nil
+}
+
+// run the statement, within the current transaction (or else, within
+// its own transaction).
+func (c *conn) run(node any, args []driver.Value) (result, error) {
+	if c.tx == nil {
+		c.BeginTx(context.Background(), driver.TxOptions{})
+		res, err := c.run(node, args)
+		if err != nil {
+			c.Rollback()
+			return res, err
+		}
+		return res, c.Commit()
+	}
+	return execute(c.tx, node, args)
+}
+
+type stmt struct {
+	conn *conn
+	node any
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	res, err := s.conn.run(s.node, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	res, err := s.conn.run(s.node, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: res.columns, values: res.rows}, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// compare two values, returning false if they are not comparable.
+func compare(a, b driver.Value) (int, bool) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, y), true
+		case float64:
+			return cmpOrdered(float64(x), y), true
+		case bool:
+			return cmpOrdered(x, boolInt(y)), true
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmpOrdered(x, float64(y)), true
+		case float64:
+			return cmpOrdered(x, y), true
+		}
+	case bool:
+		switch y := b.(type) {
+		case bool:
+			return cmpOrdered(boolInt(x), boolInt(y)), true
+		case int64:
+			return cmpOrdered(boolInt(x), y), true
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case []byte:
+		if y, ok := b.([]byte); ok {
+			return bytes.Compare(x, y), true
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// like reports whether the text matches the LIKE pattern.
+func like(text, pattern string, escape rune) bool {
+	if pattern == "" {
+		return text == ""
+	}
+	p, size := rune(pattern[0]), 1
+	if escape != 0 && p == escape && len(pattern) > 1 {
+		return text != "" && text[0] == pattern[1] && like(text[1:], pattern[2:], escape)
+	}
+	switch p {
+	case '%':
+		for i := 0; i <= len(text); i++ {
+			if like(text[i:], pattern[size:], escape) {
+				return true
+			}
+		}
+		return false
+	case '_':
+		return text != "" && like(text[1:], pattern[size:], escape)
+	default:
+		return text != "" && strings.EqualFold(text[:1], pattern[:1]) && like(text[1:], pattern[1:], escape)
+	}
+}
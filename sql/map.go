@@ -264,6 +264,8 @@ func (m *Map[K, V]) Search(ctx context.Context, query QueryFunc[K, V], issue *er
 		sql = query(key, val)
 	}
 	return func(yield func(K, V) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel() // stops the search, if the caller stops early.
 		ch := make(chan []sodium.Value, 64)
 		do := m.db.Search(m.to, sodium.Query(sql), ch)
 		tx, err := m.manage(ctx)